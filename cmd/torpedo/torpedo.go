@@ -8,13 +8,20 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/portworx/torpedo/drivers/node"
 	"github.com/portworx/torpedo/drivers/scheduler"
-	_ "github.com/portworx/torpedo/drivers/scheduler/k8s"
+	"github.com/portworx/torpedo/drivers/scheduler/k8s"
 	"github.com/portworx/torpedo/drivers/volume"
 	_ "github.com/portworx/torpedo/drivers/volume/portworx"
 	_ "github.com/portworx/torpedo/drivers/node/ssh"
 	"github.com/portworx/torpedo/pkg/errors"
+	"github.com/portworx/torpedo/pkg/k8sutils"
 )
 
+// installValidationTimeout is how long to wait for the volume driver installation to be healthy
+const installValidationTimeout = 5 * time.Minute
+
+// nodesReadyTimeout is how long to wait for all the cluster nodes to be ready
+const nodesReadyTimeout = 5 * time.Minute
+
 type torpedo struct {
 	instanceID string
 	s          scheduler.Driver
@@ -516,6 +523,40 @@ func (t *torpedo) testRemoteForceMount() error {
 }*/
 
 
+// inventoryAllowlist are the objects that tests are expected to leave behind
+var inventoryAllowlist = []string{
+	"pods/kube-system/*",
+	"secrets/*/default-token-*",
+}
+
+// runTest runs the given test. Under k8s, the objects in the cluster are compared before and after the
+// test and the objects that the test did not clean up are logged.
+func (t *torpedo) runTest(name string, f testDriverFunc) error {
+	if t.s.String() != k8s.SchedName {
+		return f()
+	}
+
+	before, err := k8sutils.SnapshotClusterInventory(nil)
+	if err != nil {
+		logrus.Warnf("Failed to snapshot cluster inventory before test %v. Err: %v", name, err)
+		return f()
+	}
+
+	testErr := f()
+
+	after, err := k8sutils.SnapshotClusterInventory(nil)
+	if err != nil {
+		logrus.Warnf("Failed to snapshot cluster inventory after test %v. Err: %v", name, err)
+		return testErr
+	}
+
+	for kind, objects := range k8sutils.DiffInventories(before, after, inventoryAllowlist) {
+		logrus.Warnf("Test %v leaked %d %v: %v", name, len(objects), kind, objects)
+	}
+
+	return testErr
+}
+
 func (t *torpedo) run(testName string) error {
 	logrus.Printf("Running torpedo test: %v", t.instanceID)
 
@@ -524,16 +565,53 @@ func (t *torpedo) run(testName string) error {
 		return err
 	}
 
-	if err := t.v.Init(t.s.String()); err != nil {
+	if err := t.v.Init(t.s.String(), t.n.String()); err != nil {
 		logrus.Fatalf("Error initializing volume driver. Err: %v", err)
 		return err
 	}
 
+	if d, ok := t.v.(volume.Destroyer); ok {
+		defer func() {
+			if err := d.Destroy(); err != nil {
+				logrus.Warnf("Error cleaning up volume driver. Err: %v", err)
+			}
+		}()
+	}
+
 	if err := t.n.Init(t.s.String()); err != nil {
 		logrus.Fatalf("Error initializing node driver. Err: %v", err)
 		return err
 	}
 
+	if v, ok := t.v.(volume.InstallationValidator); ok {
+		if err := v.ValidateInstallation(installValidationTimeout); err != nil {
+			logrus.Fatalf("Error validating volume driver installation. Err: %v", err)
+			return err
+		}
+	}
+
+	if t.s.String() == k8s.SchedName {
+		k8sutils.SetInstanceID(t.instanceID)
+
+		report, err := k8sutils.ValidateClusterNodesReady(nodesReadyTimeout)
+		if err != nil {
+			logrus.Fatalf("Error validating cluster nodes. Err: %v", err)
+			return err
+		}
+		logrus.Infof("Cluster has %v masters and %v workers ready. Worker checks passed: %v",
+			report.Masters, report.Workers, report.WorkerChecks)
+
+		if err := k8sutils.ValidateControlPlaneHealthy(); err != nil {
+			logrus.Fatalf("Error validating cluster control plane. Err: %v", err)
+			return err
+		}
+
+		if err := k8sutils.ValidateSingleDefaultStorageClass(); err != nil {
+			logrus.Fatalf("Error validating default storage class. Err: %v", err)
+			return err
+		}
+	}
+
 	// Add new test functions here.
 	testFuncs := map[string]testDriverFunc{
 		"testSetupTearDown": func () error { return t.testSetupTearDown() },
@@ -554,7 +632,7 @@ func (t *torpedo) run(testName string) error {
 			}
 		}
 
-		if err := f(); err != nil {
+		if err := t.runTest(testName, f); err != nil {
 			logrus.Infof("Test %v Failed with Error: %v", testName, err)
 			return err
 		}
@@ -564,7 +642,7 @@ func (t *torpedo) run(testName string) error {
 
 	for n, f := range testFuncs {
 		logrus.Infof("Executing test %v", n)
-		if err := f(); err != nil {
+		if err := t.runTest(n, f); err != nil {
 			logrus.Infof("Test %v Failed with Error: %v", n, err)
 		} else {
 			logrus.Infof("Test %v Passed", n)
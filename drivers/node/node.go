@@ -41,6 +41,12 @@ type TestConectionOpts struct {
 	TimeBeforeRetry time.Duration
 }
 
+// SystemctlOpts provide additional options for systemctl operation
+type SystemctlOpts struct {
+	// Action is the systemctl action to perform on the service (e.g start, stop, is-active)
+	Action string
+}
+
 var (
 	nodeDrivers = make(map[string]Driver)
 )
@@ -61,6 +67,13 @@ type Driver interface {
 
 	// TestConnection tests connection to given node. returns nil if driver can connect to given node
 	TestConnection(node Node, options TestConectionOpts) error
+
+	// Systemctl performs the given systemctl action for the service on the given node. returns nil if
+	// the systemctl command succeeds
+	Systemctl(node Node, service string, options SystemctlOpts) error
+
+	// RunCommand runs the given command on the given node and returns its output
+	RunCommand(node Node, command string, timeout time.Duration) (string, error)
 }
 
 // Register registers the given node driver
@@ -113,3 +126,15 @@ func (d *notSupportedDriver) TestConnection(node Node, options TestConectionOpts
 		Operation: "TestConnection()",
 	}
 }
+
+func (d *notSupportedDriver) Systemctl(node Node, service string, options SystemctlOpts) error {
+	return &errors.ErrNotSupported{
+		Operation: "Systemctl()",
+	}
+}
+
+func (d *notSupportedDriver) RunCommand(node Node, command string, timeout time.Duration) (string, error) {
+	return "", &errors.ErrNotSupported{
+		Operation: "RunCommand()",
+	}
+}
@@ -44,3 +44,13 @@ type ErrFailedToRunCommand struct {
 func (e *ErrFailedToRunCommand) Error() string {
 	return fmt.Sprintf("Failed to run command on: %v. Cause: %v", e.Addr, e.Cause)
 }
+
+// ErrFailedToRunSystemctl error type when failing to run systemctl on a node
+type ErrFailedToRunSystemctl struct {
+	Node  node.Node
+	Cause string
+}
+
+func (e *ErrFailedToRunSystemctl) Error() string {
+	return fmt.Sprintf("Failed to run systemctl on: %v. Cause: %v", e.Node.Name, e.Cause)
+}
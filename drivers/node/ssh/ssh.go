@@ -146,10 +146,66 @@ func (s *ssh) ShutdownNode(n node.Node, options node.ShutdownNodeOpts) error {
 	return nil
 }
 
+func (s *ssh) Systemctl(n node.Node, service string, options node.SystemctlOpts) error {
+	addr, err := s.getAddrToConnect(n)
+	if err != nil {
+		return &ErrFailedToRunSystemctl{
+			Node:  n,
+			Cause: fmt.Sprintf("failed to get node address due to: %v", err),
+		}
+	}
+
+	systemctlCmd := fmt.Sprintf("sudo systemctl %v %v", options.Action, service)
+	if err := s.doCmd(addr, systemctlCmd, false); err != nil {
+		return &ErrFailedToRunSystemctl{
+			Node:  n,
+			Cause: err.Error(),
+		}
+	}
+
+	return nil
+}
+
+func (s *ssh) RunCommand(n node.Node, command string, timeout time.Duration) (string, error) {
+	addr, err := s.getAddrToConnect(n)
+	if err != nil {
+		return "", &ErrFailedToRunCommand{
+			Addr:  n.Name,
+			Cause: fmt.Sprintf("failed to get node address due to: %v", err),
+		}
+	}
+
+	type cmdResult struct {
+		output string
+		err    error
+	}
+
+	done := make(chan cmdResult, 1)
+	go func() {
+		output, err := s.runCmd(addr, command, false)
+		done <- cmdResult{output: output, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.output, result.err
+	case <-time.After(timeout):
+		return "", &ErrFailedToRunCommand{
+			Addr:  addr,
+			Cause: fmt.Sprintf("command: %v timed out after: %v", command, timeout),
+		}
+	}
+}
+
 func (s *ssh) doCmd(addr string, cmd string, ignoreErr bool) error {
+	_, err := s.runCmd(addr, cmd, ignoreErr)
+	return err
+}
+
+func (s *ssh) runCmd(addr string, cmd string, ignoreErr bool) (string, error) {
 	connection, err := ssh_pkg.Dial("tcp", fmt.Sprintf("%v:%d", addr, DefaultSSHPort), s.sshConfig)
 	if err != nil {
-		return &ErrFailedToRunCommand{
+		return "", &ErrFailedToRunCommand{
 			Addr:  addr,
 			Cause: fmt.Sprintf("failed to dial: %v", err),
 		}
@@ -157,7 +213,7 @@ func (s *ssh) doCmd(addr string, cmd string, ignoreErr bool) error {
 
 	session, err := connection.NewSession()
 	if err != nil {
-		return &ErrFailedToRunCommand{
+		return "", &ErrFailedToRunCommand{
 			Addr:  addr,
 			Cause: fmt.Sprintf("failed to create session: %s", err),
 		}
@@ -172,7 +228,7 @@ func (s *ssh) doCmd(addr string, cmd string, ignoreErr bool) error {
 	}
 
 	if err := session.RequestPty("xterm", 80, 40, modes); err != nil {
-		return &ErrFailedToRunCommand{
+		return "", &ErrFailedToRunCommand{
 			Addr:  addr,
 			Cause: fmt.Sprintf("request for pseudo terminal failed: %s", err),
 		}
@@ -180,13 +236,13 @@ func (s *ssh) doCmd(addr string, cmd string, ignoreErr bool) error {
 
 	stdout, err := session.StdoutPipe()
 	if err != nil {
-		return &ErrFailedToRunCommand{
+		return "", &ErrFailedToRunCommand{
 			Addr:  addr,
 			Cause: fmt.Sprintf("Unable to setup stdout for session: %v", err),
 		}
 	}
 
-	chOut := make(chan string)
+	chOut := make(chan string, 1)
 	go func() {
 		var bufout bytes.Buffer
 		io.Copy(&bufout, stdout)
@@ -195,13 +251,13 @@ func (s *ssh) doCmd(addr string, cmd string, ignoreErr bool) error {
 
 	stderr, err := session.StderrPipe()
 	if err != nil {
-		return &ErrFailedToRunCommand{
+		return "", &ErrFailedToRunCommand{
 			Addr:  addr,
 			Cause: fmt.Sprintf("Unable to setup stderr for session: %v", err),
 		}
 	}
 
-	chErr := make(chan string)
+	chErr := make(chan string, 1)
 	go func() {
 		var buferr bytes.Buffer
 		io.Copy(&buferr, stderr)
@@ -209,13 +265,13 @@ func (s *ssh) doCmd(addr string, cmd string, ignoreErr bool) error {
 	}()
 
 	if err = session.Run(cmd); !ignoreErr && err != nil {
-		return &ErrFailedToRunCommand{
+		return "", &ErrFailedToRunCommand{
 			Addr:  addr,
-			Cause: fmt.Sprintf("failed to run command due to: %v", err),
+			Cause: fmt.Sprintf("failed to run command due to: %v. Stderr: %v", err, <-chErr),
 		}
 	}
 
-	return nil
+	return <-chOut, nil
 }
 
 func (s *ssh) getAddrToConnect(n node.Node) (string, error) {
@@ -23,6 +23,9 @@ import (
 // SchedName is the name of the kubernetes scheduler driver implementation
 const SchedName = "k8s"
 
+// k8sPVCBindTimeout is how long to wait for a PVC to be bound when looking up its volume
+const k8sPVCBindTimeout = 5 * time.Minute
+
 type k8s struct {
 	nodes map[string]node.Node
 }
@@ -261,7 +264,7 @@ func (k *k8s) GetVolumes(ctx *scheduler.Context) ([]string, error) {
 	var volumes []string
 	for _, storage := range ctx.App.Storage(ctx.UID) {
 		if obj, ok := storage.(*v1.PersistentVolumeClaim); ok {
-			vol, err := k8sutils.GetVolumeForPersistentVolumeClaim(obj)
+			vol, err := k8sutils.GetVolumeForPVCWithTimeout(obj, k8sPVCBindTimeout)
 			if err != nil {
 				return nil, &ErrFailedToGetVolumesForApp{
 					App:   ctx.App,
@@ -281,7 +284,7 @@ func (k *k8s) GetVolumeParameters(ctx *scheduler.Context) (map[string]map[string
 
 	for _, storage := range ctx.App.Storage(ctx.UID) {
 		if obj, ok := storage.(*v1.PersistentVolumeClaim); ok {
-			vol, err := k8sutils.GetVolumeForPersistentVolumeClaim(obj)
+			vol, err := k8sutils.GetVolumeForPVCWithTimeout(obj, k8sPVCBindTimeout)
 			if err != nil {
 				return nil, &ErrFailedToGetVolumesParameters{
 					App:   ctx.App,
@@ -17,12 +17,17 @@ import (
 	"github.com/portworx/torpedo/drivers/scheduler"
 	torpedovolume "github.com/portworx/torpedo/drivers/volume"
 	"github.com/portworx/torpedo/drivers/volume/portworx/schedops"
+	"github.com/portworx/torpedo/pkg/k8sutils"
 	"github.com/portworx/torpedo/pkg/task"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
 )
 
 // DriverName is the name of the portworx driver implementation
 const DriverName = "pxd"
 
+// pxNamespace is the namespace of the portworx scheduler objects
+const pxNamespace = "kube-system"
+
 type portworx struct {
 	hostConfig     *dockerclient.HostConfig
 	clusterManager cluster.Cluster
@@ -35,7 +40,7 @@ func (d *portworx) String() string {
 	return DriverName
 }
 
-func (d *portworx) Init(sched string) error {
+func (d *portworx) Init(sched string, nodeDriver string) error {
 	logrus.Printf("Using the Portworx volume driver under scheduler: %v\n", sched)
 	var err error
 	d.schedDriver, err = scheduler.Get(sched)
@@ -77,7 +82,30 @@ func (d *portworx) Init(sched string) error {
 
 	d.schedOps, err = schedops.Get(sched)
 	if err != nil {
-		return fmt.Errorf("Failed to get scheduler operator for portworx. Err: %v", err)
+		logrus.Infof("No portworx scheduler operator for: %v. Using: %v", sched, schedops.DefaultDriverName)
+		if d.schedOps, err = schedops.Get(schedops.DefaultDriverName); err != nil {
+			return fmt.Errorf("Failed to get scheduler operator for portworx. Err: %v", err)
+		}
+	}
+
+	var inspector schedops.NodeInspector
+	if n, err := node.Get(nodeDriver); err == nil {
+		inspector = n
+	} else {
+		logrus.Warnf("Node driver: %v is not available for node checks. Err: %v", nodeDriver, err)
+	}
+
+	if err = d.schedOps.Init(schedops.InitOptions{
+		Inspector:      inspector,
+		PxNamespace:    pxNamespace,
+		NodeDriverName: nodeDriver,
+	}); err != nil {
+		return fmt.Errorf("Failed to initialize scheduler operator for portworx. Err: %v", err)
+	}
+
+	// The k8s validations of PVCs check that their portworx volumes honor their parameters
+	if _, ok := d.schedOps.(schedops.VolumeIDResolver); ok {
+		k8sutils.RegisterVolumeParamsVerifier(d.VerifyVolumeParams)
 	}
 
 	logrus.Printf("The following Portworx nodes are in the cluster:")
@@ -93,6 +121,88 @@ func (d *portworx) Init(sched string) error {
 	return err
 }
 
+// Destroy cleans up the resources created by the scheduler operator
+func (d *portworx) Destroy() error {
+	if d.schedOps == nil {
+		return nil
+	}
+
+	if _, ok := d.schedOps.(schedops.VolumeIDResolver); ok {
+		k8sutils.RegisterVolumeParamsVerifier(nil)
+	}
+
+	return d.schedOps.Destroy()
+}
+
+func (d *portworx) ValidateInstallation(timeout time.Duration) error {
+	v, ok := d.schedOps.(schedops.InstallationValidator)
+	if !ok {
+		logrus.Infof("Portworx scheduler operator does not support validating the installation")
+		return nil
+	}
+
+	report, err := v.ValidatePortworxInstallation(pxNamespace, timeout)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Portworx installation is healthy. %v of %v portworx pods are ready",
+		report.ReadyPods, report.DesiredPods)
+	return nil
+}
+
+// ValidateHyperconvergence checks, through the scheduler operator, that the pods of the given app run on
+// nodes holding a replica of their portworx volumes
+func (d *portworx) ValidateHyperconvergence(app *v1beta1.Deployment) (*schedops.HyperconvergenceReport, error) {
+	v, ok := d.schedOps.(schedops.HyperconvergenceValidator)
+	if !ok {
+		return nil, fmt.Errorf("portworx scheduler operator does not support validating hyperconvergence")
+	}
+
+	return v.ValidateHyperconvergence(app, d.getReplicaNodes)
+}
+
+// getReplicaNodes returns the hostnames of the portworx nodes holding a replica of the volume of the
+// given PV
+func (d *portworx) getReplicaNodes(pvName string) ([]string, error) {
+	volumeID, err := d.resolveVolumeID(pvName)
+	if err != nil {
+		return nil, err
+	}
+
+	vols, err := d.volDriver.Inspect([]string{volumeID})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vols) != 1 {
+		return nil, fmt.Errorf("expected 1 volume with ID: %v of PV: %v. Found: %d", volumeID, pvName, len(vols))
+	}
+
+	cluster, err := d.clusterManager.Enumerate()
+	if err != nil {
+		return nil, err
+	}
+
+	hostnames := make(map[string]string)
+	for _, n := range cluster.Nodes {
+		hostnames[n.Id] = n.Hostname
+	}
+
+	var nodes []string
+	for _, rs := range vols[0].ReplicaSets {
+		for _, id := range rs.Nodes {
+			if hostname, ok := hostnames[id]; ok {
+				nodes = append(nodes, hostname)
+			} else {
+				nodes = append(nodes, id)
+			}
+		}
+	}
+
+	return nodes, nil
+}
+
 func (d *portworx) CleanupVolume(name string) error {
 	locator := &api.VolumeLocator{}
 
@@ -280,6 +390,25 @@ func (d *portworx) InspectVolume(name string, params map[string]string) error {
 	return nil
 }
 
+// VerifyVolumeParams inspects the portworx volume of the given PV against the given params
+func (d *portworx) VerifyVolumeParams(params map[string]string, pvName string) error {
+	volumeID, err := d.resolveVolumeID(pvName)
+	if err != nil {
+		return err
+	}
+
+	return d.InspectVolume(volumeID, params)
+}
+
+// resolveVolumeID returns the ID of the portworx volume of the given PV. It is resolved by the scheduler
+// operator if it can, and is the name of the PV otherwise.
+func (d *portworx) resolveVolumeID(pvName string) (string, error) {
+	if r, ok := d.schedOps.(schedops.VolumeIDResolver); ok {
+		return r.ResolvePortworxVolumeIDForPV(pvName)
+	}
+	return pvName, nil
+}
+
 func (d *portworx) StopDriver(n node.Node) error {
 	return d.schedOps.DisableOnNode(n)
 }
@@ -0,0 +1,62 @@
+package schedops
+
+import (
+	"fmt"
+
+	"github.com/portworx/torpedo/drivers/node"
+)
+
+const (
+	// DefaultDriverName is the name of the scheduler operator used for schedulers without one
+	DefaultDriverName = "default"
+	// pxServiceName is the name of the portworx systemd service
+	pxServiceName = "portworx"
+)
+
+// defaultSchedOps manages portworx through its systemd service using the node driver
+type defaultSchedOps struct {
+	DefaultDriver
+	nodeDriverName string
+}
+
+// Init checks that the node driver torpedo was configured with, which manages the portworx service, exists
+func (d *defaultSchedOps) Init(opts InitOptions) error {
+	if _, err := node.Get(opts.NodeDriverName); err != nil {
+		return fmt.Errorf("failed to get node driver: %v. Err: %v", opts.NodeDriverName, err)
+	}
+
+	d.nodeDriverName = opts.NodeDriverName
+	return nil
+}
+
+func (d *defaultSchedOps) String() string {
+	return DefaultDriverName
+}
+
+func (d *defaultSchedOps) DisableOnNode(n node.Node) error {
+	return d.systemctl(n, "stop")
+}
+
+func (d *defaultSchedOps) ValidateOnNode(n node.Node) error {
+	return d.systemctl(n, "is-active")
+}
+
+func (d *defaultSchedOps) EnableOnNode(n node.Node, opts ...EnableOption) error {
+	return d.systemctl(n, "start")
+}
+
+func (d *defaultSchedOps) systemctl(n node.Node, action string) error {
+	nodeDriver, err := node.Get(d.nodeDriverName)
+	if err != nil {
+		return err
+	}
+
+	return nodeDriver.Systemctl(n, pxServiceName, node.SystemctlOpts{
+		Action: action,
+	})
+}
+
+func init() {
+	d := &defaultSchedOps{}
+	Register(DefaultDriverName, d)
+}
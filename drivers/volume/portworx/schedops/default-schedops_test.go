@@ -0,0 +1,48 @@
+package schedops
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/portworx/torpedo/drivers/node"
+)
+
+// testNodeDriver records the systemctl actions and supports no other operation
+type testNodeDriver struct {
+	node.Driver
+	actions []string
+}
+
+func (d *testNodeDriver) Systemctl(n node.Node, service string, options node.SystemctlOpts) error {
+	d.actions = append(d.actions, service+" "+options.Action+" on "+n.Name)
+	return nil
+}
+
+func TestDefaultSchedOpsUsesConfiguredNodeDriver(t *testing.T) {
+	nodeDriver := &testNodeDriver{Driver: node.NotSupportedDriver}
+	node.Register("test-node-driver", nodeDriver)
+
+	d := &defaultSchedOps{}
+	if err := d.Init(InitOptions{NodeDriverName: "missing"}); err == nil {
+		t.Errorf("expected an error for a node driver that isn't registered")
+	}
+	if err := d.Init(InitOptions{NodeDriverName: "test-node-driver"}); err != nil {
+		t.Fatalf("failed to init: %v", err)
+	}
+
+	n := node.Node{Name: "node1"}
+	if err := d.DisableOnNode(n); err != nil {
+		t.Errorf("failed to disable portworx: %v", err)
+	}
+	if err := d.ValidateOnNode(n); err != nil {
+		t.Errorf("failed to validate portworx: %v", err)
+	}
+	if err := d.EnableOnNode(n); err != nil {
+		t.Errorf("failed to enable portworx: %v", err)
+	}
+
+	expected := []string{"portworx stop on node1", "portworx is-active on node1", "portworx start on node1"}
+	if !reflect.DeepEqual(nodeDriver.actions, expected) {
+		t.Errorf("expected the actions: %v, got: %v", expected, nodeDriver.actions)
+	}
+}
@@ -0,0 +1,107 @@
+package schedops
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/portworx/torpedo/drivers/node"
+)
+
+// ErrFailedToValidateOnNode error type when portworx fails validation on a node
+type ErrFailedToValidateOnNode struct {
+	Node  node.Node
+	Cause string
+}
+
+func (e *ErrFailedToValidateOnNode) Error() string {
+	return fmt.Sprintf("Failed to validate portworx on node: %v. Cause: %v", e.Node.Name, e.Cause)
+}
+
+// ErrPortworxInstallation error type when components of a portworx installation are missing or unhealthy
+type ErrPortworxInstallation struct {
+	Report *InstallationReport
+}
+
+func (e *ErrPortworxInstallation) Error() string {
+	var unhealthy []string
+	for _, c := range e.Report.Components {
+		if !c.Healthy {
+			unhealthy = append(unhealthy, fmt.Sprintf("%v/%v: %v", c.Kind, c.Name, c.Cause))
+		}
+	}
+	return fmt.Sprintf("Portworx installation is not healthy. %v of %v portworx pods are ready. Unhealthy components: %v",
+		e.Report.ReadyPods, e.Report.DesiredPods, unhealthy)
+}
+
+// ErrQuorumRisk error type when deleting portworx pods could make the portworx cluster lose quorum
+type ErrQuorumRisk struct {
+	// ClusterSize is the number of portworx pods that should be running
+	ClusterSize int
+	// Ready is the number of ready portworx pods
+	Ready int
+	// Quorum is the number of ready portworx pods needed for quorum
+	Quorum int
+	// Requested is the number of pods that would be deleted at once
+	Requested int
+}
+
+func (e *ErrQuorumRisk) Error() string {
+	return fmt.Sprintf("Deleting %v portworx pods would leave %v of %v ready pods which is below quorum: %v",
+		e.Requested, e.Ready-e.Requested, e.ClusterSize, e.Quorum)
+}
+
+// ErrNotPortworxVolume error type when the PV of a PVC is not a portworx volume
+type ErrNotPortworxVolume struct {
+	PVC string
+	PV  string
+}
+
+func (e *ErrNotPortworxVolume) Error() string {
+	if len(e.PVC) == 0 {
+		return fmt.Sprintf("PV: %v is not a portworx volume", e.PV)
+	}
+	return fmt.Sprintf("PV: %v of PVC: %v is not a portworx volume", e.PV, e.PVC)
+}
+
+// ErrFailedRollingNodeDisable error type when an app doesn't survive portworx being disabled on a node
+type ErrFailedRollingNodeDisable struct {
+	// Node is the node of the iteration that failed
+	Node node.Node
+	// Stage is the stage of the iteration that failed
+	Stage string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrFailedRollingNodeDisable) Error() string {
+	return fmt.Sprintf("Rolling node disable failed on node: %v at stage: %v. Cause: %v", e.Node.Name, e.Stage, e.Cause)
+}
+
+// ErrNotHyperconverged error type when app pods run on nodes that don't hold a replica of their volumes
+type ErrNotHyperconverged struct {
+	// App is the name of the app
+	App string
+	// Misses are the placements of the pods on nodes without a replica
+	Misses []PodPlacement
+	// Percentage is the percentage of pods running on a node with a replica
+	Percentage float64
+}
+
+func (e *ErrNotHyperconverged) Error() string {
+	var misses []string
+	for _, m := range e.Misses {
+		misses = append(misses, fmt.Sprintf("pod: %v on node: %v replica nodes: %v", m.Pod, m.Node, m.ReplicaNodes))
+	}
+	return fmt.Sprintf("%.2f%% of the pods of app: %v run on a node holding a replica of their volumes. Misses: %v",
+		e.Percentage, e.App, strings.Join(misses, ", "))
+}
+
+// ErrPodsOnPXDownNodes error type when app pods run on nodes where portworx is down
+type ErrPodsOnPXDownNodes struct {
+	// Pods maps the pods to the nodes they run on
+	Pods map[string]string
+}
+
+func (e *ErrPodsOnPXDownNodes) Error() string {
+	return fmt.Sprintf("Pods are running on nodes where portworx is down. Pods: %v", e.Pods)
+}
@@ -0,0 +1,96 @@
+package schedops
+
+import (
+	"fmt"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// ValidateHyperconvergence checks that each scheduled pod of the given app runs on a node that holds a
+// replica of the PVs of all its PVCs. The report has the placement of every pod. An ErrNotHyperconverged
+// listing the pods on nodes without a replica, with the nodes holding the replicas, is returned along with
+// the report if any pod misses.
+func (k *k8sSchedOps) ValidateHyperconvergence(
+	app *v1beta1.Deployment,
+	volumeNodeLookup func(pvName string) ([]string, error),
+) (*HyperconvergenceReport, error) {
+	pods, err := k8sutils.GetDeploymentPods(app)
+	if err != nil {
+		return nil, err
+	}
+
+	replicaNodes := make(map[string][]string)
+	report := &HyperconvergenceReport{}
+	var misses []PodPlacement
+	for _, pod := range pods {
+		if len(pod.Spec.NodeName) == 0 {
+			continue
+		}
+
+		placement := PodPlacement{
+			Pod:          pod.Name,
+			Node:         pod.Spec.NodeName,
+			ReplicaNodes: make(map[string][]string),
+			Hit:          true,
+		}
+
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim == nil {
+				continue
+			}
+
+			pvc, err := k8sutils.GetPVC(volume.PersistentVolumeClaim.ClaimName, pod.Namespace)
+			if err != nil {
+				return nil, err
+			}
+
+			pvName, err := k8sutils.GetVolumeForPersistentVolumeClaim(pvc)
+			if err != nil {
+				return nil, err
+			}
+
+			nodes, ok := replicaNodes[pvName]
+			if !ok {
+				if nodes, err = volumeNodeLookup(pvName); err != nil {
+					return nil, fmt.Errorf("failed to get the replica nodes of PV: %v. Err: %v", pvName, err)
+				}
+				replicaNodes[pvName] = nodes
+			}
+
+			placement.ReplicaNodes[pvName] = nodes
+			if !containsString(nodes, pod.Spec.NodeName) {
+				placement.Hit = false
+			}
+		}
+
+		report.Pods = append(report.Pods, placement)
+		if !placement.Hit {
+			misses = append(misses, placement)
+		}
+	}
+
+	if len(report.Pods) == 0 {
+		return report, fmt.Errorf("app: %v has no scheduled pods", app.Name)
+	}
+
+	report.Percentage = float64(len(report.Pods)-len(misses)) * 100 / float64(len(report.Pods))
+	if len(misses) > 0 {
+		return report, &ErrNotHyperconverged{
+			App:        app.Name,
+			Misses:     misses,
+			Percentage: report.Percentage,
+		}
+	}
+
+	return report, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package schedops
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/task"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// k8sPxNamespace is the namespace of the portworx daemonset and pods
+	k8sPxNamespace              = "kube-system"
+	k8sPxDaemonSetName          = "portworx"
+	k8sPxServiceName            = "portworx-service"
+	k8sPxServiceAccountName     = "px-account"
+	k8sPxClusterRoleName        = "node-get-put-list-role"
+	k8sPxClusterRoleBindingName = "node-role-binding"
+	k8sStorkDeploymentName      = "stork"
+)
+
+// ValidatePortworxInstallation validates, until the timeout, that the portworx daemonset, service and RBAC
+// objects exist in the given namespace and that all the portworx pods are ready. The stork deployment is
+// validated only if it is installed as stork is optional. The report of the last check is returned and,
+// if a component is missing or unhealthy, an ErrPortworxInstallation with the same report.
+func (k *k8sSchedOps) ValidatePortworxInstallation(namespace string, timeout time.Duration) (*InstallationReport, error) {
+	client, err := k8sutils.GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// report is only set by the attempt that succeeds
+	var report *InstallationReport
+	t := func() error {
+		current := checkPortworxInstallation(client, namespace)
+		if !current.Healthy() {
+			return &ErrPortworxInstallation{Report: current}
+		}
+		report = current
+		return nil
+	}
+
+	if err := task.DoRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+		if installErr, ok := task.LastError(err).(*ErrPortworxInstallation); ok {
+			return installErr.Report, installErr
+		}
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func checkPortworxInstallation(client *kubernetes.Clientset, namespace string) *InstallationReport {
+	report := &InstallationReport{}
+
+	ds, err := client.ExtensionsV1beta1().DaemonSets(namespace).Get(k8sPxDaemonSetName, meta_v1.GetOptions{})
+	status := newComponentStatus("daemonset", k8sPxDaemonSetName, err)
+	if err == nil {
+		report.DesiredPods = ds.Status.DesiredNumberScheduled
+		report.ReadyPods = ds.Status.NumberReady
+		if report.DesiredPods == 0 || report.ReadyPods != report.DesiredPods {
+			status.Healthy = false
+			status.Cause = fmt.Sprintf("%v of %v portworx pods are ready", report.ReadyPods, report.DesiredPods)
+		}
+	}
+	report.Components = append(report.Components, status)
+
+	_, err = client.CoreV1().Services(namespace).Get(k8sPxServiceName, meta_v1.GetOptions{})
+	report.Components = append(report.Components, newComponentStatus("service", k8sPxServiceName, err))
+
+	_, err = client.CoreV1().ServiceAccounts(namespace).Get(k8sPxServiceAccountName, meta_v1.GetOptions{})
+	report.Components = append(report.Components, newComponentStatus("serviceaccount", k8sPxServiceAccountName, err))
+
+	_, err = client.RbacV1beta1().ClusterRoles().Get(k8sPxClusterRoleName, meta_v1.GetOptions{})
+	report.Components = append(report.Components, newComponentStatus("clusterrole", k8sPxClusterRoleName, err))
+
+	_, err = client.RbacV1beta1().ClusterRoleBindings().Get(k8sPxClusterRoleBindingName, meta_v1.GetOptions{})
+	report.Components = append(report.Components,
+		newComponentStatus("clusterrolebinding", k8sPxClusterRoleBindingName, err))
+
+	stork, err := client.ExtensionsV1beta1().Deployments(namespace).Get(k8sStorkDeploymentName, meta_v1.GetOptions{})
+	if !k8s_errors.IsNotFound(err) {
+		status := newComponentStatus("deployment", k8sStorkDeploymentName, err)
+		if err == nil && stork.Spec.Replicas != nil && *stork.Spec.Replicas != stork.Status.ReadyReplicas {
+			status.Healthy = false
+			status.Cause = fmt.Sprintf("%v of %v stork replicas are ready", stork.Status.ReadyReplicas, *stork.Spec.Replicas)
+		}
+		report.Components = append(report.Components, status)
+	}
+
+	return report
+}
+
+func newComponentStatus(kind, name string, err error) ComponentStatus {
+	status := ComponentStatus{
+		Kind:    kind,
+		Name:    name,
+		Present: err == nil,
+		Healthy: err == nil,
+	}
+	if k8s_errors.IsNotFound(err) {
+		status.Cause = "not found"
+	} else if err != nil {
+		status.Cause = err.Error()
+	}
+	return status
+}
@@ -0,0 +1,170 @@
+package schedops
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// k8sPxServiceLabelKey is the label key used to control the portworx service on a node
+	k8sPxServiceLabelKey = "px/service"
+	// k8sPxServiceStopLabelValue is the label value that stops the portworx service on a node
+	k8sPxServiceStopLabelValue = "stop"
+)
+
+// ValidateNoPodsOnPXDownNodes checks that none of the pods matching the selector in the given namespace
+// run on a node where portworx is stopped or disabled with a label or that doesn't have a ready portworx pod
+func (k *k8sSchedOps) ValidateNoPodsOnPXDownNodes(namespace, selector string) error {
+	client, err := k8sutils.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return err
+	}
+
+	nodes, err := k8sutils.GetNodes()
+	if err != nil {
+		return err
+	}
+
+	pxDown := make(map[string]bool)
+	for _, n := range nodes.Items {
+		down, err := isPxDownOnNode(n)
+		if err != nil {
+			return err
+		}
+		pxDown[n.Name] = down
+	}
+
+	violations := make(map[string]string)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == v1.PodRunning && pxDown[pod.Spec.NodeName] {
+			violations[pod.Name] = pod.Spec.NodeName
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ErrPodsOnPXDownNodes{
+			Pods: violations,
+		}
+	}
+
+	return nil
+}
+
+type nodeHealthRecorder struct {
+	sync.Mutex
+	events []k8sutils.NodeHealthEvent
+	last   map[string]bool
+	quit   chan struct{}
+	done   chan struct{}
+}
+
+// StartNodeHealthRecorder checks every interval if portworx is down on each node, as
+// ValidateNoPodsOnPXDownNodes does, and records every change. The returned function stops the recording,
+// waits for it to exit and returns the recorded events, to be correlated with the pod placement history by
+// k8sutils.FindPlacementViolations.
+func (k *k8sSchedOps) StartNodeHealthRecorder(interval time.Duration) (func() []k8sutils.NodeHealthEvent, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive. Given: %v", interval)
+	}
+
+	r := &nodeHealthRecorder{
+		last: make(map[string]bool),
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	// The state at the start is recorded before returning so that the history covers the whole recording
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+
+	go r.run(interval)
+
+	var once sync.Once
+	var events []k8sutils.NodeHealthEvent
+	return func() []k8sutils.NodeHealthEvent {
+		once.Do(func() {
+			close(r.quit)
+			<-r.done
+			r.Lock()
+			events = r.events
+			r.Unlock()
+		})
+		return events
+	}, nil
+}
+
+func (r *nodeHealthRecorder) run(interval time.Duration) {
+	defer close(r.done)
+
+	for {
+		select {
+		case <-r.quit:
+			return
+		case <-time.After(interval):
+		}
+
+		if err := r.check(); err != nil {
+			logrus.Warnf("Failed to check the portworx health of the nodes. Err: %v", err)
+		}
+	}
+}
+
+// check records the health of portworx on each node
+func (r *nodeHealthRecorder) check() error {
+	nodes, err := k8sutils.GetNodes()
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes.Items {
+		down, err := isPxDownOnNode(n)
+		if err != nil {
+			return err
+		}
+		r.record(n.Name, !down)
+	}
+	return nil
+}
+
+func (r *nodeHealthRecorder) record(nodeName string, healthy bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if last, ok := r.last[nodeName]; ok && last == healthy {
+		return
+	}
+	r.last[nodeName] = healthy
+	r.events = append(r.events, k8sutils.NodeHealthEvent{
+		Time:    time.Now(),
+		Node:    nodeName,
+		Healthy: healthy,
+	})
+}
+
+func isPxDownOnNode(n v1.Node) (bool, error) {
+	if n.Labels[k8sPxServiceLabelKey] == k8sPxServiceStopLabelValue ||
+		n.Labels[k8sPxRunningLabelKey] == k8sPxNotRunningLabelValue {
+		return true, nil
+	}
+
+	ready, err := k8sutils.CountReadyPodsOnNode(n.Name, k8sPxPodSelector)
+	if err != nil {
+		return false, err
+	}
+
+	return ready == 0, nil
+}
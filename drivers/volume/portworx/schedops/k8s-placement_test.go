@@ -0,0 +1,72 @@
+package schedops
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestStartNodeHealthRecorder(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	for _, name := range []string{"node1", "node2"} {
+		server.Add(
+			&v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: name, Labels: map[string]string{"kubernetes.io/hostname": name}}},
+			newTestPxPod("portworx-"+name, name),
+		)
+	}
+
+	k := &k8sSchedOps{}
+	if _, err := k.StartNodeHealthRecorder(0); err == nil {
+		t.Errorf("expected an error for an interval of 0")
+	}
+	stop, err := k.StartNodeHealthRecorder(10 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to start the recorder: %v", err)
+	}
+
+	// portworx is stopped on node2 with the label, then its pod goes away on node1
+	node2 := &v1.Node{}
+	server.Get("nodes", "", "node2", node2)
+	node2.Labels[k8sPxServiceLabelKey] = k8sPxServiceStopLabelValue
+	server.Add(node2)
+	time.Sleep(200 * time.Millisecond)
+	server.Remove("pods", k8sPxNamespace, "portworx-node1")
+	time.Sleep(200 * time.Millisecond)
+
+	events := stop()
+	if again := stop(); !reflect.DeepEqual(again, events) {
+		t.Errorf("expected stopping again to return the same events, got: %v", again)
+	}
+
+	type change struct {
+		node    string
+		healthy bool
+	}
+	var changes []change
+	for i, e := range events {
+		if i > 0 && e.Time.Before(events[i-1].Time) {
+			t.Errorf("expected the events in time order, got: %v", events)
+		}
+		changes = append(changes, change{e.Node, e.Healthy})
+	}
+	expected := []change{{"node1", true}, {"node2", true}, {"node2", false}, {"node1", false}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected the changes: %v, got: %v", expected, changes)
+	}
+
+	// A pod that kept running on node2 overlaps with the recorded outage
+	pods := []k8sutils.PodPlacementEvent{{Time: events[0].Time, Pod: "web-1", Node: "node2", Running: true}}
+	violations := k8sutils.FindPlacementViolations(events, pods)
+	if len(violations) != 1 || violations[0].Node != "node2" || !violations[0].Start.Equal(events[2].Time) {
+		t.Errorf("expected a violation on node2 from: %v, got: %+v", events[2].Time, violations)
+	}
+}
@@ -0,0 +1,124 @@
+package schedops
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/drivers/node"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// k8sPxPodReadyTimeout is how long to wait for the portworx pods of a wave to be ready again
+const k8sPxPodReadyTimeout = 10 * time.Minute
+
+// DeletePXPodsOption is an option for DeletePXPods
+type DeletePXPodsOption func(*deletePXPodsOptions)
+
+type deletePXPodsOptions struct {
+	force bool
+}
+
+// WithForce makes DeletePXPods delete the pods even if it puts the portworx quorum at risk. This is meant
+// for tests of quorum loss.
+func WithForce() DeletePXPodsOption {
+	return func(o *deletePXPodsOptions) {
+		o.force = true
+	}
+}
+
+// DeletePXPods deletes the portworx pods on the given nodes in waves of at most maxUnavailable pods and
+// waits for the pods of a wave to be ready again before deleting the next wave. If a wave could make the
+// portworx cluster, as sized by the portworx daemonset, lose quorum, an ErrQuorumRisk is returned before
+// any pod is deleted.
+func (k *k8sSchedOps) DeletePXPods(nodes []node.Node, maxUnavailable int, opts ...DeletePXPodsOption) error {
+	o := &deletePXPodsOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if maxUnavailable <= 0 {
+		return fmt.Errorf("maxUnavailable must be positive. Given: %v", maxUnavailable)
+	}
+
+	client, err := k8sutils.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	ds, err := client.ExtensionsV1beta1().DaemonSets(k8sPxNamespace).Get(k8sPxDaemonSetName, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	size := int(ds.Status.DesiredNumberScheduled)
+	ready := int(ds.Status.NumberReady)
+	quorum := size/2 + 1
+	waveSize := maxUnavailable
+	if waveSize > len(nodes) {
+		waveSize = len(nodes)
+	}
+
+	if !o.force && ready-waveSize < quorum {
+		return &ErrQuorumRisk{
+			ClusterSize: size,
+			Ready:       ready,
+			Quorum:      quorum,
+			Requested:   waveSize,
+		}
+	}
+
+	for start := 0; start < len(nodes); start += waveSize {
+		end := start + waveSize
+		if end > len(nodes) {
+			end = len(nodes)
+		}
+		wave := nodes[start:end]
+
+		// The nodes without a portworx pod have no pod to delete or to wait for
+		var pods []v1.Pod
+		var pxNodes []node.Node
+		for _, n := range wave {
+			nodePods, err := client.CoreV1().Pods(k8sPxNamespace).List(meta_v1.ListOptions{
+				LabelSelector: k8sPxPodSelector,
+				FieldSelector: fmt.Sprintf("spec.nodeName=%v", n.Name),
+			})
+			if err != nil {
+				return err
+			}
+			if len(nodePods.Items) == 0 {
+				logrus.Infof("Skipping node: %v without portworx pods", n.Name)
+				continue
+			}
+			pods = append(pods, nodePods.Items...)
+			pxNodes = append(pxNodes, n)
+		}
+
+		if len(pods) == 0 {
+			continue
+		}
+
+		logrus.Infof("Deleting %d portworx pods on nodes: %v", len(pods), nodeNames(pxNodes))
+		if err := k8sutils.DeletePods(pods); err != nil {
+			return err
+		}
+
+		for _, n := range pxNodes {
+			if err := k8sutils.WaitForPodsScheduledOnNode(n.Name, k8sPxPodSelector, 1, k8sPxPodReadyTimeout); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func nodeNames(nodes []node.Node) []string {
+	var names []string
+	for _, n := range nodes {
+		names = append(names, n.Name)
+	}
+	return names
+}
@@ -0,0 +1,131 @@
+package schedops
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/drivers/node"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// newTestPxPod returns a running and ready portworx pod on the given node
+func newTestPxPod(name, nodeName string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: k8sPxNamespace,
+			Labels:    map[string]string{"name": "portworx"},
+		},
+		Spec: v1.PodSpec{NodeName: nodeName},
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+// newTestPxCluster adds the portworx daemonset with the given ready pods and pods on the given nodes to
+// the server. A deleted portworx pod is replaced right away by a ready one on the same node.
+func newTestPxCluster(server *k8stest.Server, ready int32, nodes ...string) {
+	server.Add(&ext_v1beta1.DaemonSet{
+		ObjectMeta: meta_v1.ObjectMeta{Name: k8sPxDaemonSetName, Namespace: k8sPxNamespace},
+		Status: ext_v1beta1.DaemonSetStatus{
+			DesiredNumberScheduled: int32(len(nodes)),
+			NumberReady:            ready,
+		},
+	})
+	for _, n := range nodes {
+		server.Add(newTestPxPod("portworx-"+n, n))
+	}
+
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method == http.MethodDelete && req.Resource == "pods" {
+			deleted := &v1.Pod{}
+			if server.Get("pods", req.Namespace, req.Name, deleted) {
+				server.Add(newTestPxPod(req.Name+"-new", deleted.Spec.NodeName))
+			}
+		}
+		return false, 0, nil
+	})
+}
+
+// countDeletes returns the number of pod deletions the server received
+func countDeletes(server *k8stest.Server) int {
+	var deletes int
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodDelete && req.Resource == "pods" {
+			deletes++
+		}
+	}
+	return deletes
+}
+
+func TestDeletePXPodsSkipsNodesWithoutPods(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	newTestPxCluster(server, 3, "node1", "node3", "node4")
+	nodes := []node.Node{{Name: "node1"}, {Name: "node2"}, {Name: "node3"}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- (&k8sSchedOps{}).DeletePXPods(nodes, 1)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to delete the portworx pods: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatalf("expected the node without portworx pods not to be waited for")
+	}
+
+	if deletes := countDeletes(server); deletes != 2 {
+		t.Errorf("expected the pods of node1 and node3 to be deleted, got: %v deletions", deletes)
+	}
+	for _, name := range []string{"portworx-node1", "portworx-node3"} {
+		if server.Get("pods", k8sPxNamespace, name, &v1.Pod{}) {
+			t.Errorf("expected pod: %v to be deleted", name)
+		}
+	}
+	if !server.Get("pods", k8sPxNamespace, "portworx-node4", &v1.Pod{}) {
+		t.Errorf("expected the pod of node4 not to be deleted")
+	}
+}
+
+func TestDeletePXPodsQuorumRisk(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	// One of the 3 pods is not ready, so deleting another leaves 1 of the 2 needed for quorum
+	newTestPxCluster(server, 2, "node1", "node2", "node3")
+	k := &k8sSchedOps{}
+
+	err := k.DeletePXPods([]node.Node{{Name: "node1"}}, 1)
+	if quorumErr, ok := err.(*ErrQuorumRisk); !ok || quorumErr.Quorum != 2 || quorumErr.Requested != 1 {
+		t.Fatalf("expected an ErrQuorumRisk, got: %v", err)
+	}
+	if deletes := countDeletes(server); deletes != 0 {
+		t.Errorf("expected no pod to be deleted, got: %v deletions", deletes)
+	}
+
+	if err := k.DeletePXPods([]node.Node{{Name: "node1"}}, 1, WithForce()); err != nil {
+		t.Fatalf("failed to force the deletion: %v", err)
+	}
+	if deletes := countDeletes(server); deletes != 1 {
+		t.Errorf("expected the pod of node1 to be deleted, got: %v deletions", deletes)
+	}
+
+	if err := k.DeletePXPods(nil, 0); err == nil {
+		t.Errorf("expected an error for a maxUnavailable of 0")
+	}
+}
@@ -0,0 +1,214 @@
+package schedops
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/drivers/node"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/plan"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	rollingStageCordon     = "cordon"
+	rollingStageDisable    = "disable portworx"
+	rollingStageReschedule = "reschedule app"
+	rollingStageEnable     = "enable portworx"
+	rollingStageUncordon   = "uncordon"
+	rollingStageReadiness  = "continuous readiness"
+
+	// k8sRollingValidationInterval is how often the app readiness is checked during a node iteration
+	k8sRollingValidationInterval = 5 * time.Second
+
+	planVerbDisablePortworx = "disable-portworx"
+	planVerbEnablePortworx  = "enable-portworx"
+)
+
+// RollingNodeDisable disables portworx on the given nodes one at a time and validates that the given app
+// stays ready. For each node, the node is cordoned, portworx is disabled on it and the app pods on the node
+// are deleted so that they are rescheduled. Once the app is ready on other nodes, portworx is enabled again,
+// the node is uncordoned and the settle time is waited before the next node. The app is checked to never
+// be down during an iteration. The returned ErrFailedRollingNodeDisable has the node and stage that failed.
+// The plan of plan.WithDryRun lists the app pods on each node when it is made. As the pods deleted from a
+// node can be rescheduled onto a later node, with plan.WithPlan each iteration checks that the app pods on
+// its node are still the planned ones before deleting them, and fails at the reschedule stage otherwise.
+func (k *k8sSchedOps) RollingNodeDisable(
+	nodes []node.Node,
+	app *v1beta1.Deployment,
+	settle time.Duration,
+	opts ...plan.Option,
+) error {
+	pods, err := k8sutils.GetDeploymentPods(app)
+	if err != nil {
+		return err
+	}
+
+	actions := rollingNodeDisableActions(nodes, pods)
+	if execute, err := plan.Check("RollingNodeDisable", actions, opts); !execute {
+		return err
+	}
+
+	reviewed := plan.Reviewed(opts)
+	for _, n := range nodes {
+		logrus.Infof("Disabling portworx on node: %v", n.Name)
+		stop := k8sutils.StartContinuousValidation(app, k8sRollingValidationInterval)
+
+		stage, err := k.disableNodeForApp(n, app, reviewed)
+		history := stop()
+		if err != nil {
+			return &ErrFailedRollingNodeDisable{
+				Node:  n,
+				Stage: stage,
+				Cause: err.Error(),
+			}
+		}
+
+		if history.TotalDowntime > 0 {
+			return &ErrFailedRollingNodeDisable{
+				Node:  n,
+				Stage: rollingStageReadiness,
+				Cause: fmt.Sprintf("app was down for %v. Causes: %v", history.TotalDowntime, history.Causes),
+			}
+		}
+
+		time.Sleep(settle)
+	}
+
+	return nil
+}
+
+// rollingNodeDisableActions returns the plan actions of the iterations over the given nodes, deleting the
+// given pods of the app on each node
+func rollingNodeDisableActions(nodes []node.Node, pods []v1.Pod) []plan.Action {
+	var actions []plan.Action
+	for _, n := range nodes {
+		actions = append(actions,
+			plan.Action{Verb: plan.VerbCordon, Kind: "node", Name: n.Name},
+			plan.Action{Verb: planVerbDisablePortworx, Kind: "node", Name: n.Name},
+		)
+		actions = append(actions, k8sutils.PodDeleteActions(getPodsOnNode(pods, n.Name))...)
+		actions = append(actions,
+			plan.Action{Verb: planVerbEnablePortworx, Kind: "node", Name: n.Name},
+			plan.Action{Verb: plan.VerbUncordon, Kind: "node", Name: n.Name},
+		)
+	}
+	return actions
+}
+
+// getPodsOnNode returns the given pods that run on the node and are not being deleted
+func getPodsOnNode(pods []v1.Pod, nodeName string) []v1.Pod {
+	var onNode []v1.Pod
+	for _, pod := range pods {
+		if pod.Spec.NodeName == nodeName && pod.DeletionTimestamp == nil {
+			onNode = append(onNode, pod)
+		}
+	}
+	return onNode
+}
+
+// getPlannedPodDeletes returns the pod deletions of the given plan on the node
+func getPlannedPodDeletes(reviewed *plan.Plan, nodeName string) []plan.Action {
+	var planned []plan.Action
+	for _, a := range reviewed.Actions {
+		if a.Verb == plan.VerbDelete && a.Node == nodeName {
+			planned = append(planned, a)
+		}
+	}
+	return planned
+}
+
+// disableNodeForApp runs one node iteration of RollingNodeDisable and returns the stage that failed. On
+// failure, portworx is enabled again and the node uncordoned. The errors of restoring the node are logged
+// and added to the returned error, as a node left cordoned or without portworx affects the later tests.
+func (k *k8sSchedOps) disableNodeForApp(
+	n node.Node,
+	app *v1beta1.Deployment,
+	reviewed *plan.Plan,
+) (stage string, err error) {
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		var restoreErrs []string
+		if enableErr := k.EnableOnNode(n); enableErr != nil {
+			restoreErrs = append(restoreErrs, fmt.Sprintf("failed to enable portworx: %v", enableErr))
+		}
+		if uncordonErr := k8sutils.UncordonNode(n.Name); uncordonErr != nil {
+			restoreErrs = append(restoreErrs, fmt.Sprintf("failed to uncordon: %v", uncordonErr))
+		}
+
+		if len(restoreErrs) > 0 {
+			logrus.Errorf("Failed to restore node: %v after rolling disable failure. Errs: %v", n.Name,
+				strings.Join(restoreErrs, "; "))
+			err = fmt.Errorf("%v. Failed to restore node: %v. Errs: %v", err, n.Name, strings.Join(restoreErrs, "; "))
+		}
+	}()
+
+	if err := k8sutils.CordonNode(n.Name); err != nil {
+		return rollingStageCordon, err
+	}
+
+	if err := k.DisableOnNode(n); err != nil {
+		return rollingStageDisable, err
+	}
+
+	if err := rescheduleAppOffNode(n, app, reviewed); err != nil {
+		return rollingStageReschedule, err
+	}
+
+	if err := k.EnableOnNode(n, WithWaitForPods(k8sPxPodReadyTimeout)); err != nil {
+		return rollingStageEnable, err
+	}
+
+	if err := k8sutils.UncordonNode(n.Name); err != nil {
+		return rollingStageUncordon, err
+	}
+
+	return "", nil
+}
+
+// rescheduleAppOffNode deletes the pods of the app on the given node and validates that the app is ready
+// with none of its pods on the node. With a reviewed plan, the pods on the node must be the planned ones.
+func rescheduleAppOffNode(n node.Node, app *v1beta1.Deployment, reviewed *plan.Plan) error {
+	pods, err := k8sutils.GetDeploymentPods(app)
+	if err != nil {
+		return err
+	}
+
+	onNode := getPodsOnNode(pods, n.Name)
+	if reviewed != nil {
+		planned := getPlannedPodDeletes(reviewed, n.Name)
+		if resolved := k8sutils.PodDeleteActions(onNode); !plan.EqualActions(planned, resolved) {
+			return &plan.ErrMismatch{
+				Operation: reviewed.Operation,
+				Planned:   &plan.Plan{Operation: reviewed.Operation, Actions: planned},
+				Resolved:  &plan.Plan{Operation: reviewed.Operation, Actions: resolved},
+			}
+		}
+	}
+
+	if err := k8sutils.DeletePods(onNode); err != nil {
+		return err
+	}
+
+	if err := k8sutils.ValidateDeployement(app); err != nil {
+		return err
+	}
+
+	if pods, err = k8sutils.GetDeploymentPods(app); err != nil {
+		return err
+	}
+
+	for _, pod := range pods {
+		if pod.Spec.NodeName == n.Name && pod.DeletionTimestamp == nil {
+			return fmt.Errorf("pod: %v of app: %v is still on node: %v", pod.Name, app.Name, n.Name)
+		}
+	}
+
+	return nil
+}
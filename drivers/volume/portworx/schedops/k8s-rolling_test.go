@@ -0,0 +1,140 @@
+package schedops
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/portworx/torpedo/drivers/node"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	"github.com/portworx/torpedo/pkg/plan"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const testNamespace = "test"
+
+// newTestApp adds a deployment with a pod on each of the given nodes to the server
+func newTestApp(server *k8stest.Server, nodes ...string) *v1beta1.Deployment {
+	labels := map[string]string{"app": "web"}
+	replicas := int32(len(nodes))
+	dep := &v1beta1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "web", Namespace: testNamespace, UID: "dep-uid"},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &meta_v1.LabelSelector{MatchLabels: labels},
+		},
+	}
+	rs := &ext_v1beta1.ReplicaSet{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "web-abc",
+			Namespace:       testNamespace,
+			UID:             "rs-uid",
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: "Deployment", Name: dep.Name, UID: dep.UID}},
+		},
+	}
+	server.Add(dep, rs)
+
+	for _, n := range nodes {
+		server.Add(&v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:            "web-abc-" + n,
+				Namespace:       testNamespace,
+				UID:             types.UID("web-abc-" + n + "-uid"),
+				Labels:          labels,
+				OwnerReferences: []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID}},
+			},
+			Spec: v1.PodSpec{NodeName: n},
+		})
+	}
+	return dep
+}
+
+func TestRollingNodeDisablePlanResolvesPods(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	app := newTestApp(server, "node1", "node2")
+	nodes := []node.Node{{Name: "node1"}, {Name: "node2"}}
+
+	var reviewed plan.Plan
+	k := &k8sSchedOps{}
+	if err := k.RollingNodeDisable(nodes, app, 0, plan.WithDryRun(&reviewed)); err != nil {
+		t.Fatalf("failed to plan the rolling disable: %v", err)
+	}
+
+	var expected []plan.Action
+	for _, n := range []string{"node1", "node2"} {
+		expected = append(expected,
+			plan.Action{Verb: plan.VerbCordon, Kind: "node", Name: n},
+			plan.Action{Verb: planVerbDisablePortworx, Kind: "node", Name: n},
+			plan.Action{
+				Verb:      plan.VerbDelete,
+				Kind:      "pod",
+				Namespace: testNamespace,
+				Name:      "web-abc-" + n,
+				UID:       "web-abc-" + n + "-uid",
+				Node:      n,
+			},
+			plan.Action{Verb: planVerbEnablePortworx, Kind: "node", Name: n},
+			plan.Action{Verb: plan.VerbUncordon, Kind: "node", Name: n},
+		)
+	}
+	if !reflect.DeepEqual(reviewed.Actions, expected) {
+		t.Errorf("expected the plan:\n%v\ngot:\n%v", &plan.Plan{Actions: expected}, &reviewed)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method != http.MethodGet {
+			t.Errorf("expected no mutations in a dry run, got: %+v", req)
+		}
+	}
+}
+
+func TestRescheduleAppOffNodeRefusesUnplannedPods(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	// The pod of node1 was rescheduled onto node2 after the plan was made
+	app := newTestApp(server, "node2")
+	server.Add(&v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "web-abc-moved",
+			Namespace:       testNamespace,
+			Labels:          map[string]string{"app": "web"},
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: "web-abc", UID: "rs-uid"}},
+		},
+		Spec: v1.PodSpec{NodeName: "node2"},
+	})
+
+	reviewed := &plan.Plan{
+		Operation: "RollingNodeDisable",
+		Actions: []plan.Action{{
+			Verb:      plan.VerbDelete,
+			Kind:      "pod",
+			Namespace: testNamespace,
+			Name:      "web-abc-node2",
+			UID:       "web-abc-node2-uid",
+			Node:      "node2",
+		}},
+	}
+
+	err := rescheduleAppOffNode(node.Node{Name: "node2"}, app, reviewed)
+	if _, ok := err.(*plan.ErrMismatch); !ok {
+		t.Fatalf("expected an ErrMismatch, got: %v", err)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodDelete {
+			t.Errorf("expected no pods to be deleted, got: %+v", req)
+		}
+	}
+}
@@ -1,38 +1,227 @@
 package schedops
 
 import (
+	"fmt"
+	"sync"
+	"time"
+
 	"github.com/portworx/torpedo/drivers/node"
-	"github.com/portworx/torpedo/pkg/errors"
 	"github.com/portworx/torpedo/pkg/k8sutils"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 
 const (
+	// k8sSchedOpsName is the name of the k8s scheduler operator
+	k8sSchedOpsName = "k8s"
 	// k8sPxRunningLabelKey is the label key used for px state
 	k8sPxRunningLabelKey = "px/running"
 	// k8sPxNotRunningLabelValue is label value for a not running px state
 	k8sPxNotRunningLabelValue = "false"
+	// k8sPxPodSelector is the label selector of the portworx pods
+	k8sPxPodSelector = "name=portworx"
+	// k8sValidateOnNodeTimeout is the timeout of the checks that validate portworx on a node
+	k8sValidateOnNodeTimeout = 1 * time.Minute
+	// pxConfigPath is the path of the portworx config on the nodes
+	pxConfigPath = "/etc/pwx/config.json"
 
 )
 
 
-type k8sSchedOps struct {}
+type k8sSchedOps struct {
+	DefaultDriver
+	inspector   NodeInspector
+	pxNamespace string
+	// disabledNodes are the nodes that DisableOnNode labeled and that were not enabled since
+	disabledNodes     map[string]bool
+	disabledNodesLock sync.Mutex
+}
+
+// Init checks that the k8s api server is reachable and that the portworx namespace exists
+func (k *k8sSchedOps) Init(opts InitOptions) error {
+	k.inspector = opts.Inspector
+	k.pxNamespace = opts.PxNamespace
+	if len(k.pxNamespace) == 0 {
+		k.pxNamespace = k8sPxNamespace
+	}
+
+	client, err := k8sutils.GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.CoreV1().Namespaces().Get(k.pxNamespace, meta_v1.GetOptions{}); err != nil {
+		return fmt.Errorf("failed to get portworx namespace: %v. Err: %v", k.pxNamespace, err)
+	}
+
+	return nil
+}
+
+func (k *k8sSchedOps) String() string {
+	return k8sSchedOpsName
+}
+
+// Destroy removes the label that disables portworx from the nodes that DisableOnNode labeled. The label
+// on the other nodes was set outside of torpedo and is left as is.
+func (k *k8sSchedOps) Destroy() error {
+	k.disabledNodesLock.Lock()
+	defer k.disabledNodesLock.Unlock()
+
+	for name := range k.disabledNodes {
+		if err := k8sutils.RemoveLabelOnNode(name, k8sPxRunningLabelKey); err != nil {
+			return err
+		}
+		delete(k.disabledNodes, name)
+	}
+
+	return nil
+}
 
 func (k *k8sSchedOps) DisableOnNode(n node.Node) error {
-	return k8sutils.AddLabelOnNode(n.Name, k8sPxRunningLabelKey, k8sPxNotRunningLabelValue)
+	if err := k8sutils.CheckDestructiveOp("DisableOnNode"); err != nil {
+		return err
+	}
+
+	if err := k8sutils.AddLabelOnNode(n.Name, k8sPxRunningLabelKey, k8sPxNotRunningLabelValue); err != nil {
+		return err
+	}
+
+	k.disabledNodesLock.Lock()
+	defer k.disabledNodesLock.Unlock()
+	if k.disabledNodes == nil {
+		k.disabledNodes = make(map[string]bool)
+	}
+	k.disabledNodes[n.Name] = true
+	return nil
 }
 
 func (k *k8sSchedOps) ValidateOnNode(n node.Node) error {
-	return &errors.ErrNotSupported{
-		Operation: "ValidateOnNode",
+	if err := k8sutils.WaitForPodsScheduledOnNode(n.Name, k8sPxPodSelector, 1, k8sValidateOnNodeTimeout); err != nil {
+		return err
+	}
+
+	if k.inspector == nil {
+		return nil
+	}
+
+	if _, err := k.inspector.RunCommand(n, "systemctl is-active "+pxServiceName, k8sValidateOnNodeTimeout); err != nil {
+		return &ErrFailedToValidateOnNode{
+			Node:  n,
+			Cause: fmt.Sprintf("portworx service is not active: %v", err),
+		}
+	}
+
+	if _, err := k.inspector.RunCommand(n, "test -f "+pxConfigPath, k8sValidateOnNodeTimeout); err != nil {
+		return &ErrFailedToValidateOnNode{
+			Node:  n,
+			Cause: fmt.Sprintf("portworx config: %v is not present: %v", pxConfigPath, err),
+		}
+	}
+
+	return nil
+}
+
+func (k *k8sSchedOps) EnableOnNode(n node.Node, opts ...EnableOption) error {
+	if err := k8sutils.RemoveLabelOnNode(n.Name, k8sPxRunningLabelKey); err != nil {
+		return err
+	}
+
+	k.disabledNodesLock.Lock()
+	delete(k.disabledNodes, n.Name)
+	k.disabledNodesLock.Unlock()
+
+	if o := newEnableOptions(opts); o.waitTimeout > 0 {
+		return k.waitForNodePods(n, o.waitTimeout)
+	}
+	return nil
+}
+
+// waitForNodePods waits till the portworx pod and the pods of the other daemonsets that run on the node
+// are ready on it, all within the given timeout
+func (k *k8sSchedOps) waitForNodePods(n node.Node, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	if err := k8sutils.WaitForPodsScheduledOnNode(n.Name, k8sPxPodSelector, 1, timeout); err != nil {
+		return err
+	}
+
+	selectors, err := k.getDaemonSetSelectorsForNode(n)
+	if err != nil {
+		return err
+	}
+
+	for _, selector := range selectors {
+		remaining := deadline.Sub(time.Now())
+		if remaining <= 0 {
+			return &k8sutils.ErrPodsNotScheduledOnNode{
+				Node:     n.Name,
+				Selector: selector,
+				Cause:    fmt.Sprintf("timed out after %v waiting for the pods of the node", timeout),
+			}
+		}
+
+		if err := k8sutils.WaitForPodsScheduledOnNode(n.Name, selector, 1, remaining); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getDaemonSetSelectorsForNode returns the pod selectors of the DaemonSets whose node selector matches
+// the given node
+func (k *k8sSchedOps) getDaemonSetSelectorsForNode(n node.Node) ([]string, error) {
+	k8sNode, err := k8sutils.GetNodeByName(n.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := k8sutils.GetK8sClient()
+	if err != nil {
+		return nil, err
 	}
+
+	daemonSets, err := client.ExtensionsV1beta1().DaemonSets("").List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var selectors []string
+	for _, ds := range daemonSets.Items {
+		nodeSelector := labels.SelectorFromSet(ds.Spec.Template.Spec.NodeSelector)
+		if !nodeSelector.Matches(labels.Set(k8sNode.Labels)) || ds.Spec.Selector == nil {
+			continue
+		}
+
+		selector, err := meta_v1.LabelSelectorAsSelector(ds.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+
+		if selector.String() != k8sPxPodSelector {
+			selectors = append(selectors, selector.String())
+		}
+	}
+
+	return selectors, nil
 }
 
-func (k *k8sSchedOps) EnableOnNode(n node.Node) error {
-	return k8sutils.RemoveLabelOnNode(n.Name, k8sPxRunningLabelKey)
+// checkPxPodReady checks that a portworx pod is running and ready on the given node
+func checkPxPodReady(nodeName string) error {
+	count, err := k8sutils.CountReadyPodsOnNode(nodeName, k8sPxPodSelector)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return fmt.Errorf("no ready portworx pod on node: %v", nodeName)
+	}
+
+	return nil
 }
 
 func init() {
 	k := &k8sSchedOps{}
-	Register("k8s", k)
+	Register(k8sSchedOpsName, k)
+	k8sutils.RegisterWorkerCheck("portworx", checkPxPodReady)
 }
\ No newline at end of file
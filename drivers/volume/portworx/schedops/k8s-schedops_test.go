@@ -0,0 +1,79 @@
+package schedops
+
+import (
+	"testing"
+
+	"github.com/portworx/torpedo/drivers/node"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestDestroyRemovesOnlyItsLabels(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	for _, name := range []string{"node1", "node2", "node3"} {
+		server.Add(&v1.Node{ObjectMeta: meta_v1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"kubernetes.io/hostname": name},
+		}})
+	}
+	// Labeled outside of torpedo
+	node3 := &v1.Node{}
+	server.Get("nodes", "", "node3", node3)
+	node3.Labels[k8sPxRunningLabelKey] = k8sPxNotRunningLabelValue
+	server.Add(node3)
+
+	k := &k8sSchedOps{}
+	for _, name := range []string{"node1", "node2"} {
+		if err := k.DisableOnNode(node.Node{Name: name}); err != nil {
+			t.Fatalf("failed to disable portworx on %v: %v", name, err)
+		}
+	}
+	if err := k.EnableOnNode(node.Node{Name: "node2"}); err != nil {
+		t.Fatalf("failed to enable portworx on node2: %v", err)
+	}
+	if err := k.Destroy(); err != nil {
+		t.Fatalf("failed to destroy: %v", err)
+	}
+
+	expected := map[string]bool{"node1": false, "node2": false, "node3": true}
+	for name, labeled := range expected {
+		n := &v1.Node{}
+		if !server.Get("nodes", "", name, n) {
+			t.Fatalf("%v was not found", name)
+		}
+		if _, ok := n.Labels[k8sPxRunningLabelKey]; ok != labeled {
+			t.Errorf("%v: expected labeled: %v, got: %v", name, labeled, n.Labels)
+		}
+	}
+}
+
+func TestDisableOnNodeDestructiveOpsGate(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	server.Add(&v1.Node{ObjectMeta: meta_v1.ObjectMeta{
+		Name:   "node1",
+		Labels: map[string]string{"kubernetes.io/hostname": "node1"},
+	}})
+
+	enabled := k8sutils.DestructiveOpsEnabled()
+	k8sutils.SetDestructiveOpsEnabled(false)
+	defer k8sutils.SetDestructiveOpsEnabled(enabled)
+
+	k := &k8sSchedOps{}
+	err := k.DisableOnNode(node.Node{Name: "node1"})
+	if disabled, ok := err.(*k8sutils.ErrDestructiveOpsDisabled); !ok || disabled.Op != "DisableOnNode" {
+		t.Errorf("expected an ErrDestructiveOpsDisabled, got: %v", err)
+	}
+	if requests := server.Requests(); len(requests) > 0 {
+		t.Errorf("expected no api requests while the gate is closed, got: %+v", requests)
+	}
+}
@@ -0,0 +1,60 @@
+package schedops
+
+import (
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// k8sProvisionedByKey is the annotation of PVs with the name of the provisioner that created them
+	k8sProvisionedByKey = "pv.kubernetes.io/provisioned-by"
+	// k8sPxProvisioner is the name of the in-tree portworx provisioner
+	k8sPxProvisioner = "kubernetes.io/portworx-volume"
+)
+
+// ResolvePortworxVolumeID returns the ID of the portworx volume of the given PVC. The ID is taken from the
+// portworx volume source of the bound PV and defaults to the name of the PV, which is the ID of volumes
+// provisioned by the in-tree portworx provisioner. An ErrPVCNotBound is returned if the PVC isn't bound
+// and an ErrNotPortworxVolume if the PV is not a portworx volume.
+func (k *k8sSchedOps) ResolvePortworxVolumeID(pvc *v1.PersistentVolumeClaim) (string, error) {
+	pvName, err := k8sutils.GetVolumeForPersistentVolumeClaim(pvc)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := k.ResolvePortworxVolumeIDForPV(pvName)
+	if notPx, ok := err.(*ErrNotPortworxVolume); ok {
+		notPx.PVC = pvc.Name
+	}
+	return id, err
+}
+
+// ResolvePortworxVolumeIDForPV returns the ID of the portworx volume of the PV with the given name, as
+// ResolvePortworxVolumeID does for the PV of a PVC
+func (k *k8sSchedOps) ResolvePortworxVolumeIDForPV(pvName string) (string, error) {
+	client, err := k8sutils.GetK8sClient()
+	if err != nil {
+		return "", err
+	}
+
+	pv, err := client.CoreV1().PersistentVolumes().Get(pvName, meta_v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	if source := pv.Spec.PortworxVolume; source != nil {
+		if len(source.VolumeID) > 0 {
+			return source.VolumeID, nil
+		}
+		return pv.Name, nil
+	}
+
+	if pv.Annotations[k8sProvisionedByKey] == k8sPxProvisioner {
+		return pv.Name, nil
+	}
+
+	return "", &ErrNotPortworxVolume{
+		PV: pv.Name,
+	}
+}
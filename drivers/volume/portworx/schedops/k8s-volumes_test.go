@@ -0,0 +1,95 @@
+package schedops
+
+import (
+	"testing"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestResolvePortworxVolumeID(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	pvs := []*v1.PersistentVolume{
+		{
+			// Provisioned by stork or a CSI-style driver, the volume ID is not the PV name
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pvc-with-id"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				PortworxVolume: &v1.PortworxVolumeSource{VolumeID: "px-123"},
+			}},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pvc-in-tree"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				PortworxVolume: &v1.PortworxVolumeSource{},
+			}},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:        "pvc-annotated",
+				Annotations: map[string]string{k8sProvisionedByKey: k8sPxProvisioner},
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "pvc-nfs"},
+			Spec: v1.PersistentVolumeSpec{PersistentVolumeSource: v1.PersistentVolumeSource{
+				NFS: &v1.NFSVolumeSource{Server: "nfs", Path: "/data"},
+			}},
+		},
+	}
+	for _, pv := range pvs {
+		server.Add(pv)
+	}
+
+	tests := []struct {
+		claim    string
+		volume   string
+		expected string
+		// err checks the returned error, nil if none is expected
+		err func(error) bool
+	}{
+		{claim: "with-id", volume: "pvc-with-id", expected: "px-123"},
+		{claim: "in-tree", volume: "pvc-in-tree", expected: "pvc-in-tree"},
+		{claim: "annotated", volume: "pvc-annotated", expected: "pvc-annotated"},
+		{
+			claim:  "nfs",
+			volume: "pvc-nfs",
+			err: func(err error) bool {
+				notPx, ok := err.(*ErrNotPortworxVolume)
+				return ok && notPx.PVC == "nfs" && notPx.PV == "pvc-nfs"
+			},
+		},
+		{
+			claim: "unbound",
+			err: func(err error) bool {
+				_, ok := err.(*k8sutils.ErrPVCNotBound)
+				return ok
+			},
+		},
+	}
+
+	k := &k8sSchedOps{}
+	for _, test := range tests {
+		pvc := &v1.PersistentVolumeClaim{
+			ObjectMeta: meta_v1.ObjectMeta{Name: test.claim, Namespace: testNamespace},
+			Spec:       v1.PersistentVolumeClaimSpec{VolumeName: test.volume},
+		}
+		server.Add(pvc)
+
+		id, err := k.ResolvePortworxVolumeID(pvc)
+		if test.err != nil {
+			if !test.err(err) {
+				t.Errorf("%v: unexpected error: %v", test.claim, err)
+			}
+			continue
+		}
+		if err != nil || id != test.expected {
+			t.Errorf("%v: expected volume ID: %v, got: %v, %v", test.claim, test.expected, id, err)
+		}
+	}
+}
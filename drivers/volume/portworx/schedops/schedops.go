@@ -1,36 +1,226 @@
 package schedops
 
 import (
+	"sync"
+	"time"
+
 	"github.com/portworx/torpedo/drivers/node"
 	"github.com/Sirupsen/logrus"
 	"github.com/portworx/torpedo/pkg/errors"
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/plan"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
 )
 
+// NodeInspector runs commands on nodes to check node-local state that the scheduler doesn't expose
+type NodeInspector interface {
+	// RunCommand runs the given command on the given node and returns its output
+	RunCommand(n node.Node, cmd string, timeout time.Duration) (string, error)
+}
+
+// InitOptions are the options of a scheduler operator
+type InitOptions struct {
+	// Inspector is used for node-level checks. Can be nil.
+	Inspector NodeInspector
+	// PxNamespace is the namespace of the portworx scheduler objects, for schedulers with namespaces
+	PxNamespace string
+	// NodeDriverName is the name of the node driver torpedo was configured with
+	NodeDriverName string
+}
+
 // Driver is the interface for portworx operations under various schedulers
 type Driver interface {
+	// Init initializes the operator and validates its prerequisites
+	Init(opts InitOptions) error
+	// String returns the name of the operator
+	String() string
+	// Destroy cleans up the resources the operator created, like node labels
+	Destroy() error
 	// DisableOnNode disabled portworx on given node
 	DisableOnNode(n node.Node) error
 	// ValidateOnNode validates portworx on given node (from scheduler perspective)
 	ValidateOnNode(n node.Node) error
 	// EnableOnNode enabled portworx on given node
-	EnableOnNode(n node.Node) error
+	EnableOnNode(n node.Node, opts ...EnableOption) error
+}
+
+// EnableOption is an option for EnableOnNode
+type EnableOption func(*enableOptions)
+
+type enableOptions struct {
+	waitTimeout time.Duration
+}
+
+// WithWaitForPods makes EnableOnNode wait, up to the given timeout, till portworx and other pods that the
+// scheduler runs on every node are back and ready on the node. Operators whose scheduler doesn't run
+// portworx in pods ignore it.
+func WithWaitForPods(timeout time.Duration) EnableOption {
+	return func(o *enableOptions) {
+		o.waitTimeout = timeout
+	}
+}
+
+func newEnableOptions(opts []EnableOption) *enableOptions {
+	o := &enableOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// InstallationValidator is implemented by scheduler operators that can validate the scheduler objects
+// of a portworx installation
+type InstallationValidator interface {
+	// ValidatePortworxInstallation validates, until the timeout, that the portworx components in the
+	// given namespace exist and are healthy and returns a report of the components
+	ValidatePortworxInstallation(namespace string, timeout time.Duration) (*InstallationReport, error)
+}
+
+// PXPodDeleter is implemented by scheduler operators that can delete portworx pods without putting the
+// portworx quorum at risk
+type PXPodDeleter interface {
+	// DeletePXPods deletes the portworx pods on the given nodes, at most maxUnavailable at a time
+	DeletePXPods(nodes []node.Node, maxUnavailable int, opts ...DeletePXPodsOption) error
+}
+
+// RollingDisabler is implemented by scheduler operators that can validate that an app survives portworx
+// being disabled on nodes one at a time
+type RollingDisabler interface {
+	// RollingNodeDisable disables and re-enables portworx on each of the given nodes in turn and
+	// validates that the given app stays ready. plan.WithDryRun and plan.WithPlan are supported.
+	RollingNodeDisable(
+		nodes []node.Node,
+		app *v1beta1.Deployment,
+		settle time.Duration,
+		opts ...plan.Option,
+	) error
+}
+
+// VolumeIDResolver is implemented by scheduler operators that can map a scheduler volume to the ID of
+// its portworx volume
+type VolumeIDResolver interface {
+	// ResolvePortworxVolumeID returns the ID of the portworx volume of the given PVC
+	ResolvePortworxVolumeID(pvc *v1.PersistentVolumeClaim) (string, error)
+	// ResolvePortworxVolumeIDForPV returns the ID of the portworx volume of the PV with the given name
+	ResolvePortworxVolumeIDForPV(pvName string) (string, error)
+}
+
+// HyperconvergenceValidator is implemented by scheduler operators that can check that app pods run on
+// nodes holding a replica of their volumes
+type HyperconvergenceValidator interface {
+	// ValidateHyperconvergence checks that each pod of the given app runs on a node that holds a replica
+	// of its volumes. volumeNodeLookup returns the names of the nodes holding a replica of the given PV.
+	ValidateHyperconvergence(
+		app *v1beta1.Deployment,
+		volumeNodeLookup func(pvName string) ([]string, error),
+	) (*HyperconvergenceReport, error)
+}
+
+// PXDownPlacementValidator is implemented by scheduler operators that can check that no app pods run on
+// nodes where portworx is down
+type PXDownPlacementValidator interface {
+	// ValidateNoPodsOnPXDownNodes checks that none of the pods matching the selector in the given
+	// namespace run on a node where portworx is stopped or doesn't have a ready portworx pod
+	ValidateNoPodsOnPXDownNodes(namespace, selector string) error
+	// StartNodeHealthRecorder records the changes of the portworx health of the nodes, checked every
+	// interval, until the returned function is called. The function returns the recorded events.
+	StartNodeHealthRecorder(interval time.Duration) (func() []k8sutils.NodeHealthEvent, error)
+}
+
+// HyperconvergenceReport is the placement of the pods of an app relative to the replicas of their volumes
+type HyperconvergenceReport struct {
+	// Pods are the placements of the scheduled pods of the app
+	Pods []PodPlacement
+	// Percentage is the percentage of pods running on a node that holds a replica of all their volumes
+	Percentage float64
+}
+
+// PodPlacement is the placement of a pod relative to the replicas of its volumes
+type PodPlacement struct {
+	// Pod is the name of the pod
+	Pod string
+	// Node is the node the pod runs on
+	Node string
+	// ReplicaNodes maps the PVs of the pod to the nodes holding their replicas
+	ReplicaNodes map[string][]string
+	// Hit is true if the node of the pod holds a replica of all its volumes
+	Hit bool
+}
+
+// InstallationReport is the state of the components of a portworx installation
+type InstallationReport struct {
+	// Components are the checked components
+	Components []ComponentStatus
+	// ReadyPods is the number of ready portworx pods
+	ReadyPods int32
+	// DesiredPods is the number of portworx pods that should be running
+	DesiredPods int32
+}
+
+// Healthy checks if all the components of the installation are healthy
+func (r *InstallationReport) Healthy() bool {
+	for _, c := range r.Components {
+		if !c.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// ComponentStatus is the state of a component of a portworx installation
+type ComponentStatus struct {
+	// Kind is the kind of the component (e.g daemonset)
+	Kind string
+	// Name is the name of the component
+	Name string
+	// Present is true if the component exists
+	Present bool
+	// Healthy is true if the component exists and is ready
+	Healthy bool
+	// Cause is why the component is not healthy
+	Cause string
+}
+
+// DefaultDriver provides no-op implementations of the lifecycle methods of Driver. Operators embed it
+// so that they keep building when lifecycle methods are added to Driver.
+type DefaultDriver struct{}
+
+// Init does nothing
+func (d *DefaultDriver) Init(opts InitOptions) error {
+	return nil
+}
+
+// String returns an empty name
+func (d *DefaultDriver) String() string {
+	return ""
+}
+
+// Destroy does nothing
+func (d *DefaultDriver) Destroy() error {
+	return nil
 }
 
 var (
+	schedOpsLock     sync.RWMutex
 	schedOpsRegistry = make(map[string]Driver)
 )
 
 
-// Register registers the given portworx scheduler operator
+// Register registers the given portworx scheduler operator. It is safe to call concurrently with Get.
 func Register(name string, d Driver) error {
 	logrus.Infof("Registering portworx scheduler operator: %v", name)
+	schedOpsLock.Lock()
+	defer schedOpsLock.Unlock()
 	schedOpsRegistry[name] = d
 	return nil
 }
 
 // Get a driver to perform portworx operations for the given scheduler
 func Get(name string) (Driver, error) {
+	schedOpsLock.RLock()
 	d, ok := schedOpsRegistry[name]
+	schedOpsLock.RUnlock()
 	if ok {
 		return d, nil
 	}
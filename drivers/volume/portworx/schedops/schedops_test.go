@@ -0,0 +1,78 @@
+package schedops
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+)
+
+// TestRegistryConcurrentWithValidators registers and gets scheduler operators while other goroutines change
+// the validation profile and validate a deployment. Run with -race to check that the registry is safe for
+// concurrent use.
+func TestRegistryConcurrentWithValidators(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	k8sutils.SetRestConfig(server.Config())
+	defer k8sutils.SetRestConfig(nil)
+
+	app := newTestApp(server, "node1", "node2")
+
+	// The deployment never gets ready, so the validations time out quickly
+	profile := k8sutils.GetValidationProfile()
+	k8sutils.SetValidationProfile(k8sutils.Profile{Name: "test", AppReadyTimeout: 10 * time.Millisecond})
+	defer func() {
+		k8sutils.SetValidationProfile(profile)
+
+		schedOpsLock.Lock()
+		for i := 0; i < 3; i++ {
+			delete(schedOpsRegistry, fmt.Sprintf("test-%d", i))
+		}
+		schedOpsLock.Unlock()
+	}()
+
+	const iterations = 100
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	run(func(i int) {
+		if err := Register(fmt.Sprintf("test-%d", i%3), &k8sSchedOps{}); err != nil {
+			t.Errorf("failed to register: %v", err)
+		}
+	})
+	run(func(i int) {
+		// The operators may not be registered yet
+		_, _ = Get(fmt.Sprintf("test-%d", i%3))
+	})
+	run(func(i int) {
+		k8sutils.SetValidationProfile(k8sutils.Profile{
+			Name:            fmt.Sprintf("profile-%d", i),
+			AppReadyTimeout: time.Duration(i%3+1) * 10 * time.Millisecond,
+			RetryInterval:   time.Millisecond,
+		})
+	})
+	for i := 0; i < 2; i++ {
+		run(func(i int) {
+			_, _ = k8sutils.ValidateDeploymentWithResult(app)
+		})
+	}
+
+	wg.Wait()
+
+	for i := 0; i < 3; i++ {
+		if _, err := Get(fmt.Sprintf("test-%d", i)); err != nil {
+			t.Errorf("expected test-%d to be registered, got: %v", i, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package volume
 
 import (
+	"time"
+
 	"github.com/Sirupsen/logrus"
 	"github.com/portworx/torpedo/pkg/errors"
 	"github.com/portworx/torpedo/drivers/node"
@@ -11,8 +13,9 @@ import (
 // Torpedo.  The functions defined here are meant to be destructive and illustrative
 // of failure scenarious that can happen with an external storage provider.
 type Driver interface {
-	// Init initializes the volume driver under the given scheduler
-	Init(sched string) error
+	// Init initializes the volume driver under the given scheduler. nodeDriver is the name of the node
+	// driver used to reach the nodes.
+	Init(sched string, nodeDriver string) error
 
 	// String returns the string name of this driver.
 	String() string
@@ -35,6 +38,28 @@ type Driver interface {
 	WaitStart(n node.Node) error
 }
 
+// InstallationValidator is implemented by volume drivers that can validate that they are correctly
+// installed before any test runs
+type InstallationValidator interface {
+	// ValidateInstallation validates, until the timeout, that all the components of the driver are
+	// installed and healthy
+	ValidateInstallation(timeout time.Duration) error
+}
+
+// ParamsVerifier is implemented by volume drivers that can check that a volume provisioned for a PV
+// honors the parameters it was requested with
+type ParamsVerifier interface {
+	// VerifyVolumeParams checks that the volume of the given PV has the given custom volume options
+	VerifyVolumeParams(params map[string]string, pvName string) error
+}
+
+// Destroyer is implemented by volume drivers that create resources that must be cleaned up once torpedo
+// is done
+type Destroyer interface {
+	// Destroy cleans up the resources created by the driver
+	Destroy() error
+}
+
 var (
 	volDrivers = make(map[string]Driver)
 )
@@ -0,0 +1,220 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	blockDeploymentImage     = "busybox"
+	blockDeploymentContainer = "block"
+	blockVolumeName          = "block"
+	blockDeviceCheckTimeout  = 30 * time.Second
+)
+
+// CreateBlockPersistentVolumeClaim creates the given claim with the Block volume mode, for a raw block
+// volume without a file system. An ErrNotSupported is returned if the cluster doesn't support raw block
+// volumes.
+func CreateBlockPersistentVolumeClaim(
+	pvc *v1.PersistentVolumeClaim,
+	opts ...CreateOption,
+) (*v1.PersistentVolumeClaim, error) {
+	if err := checkBlockVolumes("CreateBlockPersistentVolumeClaim"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if pvc.Namespace, err = resolveCreateNamespace(client, pvc.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&pvc.ObjectMeta)
+
+	// The volumeMode field is newer than the vendored API types so the claim is sent as raw JSON
+	body, err := setRawField(pvc, string(PVCVolumeModeBlock), "spec", "volumeMode")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.CoreV1().RESTClient().Post().
+		Namespace(pvc.Namespace).
+		Resource("persistentvolumeclaims").
+		Body(body).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	created := &v1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(data, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// CreateBlockVolumeDeployment creates a single replica deployment with the given name whose container has
+// the raw block volume of the given claim attached as a device at devicePath. An ErrNotSupported is
+// returned if the cluster doesn't support raw block volumes.
+func CreateBlockVolumeDeployment(
+	name string,
+	pvc *v1.PersistentVolumeClaim,
+	devicePath string,
+	opts ...CreateOption,
+) (*v1beta1.Deployment, error) {
+	if err := checkBlockVolumes("CreateBlockVolumeDeployment"); err != nil {
+		return nil, err
+	}
+
+	if err := checkAppsV1beta1("CreateBlockVolumeDeployment"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := resolveCreateNamespace(client, pvc.Namespace, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment := buildBlockVolumeDeployment(namespace, name, pvc.Name)
+	stampInstanceLabel(&deployment.ObjectMeta)
+
+	// The volumeDevices field is newer than the vendored API types so the deployment is sent as raw JSON
+	body, err := setRawField(deployment, []map[string]string{
+		{
+			"name":       blockVolumeName,
+			"devicePath": devicePath,
+		},
+	}, "spec", "template", "spec", "containers", "0", "volumeDevices")
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.AppsV1beta1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("deployments").
+		Body(body).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+
+	created := &v1beta1.Deployment{}
+	if err := json.Unmarshal(data, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// ValidateBlockDeviceInPod validates that devicePath is a block device in the first container of the pod
+func ValidateBlockDeviceInPod(pod *v1.Pod, devicePath string) error {
+	if len(pod.Spec.Containers) == 0 {
+		return fmt.Errorf("pod: %v has no containers", pod.Name)
+	}
+
+	cmd := []string{"test", "-b", devicePath}
+	if _, _, err := execInPod(*pod, pod.Spec.Containers[0].Name, cmd, blockDeviceCheckTimeout); err != nil {
+		return fmt.Errorf("%v is not a block device in pod: %v. Err: %v", devicePath, pod.Name, err)
+	}
+
+	return nil
+}
+
+func buildBlockVolumeDeployment(namespace, name, claimName string) *v1beta1.Deployment {
+	replicas := int32(1)
+	labels := map[string]string{"app": name}
+	return &v1beta1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Strategy: v1beta1.DeploymentStrategy{
+				Type: v1beta1.RecreateDeploymentStrategyType,
+			},
+			Selector: &meta_v1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Name:            blockDeploymentContainer,
+							Image:           blockDeploymentImage,
+							ImagePullPolicy: v1.PullIfNotPresent,
+							Command:         []string{"sleep", "1000000"},
+						},
+					},
+					Volumes: []v1.Volume{
+						{
+							Name: blockVolumeName,
+							VolumeSource: v1.VolumeSource{
+								PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+									ClaimName: claimName,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// setRawField marshals the object to JSON and sets the field at the given path, of map keys and list
+// indexes, to value
+func setRawField(obj interface{}, value interface{}, path ...string) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	current := raw
+	for i, key := range path {
+		last := i == len(path)-1
+		switch node := current.(type) {
+		case map[string]interface{}:
+			if last {
+				node[key] = value
+				break
+			}
+			next, ok := node[key]
+			if !ok {
+				next = make(map[string]interface{})
+				node[key] = next
+			}
+			current = next
+		case []interface{}:
+			var index int
+			if _, err := fmt.Sscanf(key, "%d", &index); err != nil || index >= len(node) || last {
+				return nil, fmt.Errorf("invalid path: %v", path)
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("invalid path: %v", path)
+		}
+	}
+
+	return json.Marshal(raw)
+}
@@ -0,0 +1,198 @@
+package k8sutils
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// configMapReadTimeout is the timeout of reading a config map key from a pod
+const configMapReadTimeout = 30 * time.Second
+
+// CreateConfigMap creates the given config map
+func CreateConfigMap(configMap *v1.ConfigMap, opts ...CreateOption) (*v1.ConfigMap, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if configMap.Namespace, err = resolveCreateNamespace(client, configMap.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&configMap.ObjectMeta)
+
+	return client.CoreV1().ConfigMaps(configMap.Namespace).Create(configMap)
+}
+
+// GetConfigMap returns the current state of the config map with the given name and namespace
+func GetConfigMap(name, namespace string) (*v1.ConfigMap, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().ConfigMaps(namespaceOrDefault(namespace)).Get(name, meta_v1.GetOptions{})
+}
+
+// UpdateConfigMap updates the data of the existing config map to match the given config map. The update is
+// retried on conflicts against the latest version of the config map.
+func UpdateConfigMap(configMap *v1.ConfigMap) (*v1.ConfigMap, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := namespaceOrDefault(configMap.Namespace)
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var current, result *v1.ConfigMap
+		current, err = client.CoreV1().ConfigMaps(namespace).Get(configMap.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		current.Data = configMap.Data
+		if result, err = client.CoreV1().ConfigMaps(namespace).Update(current); err == nil ||
+			!k8s_errors.IsConflict(err) {
+			return result, err
+		}
+	}
+
+	return nil, err
+}
+
+// DeleteConfigMap deletes the given config map. An ErrNotOwned is returned if another torpedo instance
+// created it, unless WithForceDelete is given.
+func DeleteConfigMap(configMap *v1.ConfigMap, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteConfigMap"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(configMap.Namespace)
+	if current, err := client.CoreV1().ConfigMaps(namespace).Get(configMap.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("config map", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	return client.CoreV1().ConfigMaps(namespace).Delete(configMap.Name, &meta_v1.DeleteOptions{})
+}
+
+// WaitForConfigMapPropagation waits for the data of the given config map, e.g as returned by
+// UpdateConfigMap, to be visible in the volumes the given pods mount it in. The files are read with cat
+// from the first container mounting the volume. Pods that don't mount the config map, or only with a
+// subPath which the kubelet never updates, fail the validation. It waits up to the given timeout or the app
+// ready timeout of the validation profile if zero, as the kubelet only refreshes config map volumes on its
+// periodic sync.
+func WaitForConfigMapPropagation(configMap *v1.ConfigMap, pods []v1.Pod, timeout time.Duration) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppReadyTimeout
+	}
+
+	namespace := namespaceOrDefault(configMap.Namespace)
+	t := func() error {
+		stale := make(map[string]string)
+		for _, pod := range pods {
+			if cause := checkConfigMapPropagated(client, configMap, pod); len(cause) > 0 {
+				stale[pod.Name] = cause
+			}
+		}
+
+		if len(stale) > 0 {
+			return &ErrConfigMapNotPropagated{
+				ConfigMap: fmt.Sprintf("%v/%v", namespace, configMap.Name),
+				Pods:      stale,
+			}
+		}
+		return nil
+	}
+
+	t, complete := observeValidation("configmap-propagation/"+configMap.Name, t)
+	err = doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	complete(err)
+	return err
+}
+
+// checkConfigMapPropagated returns why the given pod doesn't see the data of the config map or an empty
+// string if it does
+func checkConfigMapPropagated(client *kubernetes.Clientset, configMap *v1.ConfigMap, pod v1.Pod) string {
+	current, err := client.CoreV1().Pods(pod.Namespace).Get(pod.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err.Error()
+	}
+
+	mounted := false
+	for _, volume := range current.Spec.Volumes {
+		if volume.ConfigMap == nil || volume.ConfigMap.Name != configMap.Name {
+			continue
+		}
+
+		container, mountPath := getVolumeMount(current, volume.Name)
+		if len(container) == 0 {
+			continue
+		}
+		mounted = true
+
+		for key, relPath := range getConfigMapVolumePaths(volume.ConfigMap, configMap) {
+			file := path.Join(mountPath, relPath)
+			stdout, stderr, err := execInPod(*current, container, []string{"cat", file}, configMapReadTimeout)
+			if err != nil {
+				return fmt.Sprintf("failed to read: %v. Err: %v. Output: %v", file, err, stderr)
+			}
+			if stdout != configMap.Data[key] {
+				return fmt.Sprintf("key: %v in %v has stale content", key, file)
+			}
+		}
+	}
+
+	if !mounted {
+		return "config map is not mounted in a volume without a subPath"
+	}
+	return ""
+}
+
+// getVolumeMount returns the first container of the pod mounting the whole given volume and its mount path
+func getVolumeMount(pod *v1.Pod, volumeName string) (string, string) {
+	for _, container := range pod.Spec.Containers {
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == volumeName && len(mount.SubPath) == 0 {
+				return container.Name, mount.MountPath
+			}
+		}
+	}
+	return "", ""
+}
+
+// getConfigMapVolumePaths maps the keys of the config map projected in the volume to their path in it
+func getConfigMapVolumePaths(source *v1.ConfigMapVolumeSource, configMap *v1.ConfigMap) map[string]string {
+	paths := make(map[string]string)
+	if len(source.Items) == 0 {
+		for key := range configMap.Data {
+			paths[key] = key
+		}
+		return paths
+	}
+
+	for _, item := range source.Items {
+		if _, ok := configMap.Data[item.Key]; ok {
+			paths[item.Key] = path.Clean(item.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,123 @@
+package k8sutils
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+type continuousValidation struct {
+	deployment  *v1beta1.Deployment
+	interval    time.Duration
+	transitions []ValidationTransition
+	quit        chan struct{}
+	done        chan struct{}
+}
+
+// StartContinuousValidation checks in the background, every interval, if the given deployment is ready
+// using the same checks as ValidateDeployement and records every change of readiness. The returned
+// function stops the validation, waits for the background checks to exit and returns the history.
+func StartContinuousValidation(deployment *v1beta1.Deployment, interval time.Duration) func() ValidationHistory {
+	c := &continuousValidation{
+		deployment: deployment,
+		interval:   interval,
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	go c.run()
+
+	var once sync.Once
+	var history ValidationHistory
+	return func() ValidationHistory {
+		once.Do(func() {
+			close(c.quit)
+			<-c.done
+			history = c.history(time.Now())
+		})
+		return history
+	}
+}
+
+func (c *continuousValidation) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		c.check()
+
+		select {
+		case <-c.quit:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *continuousValidation) check() {
+	report := &DeploymentStatusReport{
+		PodNodes: make(map[string]string),
+	}
+
+	transition := ValidationTransition{
+		Time:  time.Now(),
+		Ready: true,
+	}
+	if err := checkDeploymentReady(c.deployment, report, &validateOptions{}); err != nil {
+		transition.Ready = false
+		transition.Cause = err.Error()
+	}
+
+	if len(c.transitions) > 0 {
+		last := c.transitions[len(c.transitions)-1]
+		if last.Ready == transition.Ready && last.Cause == transition.Cause {
+			return
+		}
+	}
+
+	c.transitions = append(c.transitions, transition)
+}
+
+// history builds the validation history. An outage that is still ongoing is counted until the given time.
+func (c *continuousValidation) history(end time.Time) ValidationHistory {
+	h := ValidationHistory{
+		Transitions: c.transitions,
+	}
+
+	seenCauses := make(map[string]bool)
+	var outageStart *time.Time
+	for i := range c.transitions {
+		transition := c.transitions[i]
+		if transition.Ready {
+			if outageStart != nil {
+				h.addOutage(transition.Time.Sub(*outageStart))
+				outageStart = nil
+			}
+			continue
+		}
+
+		if outageStart == nil {
+			outageStart = &transition.Time
+		}
+		if !seenCauses[transition.Cause] {
+			seenCauses[transition.Cause] = true
+			h.Causes = append(h.Causes, transition.Cause)
+		}
+	}
+
+	if outageStart != nil {
+		h.addOutage(end.Sub(*outageStart))
+	}
+
+	return h
+}
+
+func (h *ValidationHistory) addOutage(outage time.Duration) {
+	h.TotalDowntime += outage
+	if outage > h.LongestOutage {
+		h.LongestOutage = outage
+	}
+}
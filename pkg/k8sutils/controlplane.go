@@ -0,0 +1,130 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// controlPlanePodSelectors are the selectors of the kube-system pods of the control plane components
+// checked when the componentstatuses API is not served
+var controlPlanePodSelectors = map[string]string{
+	"scheduler":          "component=kube-scheduler",
+	"controller-manager": "component=kube-controller-manager",
+	"etcd":               "component=etcd",
+	"dns":                "k8s-app=kube-dns",
+}
+
+// GetComponentStatuses returns the health of the control plane components from the componentstatuses
+// API. On servers that don't serve it, the readiness of the scheduler, controller manager, etcd and dns
+// pods in kube-system is returned instead. Components that don't run as pods, as on managed clusters,
+// are then not reported.
+func GetComponentStatuses() ([]ComponentHealth, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses, err := client.CoreV1().ComponentStatuses().List(meta_v1.ListOptions{})
+	if err == nil && len(statuses.Items) > 0 {
+		var components []ComponentHealth
+		for _, status := range statuses.Items {
+			components = append(components, getComponentHealth(status))
+		}
+		return components, nil
+	}
+
+	if err != nil && !k8s_errors.IsNotFound(err) && !k8s_errors.IsForbidden(err) {
+		return nil, err
+	}
+
+	return getControlPlanePodsHealth(client)
+}
+
+// ValidateControlPlaneHealthy validates that all the control plane components are healthy. An
+// ErrControlPlaneUnhealthy lists the failing components.
+func ValidateControlPlaneHealthy() error {
+	components, err := GetComponentStatuses()
+	if err != nil {
+		return err
+	}
+
+	unhealthy := make(map[string]string)
+	for _, c := range components {
+		if !c.Healthy {
+			unhealthy[c.Name] = c.Message
+		}
+	}
+
+	if len(unhealthy) > 0 {
+		return &ErrControlPlaneUnhealthy{
+			Components: unhealthy,
+		}
+	}
+
+	return nil
+}
+
+func getComponentHealth(status v1.ComponentStatus) ComponentHealth {
+	health := ComponentHealth{
+		Name:    status.Name,
+		Message: "no healthy condition",
+	}
+
+	for _, condition := range status.Conditions {
+		if condition.Type != v1.ComponentHealthy {
+			continue
+		}
+
+		health.Healthy = condition.Status == v1.ConditionTrue
+		health.Message = condition.Message
+		if len(condition.Error) > 0 {
+			health.Message = condition.Error
+		}
+	}
+
+	return health
+}
+
+func getControlPlanePodsHealth(client *kubernetes.Clientset) ([]ComponentHealth, error) {
+	var names []string
+	for name := range controlPlanePodSelectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var components []ComponentHealth
+	for _, name := range names {
+		pods, err := client.CoreV1().Pods(meta_v1.NamespaceSystem).List(meta_v1.ListOptions{
+			LabelSelector: controlPlanePodSelectors[name],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pods.Items) == 0 {
+			continue
+		}
+
+		health := ComponentHealth{Name: name}
+		var notReady []string
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == v1.PodRunning && isPodReady(pod) {
+				health.Healthy = true
+			} else {
+				notReady = append(notReady, fmt.Sprintf("%v: %v", pod.Name, pod.Status.Phase))
+			}
+		}
+		if !health.Healthy {
+			health.Message = fmt.Sprintf("no ready pod. Pods: %v", notReady)
+		}
+
+		components = append(components, health)
+	}
+
+	return components, nil
+}
@@ -0,0 +1,127 @@
+package k8sutils
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// newControlPlanePod returns a kube-system pod of a control plane component
+func newControlPlanePod(name, selector string, phase v1.PodPhase) *v1.Pod {
+	parts := strings.SplitN(selector, "=", 2)
+	pod := &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: meta_v1.NamespaceSystem,
+			Labels:    map[string]string{parts[0]: parts[1]},
+		},
+		Status: v1.PodStatus{Phase: phase},
+	}
+	if phase == v1.PodRunning {
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	}
+	return pod
+}
+
+// withoutComponentStatuses makes the server answer as the clusters that don't serve componentstatuses
+func withoutComponentStatuses(server *k8stest.Server) {
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource == "componentstatuses" {
+			return true, http.StatusNotFound, k8stest.Status(http.StatusNotFound, "NotFound", "the server could not find the requested resource")
+		}
+		return false, 0, nil
+	})
+}
+
+func TestGetComponentStatuses(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.Add(
+		&v1.ComponentStatus{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "scheduler"},
+			Conditions: []v1.ComponentCondition{{Type: v1.ComponentHealthy, Status: v1.ConditionTrue, Message: "ok"}},
+		},
+		&v1.ComponentStatus{
+			ObjectMeta: meta_v1.ObjectMeta{Name: "etcd-0"},
+			Conditions: []v1.ComponentCondition{{
+				Type:   v1.ComponentHealthy,
+				Status: v1.ConditionFalse,
+				Error:  "connection refused",
+			}},
+		},
+	)
+
+	components, err := GetComponentStatuses()
+	if err != nil {
+		t.Fatalf("failed to get the component statuses: %v", err)
+	}
+	expected := []ComponentHealth{
+		{Name: "etcd-0", Message: "connection refused"},
+		{Name: "scheduler", Healthy: true, Message: "ok"},
+	}
+	if !reflect.DeepEqual(components, expected) {
+		t.Errorf("expected the components: %+v, got: %+v", expected, components)
+	}
+}
+
+func TestGetComponentStatusesFromPods(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	// etcd doesn't run as a pod, as on managed clusters
+	withoutComponentStatuses(server)
+	server.Add(
+		newControlPlanePod("kube-scheduler-master", controlPlanePodSelectors["scheduler"], v1.PodRunning),
+		newControlPlanePod("kube-controller-manager-master", controlPlanePodSelectors["controller-manager"], v1.PodPending),
+		newControlPlanePod("kube-dns-1", controlPlanePodSelectors["dns"], v1.PodFailed),
+		newControlPlanePod("kube-dns-2", controlPlanePodSelectors["dns"], v1.PodRunning),
+	)
+
+	components, err := GetComponentStatuses()
+	if err != nil {
+		t.Fatalf("failed to get the component statuses: %v", err)
+	}
+	expected := []ComponentHealth{
+		{Name: "controller-manager", Message: "no ready pod. Pods: [kube-controller-manager-master: Pending]"},
+		{Name: "dns", Healthy: true},
+		{Name: "scheduler", Healthy: true},
+	}
+	if !reflect.DeepEqual(components, expected) {
+		t.Errorf("expected the components: %+v, got: %+v", expected, components)
+	}
+
+	err = ValidateControlPlaneHealthy()
+	unhealthy, ok := err.(*ErrControlPlaneUnhealthy)
+	if !ok || len(unhealthy.Components) != 1 || len(unhealthy.Components["controller-manager"]) == 0 {
+		t.Fatalf("expected an ErrControlPlaneUnhealthy for the controller manager, got: %v", err)
+	}
+
+	_, err = WaitForClusterSettle([]string{testNamespace}, 0, 0)
+	notSettled, ok := err.(*ErrClusterNotSettled)
+	if !ok || len(notSettled.Unsettled) != 1 ||
+		!strings.HasPrefix(notSettled.Unsettled[0], "component/controller-manager: ") {
+		t.Fatalf("expected the controller manager to keep the cluster from settling, got: %v", err)
+	}
+}
+
+func TestGetComponentStatusesErrors(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource == "componentstatuses" {
+			return true, http.StatusInternalServerError, k8stest.Status(http.StatusInternalServerError, "InternalError", "etcd timeout")
+		}
+		return false, 0, nil
+	})
+
+	if _, err := GetComponentStatuses(); err == nil {
+		t.Errorf("expected the componentstatuses error to be returned")
+	}
+}
@@ -0,0 +1,165 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// CreateDaemonSet creates the given daemonset
+func CreateDaemonSet(ds *ext_v1beta1.DaemonSet, opts ...CreateOption) (*ext_v1beta1.DaemonSet, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if ds.Namespace, err = resolveCreateNamespace(client, ds.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&ds.ObjectMeta)
+	stampInstanceLabel(&ds.Spec.Template.ObjectMeta)
+
+	return client.ExtensionsV1beta1().DaemonSets(ds.Namespace).Create(ds)
+}
+
+// GetDaemonSetPods returns the pods of the given daemonset, i.e the pods matching its selector that it owns
+func GetDaemonSetPods(ds *ext_v1beta1.DaemonSet) ([]v1.Pod, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return getDaemonSetPods(client, ds)
+}
+
+// ValidateDaemonSet validates that the given daemonset has observed its latest spec, that a pod is ready
+// on every node it is scheduled to and that none of its pods is pending, terminating or crash looping. It
+// waits up to the given timeout or the app ready timeout of the validation profile if zero.
+func ValidateDaemonSet(ds *ext_v1beta1.DaemonSet, timeout time.Duration) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppReadyTimeout
+	}
+
+	namespace := namespaceOrDefault(ds.Namespace)
+	t := func() error {
+		current, err := client.ExtensionsV1beta1().DaemonSets(namespace).Get(ds.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		return checkDaemonSetReady(client, current)
+	}
+
+	t, complete := observeValidation("daemonset/"+ds.Name, t)
+	err = doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	complete(err)
+	return err
+}
+
+// DeleteDaemonSet deletes the given daemonset and its pods. An ErrNotOwned is returned if another torpedo
+// instance created it, unless WithForceDelete is given.
+func DeleteDaemonSet(ds *ext_v1beta1.DaemonSet, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteDaemonSet"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(ds.Namespace)
+	if current, err := client.ExtensionsV1beta1().DaemonSets(namespace).Get(ds.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("daemonset", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	policy := meta_v1.DeletePropagationForeground
+	return client.ExtensionsV1beta1().DaemonSets(namespace).Delete(ds.Name, &meta_v1.DeleteOptions{
+		PropagationPolicy: &policy,
+	})
+}
+
+// ValidateTerminatedDaemonSet waits for the given daemonset and its pods to be deleted, up to the given
+// timeout or the app delete timeout of the validation profile if zero
+func ValidateTerminatedDaemonSet(ds *ext_v1beta1.DaemonSet, timeout time.Duration) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(ds.Namespace)
+	return validateTerminatedController(client, namespace, "DaemonSet", ds.Name, ds.UID, timeout,
+		func() (meta_v1.ObjectMeta, string, error) {
+			current, err := client.ExtensionsV1beta1().DaemonSets(namespace).Get(ds.Name, meta_v1.GetOptions{})
+			if err != nil {
+				return meta_v1.ObjectMeta{}, "", err
+			}
+			return current.ObjectMeta, fmt.Sprintf("%d scheduled pods", current.Status.CurrentNumberScheduled), nil
+		})
+}
+
+// checkDaemonSetReady checks once if the given live daemonset and its pods are ready
+func checkDaemonSetReady(client *kubernetes.Clientset, ds *ext_v1beta1.DaemonSet) error {
+	if ds.Status.ObservedGeneration < ds.Generation {
+		return &ErrAppNotReady{
+			ID:    ds.Name,
+			Cause: fmt.Sprintf("daemonset controller has not observed generation: %v yet", ds.Generation),
+		}
+	}
+
+	desired := ds.Status.DesiredNumberScheduled
+	if ds.Status.NumberReady != desired {
+		return &ErrAppNotReady{
+			ID:    ds.Name,
+			Cause: fmt.Sprintf("Expected ready pods: %v Ready pods: %v", desired, ds.Status.NumberReady),
+		}
+	}
+
+	pods, err := getDaemonSetPods(client, ds)
+	if err != nil {
+		return err
+	}
+
+	var ready int32
+	for _, pod := range pods {
+		if reason := getPodUnsettledReason(pod); len(reason) > 0 {
+			return &ErrAppNotReady{
+				ID:    ds.Name,
+				Cause: fmt.Sprintf("pod: %v on node: %v is %v", pod.Name, pod.Spec.NodeName, reason),
+			}
+		}
+		if pod.Status.Phase != v1.PodRunning || !isPodReady(pod) {
+			return &ErrAppNotReady{
+				ID:    ds.Name,
+				Cause: fmt.Sprintf("pod: %v on node: %v is not yet ready", pod.Name, pod.Spec.NodeName),
+			}
+		}
+		ready++
+	}
+
+	if ready != desired {
+		return &ErrAppNotReady{
+			ID:    ds.Name,
+			Cause: fmt.Sprintf("Expected ready pods: %v Ready pods found: %v", desired, ready),
+		}
+	}
+
+	return nil
+}
+
+func getDaemonSetPods(client *kubernetes.Clientset, ds *ext_v1beta1.DaemonSet) ([]v1.Pod, error) {
+	return getOwnedPods(client, ds.Namespace, "DaemonSet", ds.Name, ds.UID, ds.Spec.Selector)
+}
@@ -0,0 +1,90 @@
+package k8sutils
+
+import (
+	"math"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// pendingPodsKey is the key of the pending pods in a pod density report
+const pendingPodsKey = ""
+
+// GetPodDensityReport returns the number of running pods matching the selector in the given namespace
+// on each node. Pods that are pending without a node are counted under the "" key.
+func GetPodDensityReport(namespace, selector string) (map[string]int, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := listAllPods(client, namespaceOrDefault(namespace), meta_v1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := make(map[string]int)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		switch {
+		case pod.Status.Phase == v1.PodRunning:
+			report[pod.Spec.NodeName]++
+		case pod.Status.Phase == v1.PodPending && len(pod.Spec.NodeName) == 0:
+			report[pendingPodsKey]++
+		}
+	}
+
+	return report, nil
+}
+
+// ValidateBalancedPlacement validates that the number of pods of each schedulable and ready worker node
+// in the given density report doesn't deviate from the mean by more than the tolerance fraction of the
+// mean. Nodes without pods count as having none. Cordoned and not ready nodes are not expected to have
+// pods and are ignored.
+func ValidateBalancedPlacement(report map[string]int, tolerance float64) error {
+	workers, err := GetWorkerNodes()
+	if err != nil {
+		return err
+	}
+
+	var expected []string
+	for _, node := range workers {
+		if node.Spec.Unschedulable || IsNodeReady(node.Name) != nil {
+			continue
+		}
+		expected = append(expected, node.Name)
+	}
+
+	if len(expected) == 0 {
+		return nil
+	}
+
+	total := 0
+	for _, name := range expected {
+		total += report[name]
+	}
+	mean := float64(total) / float64(len(expected))
+
+	deviating := make(map[string]int)
+	for _, name := range expected {
+		if math.Abs(float64(report[name])-mean) > tolerance*mean {
+			deviating[name] = report[name]
+		}
+	}
+
+	if len(deviating) > 0 {
+		return &ErrUnbalancedPlacement{
+			Mean:      mean,
+			Tolerance: tolerance,
+			Deviating: deviating,
+			Pending:   report[pendingPodsKey],
+		}
+	}
+
+	return nil
+}
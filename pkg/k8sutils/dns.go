@@ -0,0 +1,128 @@
+package k8sutils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// nslookupAddressRegex matches the addresses in the answer section of busybox nslookup output, in both
+// the "Address 1: <ip> <name>" and "Address: <ip>" formats
+var nslookupAddressRegex = regexp.MustCompile(`Address(?: \d+)?:\s+([0-9a-fA-F.:]+)`)
+
+// ValidateStatefulSetDNS validates, from a prober pod in the namespace of the statefulset, that the DNS
+// record of each replica (<pod>.<service>.<namespace>.svc.cluster.local) resolves to the current IP of
+// the replica's pod. Records are checked until the timeout to allow for the kube-dns TTL.
+func ValidateStatefulSetDNS(ss *v1beta1.StatefulSet, timeout time.Duration) (err error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	current, err := client.AppsV1beta1().StatefulSets(ss.Namespace).Get(ss.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if len(current.Spec.ServiceName) == 0 {
+		return fmt.Errorf("statefulset: %v does not have a governing service", ss.Name)
+	}
+
+	prober, cleanup, err := startProberPod(ss.Namespace, ss.Name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cleanupErr := cleanup(); cleanupErr != nil && err == nil {
+			err = cleanupErr
+		}
+	}()
+
+	var replicas int32 = 1
+	if current.Spec.Replicas != nil {
+		replicas = *current.Spec.Replicas
+	}
+
+	t := func() error {
+		stale := make(map[int32]string)
+		for ordinal := int32(0); ordinal < replicas; ordinal++ {
+			podName := fmt.Sprintf("%v-%d", current.Name, ordinal)
+			if cause := checkPodDNSRecord(*prober, current, podName); len(cause) > 0 {
+				stale[ordinal] = cause
+			}
+		}
+
+		if len(stale) > 0 {
+			return &ErrStaleDNSRecords{
+				StatefulSet: current.Name,
+				Ordinals:    stale,
+			}
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+		if staleErr, ok := task.LastError(err).(*ErrStaleDNSRecords); ok {
+			return staleErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkPodDNSRecord resolves the DNS record of the given statefulset pod and returns why it does
+// not match the pod's IP. An empty string is returned if the record is up to date.
+func checkPodDNSRecord(prober v1.Pod, ss *v1beta1.StatefulSet, podName string) string {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err.Error()
+	}
+
+	pod, err := client.CoreV1().Pods(ss.Namespace).Get(podName, meta_v1.GetOptions{})
+	if err != nil {
+		return fmt.Sprintf("failed to get pod: %v", err)
+	}
+
+	if len(pod.Status.PodIP) == 0 {
+		return "pod does not have an IP yet"
+	}
+
+	host := fmt.Sprintf("%v.%v.%v.svc.cluster.local", podName, ss.Spec.ServiceName, ss.Namespace)
+	stdout, stderr, err := execInPod(prober, proberPodContainer, []string{"nslookup", host}, time.Minute)
+	if err != nil {
+		return fmt.Sprintf("failed to resolve: %v: %v %v", host, err, strings.TrimSpace(stderr))
+	}
+
+	addresses := parseNslookupAddresses(stdout)
+	for _, address := range addresses {
+		if address == pod.Status.PodIP {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("%v resolves to: %v. Pod IP: %v", host, addresses, pod.Status.PodIP)
+}
+
+// parseNslookupAddresses returns the addresses that the name resolved to, skipping the address of the
+// DNS server that is printed first
+func parseNslookupAddresses(output string) []string {
+	idx := strings.Index(output, "Name:")
+	if idx < 0 {
+		return nil
+	}
+
+	var addresses []string
+	for _, match := range nslookupAddressRegex.FindAllStringSubmatch(output[idx:], -1) {
+		addresses = append(addresses, match[1])
+	}
+	return addresses
+}
@@ -0,0 +1,233 @@
+package k8sutils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// failoverProbeTimeoutFraction is the fraction of the interval a probe is given to return
+	failoverProbeTimeoutFraction = 0.8
+
+	failoverErrorTimeout = "Timeout"
+	failoverErrorExec    = "ExecFailed"
+)
+
+// errFailoverProbeTimeout is the failure of a probe that didn't return within its timeout
+var errFailoverProbeTimeout = errors.New("probe did not return in time")
+
+// FailoverMeasurement probes an app at a fixed interval while a fault is injected and records when the
+// probes failed and recovered relative to the marked chaos events
+type FailoverMeasurement struct {
+	lock     sync.Mutex
+	probe    FailoverProbe
+	interval time.Duration
+	report   FailoverReport
+	// outage is the index of the ongoing outage in the report. -1 if the app is up.
+	outage int
+	// pending receives the result of a probe that didn't return within its timeout. Only used by run.
+	pending chan error
+	quit    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewHTTPServiceProbe returns a probe sending an HTTP GET request for path to the service from a prober
+// pod in its namespace, like ProbeServiceFromCluster. The returned function deletes the prober pod.
+func NewHTTPServiceProbe(svc *v1.Service, path string, expectedCode int) (FailoverProbe, func() error, error) {
+	if len(svc.Spec.Ports) == 0 {
+		return nil, nil, fmt.Errorf("service: %v/%v does not expose any ports", svc.Namespace, svc.Name)
+	}
+
+	pod, cleanup, err := startProberPod(svc.Namespace, svc.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	host := fmt.Sprintf("%v.%v.svc.cluster.local", svc.Name, svc.Namespace)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("http://%v:%d%v", host, svc.Spec.Ports[0].Port, path)
+
+	probe := func(timeout time.Duration) error {
+		seconds := int(timeout.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		cmd := []string{"wget", "-S", "-O", "/dev/null", "-T", strconv.Itoa(seconds), url}
+		stdout, stderr, execErr := execInPod(*pod, proberPodContainer, cmd, timeout)
+		_, err := classifyProbeOutput(host, stdout+stderr, execErr, expectedCode)
+		return err
+	}
+
+	return probe, cleanup, nil
+}
+
+// NewExecProbe returns a probe running the given command in the container of the pod. The probe fails if
+// the command exits with a non-zero code.
+func NewExecProbe(pod v1.Pod, container string, cmd []string) FailoverProbe {
+	return func(timeout time.Duration) error {
+		_, _, err := execInPod(pod, container, cmd, timeout)
+		return err
+	}
+}
+
+// StartFailoverMeasurement starts probing the app every interval. Each probe is given less than the
+// interval to return so that a hung probe doesn't delay the next ones; a probe that doesn't return in time
+// counts as failed, and so do the next intervals until it returns, instead of piling up more probes.
+func StartFailoverMeasurement(probe FailoverProbe, interval time.Duration) *FailoverMeasurement {
+	m := &FailoverMeasurement{
+		probe:    probe,
+		interval: interval,
+		outage:   -1,
+		report: FailoverReport{
+			Start:  time.Now(),
+			Errors: make(map[string]int),
+		},
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go m.run()
+	return m
+}
+
+// Mark records an event of the chaos timeline, e.g when the fault was injected
+func (m *FailoverMeasurement) Mark(label string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.report.Marks = append(m.report.Marks, FailoverMark{
+		Label: label,
+		Time:  time.Now(),
+	})
+}
+
+// Stop stops probing and returns the report of the measurement
+func (m *FailoverMeasurement) Stop() FailoverReport {
+	m.once.Do(func() {
+		close(m.quit)
+		<-m.done
+	})
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	report := m.report
+	if report.End.IsZero() {
+		report.End = time.Now()
+	}
+
+	report.TotalDowntime = 0
+	for _, outage := range report.Outages {
+		end := outage.End
+		if end.IsZero() {
+			end = report.End
+		}
+		report.TotalDowntime += end.Sub(outage.Start)
+	}
+
+	report.DetectionLatency = 0
+	if len(report.Marks) > 0 {
+		mark := report.Marks[0].Time
+		for _, outage := range report.Outages {
+			if !outage.Start.Before(mark) {
+				report.DetectionLatency = outage.Start.Sub(mark)
+				break
+			}
+		}
+	}
+
+	m.report = report
+	return report
+}
+
+func (m *FailoverMeasurement) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	timeout := time.Duration(float64(m.interval) * failoverProbeTimeoutFraction)
+	for {
+		start := time.Now()
+		m.record(start, m.probeWithTimeout(timeout))
+
+		select {
+		case <-m.quit:
+			m.lock.Lock()
+			m.report.End = time.Now()
+			m.lock.Unlock()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeWithTimeout runs the probe and returns a timeout error if it doesn't return within the timeout. A
+// new probe is only started once the previous one returned.
+func (m *FailoverMeasurement) probeWithTimeout(timeout time.Duration) error {
+	if m.pending != nil {
+		select {
+		case <-m.pending:
+			m.pending = nil
+		default:
+			return errFailoverProbeTimeout
+		}
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- m.probe(timeout)
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(timeout):
+		m.pending = result
+		return errFailoverProbeTimeout
+	}
+}
+
+func (m *FailoverMeasurement) record(at time.Time, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.report.Probes++
+	if err == nil {
+		if m.outage >= 0 {
+			m.report.Outages[m.outage].End = at
+			m.outage = -1
+		}
+		return
+	}
+
+	m.report.Failures++
+	m.report.Errors[classifyFailoverError(err)]++
+	if m.outage < 0 {
+		m.report.Outages = append(m.report.Outages, Outage{Start: at})
+		m.outage = len(m.report.Outages) - 1
+	}
+}
+
+func classifyFailoverError(err error) string {
+	if err == errFailoverProbeTimeout {
+		return failoverErrorTimeout
+	}
+
+	switch e := err.(type) {
+	case *ErrServiceProbeFailed:
+		return string(e.Reason)
+	case *ErrFailedToExecInPod:
+		return failoverErrorExec
+	default:
+		return string(ProbeFailureOther)
+	}
+}
@@ -0,0 +1,51 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailoverMeasurementDoesNotPileUpHungProbes(t *testing.T) {
+	release := make(chan struct{})
+	var started int32
+	probe := func(timeout time.Duration) error {
+		atomic.AddInt32(&started, 1)
+		<-release
+		return nil
+	}
+
+	m := StartFailoverMeasurement(probe, 10*time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	close(release)
+	report := m.Stop()
+
+	if got := atomic.LoadInt32(&started); got != 1 && got != 2 {
+		t.Errorf("expected the hung probe to block new probes, %v were started", got)
+	}
+	if report.Errors[failoverErrorTimeout] == 0 {
+		t.Errorf("expected the intervals of the hung probe to count as timeouts, got: %v", report.Errors)
+	}
+	if len(report.Outages) == 0 {
+		t.Errorf("expected an outage while the probe was hung")
+	}
+}
+
+func TestClassifyFailoverError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{err: errFailoverProbeTimeout, expected: failoverErrorTimeout},
+		{err: &ErrServiceProbeFailed{Reason: ProbeFailureDNS}, expected: string(ProbeFailureDNS)},
+		{err: &ErrFailedToExecInPod{Pod: "prober", ExitCode: 1}, expected: failoverErrorExec},
+		{err: fmt.Errorf("unknown"), expected: string(ProbeFailureOther)},
+	}
+
+	for _, test := range tests {
+		if got := classifyFailoverError(test.err); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.err, test.expected, got)
+		}
+	}
+}
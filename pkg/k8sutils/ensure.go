@@ -0,0 +1,214 @@
+package k8sutils
+
+import (
+	"reflect"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// EnsureDeployment creates the given deployment or, if it already exists, updates its labels, replicas
+// and container images to match. It returns the live deployment and whether it was created or updated. An
+// ErrImmutableChange is returned if the selector or containers of the existing deployment differ.
+func EnsureDeployment(deployment *v1beta1.Deployment, opts ...CreateOption) (*v1beta1.Deployment, bool, error) {
+	if err := checkAppsV1beta1("EnsureDeployment"); err != nil {
+		return nil, false, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if deployment.Namespace, err = resolveCreateNamespace(client, deployment.Namespace, opts); err != nil {
+		return nil, false, err
+	}
+
+	stampInstanceLabel(&deployment.ObjectMeta)
+	stampInstanceLabel(&deployment.Spec.Template.ObjectMeta)
+
+	result, err := client.AppsV1beta1().Deployments(deployment.Namespace).Create(deployment)
+	if err == nil || !k8s_errors.IsAlreadyExists(err) {
+		return result, err == nil, err
+	}
+
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var current *v1beta1.Deployment
+		current, err = client.AppsV1beta1().Deployments(deployment.Namespace).Get(deployment.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+
+		var changed bool
+		if changed, err = reconcileDeployment(current, deployment); err != nil || !changed {
+			return current, false, err
+		}
+
+		if result, err = client.AppsV1beta1().Deployments(deployment.Namespace).Update(current); err == nil {
+			return result, true, nil
+		}
+		if !k8s_errors.IsConflict(err) {
+			return nil, false, err
+		}
+	}
+
+	return nil, false, err
+}
+
+// EnsurePersistentVolumeClaim creates the given pvc or, if it already exists, updates its labels and
+// requested size to match. It returns the live pvc and whether it was created or updated. An
+// ErrImmutableChange is returned if the existing pvc is larger or its storage class, access modes or
+// selector differ.
+func EnsurePersistentVolumeClaim(
+	pvc *v1.PersistentVolumeClaim,
+	opts ...CreateOption,
+) (*v1.PersistentVolumeClaim, bool, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if pvc.Namespace, err = resolveCreateNamespace(client, pvc.Namespace, opts); err != nil {
+		return nil, false, err
+	}
+
+	stampInstanceLabel(&pvc.ObjectMeta)
+
+	result, err := client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Create(pvc)
+	if err == nil || !k8s_errors.IsAlreadyExists(err) {
+		return result, err == nil, err
+	}
+
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var current *v1.PersistentVolumeClaim
+		current, err = client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(pvc.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+
+		var changed bool
+		if changed, err = reconcilePVC(current, pvc); err != nil || !changed {
+			return current, false, err
+		}
+
+		if result, err = client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(current); err == nil {
+			return result, true, nil
+		}
+		if !k8s_errors.IsConflict(err) {
+			return nil, false, err
+		}
+	}
+
+	return nil, false, err
+}
+
+// reconcileDeployment updates the mutable fields of current to match desired and returns whether any
+// field changed
+func reconcileDeployment(current, desired *v1beta1.Deployment) (bool, error) {
+	var immutable []string
+	if desired.Spec.Selector != nil && !reflect.DeepEqual(current.Spec.Selector, desired.Spec.Selector) {
+		immutable = append(immutable, "spec.selector")
+	}
+
+	currentContainers := current.Spec.Template.Spec.Containers
+	desiredContainers := desired.Spec.Template.Spec.Containers
+	if len(currentContainers) != len(desiredContainers) {
+		immutable = append(immutable, "spec.template.spec.containers")
+	} else {
+		for i := range desiredContainers {
+			if currentContainers[i].Name != desiredContainers[i].Name {
+				immutable = append(immutable, "spec.template.spec.containers")
+				break
+			}
+		}
+	}
+
+	if len(immutable) > 0 {
+		return false, &ErrImmutableChange{
+			Kind:   "deployment",
+			Name:   current.Name,
+			Fields: immutable,
+		}
+	}
+
+	changed := mergeLabels(&current.ObjectMeta, desired.Labels)
+
+	if desired.Spec.Replicas != nil &&
+		(current.Spec.Replicas == nil || *current.Spec.Replicas != *desired.Spec.Replicas) {
+		replicas := *desired.Spec.Replicas
+		current.Spec.Replicas = &replicas
+		changed = true
+	}
+
+	for i := range desiredContainers {
+		if currentContainers[i].Image != desiredContainers[i].Image {
+			currentContainers[i].Image = desiredContainers[i].Image
+			changed = true
+		}
+	}
+
+	return changed, nil
+}
+
+// reconcilePVC updates the mutable fields of current to match desired and returns whether any field
+// changed
+func reconcilePVC(current, desired *v1.PersistentVolumeClaim) (bool, error) {
+	var immutable []string
+
+	currentSize := current.Spec.Resources.Requests[v1.ResourceStorage]
+	desiredSize, sizeSet := desired.Spec.Resources.Requests[v1.ResourceStorage]
+	if sizeSet && desiredSize.Cmp(currentSize) < 0 {
+		immutable = append(immutable, "spec.resources.requests.storage")
+	}
+
+	currentSC, _ := getPVCStorageClassName(current)
+	if desiredSC, ok := getPVCStorageClassName(desired); ok && desiredSC != currentSC {
+		immutable = append(immutable, "storage class")
+	}
+
+	if len(desired.Spec.AccessModes) > 0 && !reflect.DeepEqual(current.Spec.AccessModes, desired.Spec.AccessModes) {
+		immutable = append(immutable, "spec.accessModes")
+	}
+
+	if desired.Spec.Selector != nil && !reflect.DeepEqual(current.Spec.Selector, desired.Spec.Selector) {
+		immutable = append(immutable, "spec.selector")
+	}
+
+	if len(immutable) > 0 {
+		return false, &ErrImmutableChange{
+			Kind:   "PVC",
+			Name:   current.Name,
+			Fields: immutable,
+		}
+	}
+
+	changed := mergeLabels(&current.ObjectMeta, desired.Labels)
+
+	if sizeSet && desiredSize.Cmp(currentSize) > 0 {
+		if current.Spec.Resources.Requests == nil {
+			current.Spec.Resources.Requests = v1.ResourceList{}
+		}
+		current.Spec.Resources.Requests[v1.ResourceStorage] = desiredSize
+		changed = true
+	}
+
+	return changed, nil
+}
+
+// mergeLabels sets the given labels on the object and returns whether any label changed
+func mergeLabels(meta *meta_v1.ObjectMeta, labels map[string]string) bool {
+	changed := false
+	for key, value := range labels {
+		if current, ok := meta.Labels[key]; ok && current == value {
+			continue
+		}
+		if meta.Labels == nil {
+			meta.Labels = make(map[string]string)
+		}
+		meta.Labels[key] = value
+		changed = true
+	}
+	return changed
+}
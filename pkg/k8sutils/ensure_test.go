@@ -0,0 +1,136 @@
+package k8sutils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// newTestClaim returns a PVC of the given size in the px storage class
+func newTestClaim(name, size string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        name,
+			Namespace:   testNamespace,
+			Annotations: map[string]string{k8sPVCStorageClassKey: "px"},
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+// countUpdates returns the number of updates of the given resource the server received
+func countUpdates(server *k8stest.Server, resource string) int {
+	count := 0
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPut && req.Resource == resource {
+			count++
+		}
+	}
+	return count
+}
+
+func TestEnsureDeployment(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	result, changed, err := EnsureDeployment(newTestDeployment("web", "", 1))
+	if err != nil || !changed || result.Name != "web" {
+		t.Fatalf("expected the deployment to be created, got: %v, %v, %v", result, changed, err)
+	}
+
+	_, changed, err = EnsureDeployment(newTestDeployment("web", "", 1))
+	if err != nil || changed {
+		t.Fatalf("expected the unchanged deployment to be kept, got: %v, %v", changed, err)
+	}
+	if updates := countUpdates(server, "deployments"); updates > 0 {
+		t.Errorf("expected no update of an unchanged deployment, got %d", updates)
+	}
+
+	// The first update conflicts with another writer
+	conflicts := 0
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method == http.MethodPut && req.Resource == "deployments" && conflicts == 0 {
+			conflicts++
+			return true, http.StatusConflict, k8stest.Status(http.StatusConflict, "Conflict", "object was modified")
+		}
+		return false, 0, nil
+	})
+
+	desired := newTestDeployment("web", "", 3)
+	desired.Labels = map[string]string{"tier": "frontend"}
+	desired.Spec.Template.Spec.Containers[0].Image = "nginx"
+	result, changed, err = EnsureDeployment(desired)
+	if err != nil || !changed {
+		t.Fatalf("expected the deployment to be updated, got: %v, %v", changed, err)
+	}
+	if updates := countUpdates(server, "deployments"); updates != 2 {
+		t.Errorf("expected the update to be retried after the conflict, got %d updates", updates)
+	}
+
+	var live v1beta1.Deployment
+	server.Get("deployments", testNamespace, "web", &live)
+	if *live.Spec.Replicas != 3 || live.Labels["tier"] != "frontend" ||
+		live.Spec.Template.Spec.Containers[0].Image != "nginx" {
+		t.Errorf("expected the replicas, labels and image to be updated, got: %+v", live)
+	}
+	if result.ResourceVersion != live.ResourceVersion {
+		t.Errorf("expected the live deployment to be returned, got version %v instead of %v",
+			result.ResourceVersion, live.ResourceVersion)
+	}
+
+	immutable := newTestDeployment("web", "", 3)
+	immutable.Spec.Selector = &meta_v1.LabelSelector{MatchLabels: map[string]string{"app": "other"}}
+	immutable.Spec.Template.Spec.Containers[0].Name = "other"
+	_, changed, err = EnsureDeployment(immutable)
+	if e, ok := err.(*ErrImmutableChange); !ok || changed ||
+		!reflect.DeepEqual(e.Fields, []string{"spec.selector", "spec.template.spec.containers"}) {
+		t.Fatalf("expected an ErrImmutableChange for the selector and containers, got: %v, %v", changed, err)
+	}
+}
+
+func TestEnsurePersistentVolumeClaim(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	result, changed, err := EnsurePersistentVolumeClaim(newTestClaim("data", "1Gi"))
+	if err != nil || !changed || result.Name != "data" {
+		t.Fatalf("expected the PVC to be created, got: %v, %v, %v", result, changed, err)
+	}
+
+	_, changed, err = EnsurePersistentVolumeClaim(newTestClaim("data", "1Gi"))
+	if err != nil || changed {
+		t.Fatalf("expected the unchanged PVC to be kept, got: %v, %v", changed, err)
+	}
+
+	_, changed, err = EnsurePersistentVolumeClaim(newTestClaim("data", "2Gi"))
+	if err != nil || !changed {
+		t.Fatalf("expected the PVC to be expanded, got: %v, %v", changed, err)
+	}
+	var live v1.PersistentVolumeClaim
+	server.Get("persistentvolumeclaims", testNamespace, "data", &live)
+	if size := live.Spec.Resources.Requests[v1.ResourceStorage]; size.String() != "2Gi" {
+		t.Errorf("expected the PVC to request 2Gi, got: %v", size.String())
+	}
+
+	shrunk := newTestClaim("data", "1Gi")
+	shrunk.Annotations[k8sPVCStorageClassKey] = "other"
+	_, changed, err = EnsurePersistentVolumeClaim(shrunk)
+	if e, ok := err.(*ErrImmutableChange); !ok || changed ||
+		!reflect.DeepEqual(e.Fields, []string{"spec.resources.requests.storage", "storage class"}) {
+		t.Fatalf("expected an ErrImmutableChange for the size and storage class, got: %v, %v", changed, err)
+	}
+	if updates := countUpdates(server, "persistentvolumeclaims"); updates != 1 {
+		t.Errorf("expected only the expansion to update the PVC, got %d updates", updates)
+	}
+}
@@ -3,6 +3,9 @@ package k8sutils
 import (
 	"errors"
 	"fmt"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
 )
 
 // ErrK8SApiAccountNotSet is returned when the account used to talk to k8s api is not setup
@@ -67,3 +70,590 @@ type ErrPVCNotReady struct {
 func (e *ErrPVCNotReady) Error() string {
 	return fmt.Sprintf("PVC %v is not ready yet. Cause: %v", e.ID, e.Cause)
 }
+
+// ErrPVCNotBound error type for when a PVC is not bound to a volume yet
+type ErrPVCNotBound struct {
+	// ID is the identifier of the PVC
+	ID string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrPVCNotBound) Error() string {
+	return fmt.Sprintf("PVC %v is not bound yet. Cause: %v", e.ID, e.Cause)
+}
+
+// ErrFailedToCollectSupportBundle error type for when a support bundle could not be fully collected
+type ErrFailedToCollectSupportBundle struct {
+	// Path is the directory where the bundle is collected
+	Path string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrFailedToCollectSupportBundle) Error() string {
+	return fmt.Sprintf("Failed to collect support bundle in: %v. Cause: %v", e.Path, e.Cause)
+}
+
+// ErrTooManyRequests error type for when the k8s api server is throttling requests
+type ErrTooManyRequests struct {
+	// Delay is how long the api server asked to wait before retrying
+	Delay time.Duration
+	// Cause is the underlying cause of the error
+	Cause string
+	// Err is the error returned by the api server
+	Err error
+}
+
+func (e *ErrTooManyRequests) Error() string {
+	return fmt.Sprintf("k8s api server is throttling requests. Retry after: %v. Cause: %v", e.Delay, e.Cause)
+}
+
+// RetryAfter returns the delay requested by the api server
+func (e *ErrTooManyRequests) RetryAfter() time.Duration {
+	return e.Delay
+}
+
+// Unwrap returns the error returned by the api server
+func (e *ErrTooManyRequests) Unwrap() error {
+	return e.Err
+}
+
+// ErrFailedToExecInPod error type for when a command could not be run in a pod or exited with an error
+type ErrFailedToExecInPod struct {
+	// Pod is the name of the pod in which the command was run
+	Pod string
+	// Command is the command that was run
+	Command []string
+	// ExitCode is the exit code of the command. -1 if the command did not complete.
+	ExitCode int
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrFailedToExecInPod) Error() string {
+	return fmt.Sprintf("Failed to run command: %v in pod: %v. Exit code: %v. Cause: %v",
+		e.Command, e.Pod, e.ExitCode, e.Cause)
+}
+
+// ErrServiceProbeFailed error type for when a service could not be probed successfully from within the cluster
+type ErrServiceProbeFailed struct {
+	// Service is the DNS name of the service that was probed
+	Service string
+	// Reason is the class of the probe failure
+	Reason ProbeFailureReason
+	// StatusCode is the HTTP status returned by the service, if any
+	StatusCode int
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrServiceProbeFailed) Error() string {
+	return fmt.Sprintf("Failed to probe service: %v. Reason: %v. Status code: %v. Cause: %v",
+		e.Service, e.Reason, e.StatusCode, e.Cause)
+}
+
+// ErrPodVolumesNotReady error type for when volumes of a pod could not be attached or mounted
+type ErrPodVolumesNotReady struct {
+	// Pod is the name of the pod
+	Pod string
+	// Volumes maps the volumes that failed to the reason they failed
+	Volumes map[string]string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrPodVolumesNotReady) Error() string {
+	return fmt.Sprintf("volumes of pod %v are not ready. Failed volumes: %v. Cause: %v", e.Pod, e.Volumes, e.Cause)
+}
+
+// ErrPodsNotScheduledOnNode error type for when pods are not back running on a node
+type ErrPodsNotScheduledOnNode struct {
+	// Node is the name of the node
+	Node string
+	// Selector is the label selector of the expected pods
+	Selector string
+	// Taints are the taints on the node
+	Taints []v1.Taint
+	// Unschedulable is true if the node is cordoned
+	Unschedulable bool
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrPodsNotScheduledOnNode) Error() string {
+	return fmt.Sprintf("pods with selector: %v are not scheduled on node: %v. Unschedulable: %v Taints: %v. Cause: %v",
+		e.Selector, e.Node, e.Unschedulable, e.Taints, e.Cause)
+}
+
+// IsAppNotReady checks if the given error, or any error it wraps, is an ErrAppNotReady
+func IsAppNotReady(err error) bool {
+	for ; err != nil; err = unwrapError(err) {
+		if _, ok := err.(*ErrAppNotReady); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAppNotTerminated checks if the given error, or any error it wraps, is an ErrAppNotTerminated
+func IsAppNotTerminated(err error) bool {
+	for ; err != nil; err = unwrapError(err) {
+		if _, ok := err.(*ErrAppNotTerminated); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPVCNotReady checks if the given error, or any error it wraps, is an ErrPVCNotReady
+func IsPVCNotReady(err error) bool {
+	for ; err != nil; err = unwrapError(err) {
+		if _, ok := err.(*ErrPVCNotReady); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// unwrapError returns the error wrapped by the given error, e.g the last error of a timed out task, or nil
+func unwrapError(err error) error {
+	if wrapper, ok := err.(interface {
+		Unwrap() error
+	}); ok {
+		return wrapper.Unwrap()
+	}
+	return nil
+}
+
+// ErrStaleDNSRecords error type for when the DNS records of statefulset replicas do not match their pods
+type ErrStaleDNSRecords struct {
+	// StatefulSet is the name of the statefulset
+	StatefulSet string
+	// Ordinals maps the ordinals of the replicas with stale records to the cause
+	Ordinals map[int32]string
+}
+
+func (e *ErrStaleDNSRecords) Error() string {
+	return fmt.Sprintf("statefulset %v has stale DNS records for ordinals: %v", e.StatefulSet, e.Ordinals)
+}
+
+// ErrNodeCountMismatch error type for when the cluster does not have the expected number of worker nodes
+type ErrNodeCountMismatch struct {
+	// Expected is the expected number of worker nodes
+	Expected int
+	// Actual is the current number of worker nodes
+	Actual int
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrNodeCountMismatch) Error() string {
+	return fmt.Sprintf("cluster has %v worker nodes. Expected: %v. Cause: %v", e.Actual, e.Expected, e.Cause)
+}
+
+// ErrPVCsNotBound error type for when a set of PVCs is not yet bound
+type ErrPVCsNotBound struct {
+	// Unbound are the names of some of the unbound PVCs
+	Unbound []string
+	// Count is the number of unbound PVCs
+	Count int
+	// Total is the number of PVCs in the set
+	Total int
+}
+
+func (e *ErrPVCsNotBound) Error() string {
+	return fmt.Sprintf("%v of %v PVCs are not bound yet. Unbound PVCs include: %v", e.Count, e.Total, e.Unbound)
+}
+
+// ErrFailedToMigratePVC error type for when a deployment could not be migrated to a new PVC
+type ErrFailedToMigratePVC struct {
+	// Deployment is the name of the deployment
+	Deployment string
+	// Stage is the stage of the migration that failed
+	Stage string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrFailedToMigratePVC) Error() string {
+	return fmt.Sprintf("Failed to migrate PVC of deployment: %v at stage: %v. Cause: %v", e.Deployment, e.Stage, e.Cause)
+}
+
+// ErrSLAExceeded error type for when an app or PVC became ready but took longer than its budget
+type ErrSLAExceeded struct {
+	// ID is the identifier of the app or PVC
+	ID string
+	// Actual is the time it took to become ready
+	Actual time.Duration
+	// Budget is the time it was expected to become ready within
+	Budget time.Duration
+}
+
+func (e *ErrSLAExceeded) Error() string {
+	return fmt.Sprintf("%v became ready in %v which exceeds the budget of %v", e.ID, e.Actual, e.Budget)
+}
+
+// ErrStorageClassInUse error type for when a storage class is still referenced by PVCs
+type ErrStorageClassInUse struct {
+	// Name is the name of the storage class
+	Name string
+	// PVCs are the <namespace>/<name> of the PVCs referencing the storage class
+	PVCs []string
+}
+
+func (e *ErrStorageClassInUse) Error() string {
+	return fmt.Sprintf("storage class %v is in use by PVCs: %v", e.Name, e.PVCs)
+}
+
+// ErrPVCInUse error type for when a PVC is still used by running pods
+type ErrPVCInUse struct {
+	// Name is the name of the PVC
+	Name string
+	// Pods are the names of the running pods using the PVC
+	Pods []string
+}
+
+func (e *ErrPVCInUse) Error() string {
+	return fmt.Sprintf("PVC %v is in use by pods: %v", e.Name, e.Pods)
+}
+
+// ErrStorageClassHasPendingPVCs error type for when a storage class can't be replaced as PVCs are being
+// provisioned with it
+type ErrStorageClassHasPendingPVCs struct {
+	// Name is the name of the storage class
+	Name string
+	// PVCs are the <namespace>/<name> of the pending PVCs
+	PVCs []string
+}
+
+func (e *ErrStorageClassHasPendingPVCs) Error() string {
+	return fmt.Sprintf("storage class %v has pending PVCs: %v", e.Name, e.PVCs)
+}
+
+// ErrDefaultStorageClass error type for when the cluster doesn't have exactly one default storage class
+type ErrDefaultStorageClass struct {
+	// Defaults are the names of the default storage classes
+	Defaults []string
+}
+
+func (e *ErrDefaultStorageClass) Error() string {
+	return fmt.Sprintf("cluster has %v default storage classes: %v. Expected: 1", len(e.Defaults), e.Defaults)
+}
+
+// ErrNotOwned error type for when an object can't be deleted as another torpedo instance created it
+type ErrNotOwned struct {
+	// Kind is the kind of the object
+	Kind string
+	// Name is the name of the object
+	Name string
+	// Instance is the ID of this torpedo instance
+	Instance string
+	// Owner is the ID of the torpedo instance that created the object
+	Owner string
+}
+
+func (e *ErrNotOwned) Error() string {
+	return fmt.Sprintf("%v %v was created by torpedo instance: %v and can't be deleted by instance: %v",
+		e.Kind, e.Name, e.Owner, e.Instance)
+}
+
+// ErrAdmissionDenied error type for when the pods of an app are rejected at admission
+type ErrAdmissionDenied struct {
+	// ID is the identifier of the app
+	ID string
+	// Cause is the rejection message of the api server
+	Cause string
+}
+
+func (e *ErrAdmissionDenied) Error() string {
+	return fmt.Sprintf("pods of app %v are denied admission. Cause: %v", e.ID, e.Cause)
+}
+
+// ErrUnbalancedPlacement error type for when pods are not evenly spread across the nodes
+type ErrUnbalancedPlacement struct {
+	// Mean is the mean number of pods per node
+	Mean float64
+	// Tolerance is the allowed deviation from the mean as a fraction of the mean
+	Tolerance float64
+	// Deviating maps the nodes that deviate from the mean to their number of pods
+	Deviating map[string]int
+	// Pending is the number of pods that are pending without a node
+	Pending int
+}
+
+func (e *ErrUnbalancedPlacement) Error() string {
+	return fmt.Sprintf("pods are not balanced across nodes. Mean: %.2f Tolerance: %v Deviating nodes: %v Pending pods: %v",
+		e.Mean, e.Tolerance, e.Deviating, e.Pending)
+}
+
+// ErrPVCSpecMismatch error type for when a bound PVC doesn't have the expected properties
+type ErrPVCSpecMismatch struct {
+	// ID is the identifier of the PVC
+	ID string
+	// Field is the property of the PVC that doesn't match
+	Field string
+	// Expected is the expected value of the field
+	Expected string
+	// Actual is the value of the field of the PVC
+	Actual string
+}
+
+func (e *ErrPVCSpecMismatch) Error() string {
+	return fmt.Sprintf("PVC %v has unexpected %v. Expected: %v Actual: %v", e.ID, e.Field, e.Expected, e.Actual)
+}
+
+// ErrPreservedVolumeMissing error type for when a PVC that was kept across an app teardown is gone
+type ErrPreservedVolumeMissing struct {
+	// PVC is the namespace/name of the PVC
+	PVC string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrPreservedVolumeMissing) Error() string {
+	return fmt.Sprintf("preserved PVC %v is missing. Cause: %v", e.PVC, e.Cause)
+}
+
+// ErrGracePeriodExceeded error type for when pods take longer than their grace period to terminate
+type ErrGracePeriodExceeded struct {
+	// ID is the identifier of the app
+	ID string
+	// Pods maps the pods that exceeded their grace period to their termination time
+	Pods map[string]time.Duration
+}
+
+func (e *ErrGracePeriodExceeded) Error() string {
+	return fmt.Sprintf("pods of app %v exceeded their termination grace period: %v", e.ID, e.Pods)
+}
+
+// ErrImmutableChange error type for when an existing object differs from the desired one in fields that
+// can't be updated
+type ErrImmutableChange struct {
+	// Kind is the kind of the object
+	Kind string
+	// Name is the name of the object
+	Name string
+	// Fields are the fields that differ
+	Fields []string
+}
+
+func (e *ErrImmutableChange) Error() string {
+	return fmt.Sprintf("existing %v: %v can't be updated to the desired spec. Fields: %v", e.Kind, e.Name, e.Fields)
+}
+
+// ErrClusterNotSettled error type for when the cluster doesn't settle in time
+type ErrClusterNotSettled struct {
+	// Timeout is how long the cluster was waited on
+	Timeout time.Duration
+	// Unsettled are the resources that were still unsettled
+	Unsettled []string
+}
+
+func (e *ErrClusterNotSettled) Error() string {
+	return fmt.Sprintf("cluster did not settle in %v. Unsettled: %v", e.Timeout, e.Unsettled)
+}
+
+// ErrRevisionNotFound error type for when a deployment has no replica set for a revision, as is the case
+// once the replica set is garbage collected
+type ErrRevisionNotFound struct {
+	// ID is the identifier of the deployment
+	ID string
+	// Revision is the revision that was not found
+	Revision int64
+	// Available are the revisions of the deployment that still exist
+	Available []int64
+}
+
+func (e *ErrRevisionNotFound) Error() string {
+	return fmt.Sprintf("deployment %v has no revision: %v. Available revisions: %v", e.ID, e.Revision, e.Available)
+}
+
+// ErrTokenRejected error type for when the api server doesn't authenticate a token
+type ErrTokenRejected struct {
+	// Pod is the pod the token was used from
+	Pod string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrTokenRejected) Error() string {
+	return fmt.Sprintf("token was not authenticated from pod %v. Cause: %v", e.Pod, e.Cause)
+}
+
+// ErrNodesNotReady error type for when some nodes of the cluster are not ready
+type ErrNodesNotReady struct {
+	// Masters maps the master nodes that are not ready to the cause
+	Masters map[string]string
+	// Workers maps the worker nodes that are not ready or failed a worker check to the cause
+	Workers map[string]string
+}
+
+func (e *ErrNodesNotReady) Error() string {
+	return fmt.Sprintf("nodes are not ready. Masters: %v Workers: %v", e.Masters, e.Workers)
+}
+
+// ErrPodOnEvacuatedNode error type for when a pod is scheduled back on the node it was evacuated from
+type ErrPodOnEvacuatedNode struct {
+	// Pod is the name of the pod
+	Pod string
+	// Node is the evacuated node
+	Node string
+}
+
+func (e *ErrPodOnEvacuatedNode) Error() string {
+	return fmt.Sprintf("pod %v was scheduled on evacuated node %v. The cordon of the node raced the scheduler",
+		e.Pod, e.Node)
+}
+
+// ErrControlPlaneUnhealthy error type for when control plane components are unhealthy
+type ErrControlPlaneUnhealthy struct {
+	// Components maps the unhealthy components to why they are unhealthy
+	Components map[string]string
+}
+
+func (e *ErrControlPlaneUnhealthy) Error() string {
+	return fmt.Sprintf("control plane is unhealthy. Components: %v", e.Components)
+}
+
+// ErrReadOnlyMounts error type for when the mounts of an app are not writable
+type ErrReadOnlyMounts struct {
+	// App is the name of the app
+	App string
+	// Mounts are the mounts that are not read-write
+	Mounts []PodMountState
+}
+
+func (e *ErrReadOnlyMounts) Error() string {
+	var mounts []string
+	for _, m := range e.Mounts {
+		mounts = append(mounts, fmt.Sprintf("%v/%v:%v (%v)", m.Pod, m.Container, m.MountPath, m.State))
+	}
+	return fmt.Sprintf("app: %v has mounts that are not read-write. Mounts: %v", e.App, mounts)
+}
+
+// ErrStatsUnavailable error type for when the kubelet of a node doesn't provide volume stats
+type ErrStatsUnavailable struct {
+	// Node is the name of the node
+	Node string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrStatsUnavailable) Error() string {
+	return fmt.Sprintf("volume stats are unavailable on node: %v. Cause: %v", e.Node, e.Cause)
+}
+
+// ErrPodsChanged error type for when the pods of an app were replaced or their containers restarted
+type ErrPodsChanged struct {
+	// App is the name of the app
+	App string
+	// ReplacedPods are the pods of the snapshot that no longer exist
+	ReplacedPods []string
+	// RestartedContainers maps the pods to their containers whose container ID changed
+	RestartedContainers map[string][]string
+}
+
+func (e *ErrPodsChanged) Error() string {
+	return fmt.Sprintf("pods of app: %v changed. Replaced pods: %v Restarted containers: %v",
+		e.App, e.ReplacedPods, e.RestartedContainers)
+}
+
+// ErrSystemUnstable error type for when system pods restarted or were replaced unexpectedly
+type ErrSystemUnstable struct {
+	// Restarted maps the pods (<namespace>/<name>) with unexpected container restarts to their count
+	Restarted map[string]int32
+	// Replaced are the pods (<namespace>/<name>) of the snapshot that no longer exist
+	Replaced []string
+}
+
+func (e *ErrSystemUnstable) Error() string {
+	return fmt.Sprintf("system pods are unstable. Unexpected restarts: %v Replaced pods: %v", e.Restarted, e.Replaced)
+}
+
+// ErrNamespaceNotTerminated error type for when a deleted namespace is still present
+type ErrNamespaceNotTerminated struct {
+	// Namespace is the name of the namespace
+	Namespace string
+	// Finalizers maps the objects (<kind>/<name>) still in the namespace to their finalizers
+	Finalizers map[string][]string
+}
+
+func (e *ErrNamespaceNotTerminated) Error() string {
+	return fmt.Sprintf("namespace: %v is not terminated yet. Objects with finalizers: %v", e.Namespace, e.Finalizers)
+}
+
+// ErrVolumeParamsNotEffective error type for when the volume of a PVC doesn't honor its parameters
+type ErrVolumeParamsNotEffective struct {
+	// PVC is the name of the PVC
+	PVC string
+	// PV is the name of the PV of the PVC
+	PV string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrVolumeParamsNotEffective) Error() string {
+	return fmt.Sprintf("volume: %v of PVC: %v does not honor its parameters. Cause: %v", e.PV, e.PVC, e.Cause)
+}
+
+// ErrDestructiveOpsDisabled error type for when a destructive helper is called while the destructive ops
+// gate is closed
+type ErrDestructiveOpsDisabled struct {
+	// Op is the refused operation
+	Op string
+}
+
+func (e *ErrDestructiveOpsDisabled) Error() string {
+	return fmt.Sprintf("destructive op: %v refused. Destructive ops are disabled", e.Op)
+}
+
+// ErrPVCNotDeleted error type for when a deleted PVC is still present
+type ErrPVCNotDeleted struct {
+	// Name is the name of the PVC
+	Name string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrPVCNotDeleted) Error() string {
+	return fmt.Sprintf("PVC %v is not deleted yet. Cause: %v", e.Name, e.Cause)
+}
+
+// ErrJobFailed error type for when a job, or a job of a cronjob, failed
+type ErrJobFailed struct {
+	// Name is the name of the job
+	Name string
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrJobFailed) Error() string {
+	return fmt.Sprintf("job %v failed. Cause: %v", e.Name, e.Cause)
+}
+
+// ErrConfigMapNotPropagated error type for when a config map change is not visible in the pods mounting it
+type ErrConfigMapNotPropagated struct {
+	// ConfigMap is the <namespace>/<name> of the config map
+	ConfigMap string
+	// Pods maps the pods that don't see the change to why
+	Pods map[string]string
+}
+
+func (e *ErrConfigMapNotPropagated) Error() string {
+	return fmt.Sprintf("config map %v is not propagated to pods: %v", e.ConfigMap, e.Pods)
+}
+
+// ErrPVNotReclaimed error type for when a released persistent volume was not reclaimed per its reclaim policy
+type ErrPVNotReclaimed struct {
+	// Name is the name of the PV
+	Name string
+	// Policy is the reclaim policy of the PV
+	Policy v1.PersistentVolumeReclaimPolicy
+	// Cause is the underlying cause of the error
+	Cause string
+}
+
+func (e *ErrPVNotReclaimed) Error() string {
+	return fmt.Sprintf("PV %v is not reclaimed per its reclaim policy: %v. Cause: %v", e.Name, e.Policy, e.Cause)
+}
@@ -0,0 +1,72 @@
+package k8sutils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+)
+
+func TestErrorPredicates(t *testing.T) {
+	notReady := &ErrAppNotReady{ID: "app", Cause: "pending"}
+	notTerminated := &ErrAppNotTerminated{ID: "app", Cause: "running"}
+	pvcNotReady := &ErrPVCNotReady{ID: "pvc", Cause: "pending"}
+
+	tests := []struct {
+		name          string
+		err           error
+		notReady      bool
+		notTerminated bool
+		pvcNotReady   bool
+	}{
+		{name: "nil"},
+		{name: "other error", err: fmt.Errorf("app not ready")},
+		{name: "app not ready", err: notReady, notReady: true},
+		{name: "app not terminated", err: notTerminated, notTerminated: true},
+		{name: "pvc not ready", err: pvcNotReady, pvcNotReady: true},
+		{
+			name:     "wrapped in too many requests",
+			err:      &ErrTooManyRequests{Delay: time.Second, Err: notReady},
+			notReady: true,
+		},
+	}
+
+	for _, test := range tests {
+		if got := IsAppNotReady(test.err); got != test.notReady {
+			t.Errorf("%v: IsAppNotReady: expected %v, got %v", test.name, test.notReady, got)
+		}
+		if got := IsAppNotTerminated(test.err); got != test.notTerminated {
+			t.Errorf("%v: IsAppNotTerminated: expected %v, got %v", test.name, test.notTerminated, got)
+		}
+		if got := IsPVCNotReady(test.err); got != test.pvcNotReady {
+			t.Errorf("%v: IsPVCNotReady: expected %v, got %v", test.name, test.pvcNotReady, got)
+		}
+	}
+}
+
+func TestErrorPredicatesThroughRetryTimeout(t *testing.T) {
+	err := task.DoRetryWithTimeout(func() error {
+		return &ErrPVCNotReady{ID: "pvc", Cause: "pending"}
+	}, 50*time.Millisecond, 10*time.Millisecond)
+
+	if err == nil {
+		t.Fatalf("expected a timeout error")
+	}
+	if !IsPVCNotReady(err) {
+		t.Errorf("expected the timeout error to wrap an ErrPVCNotReady, got: %v", err)
+	}
+	if IsAppNotReady(err) {
+		t.Errorf("expected the timeout error not to wrap an ErrAppNotReady, got: %v", err)
+	}
+}
+
+func TestErrorPredicatesThroughThrottledRetry(t *testing.T) {
+	err := doRetryWithTimeout(func() error {
+		return &ErrAppNotReady{ID: "app", Cause: "pending"}
+	}, 50*time.Millisecond, 10*time.Millisecond)
+
+	if !IsAppNotReady(err) {
+		t.Errorf("expected the timeout error to wrap an ErrAppNotReady, got: %v", err)
+	}
+}
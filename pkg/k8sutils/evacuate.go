@@ -0,0 +1,153 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/plan"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// The kinds of the targets of the plan actions
+const (
+	planKindNode      = "node"
+	planKindPod       = "pod"
+	planKindPVC       = "persistentvolumeclaim"
+	planKindNamespace = "namespace"
+)
+
+// EvacuateAppFromNode moves the pods of the given deployment off the given node: the node is cordoned, the
+// pods of the deployment on it are deleted and the deployment is validated once the replacement pods run
+// on other nodes. The node is uncordoned on return, also on failure. An ErrPodOnEvacuatedNode is returned
+// if a replacement pod is scheduled on the node. The deleted pods and their replacements are returned, the
+// replacements are not paired with the pods they replace. With plan.WithDryRun, the pods to delete are
+// resolved and the plan is filled without cordoning the node, and no evacuation is returned.
+func EvacuateAppFromNode(
+	deployment *v1beta1.Deployment,
+	nodeName string,
+	timeout time.Duration,
+	opts ...plan.Option,
+) (evacuation *Evacuation, err error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := make(map[types.UID]bool)
+	var onNode []v1.Pod
+	for _, pod := range pods {
+		existing[pod.UID] = true
+		if pod.Spec.NodeName == nodeName && pod.DeletionTimestamp == nil {
+			onNode = append(onNode, pod)
+		}
+	}
+
+	actions := []plan.Action{{Verb: plan.VerbCordon, Kind: planKindNode, Name: nodeName}}
+	actions = append(actions, PodDeleteActions(onNode)...)
+	actions = append(actions, plan.Action{Verb: plan.VerbUncordon, Kind: planKindNode, Name: nodeName})
+
+	if execute, err := plan.Check("EvacuateAppFromNode", actions, opts); !execute {
+		return nil, err
+	}
+
+	if err := CordonNode(nodeName); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if uncordonErr := UncordonNode(nodeName); uncordonErr != nil {
+			logrus.Warnf("Failed to uncordon node: %v. Err: %v", nodeName, uncordonErr)
+			if err == nil {
+				err = uncordonErr
+			}
+		}
+	}()
+
+	evacuation = &Evacuation{
+		Node:            nodeName,
+		ReplacementPods: make(map[string]string),
+	}
+	if len(onNode) == 0 {
+		return evacuation, nil
+	}
+
+	if err := DeletePods(onNode); err != nil {
+		return nil, err
+	}
+
+	var replacements []v1.Pod
+	var landed *v1.Pod
+	t := func() error {
+		pods, err := GetDeploymentPods(deployment)
+		if err != nil {
+			return err
+		}
+
+		replacements = nil
+		for i, pod := range pods {
+			if existing[pod.UID] || pod.DeletionTimestamp != nil || len(pod.Spec.NodeName) == 0 {
+				continue
+			}
+
+			if pod.Spec.NodeName == nodeName {
+				// Stop waiting, the evacuation failed
+				landed = &pods[i]
+				return nil
+			}
+			replacements = append(replacements, pod)
+		}
+
+		if len(replacements) < len(onNode) {
+			return &ErrAppNotReady{
+				ID:    deployment.Name,
+				Cause: fmt.Sprintf("%d of %d pods are rescheduled off node: %v", len(replacements), len(onNode), nodeName),
+			}
+		}
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return nil, err
+	}
+
+	if landed != nil {
+		return nil, &ErrPodOnEvacuatedNode{
+			Pod:  landed.Name,
+			Node: nodeName,
+		}
+	}
+
+	if err := ValidateDeployement(deployment); err != nil {
+		return nil, err
+	}
+
+	for _, pod := range onNode {
+		evacuation.DeletedPods = append(evacuation.DeletedPods, pod.Name)
+	}
+	sort.Strings(evacuation.DeletedPods)
+	for _, pod := range replacements {
+		evacuation.ReplacementPods[pod.Name] = pod.Spec.NodeName
+	}
+
+	return evacuation, nil
+}
+
+// PodDeleteActions returns the plan actions deleting the given pods, in order
+func PodDeleteActions(pods []v1.Pod) []plan.Action {
+	var actions []plan.Action
+	for _, pod := range pods {
+		actions = append(actions, plan.Action{
+			Verb:      plan.VerbDelete,
+			Kind:      planKindPod,
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       string(pod.UID),
+			Node:      pod.Spec.NodeName,
+		})
+	}
+	return actions
+}
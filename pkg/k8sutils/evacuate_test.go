@@ -0,0 +1,154 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	"github.com/portworx/torpedo/pkg/plan"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// newTestEvacuation adds a deployment with two pods on node1 and one on node2 to the server. Each deleted
+// pod is replaced by a pod on node2, like the controller does once node1 is cordoned. The returned pod of
+// the deployment on node1 is not added.
+func newTestEvacuation(server *k8stest.Server) *v1.Pod {
+	dep := newTestDeployment("web", "dep-uid", 3)
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	pods := []*v1.Pod{
+		newTestPod(rs, "web-abc-1", "node1"),
+		newTestPod(rs, "web-abc-2", "node1"),
+		newTestPod(rs, "web-abc-3", "node2"),
+	}
+
+	server.Add(dep, rs, pods[0], pods[1], pods[2])
+	for _, name := range []string{"node1", "node2"} {
+		server.Add(&v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: name}})
+	}
+
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method == http.MethodDelete && req.Resource == "pods" {
+			server.Add(newTestPod(rs, req.Name+"-new", "node2"))
+		}
+		return false, 0, nil
+	})
+
+	return newTestPod(rs, "web-abc-4", "node1")
+}
+
+// getExecutedActions returns the node cordons and uncordons and the pod deletes the server received as
+// plan actions
+func getExecutedActions(t *testing.T, server *k8stest.Server) []plan.Action {
+	var actions []plan.Action
+	for _, req := range server.Requests() {
+		switch {
+		case req.Method == http.MethodDelete && req.Resource == "pods":
+			actions = append(actions, plan.Action{
+				Verb:      plan.VerbDelete,
+				Kind:      planKindPod,
+				Namespace: req.Namespace,
+				Name:      req.Name,
+			})
+		case req.Method == http.MethodPut && req.Resource == "nodes":
+			var node v1.Node
+			data, err := json.Marshal(req.Body)
+			if err != nil || json.Unmarshal(data, &node) != nil {
+				t.Fatalf("failed to decode the node update: %v", req.Body)
+			}
+			verb := plan.VerbUncordon
+			if node.Spec.Unschedulable {
+				verb = plan.VerbCordon
+			}
+			actions = append(actions, plan.Action{Verb: verb, Kind: planKindNode, Name: req.Name})
+		}
+	}
+	return actions
+}
+
+// planTargets returns the actions of the plan without the resolved details that the api requests don't
+// carry, to be compared with getExecutedActions
+func planTargets(p *plan.Plan) []plan.Action {
+	var targets []plan.Action
+	for _, a := range p.Actions {
+		targets = append(targets, plan.Action{Verb: a.Verb, Kind: a.Kind, Namespace: a.Namespace, Name: a.Name})
+	}
+	return targets
+}
+
+func TestEvacuateAppFromNodeExecutesThePlan(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	newTestEvacuation(server)
+	dep := newTestDeployment("web", "dep-uid", 3)
+
+	var reviewed plan.Plan
+	if _, err := EvacuateAppFromNode(dep, "node1", time.Second, plan.WithDryRun(&reviewed)); err != nil {
+		t.Fatalf("failed to plan the evacuation: %v", err)
+	}
+	if executed := getExecutedActions(t, server); len(executed) > 0 {
+		t.Fatalf("expected no mutations in a dry run, got: %v", executed)
+	}
+
+	data, err := json.Marshal(&reviewed)
+	if err != nil {
+		t.Fatalf("failed to serialize the plan: %v", err)
+	}
+	var decoded plan.Plan
+	if err := json.Unmarshal(data, &decoded); err != nil || !reflect.DeepEqual(decoded, reviewed) {
+		t.Fatalf("expected the plan to survive JSON serialization, got: %+v, %v", decoded, err)
+	}
+
+	evacuation, err := EvacuateAppFromNode(dep, "node1", time.Second, plan.WithPlan(&decoded))
+	if err != nil {
+		t.Fatalf("failed to execute the reviewed plan: %v", err)
+	}
+	if deleted := []string{"web-abc-1", "web-abc-2"}; !reflect.DeepEqual(evacuation.DeletedPods, deleted) {
+		t.Errorf("expected the deleted pods: %v, got: %+v", deleted, evacuation)
+	}
+	replacements := map[string]string{"web-abc-1-new": "node2", "web-abc-2-new": "node2"}
+	if !reflect.DeepEqual(evacuation.ReplacementPods, replacements) {
+		t.Errorf("expected the replacement pods: %v, got: %+v", replacements, evacuation)
+	}
+
+	expected := []plan.Action{
+		{Verb: plan.VerbCordon, Kind: planKindNode, Name: "node1"},
+		{Verb: plan.VerbDelete, Kind: planKindPod, Namespace: testNamespace, Name: "web-abc-1"},
+		{Verb: plan.VerbDelete, Kind: planKindPod, Namespace: testNamespace, Name: "web-abc-2"},
+		{Verb: plan.VerbUncordon, Kind: planKindNode, Name: "node1"},
+	}
+	if targets := planTargets(&reviewed); !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected the plan:\n%v\ngot:\n%v", expected, targets)
+	}
+	if executed := getExecutedActions(t, server); !reflect.DeepEqual(executed, planTargets(&reviewed)) {
+		t.Errorf("expected the execution to match the plan:\n%v\ngot:\n%v", reviewed.Actions, executed)
+	}
+}
+
+func TestEvacuateAppFromNodeRefusesOutdatedPlan(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	extra := newTestEvacuation(server)
+	dep := newTestDeployment("web", "dep-uid", 3)
+
+	var reviewed plan.Plan
+	if _, err := EvacuateAppFromNode(dep, "node1", time.Second, plan.WithDryRun(&reviewed)); err != nil {
+		t.Fatalf("failed to plan the evacuation: %v", err)
+	}
+
+	// A pod scheduled on the node after the review
+	server.Add(extra)
+
+	_, err := EvacuateAppFromNode(dep, "node1", time.Second, plan.WithPlan(&reviewed))
+	if _, ok := err.(*plan.ErrMismatch); !ok {
+		t.Fatalf("expected an ErrMismatch, got: %v", err)
+	}
+	if executed := getExecutedActions(t, server); len(executed) > 0 {
+		t.Errorf("expected no mutations for an outdated plan, got: %v", executed)
+	}
+}
@@ -0,0 +1,134 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// maxAttachedEvents is the number of most recent warning events attached to a validation error
+const maxAttachedEvents = 10
+
+// GetEventsForObject returns the events recorded for the object of the given kind (e.g Pod or
+// PersistentVolumeClaim), namespace and name, oldest first
+func GetEventsForObject(kind, namespace, name string) ([]v1.Event, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return listEvents(client, namespaceOrDefault(namespace), meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=%v,involvedObject.name=%v", kind, name),
+	})
+}
+
+// DumpNamespaceEvents returns the events of the given namespace, oldest first, one per line as
+// <last seen> <type> <kind>/<name> <reason> (x<count>): <message>
+func DumpNamespaceEvents(namespace string) (string, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return "", err
+	}
+
+	events, err := listEvents(client, namespaceOrDefault(namespace), meta_v1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, event := range events {
+		lines = append(lines, fmt.Sprintf("%v %v %v/%v %v (x%d): %v",
+			getEventTime(event).Format(time.RFC3339), event.Type, event.InvolvedObject.Kind,
+			event.InvolvedObject.Name, event.Reason, event.Count, strings.TrimSpace(event.Message)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// getDeploymentWarningEvents returns the warning events seen since the given time for the deployment, its
+// replicasets, its pods and its PVCs, e.g FailedScheduling, FailedMount or ProvisioningFailed, up to the most
+// recent maxAttachedEvents
+func getDeploymentWarningEvents(deployment *v1beta1.Deployment, since time.Time) ([]v1.Event, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := namespaceOrDefault(deployment.Namespace)
+	events, err := listEvents(client, namespace, meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("type=%v", v1.EventTypeWarning),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	involved := map[string]bool{
+		"Deployment/" + deployment.Name: true,
+	}
+	for _, name := range getDeploymentPVCNames(deployment) {
+		involved["PersistentVolumeClaim/"+name] = true
+	}
+	if pods, err := GetDeploymentPods(deployment); err == nil {
+		for _, pod := range pods {
+			involved["Pod/"+pod.Name] = true
+			for _, owner := range pod.OwnerReferences {
+				involved[owner.Kind+"/"+owner.Name] = true
+			}
+		}
+	}
+
+	var result []v1.Event
+	for _, event := range events {
+		if !involved[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] || getEventTime(event).Before(since) {
+			continue
+		}
+		result = append(result, event)
+	}
+
+	if len(result) > maxAttachedEvents {
+		result = result[len(result)-maxAttachedEvents:]
+	}
+	return result, nil
+}
+
+// listEvents returns the events of the namespace matching the list options, oldest first
+func listEvents(client *kubernetes.Clientset, namespace string, opts meta_v1.ListOptions) ([]v1.Event, error) {
+	events, err := client.CoreV1().Events(namespace).List(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	sortEvents(events.Items)
+	return events.Items, nil
+}
+
+func formatEvents(events []v1.Event) string {
+	var parts []string
+	for _, event := range events {
+		parts = append(parts, fmt.Sprintf("%v/%v %v: %v", event.InvolvedObject.Kind, event.InvolvedObject.Name,
+			event.Reason, strings.TrimSpace(event.Message)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func sortEvents(events []v1.Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return getEventTime(events[i]).Before(getEventTime(events[j]))
+	})
+}
+
+// getEventTime returns when the event was last seen
+func getEventTime(event v1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	if !event.FirstTimestamp.IsZero() {
+		return event.FirstTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
@@ -0,0 +1,72 @@
+package k8sutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// newTestEvent returns an event of the given type about the object, last seen at the given time
+func newTestEvent(name, eventType, kind, object, reason string, seen time.Time) *v1.Event {
+	return &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: name, Namespace: testNamespace},
+		InvolvedObject: v1.ObjectReference{Kind: kind, Name: object, Namespace: testNamespace},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        reason + " of " + object,
+		Count:          1,
+		LastTimestamp:  meta_v1.NewTime(seen),
+	}
+}
+
+func TestGetDeploymentWarningEvents(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	dep.Spec.Template.Spec.Volumes = []v1.Volume{{
+		Name: "data",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: "web-data"},
+		},
+	}}
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	server.Add(dep, rs, newTestPod(rs, "web-abc-1", "node1"),
+		newTestEvent("e1", v1.EventTypeWarning, "Pod", "web-abc-1", "FailedMount", at(3)),
+		newTestEvent("e2", v1.EventTypeWarning, "PersistentVolumeClaim", "web-data", "ProvisioningFailed", at(1)),
+		newTestEvent("e3", v1.EventTypeNormal, "PersistentVolumeClaim", "web-data", "Provisioning", at(2)),
+		newTestEvent("e4", v1.EventTypeWarning, "PersistentVolumeClaim", "other-data", "ProvisioningFailed", at(2)),
+		newTestEvent("e5", v1.EventTypeWarning, "ReplicaSet", "web-abc", "FailedCreate", at(-5)),
+	)
+
+	events, err := getDeploymentWarningEvents(dep, start)
+	if err != nil {
+		t.Fatalf("failed to get the events: %v", err)
+	}
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Name)
+	}
+	if expected := []string{"e2", "e1"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected the events: %v, got: %v", expected, names)
+	}
+
+	dump, err := DumpNamespaceEvents(testNamespace)
+	if err != nil {
+		t.Fatalf("failed to dump the events: %v", err)
+	}
+	lines := strings.Split(dump, "\n")
+	oldest := "Warning ReplicaSet/web-abc FailedCreate (x1): FailedCreate of web-abc"
+	if len(lines) != 5 || !strings.HasSuffix(lines[0], oldest) || !strings.Contains(lines[4], "Pod/web-abc-1 FailedMount") {
+		t.Errorf("expected the events of the namespace oldest first, got:\n%v", dump)
+	}
+}
@@ -0,0 +1,260 @@
+package k8sutils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/net/websocket"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// execProtocolV4 is the websocket channel protocol that reports the exit status as a metav1.Status
+	execProtocolV4 = "v4.channel.k8s.io"
+	// execProtocolV1 is the websocket channel protocol that reports errors as plain text
+	execProtocolV1 = "channel.k8s.io"
+
+	execStdoutChannel = 1
+	execStderrChannel = 2
+	execErrorChannel  = 3
+
+	execNonZeroExitCodeReason = "NonZeroExitCode"
+	execExitCodeCauseType     = "ExitCode"
+
+	// runCommandTimeout is how long RunCommandInPod waits for a command, long enough for data integrity
+	// checks
+	runCommandTimeout = 30 * time.Minute
+)
+
+// RunCommandInPod runs the given command in the container of the pod, its first container if empty, and
+// returns the stdout and stderr of the command. If the command exits with a non-zero code, the error is an
+// ErrFailedToExecInPod with the exit code. It waits for the command for up to 30 minutes.
+func RunCommandInPod(pod *v1.Pod, container string, cmd []string) (string, string, error) {
+	return RunCommandInPodWithTimeout(pod, container, cmd, 0)
+}
+
+// RunCommandInPodWithTimeout is RunCommandInPod waiting for the command for up to the given timeout, or 30
+// minutes if zero. On timeout the connection to the api server is closed and an ErrFailedToExecInPod is
+// returned, but the command is not killed: it keeps running in the container until it exits by itself.
+// Commands that must not outlive the timeout should bound themselves, e.g with timeout(1).
+func RunCommandInPodWithTimeout(pod *v1.Pod, container string, cmd []string, timeout time.Duration) (string, string, error) {
+	if timeout == 0 {
+		timeout = runCommandTimeout
+	}
+
+	if len(container) == 0 {
+		if len(pod.Spec.Containers) == 0 {
+			return "", "", fmt.Errorf("pod: %v/%v has no containers", pod.Namespace, pod.Name)
+		}
+		container = pod.Spec.Containers[0].Name
+	}
+
+	target := *pod
+	target.Namespace = namespaceOrDefault(pod.Namespace)
+	return execInPod(target, container, cmd, timeout)
+}
+
+// execInPod runs the given command in the container of the pod through the api server's exec
+// websocket endpoint and returns the stdout and stderr of the command
+func execInPod(pod v1.Pod, container string, cmd []string, timeout time.Duration) (string, string, error) {
+	config, err := GetRestConfig()
+	if err != nil {
+		return "", "", err
+	}
+
+	wsConfig, err := execWebsocketConfig(config, pod, container, cmd)
+	if err != nil {
+		return "", "", err
+	}
+	deadline := time.Now().Add(timeout)
+	ws, err := dialWebsocket(wsConfig, deadline)
+	if err != nil {
+		return "", "", &ErrFailedToExecInPod{
+			Pod:      pod.Name,
+			Command:  cmd,
+			ExitCode: -1,
+			Cause:    fmt.Sprintf("failed to connect to exec endpoint: %v", err),
+		}
+	}
+	defer ws.Close()
+
+	var stdout, stderr, errOut bytes.Buffer
+	for {
+		var msg []byte
+		if err := websocket.Message.Receive(ws, &msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return stdout.String(), stderr.String(), &ErrFailedToExecInPod{
+				Pod:      pod.Name,
+				Command:  cmd,
+				ExitCode: -1,
+				Cause:    fmt.Sprintf("failed to read exec output: %v", err),
+			}
+		}
+
+		if len(msg) < 2 {
+			continue
+		}
+
+		switch msg[0] {
+		case execStdoutChannel:
+			stdout.Write(msg[1:])
+		case execStderrChannel:
+			stderr.Write(msg[1:])
+		case execErrorChannel:
+			errOut.Write(msg[1:])
+		}
+	}
+
+	if err := parseExecError(wsConfig.Protocol, errOut.Bytes()); err != nil {
+		err.Pod = pod.Name
+		err.Command = cmd
+		return stdout.String(), stderr.String(), err
+	}
+
+	return stdout.String(), stderr.String(), nil
+}
+
+// dialWebsocket opens the websocket connection of the config. The connection, TLS and websocket handshakes
+// and all later reads and writes must complete before the deadline.
+func dialWebsocket(config *websocket.Config, deadline time.Time) (*websocket.Conn, error) {
+	host := config.Location.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		port := "443"
+		if config.Location.Scheme == "ws" {
+			port = "80"
+		}
+		host = net.JoinHostPort(host, port)
+	}
+
+	dialer := &net.Dialer{Deadline: deadline}
+	var conn net.Conn
+	var err error
+	if config.Location.Scheme == "ws" {
+		conn, err = dialer.Dial("tcp", host)
+	} else {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, config.TlsConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(deadline); err != nil {
+		closeConn(conn)
+		return nil, err
+	}
+
+	ws, err := websocket.NewClient(config, conn)
+	if err != nil {
+		closeConn(conn)
+		return nil, err
+	}
+	return ws, nil
+}
+
+// closeConn closes a connection that failed to set up
+func closeConn(conn net.Conn) {
+	if err := conn.Close(); err != nil {
+		logrus.Debugf("Failed to close connection to: %v. Err: %v", conn.RemoteAddr(), err)
+	}
+}
+
+func execWebsocketConfig(config *rest.Config, pod v1.Pod, container string, cmd []string) (*websocket.Config, error) {
+	host, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for _, arg := range cmd {
+		query.Add("command", arg)
+	}
+	query.Set("container", container)
+	query.Set("stdout", "true")
+	query.Set("stderr", "true")
+
+	location := *host
+	location.Scheme = "wss"
+	if host.Scheme == "http" {
+		location.Scheme = "ws"
+	}
+	location.Path = fmt.Sprintf("/api/v1/namespaces/%v/pods/%v/exec", pod.Namespace, pod.Name)
+	location.RawQuery = query.Encode()
+
+	wsConfig, err := websocket.NewConfig(location.String(), host.String())
+	if err != nil {
+		return nil, err
+	}
+
+	wsConfig.Protocol = []string{execProtocolV4, execProtocolV1}
+	if wsConfig.TlsConfig, err = rest.TLSConfigFor(config); err != nil {
+		return nil, err
+	}
+
+	if len(config.BearerToken) > 0 {
+		wsConfig.Header.Set("Authorization", "Bearer "+config.BearerToken)
+	} else if len(config.Username) > 0 {
+		wsConfig.Header.Set("Authorization", "Basic "+basicAuth(config.Username, config.Password))
+	}
+
+	return wsConfig, nil
+}
+
+// parseExecError parses the content of the exec error channel for the negotiated protocol
+func parseExecError(protocol []string, errOut []byte) *ErrFailedToExecInPod {
+	if len(errOut) == 0 {
+		return nil
+	}
+
+	if len(protocol) == 0 || protocol[0] != execProtocolV4 {
+		return &ErrFailedToExecInPod{
+			ExitCode: -1,
+			Cause:    string(errOut),
+		}
+	}
+
+	var status meta_v1.Status
+	if err := json.Unmarshal(errOut, &status); err != nil {
+		return &ErrFailedToExecInPod{
+			ExitCode: -1,
+			Cause:    fmt.Sprintf("failed to parse exec status: %v (%v)", string(errOut), err),
+		}
+	}
+
+	if status.Status == meta_v1.StatusSuccess {
+		return nil
+	}
+
+	exitCode := -1
+	if status.Reason == execNonZeroExitCodeReason && status.Details != nil {
+		for _, cause := range status.Details.Causes {
+			if string(cause.Type) == execExitCodeCauseType {
+				if code, err := strconv.Atoi(cause.Message); err == nil {
+					exitCode = code
+				}
+			}
+		}
+	}
+
+	return &ErrFailedToExecInPod{
+		ExitCode: exitCode,
+		Cause:    strings.TrimSpace(status.Message),
+	}
+}
+
+func basicAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
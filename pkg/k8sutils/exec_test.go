@@ -0,0 +1,61 @@
+package k8sutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/rest"
+)
+
+// newTestExecServer starts a server whose exec endpoint writes the given stdout and then, if hang is set,
+// keeps the connection open until the client closes it
+func newTestExecServer(stdout string, hang bool) *httptest.Server {
+	return httptest.NewServer(websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			config.Protocol = []string{execProtocolV1}
+			return nil
+		},
+		Handler: func(ws *websocket.Conn) {
+			websocket.Message.Send(ws, append([]byte{execStdoutChannel}, stdout...))
+			if hang {
+				var msg []byte
+				websocket.Message.Receive(ws, &msg)
+			}
+		},
+	})
+}
+
+func TestRunCommandInPodWithTimeout(t *testing.T) {
+	pod := &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "web-1", Namespace: testNamespace},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "app"}}},
+	}
+	defer SetRestConfig(nil)
+
+	server := newTestExecServer("d41d8cd9  /data/file\n", false)
+	defer server.Close()
+	SetRestConfig(&rest.Config{Host: server.URL})
+
+	stdout, _, err := RunCommandInPod(pod, "", []string{"md5sum", "/data/file"})
+	if err != nil || stdout != "d41d8cd9  /data/file\n" {
+		t.Errorf("expected the output of the command, got: %q, %v", stdout, err)
+	}
+
+	hanging := newTestExecServer("started\n", true)
+	defer hanging.Close()
+	SetRestConfig(&rest.Config{Host: hanging.URL})
+
+	start := time.Now()
+	stdout, _, err = RunCommandInPodWithTimeout(pod, "app", []string{"fio", "--verify=md5"}, 100*time.Millisecond)
+	if _, ok := err.(*ErrFailedToExecInPod); !ok || stdout != "started\n" {
+		t.Errorf("expected an ErrFailedToExecInPod with the output so far, got: %q, %v", stdout, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the timeout to be honored, took: %v", elapsed)
+	}
+}
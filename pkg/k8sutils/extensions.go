@@ -0,0 +1,107 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	apiExtensionsGroup   = "apiextensions.k8s.io"
+	apiExtensionsVersion = "v1beta1"
+	// crdResource is the resource of the custom resource definitions in the apiextensions group
+	crdResource = "customresourcedefinitions"
+	// crdEstablishedCondition is the condition of a CRD that is true once its resources are served
+	crdEstablishedCondition = "Established"
+	// crdNamesAcceptedCondition is the condition of a CRD that is false if its names conflict with another CRD
+	crdNamesAcceptedCondition = "NamesAccepted"
+
+	crdEstablishedRetryInterval = 2 * time.Second
+)
+
+var (
+	restConfigLock sync.Mutex
+	// baseRestConfig is the cached in-cluster config, without the transports added by GetRestConfig
+	baseRestConfig *rest.Config
+)
+
+// crdStatus is the part of a CRD with its conditions
+type crdStatus struct {
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// GetDynamicClient returns a client for the resources of the given group version, e.g the custom
+// resources of a CRD. The client doesn't know the types of the resources so the responses are read raw,
+// with DoRaw, and unmarshalled by the caller.
+func GetDynamicClient(gv schema.GroupVersion) (*rest.RESTClient, error) {
+	config, err := GetRestConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	if len(gv.Group) == 0 {
+		config.APIPath = "/api"
+	}
+	config.NegotiatedSerializer = scheme.Codecs
+	if len(config.UserAgent) == 0 {
+		config.UserAgent = rest.DefaultKubernetesUserAgent()
+	}
+
+	return rest.RESTClientFor(config)
+}
+
+// GetAPIExtensionsClient returns a client for the apiextensions.k8s.io/v1beta1 API that manages the
+// custom resource definitions
+func GetAPIExtensionsClient() (*rest.RESTClient, error) {
+	return GetDynamicClient(schema.GroupVersion{
+		Group:   apiExtensionsGroup,
+		Version: apiExtensionsVersion,
+	})
+}
+
+// WaitForCRDEstablished waits until the custom resource definition with the given name is established,
+// i.e its custom resources are served by the api server
+func WaitForCRDEstablished(name string, timeout time.Duration) error {
+	client, err := GetAPIExtensionsClient()
+	if err != nil {
+		return err
+	}
+
+	t := func() error {
+		data, err := client.Get().Resource(crdResource).Name(name).DoRaw()
+		if err != nil {
+			return err
+		}
+
+		var crd crdStatus
+		if err := json.Unmarshal(data, &crd); err != nil {
+			return err
+		}
+
+		for _, condition := range crd.Status.Conditions {
+			switch {
+			case condition.Type == crdEstablishedCondition && condition.Status == "True":
+				return nil
+			case condition.Type == crdNamesAcceptedCondition && condition.Status == "False":
+				return fmt.Errorf("names of CRD: %v are not accepted. Cause: %v", name, condition.Message)
+			}
+		}
+
+		return fmt.Errorf("CRD: %v is not established yet", name)
+	}
+
+	return doRetryWithTimeout(t, timeout, crdEstablishedRetryInterval)
+}
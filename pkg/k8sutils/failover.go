@@ -0,0 +1,147 @@
+package k8sutils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+var (
+	endpointsLock sync.RWMutex
+	// apiEndpoints are the <scheme>://<host>[:<port>] of the api servers to fail over between
+	apiEndpoints []*url.URL
+	// currentEndpoint is the index of the endpoint requests are sent to until it fails
+	currentEndpoint int
+)
+
+// SetAPIServerEndpoints sets the api servers that requests fail over to when the api server they are sent
+// to can't be dialed. GET, HEAD and OPTIONS requests also fail over on other connection errors and timeouts,
+// while other requests may already have reached the server and are not sent again. Requests stick to the
+// last endpoint that answered. An endpoint without a scheme uses https. An empty list disables the failover.
+func SetAPIServerEndpoints(endpoints []string) error {
+	var parsed []*url.URL
+	for _, endpoint := range endpoints {
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid api server endpoint: %v. Err: %v", endpoint, err)
+		}
+		parsed = append(parsed, u)
+	}
+
+	endpointsLock.Lock()
+	defer endpointsLock.Unlock()
+	apiEndpoints = parsed
+	currentEndpoint = 0
+	return nil
+}
+
+// failoverRoundTripper sends requests to the current api server endpoint and, on connection failures, to
+// the next endpoints in turn
+type failoverRoundTripper struct {
+	rt http.RoundTripper
+}
+
+func newFailoverRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &failoverRoundTripper{rt: rt}
+}
+
+func (f *failoverRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpointsLock.RLock()
+	endpoints := apiEndpoints
+	start := currentEndpoint
+	endpointsLock.RUnlock()
+
+	if len(endpoints) == 0 {
+		return f.rt.RoundTrip(req)
+	}
+
+	var err error
+	for i := 0; i < len(endpoints); i++ {
+		idx := (start + i) % len(endpoints)
+
+		attempt := copyRequest(req)
+		attempt.URL.Scheme = endpoints[idx].Scheme
+		attempt.URL.Host = endpoints[idx].Host
+		attempt.Host = ""
+		if i > 0 && req.Body != nil {
+			// The body of the failed attempt was consumed
+			if req.GetBody == nil {
+				return nil, err
+			}
+			if attempt.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+
+		var resp *http.Response
+		resp, err = f.rt.RoundTrip(attempt)
+		if err == nil {
+			if idx != start {
+				endpointsLock.Lock()
+				currentEndpoint = idx
+				endpointsLock.Unlock()
+				logrus.Infof("Failed over to api server: %v", endpoints[idx].Host)
+			}
+			return resp, nil
+		}
+
+		if !canFailOver(req, err) {
+			return nil, err
+		}
+
+		logrus.Warnf("Request to api server: %v failed. Err: %v", endpoints[idx].Host, err)
+	}
+
+	return nil, err
+}
+
+// copyRequest returns a copy of the request whose URL and headers can be modified without changing the
+// original request
+func copyRequest(req *http.Request) *http.Request {
+	attempt := new(http.Request)
+	*attempt = *req
+
+	u := *req.URL
+	attempt.URL = &u
+
+	attempt.Header = make(http.Header, len(req.Header))
+	for key, values := range req.Header {
+		attempt.Header[key] = append([]string(nil), values...)
+	}
+	return attempt
+}
+
+// canFailOver checks if the request can be sent to another endpoint after failing with the given error. A
+// request that failed to dial never reached the server. Other connection errors and timeouts are only
+// retried for idempotent methods, as the server may have already processed the request.
+func canFailOver(req *http.Request, err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+
+	opErr, isOpErr := err.(*net.OpError)
+	if isOpErr && opErr.Op == "dial" {
+		return true
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+
+	if isOpErr {
+		return true
+	}
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
@@ -0,0 +1,176 @@
+package k8sutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer starts an api server stub counting the requests it receives
+func countingServer(count *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(count, 1)
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+// closedServerURL returns the URL of a server that no longer accepts connections
+func closedServerURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+// setTestEndpoints sets the failover endpoints and returns a func restoring the previous ones
+func setTestEndpoints(t *testing.T, endpoints ...string) func() {
+	endpointsLock.RLock()
+	previous, previousCurrent := apiEndpoints, currentEndpoint
+	endpointsLock.RUnlock()
+
+	if err := SetAPIServerEndpoints(endpoints); err != nil {
+		t.Fatalf("failed to set the endpoints: %v", err)
+	}
+
+	return func() {
+		endpointsLock.Lock()
+		apiEndpoints, currentEndpoint = previous, previousCurrent
+		endpointsLock.Unlock()
+	}
+}
+
+func sendThroughFailover(t *testing.T, rt http.RoundTripper, method, body string) (*http.Response, error) {
+	req, err := http.NewRequest(method, "https://ignored.invalid/api/v1/pods", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to build the request: %v", err)
+	}
+	return newFailoverRoundTripper(rt).RoundTrip(req)
+}
+
+func TestFailoverOnDialError(t *testing.T) {
+	var count int32
+	live := countingServer(&count)
+	defer live.Close()
+
+	defer setTestEndpoints(t, closedServerURL(), live.URL)()
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch} {
+		resp, err := sendThroughFailover(t, http.DefaultTransport, method, "{}")
+		if err != nil {
+			t.Fatalf("%v: expected the request to fail over, got: %v", method, err)
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Errorf("%v: failed to close the body: %v", method, err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&count); got != 4 {
+		t.Errorf("expected 4 requests on the live endpoint, got %v", got)
+	}
+
+	endpointsLock.RLock()
+	current := currentEndpoint
+	endpointsLock.RUnlock()
+	if current != 1 {
+		t.Errorf("expected requests to stick to the live endpoint, current endpoint is %v", current)
+	}
+}
+
+func TestFailoverOnTimeoutOnlyForIdempotentMethods(t *testing.T) {
+	block := make(chan struct{})
+	var hung int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hung, 1)
+		<-block
+	}))
+	defer slow.Close()
+	defer close(block)
+
+	var count int32
+	live := countingServer(&count)
+	defer live.Close()
+
+	transport := &http.Transport{ResponseHeaderTimeout: 100 * time.Millisecond}
+	defer transport.CloseIdleConnections()
+
+	tests := []struct {
+		method     string
+		failedOver bool
+	}{
+		{method: http.MethodGet, failedOver: true},
+		{method: http.MethodHead, failedOver: true},
+		{method: http.MethodPost},
+		{method: http.MethodPut},
+		{method: http.MethodPatch},
+		{method: http.MethodDelete},
+	}
+
+	for _, test := range tests {
+		restore := setTestEndpoints(t, slow.URL, live.URL)
+		before := atomic.LoadInt32(&count)
+
+		resp, err := sendThroughFailover(t, transport, test.method, "")
+		if err == nil {
+			if err := resp.Body.Close(); err != nil {
+				t.Errorf("%v: failed to close the body: %v", test.method, err)
+			}
+		}
+		restore()
+
+		failedOver := atomic.LoadInt32(&count) > before
+		if failedOver != test.failedOver {
+			t.Errorf("%v: expected failover: %v, got: %v (err: %v)", test.method, test.failedOver, failedOver, err)
+		}
+		if !test.failedOver && err == nil {
+			t.Errorf("%v: expected the timeout to be returned", test.method)
+		}
+	}
+
+	if got := atomic.LoadInt32(&hung); got != int32(len(tests)) {
+		t.Errorf("expected every request to reach the slow endpoint first, got %v", got)
+	}
+}
+
+func TestFailoverDisabledWithoutEndpoints(t *testing.T) {
+	var count int32
+	live := countingServer(&count)
+	defer live.Close()
+
+	defer setTestEndpoints(t)()
+
+	req, err := http.NewRequest(http.MethodGet, live.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to build the request: %v", err)
+	}
+	resp, err := newFailoverRoundTripper(http.DefaultTransport).RoundTrip(req)
+	if err != nil {
+		t.Fatalf("expected the request to be sent as is, got: %v", err)
+	}
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("failed to close the body: %v", err)
+	}
+	if got := atomic.LoadInt32(&count); got != 1 {
+		t.Errorf("expected 1 request on the server, got %v", got)
+	}
+}
+
+func TestCopyRequestDoesNotModifyOriginal(t *testing.T) {
+	req, err := http.NewRequest(http.MethodGet, "https://10.0.0.1:6443/api/v1/pods", nil)
+	if err != nil {
+		t.Fatalf("failed to build the request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+
+	attempt := copyRequest(req)
+	attempt.URL.Host = "10.0.0.2:6443"
+	attempt.Header.Set("Authorization", "Bearer other")
+
+	if req.URL.Host != "10.0.0.1:6443" {
+		t.Errorf("expected the original host to be kept, got %v", req.URL.Host)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("expected the original header to be kept, got %v", got)
+	}
+}
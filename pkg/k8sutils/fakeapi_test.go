@@ -0,0 +1,131 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const testNamespace = "test"
+
+// testProfile keeps the validations of the tests short
+var testProfile = Profile{
+	Name:             "test",
+	AppReadyTimeout:  time.Second,
+	AppDeleteTimeout: time.Second,
+	PVCBindTimeout:   time.Second,
+	RetryInterval:    10 * time.Millisecond,
+}
+
+// newTestAPIServer starts a k8stest server that the package talks to until the returned func is called
+func newTestAPIServer(t *testing.T) (*k8stest.Server, func()) {
+	server := k8stest.NewServer()
+	SetRestConfig(server.Config())
+
+	profile := GetValidationProfile()
+	SetValidationProfile(testProfile)
+
+	return server, func() {
+		SetValidationProfile(profile)
+		SetRestConfig(nil)
+		server.Close()
+	}
+}
+
+// newTestDeployment returns a deployment of the given replicas selecting the app=<name> label
+func newTestDeployment(name string, uid types.UID, replicas int32) *v1beta1.Deployment {
+	labels := map[string]string{"app": name}
+	return &v1beta1.Deployment{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			UID:       uid,
+		},
+		Spec: v1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &meta_v1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{Name: "app", Image: "busybox"}},
+				},
+			},
+		},
+		Status: v1beta1.DeploymentStatus{
+			Replicas:          replicas,
+			ReadyReplicas:     replicas,
+			AvailableReplicas: replicas,
+		},
+	}
+}
+
+// newTestReplicaSet returns the replica set of the given revision of the deployment, named and labeled
+// with the given pod-template-hash
+func newTestReplicaSet(dep *v1beta1.Deployment, hash string, uid types.UID, revision int64) *ext_v1beta1.ReplicaSet {
+	labels := map[string]string{k8sPodTemplateHashKey: hash}
+	for key, value := range dep.Spec.Template.Labels {
+		labels[key] = value
+	}
+
+	rs := &ext_v1beta1.ReplicaSet{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        fmt.Sprintf("%v-%v", dep.Name, hash),
+			Namespace:   dep.Namespace,
+			UID:         uid,
+			Labels:      labels,
+			Annotations: map[string]string{k8sRevisionAnnotation: fmt.Sprintf("%d", revision)},
+			OwnerReferences: []meta_v1.OwnerReference{
+				{Kind: "Deployment", Name: dep.Name, UID: dep.UID},
+			},
+		},
+		Spec: ext_v1beta1.ReplicaSetSpec{
+			Replicas: dep.Spec.Replicas,
+		},
+	}
+	rs.Spec.Template.ObjectMeta.Labels = labels
+	rs.Spec.Template.Spec = dep.Spec.Template.Spec
+	return rs
+}
+
+// newTestPod returns a running and ready pod owned by the given replica set
+func newTestPod(rs *ext_v1beta1.ReplicaSet, name string, node string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: rs.Namespace,
+			UID:       types.UID(name + "-uid"),
+			Labels:    rs.Spec.Template.Labels,
+			OwnerReferences: []meta_v1.OwnerReference{
+				{Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID},
+			},
+		},
+		Spec: v1.PodSpec{
+			NodeName:   node,
+			Containers: rs.Spec.Template.Spec.Containers,
+		},
+		Status: v1.PodStatus{
+			Phase: v1.PodRunning,
+			Conditions: []v1.PodCondition{
+				{Type: v1.PodReady, Status: v1.ConditionTrue},
+			},
+		},
+	}
+}
+
+// podNames returns the sorted names of the given pods
+func podNames(pods []v1.Pod) []string {
+	var names []string
+	for _, pod := range pods {
+		names = append(names, pod.Name)
+	}
+	sort.Strings(names)
+	return names
+}
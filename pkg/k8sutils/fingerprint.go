@@ -0,0 +1,86 @@
+package k8sutils
+
+import (
+	"sort"
+	"strings"
+)
+
+// GetClusterFingerprint returns the api server version, the provider, OS and container runtime details of
+// every node and the node properties that are not the same on all nodes
+func GetClusterFingerprint() (ClusterInfo, error) {
+	var info ClusterInfo
+
+	version, err := GetK8sVersion()
+	if err != nil {
+		return info, err
+	}
+	info.ServerVersion = version.GitVersion
+
+	nodes, err := GetNodes()
+	if err != nil {
+		return info, err
+	}
+
+	providers := make(map[string]bool)
+	for _, n := range nodes.Items {
+		node := getNodeInfo(n)
+		info.Nodes = append(info.Nodes, node)
+
+		if node.Master {
+			info.MasterCount++
+		}
+
+		// Provider IDs are <provider>://<provider specific id>
+		if i := strings.Index(node.ProviderID, "://"); i > 0 {
+			providers[node.ProviderID[:i]] = true
+		}
+	}
+
+	sort.Slice(info.Nodes, func(i, j int) bool {
+		return info.Nodes[i].Name < info.Nodes[j].Name
+	})
+	info.NodeCount = len(info.Nodes)
+	info.CloudProviders = sortedKeys(providers)
+	info.Heterogeneous = DetectHeterogeneity(info.Nodes)
+
+	return info, nil
+}
+
+// DetectHeterogeneity returns the kernel, OS, container runtime and kubelet versions and the container
+// runtimes that differ between the given nodes, mapped to their distinct values. It returns nil if all
+// the nodes have the same versions.
+func DetectHeterogeneity(nodes []NodeInfo) map[string][]string {
+	properties := map[string]func(NodeInfo) string{
+		"kernelVersion":           func(n NodeInfo) string { return n.KernelVersion },
+		"osImage":                 func(n NodeInfo) string { return n.OSImage },
+		"containerRuntime":        func(n NodeInfo) string { return n.ContainerRuntime },
+		"containerRuntimeVersion": func(n NodeInfo) string { return n.ContainerRuntimeVersion },
+		"kubeletVersion":          func(n NodeInfo) string { return n.KubeletVersion },
+	}
+
+	var mixed map[string][]string
+	for property, get := range properties {
+		values := make(map[string]bool)
+		for _, n := range nodes {
+			values[get(n)] = true
+		}
+
+		if len(values) > 1 {
+			if mixed == nil {
+				mixed = make(map[string][]string)
+			}
+			mixed[property] = sortedKeys(values)
+		}
+	}
+
+	return mixed
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,147 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	// defaultTerminationGracePeriod is the grace period of pods that don't set one
+	defaultTerminationGracePeriod = 30 * time.Second
+	// gracePeriodTolerance is how much longer than their grace period pods may take to terminate
+	gracePeriodTolerance = 5 * time.Second
+	// terminationPollInterval is the interval at which terminating pods are checked
+	terminationPollInterval = time.Second
+)
+
+// MeasurePodTerminationTimes waits for the given pods, which are expected to be deleted, to disappear
+// and returns the time each pod took from its deletion to its disappearance, by pod name. On timeout, the
+// times of the pods that terminated are returned along with the error.
+func MeasurePodTerminationTimes(pods []v1.Pod, timeout time.Duration) (map[string]time.Duration, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	times := make(map[string]time.Duration)
+	deletedAt := make(map[string]time.Time)
+	deadline := time.Now().Add(timeout)
+	for {
+		var remaining []string
+		for _, pod := range pods {
+			if _, done := times[pod.Name]; done {
+				continue
+			}
+
+			current, err := client.CoreV1().Pods(pod.Namespace).Get(pod.Name, meta_v1.GetOptions{})
+			if err != nil && !k8s_errors.IsNotFound(err) {
+				return times, err
+			}
+
+			// A pod with the same name but another UID replaced the measured pod
+			if k8s_errors.IsNotFound(err) || current.UID != pod.UID {
+				start, ok := deletedAt[pod.Name]
+				if !ok {
+					start = getPodDeletionTime(&pod)
+				}
+				times[pod.Name] = time.Since(start)
+				continue
+			}
+
+			if _, ok := deletedAt[pod.Name]; !ok && current.DeletionTimestamp != nil {
+				deletedAt[pod.Name] = getPodDeletionTime(current)
+			}
+			remaining = append(remaining, pod.Name)
+		}
+
+		if len(remaining) == 0 {
+			return times, nil
+		}
+
+		if time.Now().After(deadline) {
+			return times, fmt.Errorf("timed out after %v waiting for pods to terminate: %v", timeout, remaining)
+		}
+
+		time.Sleep(terminationPollInterval)
+	}
+}
+
+// ValidateTerminationWithinGracePeriod deletes the pods of the given deployment with their grace period
+// and validates that each pod terminates within its grace period, plus a small tolerance. The termination
+// time of every pod is returned, also when some pods exceed their grace period.
+func ValidateTerminationWithinGracePeriod(deployment *v1beta1.Deployment) (map[string]time.Duration, error) {
+	if err := CheckDestructiveOp("ValidateTerminationWithinGracePeriod"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	maxGracePeriod := defaultTerminationGracePeriod
+	gracePeriods := make(map[string]time.Duration)
+	for _, pod := range pods {
+		gracePeriod := defaultTerminationGracePeriod
+		if pod.Spec.TerminationGracePeriodSeconds != nil {
+			gracePeriod = time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+		}
+		gracePeriods[pod.Name] = gracePeriod
+		if gracePeriod > maxGracePeriod {
+			maxGracePeriod = gracePeriod
+		}
+
+		if err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &meta_v1.DeleteOptions{}); err != nil &&
+			!k8s_errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	// Wait long enough to measure how much the pods exceed their grace period
+	timeout := 2*maxGracePeriod + time.Minute
+	times, err := MeasurePodTerminationTimes(pods, timeout)
+
+	exceeded := make(map[string]time.Duration)
+	for name, gracePeriod := range gracePeriods {
+		actual, ok := times[name]
+		if !ok {
+			// The pod didn't terminate before the timeout
+			exceeded[name] = timeout
+		} else if actual > gracePeriod+gracePeriodTolerance {
+			exceeded[name] = actual
+		}
+	}
+
+	if len(exceeded) > 0 {
+		return times, &ErrGracePeriodExceeded{
+			ID:   deployment.Name,
+			Pods: exceeded,
+		}
+	}
+
+	return times, err
+}
+
+// getPodDeletionTime returns when the deletion of the pod was requested. The deletion timestamp of a pod
+// is the end of its grace period. Pods that are not being deleted return the current time.
+func getPodDeletionTime(pod *v1.Pod) time.Time {
+	if pod.DeletionTimestamp == nil {
+		return time.Now()
+	}
+
+	start := pod.DeletionTimestamp.Time
+	if pod.DeletionGracePeriodSeconds != nil {
+		start = start.Add(-time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second)
+	}
+	return start
+}
@@ -0,0 +1,101 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// GetRunningImagesForDeployment returns, for each pod of the given deployment, the Image and ImageID
+// values reported by its container statuses. Pods that are being deleted are skipped.
+func GetRunningImagesForDeployment(deployment *v1beta1.Deployment) (map[string][]string, error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		var images []string
+		for _, status := range pod.Status.ContainerStatuses {
+			images = append(images, status.Image, status.ImageID)
+		}
+		result[pod.Name] = images
+	}
+
+	return result, nil
+}
+
+// ValidateDeploymentImage waits until all pods of the given deployment report the expected image for
+// the given container. The expected image may be a tag (e.g nginx:1.13) or a digest (e.g nginx@sha256:...).
+// Pods of an old replica set that are still being deleted are ignored.
+func ValidateDeploymentImage(
+	deployment *v1beta1.Deployment,
+	container string,
+	expectedImage string,
+	timeout time.Duration,
+) error {
+	t := func() error {
+		pods, err := GetDeploymentPods(deployment)
+		if err != nil {
+			return err
+		}
+
+		var stalePods []string
+		for _, pod := range pods {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+
+			status, found := getContainerStatus(pod, container)
+			if !found || !containerHasImage(status, expectedImage) {
+				stalePods = append(stalePods, pod.Name)
+			}
+		}
+
+		if len(stalePods) > 0 {
+			return &ErrAppNotReady{
+				ID: deployment.Name,
+				Cause: fmt.Sprintf("pods: %v are not running image: %v in container: %v",
+					stalePods, expectedImage, container),
+			}
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+		// Check once more so the caller gets the list of pods still on the old image
+		if checkErr := t(); checkErr != nil {
+			return checkErr
+		}
+	}
+
+	return nil
+}
+
+func getContainerStatus(pod v1.Pod, container string) (v1.ContainerStatus, bool) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == container {
+			return status, true
+		}
+	}
+	return v1.ContainerStatus{}, false
+}
+
+// containerHasImage checks if the container status matches the given image by digest, or by tag
+// allowing for a registry prefix added by the runtime (e.g docker.io/library/)
+func containerHasImage(status v1.ContainerStatus, image string) bool {
+	if idx := strings.Index(image, "@"); idx >= 0 {
+		return strings.HasSuffix(status.ImageID, image[idx:])
+	}
+
+	return status.Image == image || strings.HasSuffix(status.Image, "/"+image)
+}
@@ -0,0 +1,123 @@
+package k8sutils
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// defaultMaxInflightRequests is the default limit of api requests in flight at the same time
+const defaultMaxInflightRequests = 50
+
+// inflight limits the api requests in flight across all the clients of the package
+var inflight = newInflightLimiter(defaultMaxInflightRequests)
+
+type inflightLimiter struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	max     int
+	current int
+	peak    int
+}
+
+type inflightRoundTripper struct {
+	rt http.RoundTripper
+}
+
+// inflightBody releases the slot of a request once its response body is closed
+type inflightBody struct {
+	io.ReadCloser
+	once sync.Once
+}
+
+// SetMaxInflightRequests sets how many api requests can be in flight at the same time. Requests over the
+// limit wait for a slot. A slot is held from when the request is sent until its response body is closed,
+// so that helpers calling other helpers can't deadlock on the limit. Streaming requests, i.e watches,
+// followed logs and upgraded exec, attach or port forward connections, are not limited as they stay open.
+// Zero removes the limit.
+func SetMaxInflightRequests(n int) {
+	inflight.lock.Lock()
+	defer inflight.lock.Unlock()
+	inflight.max = n
+	inflight.cond.Broadcast()
+}
+
+// GetInflightRequestStats returns the current and peak number of api requests in flight
+func GetInflightRequestStats() InflightRequestStats {
+	inflight.lock.Lock()
+	defer inflight.lock.Unlock()
+	return InflightRequestStats{
+		Current: inflight.current,
+		Peak:    inflight.peak,
+		Max:     inflight.max,
+	}
+}
+
+func newInflightLimiter(max int) *inflightLimiter {
+	l := &inflightLimiter{max: max}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+func (l *inflightLimiter) acquire() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for l.max > 0 && l.current >= l.max {
+		l.cond.Wait()
+	}
+
+	l.current++
+	if l.current > l.peak {
+		l.peak = l.current
+	}
+}
+
+func (l *inflightLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.current--
+	l.cond.Signal()
+}
+
+func newInflightRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &inflightRoundTripper{rt: rt}
+}
+
+func (i *inflightRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if isStreamingRequest(req) {
+		return i.rt.RoundTrip(req)
+	}
+
+	inflight.acquire()
+	resp, err := i.rt.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		inflight.release()
+		return resp, err
+	}
+
+	resp.Body = &inflightBody{ReadCloser: resp.Body}
+	return resp, nil
+}
+
+func (b *inflightBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(inflight.release)
+	return err
+}
+
+// isStreamingRequest checks if the response of the request streams until the client or the server ends it,
+// as for watches, followed logs and upgraded connections
+func isStreamingRequest(req *http.Request) bool {
+	if isWatchRequest(req) || req.URL.Query().Get("follow") == "true" || len(req.Header.Get("Upgrade")) > 0 {
+		return true
+	}
+
+	for _, subresource := range []string{"/exec", "/attach", "/portforward"} {
+		if strings.HasSuffix(req.URL.Path, subresource) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package k8sutils
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setTestMaxInflightRequests sets the in-flight limit and returns a func restoring the previous one
+func setTestMaxInflightRequests(n int) func() {
+	previous := GetInflightRequestStats().Max
+	SetMaxInflightRequests(n)
+	return func() {
+		SetMaxInflightRequests(previous)
+	}
+}
+
+func TestIsStreamingRequest(t *testing.T) {
+	tests := []struct {
+		url      string
+		upgrade  string
+		expected bool
+	}{
+		{url: "/api/v1/namespaces/test/pods"},
+		{url: "/api/v1/namespaces/test/pods/web/log"},
+		{url: "/api/v1/namespaces/test/pods?watch=true", expected: true},
+		{url: "/api/v1/watch/namespaces/test/pods", expected: true},
+		{url: "/api/v1/namespaces/test/pods/web/log?follow=true", expected: true},
+		{url: "/api/v1/namespaces/test/pods/web/exec?command=ls", expected: true},
+		{url: "/api/v1/namespaces/test/pods/web/attach", expected: true},
+		{url: "/api/v1/namespaces/test/pods/web/portforward", expected: true},
+		{url: "/api/v1/namespaces/test/pods/web", upgrade: "websocket", expected: true},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "http://localhost"+test.url, nil)
+		if err != nil {
+			t.Fatalf("failed to create the request: %v", err)
+		}
+		if len(test.upgrade) > 0 {
+			req.Header.Set("Upgrade", test.upgrade)
+		}
+
+		if got := isStreamingRequest(req); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.url, test.expected, got)
+		}
+	}
+}
+
+func TestInflightLimitWithFollowedStreams(t *testing.T) {
+	const max = 2
+	defer setTestMaxInflightRequests(max)()
+
+	var (
+		lock          sync.Mutex
+		current, peak int
+		done          = make(chan struct{})
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("follow") != "true" {
+			lock.Lock()
+			current++
+			if current > peak {
+				peak = current
+			}
+			lock.Unlock()
+
+			time.Sleep(time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+
+			lock.Lock()
+			current--
+			lock.Unlock()
+			return
+		}
+
+		// Followed streams stay open until the test ends
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	client := &http.Client{Transport: newInflightRoundTripper(&http.Transport{})}
+
+	// More followed streams than the limit are opened together with the other requests
+	var wg sync.WaitGroup
+	streams := make(chan io.Closer, 2*max)
+	errs := make(chan error, 22*max)
+	for i := 0; i < 2*max; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/log?follow=true")
+			if err != nil {
+				errs <- err
+				return
+			}
+			streams <- resp.Body
+		}()
+	}
+
+	for i := 0; i < 20*max; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL + "/api")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if _, err = ioutil.ReadAll(resp.Body); err != nil {
+				errs <- err
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("requests are blocked on the in-flight limit: %+v", GetInflightRequestStats())
+	}
+
+	close(streams)
+	for stream := range streams {
+		stream.Close()
+	}
+
+	close(errs)
+	for err := range errs {
+		t.Errorf("request failed: %v", err)
+	}
+
+	if peak > max {
+		t.Errorf("expected at most %v requests in flight, got: %v", max, peak)
+	}
+}
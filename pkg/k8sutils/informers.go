@@ -0,0 +1,422 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	cacheKindPod        = "pods"
+	cacheKindDeployment = "deployments"
+	cacheKindPVC        = "persistentvolumeclaims"
+
+	// defaultCacheStaleness is how long the cache is used after its watch disconnected
+	defaultCacheStaleness = 30 * time.Second
+	// cacheRelistDelay is the wait before listing again after a list or watch failed
+	cacheRelistDelay = 5 * time.Second
+)
+
+var (
+	cacheLock      sync.RWMutex
+	activeCache    *informerCache
+	cacheStaleness = defaultCacheStaleness
+)
+
+type informerCache struct {
+	stores map[string]*objectStore
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// objectStore holds the objects of a kind in a namespace, kept up to date by a list and watch loop
+type objectStore struct {
+	sync.RWMutex
+	objects map[string]runtime.Object
+	synced  bool
+	// disconnectedAt is when the watch of the store last failed. Zero while the watch is running.
+	disconnectedAt time.Time
+}
+
+type listFunc func(client *kubernetes.Clientset, namespace string) ([]runtime.Object, string, error)
+type watchFunc func(client *kubernetes.Clientset, namespace, resourceVersion string) (watch.Interface, error)
+
+// StartInformerCache starts watching the pods, deployments and PVCs in the given namespaces. While the
+// cache is running, GetDeploymentPods, the pod lookups of the deployment, statefulset, daemonset and PVC
+// validations and the waits for pods to run read these objects from the cache instead of the api server.
+// Reads fall back to the api server if the object is not in the cache or if the watch of the cache has been
+// disconnected for longer than the staleness bound. The staleness is measured from when the watch
+// disconnected, not from the last event: a running watch that sends no events means nothing changed, so
+// the cache is read however old its last update is. Deployments are watched through apps/v1beta1, so it is
+// not supported on clusters that don't serve it.
+func StartInformerCache(namespaces []string) error {
+	if err := checkAppsV1beta1("StartInformerCache"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	if activeCache != nil {
+		return fmt.Errorf("informer cache is already running")
+	}
+
+	c := &informerCache{
+		stores: make(map[string]*objectStore),
+		quit:   make(chan struct{}),
+	}
+
+	for _, namespace := range namespaces {
+		c.start(client, cacheKindPod, namespace, listPods, watchPods)
+		c.start(client, cacheKindDeployment, namespace, listDeployments, watchDeployments)
+		c.start(client, cacheKindPVC, namespace, listPVCs, watchPVCs)
+	}
+
+	activeCache = c
+	return nil
+}
+
+// StopInformerCache stops the informer cache and waits for its watches to exit. Reads go to the api
+// server again once it returns.
+func StopInformerCache() {
+	cacheLock.Lock()
+	c := activeCache
+	activeCache = nil
+	cacheLock.Unlock()
+
+	if c == nil {
+		return
+	}
+
+	close(c.quit)
+	c.wg.Wait()
+}
+
+// SetInformerCacheStaleness sets how long the informer cache is still used after its watch of the api
+// server disconnected. It doesn't bound the age of the cached objects while the watch is running.
+func SetInformerCacheStaleness(staleness time.Duration) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+	cacheStaleness = staleness
+}
+
+func (c *informerCache) start(
+	client *kubernetes.Clientset,
+	kind string,
+	namespace string,
+	list listFunc,
+	watchObjects watchFunc,
+) {
+	store := &objectStore{
+		objects: make(map[string]runtime.Object),
+	}
+	c.stores[cacheStoreKey(kind, namespace)] = store
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for {
+			if err := store.sync(client, namespace, list, watchObjects, c.quit); err != nil {
+				logrus.Warnf("Informer cache for %v in namespace: %v disconnected. Err: %v", kind, namespace, err)
+			}
+
+			select {
+			case <-c.quit:
+				return
+			case <-time.After(cacheRelistDelay):
+			}
+		}
+	}()
+}
+
+// sync lists the objects into the store and applies the watch events until the watch fails or quit is
+// closed
+func (s *objectStore) sync(
+	client *kubernetes.Clientset,
+	namespace string,
+	list listFunc,
+	watchObjects watchFunc,
+	quit chan struct{},
+) error {
+	defer s.disconnect()
+
+	objects, resourceVersion, err := list(client, namespace)
+	if err != nil {
+		return err
+	}
+
+	w, err := watchObjects(client, namespace, resourceVersion)
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	s.replace(objects)
+
+	for {
+		select {
+		case <-quit:
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return fmt.Errorf("watch closed")
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				s.put(event.Object)
+			case watch.Deleted:
+				s.delete(event.Object)
+			case watch.Error:
+				return fmt.Errorf("watch error: %v", event.Object)
+			}
+		}
+	}
+}
+
+func (s *objectStore) replace(objects []runtime.Object) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.objects = make(map[string]runtime.Object)
+	for _, obj := range objects {
+		if accessor, err := meta.Accessor(obj); err == nil {
+			s.objects[accessor.GetName()] = obj
+		}
+	}
+	s.synced = true
+	s.disconnectedAt = time.Time{}
+}
+
+func (s *objectStore) put(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	s.objects[accessor.GetName()] = obj
+}
+
+func (s *objectStore) delete(obj runtime.Object) {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	delete(s.objects, accessor.GetName())
+}
+
+func (s *objectStore) disconnect() {
+	s.Lock()
+	defer s.Unlock()
+	if s.disconnectedAt.IsZero() {
+		s.disconnectedAt = time.Now()
+	}
+}
+
+// fresh checks if the store can be read given the staleness bound
+func (s *objectStore) fresh(staleness time.Duration) bool {
+	return s.synced && (s.disconnectedAt.IsZero() || time.Since(s.disconnectedAt) <= staleness)
+}
+
+// getCacheStore returns the store of the given kind and namespace if the cache is running and the store
+// can be read
+func getCacheStore(kind, namespace string) (*objectStore, bool) {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
+
+	if activeCache == nil {
+		return nil, false
+	}
+
+	store, ok := activeCache.stores[cacheStoreKey(kind, namespace)]
+	if !ok {
+		return nil, false
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+	if !store.fresh(cacheStaleness) {
+		return nil, false
+	}
+
+	return store, true
+}
+
+// getCachedPods returns copies of the pods matching the selector in the namespace, sorted by name as the
+// api server lists them, if the cache can be read
+func getCachedPods(namespace string, selector labels.Selector) ([]v1.Pod, bool) {
+	store, ok := getCacheStore(cacheKindPod, namespace)
+	if !ok {
+		return nil, false
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	var pods []v1.Pod
+	cloner := conversion.NewCloner()
+	for _, obj := range store.objects {
+		pod, ok := obj.(*v1.Pod)
+		if !ok || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		var copied v1.Pod
+		if err := v1.DeepCopy_v1_Pod(pod, &copied, cloner); err != nil {
+			return nil, false
+		}
+		pods = append(pods, copied)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return pods[i].Name < pods[j].Name
+	})
+	return pods, true
+}
+
+// getCachedPod returns a copy of the pod if it is in the cache and the cache can be read
+func getCachedPod(namespace, name string) (*v1.Pod, bool) {
+	store, ok := getCacheStore(cacheKindPod, namespace)
+	if !ok {
+		return nil, false
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	pod, ok := store.objects[name].(*v1.Pod)
+	if !ok {
+		return nil, false
+	}
+	copied := &v1.Pod{}
+	if err := v1.DeepCopy_v1_Pod(pod, copied, conversion.NewCloner()); err != nil {
+		return nil, false
+	}
+	return copied, true
+}
+
+// listCachedPods returns the pods matching the selector in the namespace from the cache if it can be read,
+// or from the api server otherwise
+func listCachedPods(client *kubernetes.Clientset, namespace string, selector labels.Selector) ([]v1.Pod, error) {
+	if pods, ok := getCachedPods(namespace, selector); ok {
+		return pods, nil
+	}
+	return listAllPods(client, namespace, meta_v1.ListOptions{LabelSelector: selector.String()})
+}
+
+// getCachedDeployment returns a copy of the deployment if it is in the cache and the cache can be read
+func getCachedDeployment(namespace, name string) (*v1beta1.Deployment, bool) {
+	store, ok := getCacheStore(cacheKindDeployment, namespace)
+	if !ok {
+		return nil, false
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	dep, ok := store.objects[name].(*v1beta1.Deployment)
+	if !ok {
+		return nil, false
+	}
+	copied := &v1beta1.Deployment{}
+	if err := v1beta1.DeepCopy_v1beta1_Deployment(dep, copied, conversion.NewCloner()); err != nil {
+		return nil, false
+	}
+	return copied, true
+}
+
+// getCachedPVC returns a copy of the PVC if it is in the cache and the cache can be read
+func getCachedPVC(namespace, name string) (*v1.PersistentVolumeClaim, bool) {
+	store, ok := getCacheStore(cacheKindPVC, namespace)
+	if !ok {
+		return nil, false
+	}
+
+	store.RLock()
+	defer store.RUnlock()
+
+	pvc, ok := store.objects[name].(*v1.PersistentVolumeClaim)
+	if !ok {
+		return nil, false
+	}
+	copied := &v1.PersistentVolumeClaim{}
+	if err := v1.DeepCopy_v1_PersistentVolumeClaim(pvc, copied, conversion.NewCloner()); err != nil {
+		return nil, false
+	}
+	return copied, true
+}
+
+func cacheStoreKey(kind, namespace string) string {
+	return kind + "/" + namespace
+}
+
+func listPods(client *kubernetes.Clientset, namespace string) ([]runtime.Object, string, error) {
+	list, err := client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	var objects []runtime.Object
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+	return objects, list.ResourceVersion, nil
+}
+
+func watchPods(client *kubernetes.Clientset, namespace, resourceVersion string) (watch.Interface, error) {
+	return client.CoreV1().Pods(namespace).Watch(meta_v1.ListOptions{ResourceVersion: resourceVersion})
+}
+
+func listDeployments(client *kubernetes.Clientset, namespace string) ([]runtime.Object, string, error) {
+	list, err := client.AppsV1beta1().Deployments(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	var objects []runtime.Object
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+	return objects, list.ResourceVersion, nil
+}
+
+func watchDeployments(client *kubernetes.Clientset, namespace, resourceVersion string) (watch.Interface, error) {
+	return client.AppsV1beta1().Deployments(namespace).Watch(meta_v1.ListOptions{ResourceVersion: resourceVersion})
+}
+
+func listPVCs(client *kubernetes.Clientset, namespace string) ([]runtime.Object, string, error) {
+	list, err := client.CoreV1().PersistentVolumeClaims(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	var objects []runtime.Object
+	for i := range list.Items {
+		objects = append(objects, &list.Items[i])
+	}
+	return objects, list.ResourceVersion, nil
+}
+
+func watchPVCs(client *kubernetes.Clientset, namespace, resourceVersion string) (watch.Interface, error) {
+	return client.CoreV1().PersistentVolumeClaims(namespace).Watch(meta_v1.ListOptions{ResourceVersion: resourceVersion})
+}
@@ -0,0 +1,137 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/pkg/api/v1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// startTestInformerCache starts the informer cache on the test namespace and waits for its pods to be listed
+func startTestInformerCache(t *testing.T) {
+	if err := StartInformerCache([]string{testNamespace}); err != nil {
+		t.Fatalf("failed to start the informer cache: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := getCacheStore(cacheKindPod, testNamespace); ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			StopInformerCache()
+			t.Fatalf("the informer cache didn't list the pods")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInformerCacheServesPodReads(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	rs := ext_v1beta1.ReplicaSet{ObjectMeta: meta_v1.ObjectMeta{Name: "web-abc", Namespace: testNamespace}}
+	running := newTestOwnedPod("db-0", "db", "StatefulSet", "db", "db-uid")
+	running.Status.Phase = v1.PodRunning
+	server.Add(running, newTestOwnedPod("web-abc-1", "web", "ReplicaSet", "web-abc", "rs-uid"))
+
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+	reads := func() ([]string, []string) {
+		owned, err := getOwnedPods(client, testNamespace, "StatefulSet", "db", "db-uid", nil)
+		if err != nil {
+			t.Fatalf("failed to get the statefulset pods: %v", err)
+		}
+		replicaSetPods, err := GetReplicaSetPods(rs)
+		if err != nil {
+			t.Fatalf("failed to get the replica set pods: %v", err)
+		}
+		return podNames(owned), podNames(replicaSetPods)
+	}
+
+	startTestInformerCache(t)
+	defer StopInformerCache()
+
+	// The watch of the test server sends no events, so the cache keeps the pods as they were listed
+	pending := *running
+	pending.Status.Phase = v1.PodPending
+	server.Add(&pending)
+	server.Remove("pods", testNamespace, "web-abc-1")
+
+	owned, replicaSetPods := reads()
+	if !reflect.DeepEqual(owned, []string{"db-0"}) || !reflect.DeepEqual(replicaSetPods, []string{"web-abc-1"}) {
+		t.Errorf("expected the pods to be read from the cache, got: %v, %v", owned, replicaSetPods)
+	}
+	if pod, err := waitForPodRunning(&pending, 50*time.Millisecond); err != nil || pod.Status.Phase != v1.PodRunning {
+		t.Errorf("expected the running pod to be read from the cache, got: %v, %v", pod, err)
+	}
+
+	// A disconnected cache past the staleness bound is not read
+	SetInformerCacheStaleness(0)
+	defer SetInformerCacheStaleness(defaultCacheStaleness)
+	store, _ := getCacheStore(cacheKindPod, testNamespace)
+	store.disconnect()
+	time.Sleep(time.Millisecond)
+
+	if owned, replicaSetPods := reads(); !reflect.DeepEqual(owned, []string{"db-0"}) || len(replicaSetPods) != 0 {
+		t.Errorf("expected the pods to be read from the api server, got: %v, %v", owned, replicaSetPods)
+	}
+	if _, err := waitForPodRunning(&pending, 50*time.Millisecond); err == nil {
+		t.Errorf("expected the pending pod to be read from the api server")
+	}
+}
+
+func TestInformerCacheReturnsCopies(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.Add(newTestOwnedPod("web-abc-1", "web", "ReplicaSet", "web-abc", "rs-uid"))
+
+	startTestInformerCache(t)
+	defer StopInformerCache()
+
+	pod, ok := getCachedPod(testNamespace, "web-abc-1")
+	if !ok {
+		t.Fatalf("expected the pod to be in the cache")
+	}
+	pod.Labels["app"] = "mutated"
+	pods, ok := getCachedPods(testNamespace, labels.Everything())
+	if !ok || len(pods) != 1 {
+		t.Fatalf("expected the pod to be listed from the cache, got: %v", podNames(pods))
+	}
+	pods[0].Labels["app"] = "mutated"
+
+	pod, _ = getCachedPod(testNamespace, "web-abc-1")
+	if pod.Labels["app"] != "web" {
+		t.Errorf("expected the cached labels not to change, got: %v", pod.Labels)
+	}
+}
+
+func TestStartInformerCacheRequiresAppsV1beta1(t *testing.T) {
+	_, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	k8sVersionLock.Lock()
+	cached := k8sVersionCached
+	k8sVersionCached = &K8sVersion{Major: 1, Minor: 16, GitVersion: "v1.16.0"}
+	k8sVersionLock.Unlock()
+	defer func() {
+		k8sVersionLock.Lock()
+		k8sVersionCached = cached
+		k8sVersionLock.Unlock()
+	}()
+
+	if err := StartInformerCache([]string{testNamespace}); err == nil {
+		StopInformerCache()
+		t.Fatalf("expected the informer cache not to start without apps/v1beta1")
+	} else if _, ok := err.(*errors.ErrNotSupported); !ok {
+		t.Errorf("expected an ErrNotSupported, got: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package k8sutils
+
+import (
+	"sync"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// instanceLabelKey is the label set by the create helpers on objects with the torpedo instance that
+// created them
+const instanceLabelKey = "torpedo.io/instance"
+
+var (
+	instanceLock sync.RWMutex
+	instanceID   string
+)
+
+type listOptions struct {
+	allInstances bool
+	// uninitialized includes the nodes that are not initialized in the node lists
+	uninitialized bool
+}
+
+type deleteOptions struct {
+	force bool
+	// cascadeConsumers deletes the pods still using a PVC that is protected from deletion
+	cascadeConsumers bool
+}
+
+// SetInstanceID sets the identity of this torpedo instance. Once set, the create helpers label the
+// objects they create with it, the list helpers ignore the objects of other instances and the delete
+// helpers refuse to delete them.
+func SetInstanceID(id string) {
+	instanceLock.Lock()
+	defer instanceLock.Unlock()
+	instanceID = id
+}
+
+// WithAllInstances makes a list helper return the objects of all torpedo instances
+func WithAllInstances() ListOption {
+	return func(o *listOptions) {
+		o.allInstances = true
+	}
+}
+
+// WithForceDelete makes a delete helper delete the object even if another torpedo instance created it
+func WithForceDelete() DeleteOption {
+	return func(o *deleteOptions) {
+		o.force = true
+	}
+}
+
+// WithCascadeDeleteConsumers makes ValidateDeletedPersistentVolumeClaim delete the pods that keep a
+// deleted PVC from going away as they still use it
+func WithCascadeDeleteConsumers() DeleteOption {
+	return func(o *deleteOptions) {
+		o.cascadeConsumers = true
+	}
+}
+
+func getInstanceID() string {
+	instanceLock.RLock()
+	defer instanceLock.RUnlock()
+	return instanceID
+}
+
+// stampInstanceLabel labels the object with the instance ID if it is set and the object isn't labeled yet
+func stampInstanceLabel(meta *meta_v1.ObjectMeta) {
+	id := getInstanceID()
+	if len(id) == 0 {
+		return
+	}
+
+	if meta.Labels == nil {
+		meta.Labels = make(map[string]string)
+	}
+	if _, ok := meta.Labels[instanceLabelKey]; !ok {
+		meta.Labels[instanceLabelKey] = id
+	}
+}
+
+// isOtherInstance checks if the object was created by another torpedo instance. Objects that are not
+// labeled with an instance are not considered to belong to another instance.
+func isOtherInstance(meta meta_v1.ObjectMeta, opts []ListOption) bool {
+	o := &listOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	id := getInstanceID()
+	if o.allInstances || len(id) == 0 {
+		return false
+	}
+
+	owner, ok := meta.Labels[instanceLabelKey]
+	return ok && owner != id
+}
+
+// checkOwned returns an ErrNotOwned if the object was created by another torpedo instance and the delete
+// is not forced
+func checkOwned(kind string, meta meta_v1.ObjectMeta, opts []DeleteOption) error {
+	o := &deleteOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	id := getInstanceID()
+	if o.force || len(id) == 0 {
+		return nil
+	}
+
+	if owner, ok := meta.Labels[instanceLabelKey]; ok && owner != id {
+		return &ErrNotOwned{
+			Kind:     kind,
+			Name:     meta.Name,
+			Instance: id,
+			Owner:    owner,
+		}
+	}
+
+	return nil
+}
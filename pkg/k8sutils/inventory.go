@@ -0,0 +1,220 @@
+package k8sutils
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	inventoryKindDeployment   = "deployments"
+	inventoryKindStatefulSet  = "statefulsets"
+	inventoryKindDaemonSet    = "daemonsets"
+	inventoryKindJob          = "jobs"
+	inventoryKindPod          = "pods"
+	inventoryKindPVC          = "persistentvolumeclaims"
+	inventoryKindPV           = "persistentvolumes"
+	inventoryKindService      = "services"
+	inventoryKindSecret       = "secrets"
+	inventoryKindConfigMap    = "configmaps"
+	inventoryKindStorageClass = "storageclasses"
+)
+
+// SnapshotClusterInventory records the names of the deployments, statefulsets, daemonsets, jobs, pods, PVCs,
+// services, secrets and configmaps in the given namespaces (all namespaces if empty) and of all PVs and
+// storage classes. Objects of other torpedo instances are skipped unless WithAllInstances is given.
+func SnapshotClusterInventory(namespaces []string, opts ...ListOption) (Inventory, error) {
+	inventory := Inventory{
+		Objects: make(map[string][]string),
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return inventory, err
+	}
+
+	if len(namespaces) == 0 {
+		namespaces = []string{meta_v1.NamespaceAll}
+	}
+
+	for _, namespace := range namespaces {
+		if err := snapshotNamespace(client, namespace, inventory, opts); err != nil {
+			return inventory, err
+		}
+	}
+
+	pvs, err := client.CoreV1().PersistentVolumes().List(meta_v1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, pv := range pvs.Items {
+		inventory.add(inventoryKindPV, pv.ObjectMeta, opts)
+	}
+
+	scs, err := client.StorageV1beta1().StorageClasses().List(meta_v1.ListOptions{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, sc := range scs.Items {
+		inventory.add(inventoryKindStorageClass, sc.ObjectMeta, opts)
+	}
+
+	for kind := range inventory.Objects {
+		sort.Strings(inventory.Objects[kind])
+	}
+
+	return inventory, nil
+}
+
+// ListResourcesInNamespace records the names of the namespaced objects of the given namespace, of the kinds
+// recorded by SnapshotClusterInventory. Objects of other torpedo instances are skipped unless
+// WithAllInstances is given. An empty inventory means the namespace was fully torn down, apart from the
+// objects k8s creates in every namespace (e.g the token secret of the default service account).
+func ListResourcesInNamespace(namespace string, opts ...ListOption) (Inventory, error) {
+	inventory := Inventory{
+		Objects: make(map[string][]string),
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return inventory, err
+	}
+
+	if err := snapshotNamespace(client, namespaceOrDefault(namespace), inventory, opts); err != nil {
+		return inventory, err
+	}
+
+	for kind := range inventory.Objects {
+		sort.Strings(inventory.Objects[kind])
+	}
+
+	return inventory, nil
+}
+
+// DiffInventories returns, for each kind, the objects that are in after but not in before. Objects
+// matching any of the allowlist patterns are ignored. The patterns are matched with path.Match against
+// <kind>/<namespace>/<name> for namespaced objects and <kind>/<name> otherwise
+// (e.g "persistentvolumes/*" or "secrets/kube-system/*").
+func DiffInventories(before, after Inventory, allowlist []string) map[string][]string {
+	diff := make(map[string][]string)
+	for kind, objects := range after.Objects {
+		existing := make(map[string]bool)
+		for _, object := range before.Objects[kind] {
+			existing[object] = true
+		}
+
+		for _, object := range objects {
+			if existing[object] || isAllowlisted(kind+"/"+object, allowlist) {
+				continue
+			}
+			diff[kind] = append(diff[kind], object)
+		}
+	}
+
+	return diff
+}
+
+// Count returns the number of objects of the given kind in the inventory
+func (i Inventory) Count(kind string) int {
+	return len(i.Objects[kind])
+}
+
+func (i Inventory) add(kind string, meta meta_v1.ObjectMeta, opts []ListOption) {
+	if isOtherInstance(meta, opts) {
+		return
+	}
+
+	object := meta.Name
+	if len(meta.Namespace) > 0 {
+		object = fmt.Sprintf("%v/%v", meta.Namespace, meta.Name)
+	}
+	i.Objects[kind] = append(i.Objects[kind], object)
+}
+
+func snapshotNamespace(client *kubernetes.Clientset, namespace string, inventory Inventory, opts []ListOption) error {
+	deployments, err := client.AppsV1beta1().Deployments(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments.Items {
+		inventory.add(inventoryKindDeployment, d.ObjectMeta, opts)
+	}
+
+	statefulSets, err := client.AppsV1beta1().StatefulSets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, s := range statefulSets.Items {
+		inventory.add(inventoryKindStatefulSet, s.ObjectMeta, opts)
+	}
+
+	daemonSets, err := client.ExtensionsV1beta1().DaemonSets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, d := range daemonSets.Items {
+		inventory.add(inventoryKindDaemonSet, d.ObjectMeta, opts)
+	}
+
+	jobs, err := client.BatchV1().Jobs(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, j := range jobs.Items {
+		inventory.add(inventoryKindJob, j.ObjectMeta, opts)
+	}
+
+	pods, err := listAllPods(client, namespace, meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, p := range pods {
+		inventory.add(inventoryKindPod, p.ObjectMeta, opts)
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, p := range pvcs.Items {
+		inventory.add(inventoryKindPVC, p.ObjectMeta, opts)
+	}
+
+	services, err := client.CoreV1().Services(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, s := range services.Items {
+		inventory.add(inventoryKindService, s.ObjectMeta, opts)
+	}
+
+	secrets, err := client.CoreV1().Secrets(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, s := range secrets.Items {
+		inventory.add(inventoryKindSecret, s.ObjectMeta, opts)
+	}
+
+	configMaps, err := client.CoreV1().ConfigMaps(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, c := range configMaps.Items {
+		inventory.add(inventoryKindConfigMap, c.ObjectMeta, opts)
+	}
+
+	return nil
+}
+
+func isAllowlisted(object string, allowlist []string) bool {
+	for _, pattern := range allowlist {
+		if matched, _ := path.Match(pattern, object); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	batch_v1 "k8s.io/client-go/pkg/apis/batch/v1"
+	batch_v2alpha1 "k8s.io/client-go/pkg/apis/batch/v2alpha1"
+)
+
+// CreateJob creates the given job
+func CreateJob(job *batch_v1.Job, opts ...CreateOption) (*batch_v1.Job, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Namespace, err = resolveCreateNamespace(client, job.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&job.ObjectMeta)
+	stampInstanceLabel(&job.Spec.Template.ObjectMeta)
+
+	return client.BatchV1().Jobs(job.Namespace).Create(job)
+}
+
+// WaitForJobCompletion waits for the given job to have the given number of succeeded pods, or the
+// completions of its spec if zero, and returns the completed job. It waits up to the given timeout or the
+// app ready timeout of the validation profile if zero. An ErrJobFailed is returned as soon as the job
+// fails.
+func WaitForJobCompletion(job *batch_v1.Job, completions int32, timeout time.Duration) (*batch_v1.Job, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppReadyTimeout
+	}
+
+	namespace := namespaceOrDefault(job.Namespace)
+	var (
+		completed *batch_v1.Job
+		failed    error
+	)
+	t := func() error {
+		current, err := client.BatchV1().Jobs(namespace).Get(job.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		// A failed job won't complete, so stop retrying
+		if cause := getJobFailure(current); len(cause) > 0 {
+			failed = &ErrJobFailed{
+				Name:  current.Name,
+				Cause: cause,
+			}
+			return nil
+		}
+
+		expected := completions
+		if expected == 0 {
+			expected = getJobCompletions(current)
+		}
+
+		if current.Status.Succeeded < expected {
+			return &ErrAppNotReady{
+				ID: current.Name,
+				Cause: fmt.Sprintf("Expected completions: %v Succeeded pods: %v Active pods: %v",
+					expected, current.Status.Succeeded, current.Status.Active),
+			}
+		}
+
+		completed = current
+		return nil
+	}
+
+	t, complete := observeValidation("job/"+job.Name, t)
+	err = doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	if err == nil {
+		err = failed
+	}
+	complete(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+// DeleteJob deletes the given job and its pods. An ErrNotOwned is returned if another torpedo instance
+// created it, unless WithForceDelete is given.
+func DeleteJob(job *batch_v1.Job, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteJob"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(job.Namespace)
+	if current, err := client.BatchV1().Jobs(namespace).Get(job.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("job", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	policy := meta_v1.DeletePropagationForeground
+	return client.BatchV1().Jobs(namespace).Delete(job.Name, &meta_v1.DeleteOptions{
+		PropagationPolicy: &policy,
+	})
+}
+
+// CreateCronJob creates the given cronjob. CronJobs are served by the batch/v2alpha1 API, which has to be
+// enabled in the api server.
+func CreateCronJob(cronJob *batch_v2alpha1.CronJob, opts ...CreateOption) (*batch_v2alpha1.CronJob, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if cronJob.Namespace, err = resolveCreateNamespace(client, cronJob.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&cronJob.ObjectMeta)
+	stampInstanceLabel(&cronJob.Spec.JobTemplate.ObjectMeta)
+	stampInstanceLabel(&cronJob.Spec.JobTemplate.Spec.Template.ObjectMeta)
+
+	return client.BatchV2alpha1().CronJobs(cronJob.Namespace).Create(cronJob)
+}
+
+// WaitForCronJobCompletion waits for the given number of jobs of the given cronjob, at least one, to have
+// completed and returns them. It waits up to the given timeout or the app ready timeout of the validation
+// profile if zero, so the timeout has to cover the schedule. An ErrJobFailed is returned as soon as one of
+// its jobs fails.
+func WaitForCronJobCompletion(
+	cronJob *batch_v2alpha1.CronJob,
+	completions int,
+	timeout time.Duration,
+) ([]batch_v1.Job, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppReadyTimeout
+	}
+	if completions <= 0 {
+		completions = 1
+	}
+
+	namespace := namespaceOrDefault(cronJob.Namespace)
+	var (
+		completed []batch_v1.Job
+		failed    error
+	)
+	t := func() error {
+		jobs, err := client.BatchV1().Jobs(namespace).List(meta_v1.ListOptions{})
+		if err != nil {
+			return err
+		}
+
+		var done []batch_v1.Job
+		for _, job := range jobs.Items {
+			if !isCronJobJob(cronJob.Name, job) {
+				continue
+			}
+
+			if cause := getJobFailure(&job); len(cause) > 0 {
+				failed = &ErrJobFailed{
+					Name:  job.Name,
+					Cause: cause,
+				}
+				return nil
+			}
+
+			if job.Status.Succeeded >= getJobCompletions(&job) {
+				done = append(done, job)
+			}
+		}
+
+		if len(done) < completions {
+			return &ErrAppNotReady{
+				ID:    cronJob.Name,
+				Cause: fmt.Sprintf("Expected completed jobs: %v Completed jobs: %v", completions, len(done)),
+			}
+		}
+
+		completed = done
+		return nil
+	}
+
+	t, complete := observeValidation("cronjob/"+cronJob.Name, t)
+	err = doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	if err == nil {
+		err = failed
+	}
+	complete(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return completed, nil
+}
+
+// DeleteCronJob deletes the given cronjob and the jobs it created. An ErrNotOwned is returned if another
+// torpedo instance created it, unless WithForceDelete is given.
+func DeleteCronJob(cronJob *batch_v2alpha1.CronJob, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteCronJob"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(cronJob.Namespace)
+	if current, err := client.BatchV2alpha1().CronJobs(namespace).Get(cronJob.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("cronjob", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	policy := meta_v1.DeletePropagationForeground
+	if err := client.BatchV2alpha1().CronJobs(namespace).Delete(cronJob.Name, &meta_v1.DeleteOptions{
+		PropagationPolicy: &policy,
+	}); err != nil {
+		return err
+	}
+
+	// The jobs of older cronjob controllers have no owner reference to be garbage collected with
+	jobs, err := client.BatchV1().Jobs(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs.Items {
+		if !isCronJobJob(cronJob.Name, job) || len(job.OwnerReferences) > 0 {
+			continue
+		}
+		if err := client.BatchV1().Jobs(namespace).Delete(job.Name, &meta_v1.DeleteOptions{
+			PropagationPolicy: &policy,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getJobCompletions returns the number of succeeded pods needed for the job to complete
+func getJobCompletions(job *batch_v1.Job) int32 {
+	if job.Spec.Completions != nil {
+		return *job.Spec.Completions
+	}
+	return 1
+}
+
+// getJobFailure returns why the job failed or an empty string if it didn't
+func getJobFailure(job *batch_v1.Job) string {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batch_v1.JobFailed && condition.Status == v1.ConditionTrue {
+			return fmt.Sprintf("%v: %v", condition.Reason, condition.Message)
+		}
+	}
+	return ""
+}
+
+// isCronJobJob returns true if the given job was created by the cronjob with the given name. Jobs of
+// older cronjob controllers have no owner reference and are matched on their <cronjob>-<hash> name.
+func isCronJobJob(cronJobName string, job batch_v1.Job) bool {
+	for _, owner := range job.OwnerReferences {
+		if owner.Kind == "CronJob" {
+			return owner.Name == cronJobName
+		}
+	}
+
+	return len(job.OwnerReferences) == 0 && strings.HasPrefix(job.Name, cronJobName+"-") &&
+		isDigits(strings.TrimPrefix(job.Name, cronJobName+"-"))
+}
+
+func isDigits(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,862 @@
+// Package k8stest provides an in-memory k8s api server for the tests of the packages talking to k8s
+// through k8sutils. It serves the JSON REST api of the objects it holds: get, list with label and field
+// selectors and pagination, create, update, merge patch and delete. It doesn't run controllers, so tests
+// set the status of the objects themselves. The resources of every kind it knows are discovered in every
+// group version; tests drop a group with a reactor answering its requests with a 404.
+package k8stest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+)
+
+// clusterScopedKinds are the kinds of the objects that don't live in a namespace
+var clusterScopedKinds = map[string]bool{
+	"ComponentStatus":          true,
+	"Node":                     true,
+	"Namespace":                true,
+	"PersistentVolume":         true,
+	"StorageClass":             true,
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"CustomResourceDefinition": true,
+}
+
+// Request is an api request received by the server
+type Request struct {
+	// Method is the HTTP method of the request
+	Method string
+	// APIVersion is the group version of the request path, e.g v1 or apps/v1beta1
+	APIVersion string
+	// Resource is the resource of the request path, e.g pods. Empty for the discovery of the resources of
+	// a group version.
+	Resource string
+	// Namespace is the namespace of the request path. Empty for cluster scoped resources and requests
+	// across all namespaces.
+	Namespace string
+	// Name is the name of the object. Empty for lists and creates.
+	Name string
+	// Subresource is the subresource of the request path, e.g status or log
+	Subresource string
+	// Query is the query of the request
+	Query url.Values
+	// Body is the decoded JSON body of the request, if any
+	Body map[string]interface{}
+}
+
+// Reactor handles a request before the server does. It returns false to let the server handle the
+// request, or true with the status code and the object to answer with, e.g one returned by Status, or a
+// *Response to also set headers.
+type Reactor func(req *Request) (handled bool, code int, obj interface{})
+
+// Response is an answer of a reactor with headers, e.g the Retry-After header of a throttled request
+type Response struct {
+	// Header are the headers of the response
+	Header http.Header
+	// Object is the object to answer with
+	Object interface{}
+}
+
+// Server is an in-memory k8s api server
+type Server struct {
+	*httptest.Server
+
+	lock sync.Mutex
+	// objects are the stored objects by objectKey
+	objects         map[string]map[string]interface{}
+	kinds           map[string]string
+	resourceVersion int
+	reactors        []Reactor
+	requests        []Request
+	gitVersion      string
+	quit            chan struct{}
+	closeOnce       sync.Once
+	// logs are the logs of the pod containers by objectKey and container name
+	logs map[string]map[string]string
+}
+
+// NewServer starts an empty server reporting k8s version v1.6.0
+func NewServer() *Server {
+	s := &Server{
+		objects:    make(map[string]map[string]interface{}),
+		kinds:      make(map[string]string),
+		logs:       make(map[string]map[string]string),
+		gitVersion: "v1.6.0",
+		quit:       make(chan struct{}),
+	}
+	for _, kind := range []string{
+		"Pod", "Node", "Namespace", "Service", "Endpoints", "Event", "Secret", "ConfigMap",
+		"PersistentVolume", "PersistentVolumeClaim", "ServiceAccount", "Deployment", "ReplicaSet",
+		"StatefulSet", "DaemonSet", "StorageClass", "ClusterRole", "ClusterRoleBinding", "Role",
+		"RoleBinding", "Job", "PodDisruptionBudget", "ControllerRevision", "CustomResourceDefinition",
+	} {
+		s.kinds[resourceForKind(kind)] = kind
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Close stops the server, ending the open watches
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.quit)
+		s.Server.Close()
+	})
+}
+
+// Config returns the config of a client of the server
+func (s *Server) Config() *rest.Config {
+	return &rest.Config{Host: s.URL}
+}
+
+// SetVersion sets the git version the server reports, e.g v1.9.3
+func (s *Server) SetVersion(gitVersion string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.gitVersion = gitVersion
+}
+
+// AddReactor adds a reactor that sees the requests before the server. Reactors are called in the order
+// they were added.
+func (s *Server) AddReactor(reactor Reactor) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reactors = append(s.reactors, reactor)
+}
+
+// SetPodLogs sets the logs the server returns for the given container of a pod. Followed logs are sent and
+// the stream is then kept open until the client or the server goes away.
+func (s *Server) SetPodLogs(namespace, pod, container, logs string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	key := objectKey("pods", namespace, pod)
+	if s.logs[key] == nil {
+		s.logs[key] = make(map[string]string)
+	}
+	s.logs[key][container] = logs
+}
+
+// Requests returns the requests the server received
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Add stores the given typed objects (e.g a *v1.Pod), replacing the stored objects with the same kind,
+// namespace and name. The kind is taken from the Go type of the object. Objects without a UID get one.
+// It panics if an object can't be encoded to JSON.
+func (s *Server) Add(objs ...interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, obj := range objs {
+		kind := reflect.Indirect(reflect.ValueOf(obj)).Type().Name()
+		resource := resourceForKind(kind)
+		s.kinds[resource] = kind
+
+		stored, err := toMap(obj)
+		if err != nil {
+			panic(fmt.Sprintf("failed to encode %v: %v", kind, err))
+		}
+
+		meta := metadata(stored)
+		if clusterScopedKinds[kind] {
+			delete(meta, "namespace")
+		} else if len(getString(meta, "namespace")) == 0 {
+			meta["namespace"] = "default"
+		}
+		if len(getString(meta, "uid")) == 0 {
+			meta["uid"] = fmt.Sprintf("%v-%v-uid", strings.ToLower(kind), getString(meta, "name"))
+		}
+		s.stamp(meta)
+		s.objects[objectKey(resource, getString(meta, "namespace"), getString(meta, "name"))] = stored
+	}
+}
+
+// Get reads the stored object of the given resource into obj, e.g a *v1.Pod. It returns false if the
+// object doesn't exist.
+func (s *Server) Get(resource, namespace, name string, obj interface{}) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	stored, ok := s.objects[objectKey(resource, namespace, name)]
+	if !ok {
+		return false
+	}
+
+	if err := fromMap(stored, obj); err != nil {
+		panic(fmt.Sprintf("failed to decode %v: %v/%v: %v", resource, namespace, name, err))
+	}
+	return true
+}
+
+// Remove deletes the stored object of the given resource, ignoring its finalizers
+func (s *Server) Remove(resource, namespace, name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.objects, objectKey(resource, namespace, name))
+}
+
+// Count returns the number of stored objects of the given resource
+func (s *Server) Count(resource string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	count := 0
+	for key := range s.objects {
+		if strings.HasPrefix(key, resource+"/") {
+			count++
+		}
+	}
+	return count
+}
+
+// Status returns a Status object of a failed request, e.g Status(404, "NotFound", "not found")
+func Status(code int, reason, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"metadata":   map[string]interface{}{},
+		"status":     "Failure",
+		"message":    message,
+		"reason":     reason,
+		"code":       code,
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/version" {
+		s.lock.Lock()
+		version := s.gitVersion
+		s.lock.Unlock()
+		writeJSON(w, http.StatusOK, versionInfo(version))
+		return
+	}
+
+	req, watch, err := parseRequest(r)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, Status(http.StatusNotFound, "NotFound", err.Error()))
+		return
+	}
+
+	s.lock.Lock()
+	s.requests = append(s.requests, *req)
+	reactors := s.reactors
+	s.lock.Unlock()
+
+	for _, reactor := range reactors {
+		if handled, code, obj := reactor(req); handled {
+			if resp, ok := obj.(*Response); ok {
+				for key, values := range resp.Header {
+					w.Header()[key] = values
+				}
+				obj = resp.Object
+			}
+			writeJSON(w, code, obj)
+			return
+		}
+	}
+
+	if watch {
+		s.serveWatch(w, r)
+		return
+	}
+
+	if len(req.Resource) == 0 {
+		s.lock.Lock()
+		resources := s.discovery(req)
+		s.lock.Unlock()
+		writeJSON(w, http.StatusOK, resources)
+		return
+	}
+
+	if req.Resource == "pods" && req.Subresource == "log" {
+		s.serveLogs(w, r, req)
+		return
+	}
+
+	s.lock.Lock()
+	code, obj := s.handle(req)
+	s.lock.Unlock()
+	writeJSON(w, code, obj)
+}
+
+// serveWatch answers a watch without ever sending an event until the client or the server goes away
+func (s *Server) serveWatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+
+	select {
+	case <-r.Context().Done():
+	case <-s.quit:
+	}
+}
+
+// serveLogs answers a request for the logs of a pod container
+func (s *Server) serveLogs(w http.ResponseWriter, r *http.Request, req *Request) {
+	s.lock.Lock()
+	logs, ok := s.logs[objectKey(req.Resource, req.Namespace, req.Name)][req.Query.Get("container")]
+	s.lock.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, Status(http.StatusNotFound, "NotFound",
+			fmt.Sprintf("no logs for container %v of pod %v", req.Query.Get("container"), req.Name)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, logs); err != nil || req.Query.Get("follow") != "true" {
+		return
+	}
+
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	select {
+	case <-r.Context().Done():
+	case <-s.quit:
+	}
+}
+
+func (s *Server) handle(req *Request) (int, interface{}) {
+	switch {
+	case req.Method == http.MethodGet && len(req.Name) == 0:
+		return s.list(req)
+	case req.Method == http.MethodGet:
+		return s.get(req)
+	case req.Method == http.MethodPost:
+		return s.create(req)
+	case req.Method == http.MethodPut:
+		return s.update(req)
+	case req.Method == http.MethodPatch:
+		return s.patch(req)
+	case req.Method == http.MethodDelete && len(req.Name) == 0:
+		return s.deleteCollection(req)
+	case req.Method == http.MethodDelete:
+		return s.delete(req)
+	default:
+		return http.StatusMethodNotAllowed, Status(http.StatusMethodNotAllowed, "MethodNotAllowed",
+			fmt.Sprintf("method %v is not supported", req.Method))
+	}
+}
+
+func (s *Server) get(req *Request) (int, interface{}) {
+	stored, ok := s.objects[objectKey(req.Resource, req.Namespace, req.Name)]
+	if !ok {
+		return notFound(req)
+	}
+	return http.StatusOK, s.withType(stored, req)
+}
+
+func (s *Server) list(req *Request) (int, interface{}) {
+	selector, err := labels.Parse(req.Query.Get("labelSelector"))
+	if err != nil {
+		return badRequest(err)
+	}
+	fieldSelector, err := parseFieldSelector(req.Query.Get("fieldSelector"))
+	if err != nil {
+		return badRequest(err)
+	}
+
+	var keys []string
+	prefix := req.Resource + "/"
+	if len(req.Namespace) > 0 {
+		prefix += req.Namespace + "/"
+	}
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	continueAfter := req.Query.Get("continue")
+	limit, _ := strconv.Atoi(req.Query.Get("limit"))
+
+	items := []interface{}{}
+	next := ""
+	for _, key := range keys {
+		if len(continueAfter) > 0 && key <= continueAfter {
+			continue
+		}
+
+		stored := s.objects[key]
+		if !selector.Matches(labels.Set(getStringMap(metadata(stored), "labels"))) ||
+			!fieldSelector.matches(stored) {
+			continue
+		}
+
+		if limit > 0 && len(items) == limit {
+			next = lastKey(items, req.Resource)
+			break
+		}
+		items = append(items, s.withType(stored, req))
+	}
+
+	return http.StatusOK, map[string]interface{}{
+		"kind":       s.kinds[req.Resource] + "List",
+		"apiVersion": req.APIVersion,
+		"metadata": map[string]interface{}{
+			"resourceVersion": strconv.Itoa(s.resourceVersion),
+			"continue":        next,
+		},
+		"items": items,
+	}
+}
+
+func (s *Server) create(req *Request) (int, interface{}) {
+	if req.Body == nil {
+		return badRequest(fmt.Errorf("request has no body"))
+	}
+
+	obj := copyMap(req.Body)
+	meta := metadata(obj)
+	name := getString(meta, "name")
+	if len(name) == 0 {
+		generateName := getString(meta, "generateName")
+		if len(generateName) == 0 {
+			return http.StatusUnprocessableEntity, Status(http.StatusUnprocessableEntity, "Invalid",
+				"name or generateName is required")
+		}
+		name = fmt.Sprintf("%v%05d", generateName, s.resourceVersion+1)
+		meta["name"] = name
+	}
+	if len(req.Namespace) > 0 {
+		meta["namespace"] = req.Namespace
+	}
+
+	key := objectKey(req.Resource, req.Namespace, name)
+	if _, exists := s.objects[key]; exists {
+		return http.StatusConflict, withDetails(Status(http.StatusConflict, "AlreadyExists",
+			fmt.Sprintf("%v %q already exists", req.Resource, name)), req, name)
+	}
+
+	meta["uid"] = fmt.Sprintf("%v-%v-%d", req.Resource, name, s.resourceVersion+1)
+	meta["creationTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+	s.stamp(meta)
+	s.objects[key] = obj
+	return http.StatusCreated, s.withType(obj, req)
+}
+
+func (s *Server) update(req *Request) (int, interface{}) {
+	if req.Body == nil {
+		return badRequest(fmt.Errorf("request has no body"))
+	}
+
+	key := objectKey(req.Resource, req.Namespace, req.Name)
+	stored, ok := s.objects[key]
+	if !ok {
+		return notFound(req)
+	}
+
+	obj := copyMap(req.Body)
+	meta := metadata(obj)
+	storedMeta := metadata(stored)
+	if version := getString(meta, "resourceVersion"); len(version) > 0 &&
+		version != getString(storedMeta, "resourceVersion") {
+		return http.StatusConflict, withDetails(Status(http.StatusConflict, "Conflict",
+			fmt.Sprintf("the object has been modified: %v %q", req.Resource, req.Name)), req, req.Name)
+	}
+
+	for _, field := range []string{"uid", "creationTimestamp", "deletionTimestamp", "namespace"} {
+		if value, ok := storedMeta[field]; ok {
+			meta[field] = value
+		}
+	}
+	if req.Subresource == "status" {
+		stored = copyMap(stored)
+		stored["status"] = obj["status"]
+		obj = stored
+		meta = metadata(obj)
+	}
+	s.stamp(meta)
+	return s.store(key, obj, req)
+}
+
+func (s *Server) patch(req *Request) (int, interface{}) {
+	key := objectKey(req.Resource, req.Namespace, req.Name)
+	stored, ok := s.objects[key]
+	if !ok {
+		return notFound(req)
+	}
+
+	obj := mergePatch(copyMap(stored), req.Body).(map[string]interface{})
+	s.stamp(metadata(obj))
+	return s.store(key, obj, req)
+}
+
+// store saves an updated object, deleting it if it is being deleted and has no finalizers left
+func (s *Server) store(key string, obj map[string]interface{}, req *Request) (int, interface{}) {
+	meta := metadata(obj)
+	if _, deleting := meta["deletionTimestamp"]; deleting && len(getSlice(meta, "finalizers")) == 0 {
+		delete(s.objects, key)
+	} else {
+		s.objects[key] = obj
+	}
+	return http.StatusOK, s.withType(obj, req)
+}
+
+func (s *Server) delete(req *Request) (int, interface{}) {
+	key := objectKey(req.Resource, req.Namespace, req.Name)
+	stored, ok := s.objects[key]
+	if !ok {
+		return notFound(req)
+	}
+
+	s.deleteObject(key, stored)
+	return http.StatusOK, map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Success",
+	}
+}
+
+func (s *Server) deleteCollection(req *Request) (int, interface{}) {
+	code, obj := s.list(req)
+	if code != http.StatusOK {
+		return code, obj
+	}
+
+	for _, item := range obj.(map[string]interface{})["items"].([]interface{}) {
+		meta := metadata(item.(map[string]interface{}))
+		key := objectKey(req.Resource, getString(meta, "namespace"), getString(meta, "name"))
+		s.deleteObject(key, s.objects[key])
+	}
+	return http.StatusOK, map[string]interface{}{
+		"kind":       "Status",
+		"apiVersion": "v1",
+		"status":     "Success",
+	}
+}
+
+// deleteObject deletes the stored object, or marks it as being deleted if it has finalizers
+func (s *Server) deleteObject(key string, stored map[string]interface{}) {
+	meta := metadata(stored)
+	if len(getSlice(meta, "finalizers")) == 0 {
+		delete(s.objects, key)
+		return
+	}
+
+	if _, deleting := meta["deletionTimestamp"]; !deleting {
+		meta["deletionTimestamp"] = time.Now().UTC().Format(time.RFC3339)
+		s.stamp(meta)
+	}
+}
+
+// stamp bumps the resource version of the server and sets it on the given object metadata
+func (s *Server) stamp(meta map[string]interface{}) {
+	s.resourceVersion++
+	meta["resourceVersion"] = strconv.Itoa(s.resourceVersion)
+}
+
+// withType returns a copy of the stored object with the kind and api version of the request
+func (s *Server) withType(stored map[string]interface{}, req *Request) map[string]interface{} {
+	obj := copyMap(stored)
+	obj["kind"] = s.kinds[req.Resource]
+	obj["apiVersion"] = req.APIVersion
+	return obj
+}
+
+// discovery returns the resource list of the group version of the request, made of the resources of all the
+// known kinds
+func (s *Server) discovery(req *Request) map[string]interface{} {
+	var names []string
+	for resource := range s.kinds {
+		names = append(names, resource)
+	}
+	sort.Strings(names)
+
+	resources := []interface{}{}
+	for _, resource := range names {
+		resources = append(resources, map[string]interface{}{
+			"name":       resource,
+			"namespaced": !clusterScopedKinds[s.kinds[resource]],
+			"kind":       s.kinds[resource],
+			"verbs":      []string{"create", "delete", "get", "list", "patch", "update", "watch"},
+		})
+	}
+
+	return map[string]interface{}{
+		"kind":         "APIResourceList",
+		"apiVersion":   "v1",
+		"groupVersion": req.APIVersion,
+		"resources":    resources,
+	}
+}
+
+// parseRequest parses the api path of the request. It returns whether the request is a watch.
+func parseRequest(r *http.Request) (*Request, bool, error) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	req := &Request{
+		Method: r.Method,
+		Query:  r.URL.Query(),
+	}
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		req.APIVersion = parts[1]
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		req.APIVersion = parts[1] + "/" + parts[2]
+		parts = parts[3:]
+	default:
+		return nil, false, fmt.Errorf("unsupported path: %v", r.URL.Path)
+	}
+
+	watch := req.Query.Get("watch") == "true"
+	if len(parts) > 0 && parts[0] == "watch" {
+		watch = true
+		parts = parts[1:]
+	}
+
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		req.Namespace = parts[1]
+		parts = parts[2:]
+	}
+	if len(parts) == 0 {
+		if req.Method != http.MethodGet || watch {
+			return nil, false, fmt.Errorf("path has no resource: %v", r.URL.Path)
+		}
+		return req, false, nil
+	}
+
+	req.Resource = parts[0]
+	if len(parts) > 1 {
+		req.Name = parts[1]
+	}
+	if len(parts) > 2 {
+		req.Subresource = parts[2]
+	}
+
+	if r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+			req.Body = body
+		}
+	}
+
+	return req, watch, nil
+}
+
+// fieldSelector is a parsed field selector made of field=value and field!=value terms
+type fieldSelector []fieldTerm
+
+type fieldTerm struct {
+	path   []string
+	value  string
+	negate bool
+}
+
+func parseFieldSelector(selector string) (fieldSelector, error) {
+	var terms fieldSelector
+	for _, term := range strings.Split(selector, ",") {
+		if len(term) == 0 {
+			continue
+		}
+
+		negate := strings.Contains(term, "!=")
+		parts := strings.SplitN(strings.Replace(strings.Replace(term, "!=", "=", 1), "==", "=", 1), "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid field selector term: %v", term)
+		}
+		terms = append(terms, fieldTerm{
+			path:   strings.Split(parts[0], "."),
+			value:  parts[1],
+			negate: negate,
+		})
+	}
+	return terms, nil
+}
+
+func (f fieldSelector) matches(obj map[string]interface{}) bool {
+	for _, term := range f {
+		var value interface{} = obj
+		for _, field := range term.path {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = m[field]
+		}
+
+		actual := ""
+		if value != nil {
+			actual = fmt.Sprintf("%v", value)
+		}
+		if (actual == term.value) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// mergePatch applies a JSON merge patch. Strategic merge patches are applied the same way, which is
+// enough for the maps and scalar fields helpers patch.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchMap, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetMap, ok := target.(map[string]interface{})
+	if !ok {
+		targetMap = make(map[string]interface{})
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+			continue
+		}
+		targetMap[key] = mergePatch(targetMap[key], value)
+	}
+	return targetMap
+}
+
+func notFound(req *Request) (int, interface{}) {
+	return http.StatusNotFound, withDetails(Status(http.StatusNotFound, "NotFound",
+		fmt.Sprintf("%v %q not found", req.Resource, req.Name)), req, req.Name)
+}
+
+func badRequest(err error) (int, interface{}) {
+	return http.StatusBadRequest, Status(http.StatusBadRequest, "BadRequest", err.Error())
+}
+
+func withDetails(status map[string]interface{}, req *Request, name string) map[string]interface{} {
+	status["details"] = map[string]interface{}{
+		"name": name,
+		"kind": req.Resource,
+	}
+	return status
+}
+
+func versionInfo(gitVersion string) map[string]interface{} {
+	major, minor := "", ""
+	if parts := strings.SplitN(strings.TrimPrefix(gitVersion, "v"), ".", 3); len(parts) >= 2 {
+		major, minor = parts[0], parts[1]
+	}
+	return map[string]interface{}{
+		"major":      major,
+		"minor":      minor,
+		"gitVersion": gitVersion,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	// The client going away is not an error of the server
+	_, _ = w.Write(data)
+}
+
+// resourceForKind returns the plural lower case resource of the given kind
+func resourceForKind(kind string) string {
+	resource := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(resource, "endpoints"):
+		return resource
+	case strings.HasSuffix(resource, "s"):
+		return resource + "es"
+	case strings.HasSuffix(resource, "y"):
+		return strings.TrimSuffix(resource, "y") + "ies"
+	default:
+		return resource + "s"
+	}
+}
+
+func objectKey(resource, namespace, name string) string {
+	if len(namespace) == 0 {
+		return resource + "/" + name
+	}
+	return resource + "/" + namespace + "/" + name
+}
+
+// lastKey returns the key of the last object of a page
+func lastKey(items []interface{}, resource string) string {
+	meta := metadata(items[len(items)-1].(map[string]interface{}))
+	return objectKey(resource, getString(meta, "namespace"), getString(meta, "name"))
+}
+
+func toMap(obj interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func fromMap(m map[string]interface{}, obj interface{}) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, obj)
+}
+
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	c, err := toMap(m)
+	if err != nil {
+		panic(fmt.Sprintf("failed to copy object: %v", err))
+	}
+	return c
+}
+
+// metadata returns the metadata of the object, adding it if missing
+func metadata(obj map[string]interface{}) map[string]interface{} {
+	meta, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		meta = make(map[string]interface{})
+		obj["metadata"] = meta
+	}
+	return meta
+}
+
+func getString(m map[string]interface{}, key string) string {
+	value, _ := m[key].(string)
+	return value
+}
+
+func getSlice(m map[string]interface{}, key string) []interface{} {
+	value, _ := m[key].([]interface{})
+	return value
+}
+
+func getStringMap(m map[string]interface{}, key string) map[string]string {
+	values := make(map[string]string)
+	if raw, ok := m[key].(map[string]interface{}); ok {
+		for k, v := range raw {
+			values[k] = fmt.Sprintf("%v", v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,145 @@
+package k8stest
+
+import (
+	"testing"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func newClient(t *testing.T, s *Server) *kubernetes.Clientset {
+	client, err := kubernetes.NewForConfig(s.Config())
+	if err != nil {
+		t.Fatalf("failed to create the client: %v", err)
+	}
+	return client
+}
+
+func TestServerCRUD(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client := newClient(t, s)
+
+	created, err := client.CoreV1().ConfigMaps("ns").Create(&v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cm"},
+		Data:       map[string]string{"key": "value"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create: %v", err)
+	}
+	if len(created.UID) == 0 || len(created.ResourceVersion) == 0 || created.Namespace != "ns" {
+		t.Errorf("expected the server to set the uid, resource version and namespace, got: %+v", created.ObjectMeta)
+	}
+
+	if _, err := client.CoreV1().ConfigMaps("ns").Create(created); !k8s_errors.IsAlreadyExists(err) {
+		t.Errorf("expected an already exists error, got: %v", err)
+	}
+
+	created.Data["key"] = "updated"
+	if _, err := client.CoreV1().ConfigMaps("ns").Update(created); err != nil {
+		t.Fatalf("failed to update: %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("ns").Update(created); !k8s_errors.IsConflict(err) {
+		t.Errorf("expected a conflict on a stale resource version, got: %v", err)
+	}
+
+	var stored v1.ConfigMap
+	if !s.Get("configmaps", "ns", "cm", &stored) || stored.Data["key"] != "updated" {
+		t.Errorf("expected the update to be stored, got: %+v", stored)
+	}
+
+	if err := client.CoreV1().ConfigMaps("ns").Delete("cm", &meta_v1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if _, err := client.CoreV1().ConfigMaps("ns").Get("cm", meta_v1.GetOptions{}); !k8s_errors.IsNotFound(err) {
+		t.Errorf("expected a not found error, got: %v", err)
+	}
+}
+
+func TestServerListSelectors(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client := newClient(t, s)
+
+	s.Add(
+		&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "a", Namespace: "ns", Labels: map[string]string{"app": "x"}},
+			Spec: v1.PodSpec{NodeName: "node1"}},
+		&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "b", Namespace: "ns", Labels: map[string]string{"app": "y"}},
+			Spec: v1.PodSpec{NodeName: "node1"}},
+		&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "c", Namespace: "other", Labels: map[string]string{"app": "x"}},
+			Spec: v1.PodSpec{NodeName: "node2"}},
+	)
+
+	tests := []struct {
+		namespace string
+		opts      meta_v1.ListOptions
+		expected  int
+	}{
+		{namespace: "ns", expected: 2},
+		{namespace: "", expected: 3},
+		{namespace: "", opts: meta_v1.ListOptions{LabelSelector: "app=x"}, expected: 2},
+		{namespace: "", opts: meta_v1.ListOptions{FieldSelector: "spec.nodeName=node1"}, expected: 2},
+		{namespace: "ns", opts: meta_v1.ListOptions{LabelSelector: "app=x", FieldSelector: "spec.nodeName!=node1"}},
+	}
+
+	for _, test := range tests {
+		pods, err := client.CoreV1().Pods(test.namespace).List(test.opts)
+		if err != nil {
+			t.Fatalf("%+v: failed to list: %v", test, err)
+		}
+		if len(pods.Items) != test.expected {
+			t.Errorf("%+v: expected %d pods, got %d", test, test.expected, len(pods.Items))
+		}
+	}
+}
+
+func TestServerFinalizers(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client := newClient(t, s)
+
+	s.Add(&v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: "ns", Finalizers: []string{"test"}}})
+	if err := client.CoreV1().Namespaces().Delete("ns", &meta_v1.DeleteOptions{}); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+
+	ns, err := client.CoreV1().Namespaces().Get("ns", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the namespace to wait for its finalizers, got: %v", err)
+	}
+	if ns.DeletionTimestamp == nil {
+		t.Errorf("expected a deletion timestamp")
+	}
+
+	ns.Finalizers = nil
+	if _, err := client.CoreV1().Namespaces().Update(ns); err != nil {
+		t.Fatalf("failed to remove the finalizers: %v", err)
+	}
+	if s.Count("namespaces") != 0 {
+		t.Errorf("expected the namespace to be deleted once its finalizers are removed")
+	}
+}
+
+func TestServerDiscovery(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	client := newClient(t, s)
+
+	resources, err := client.Discovery().ServerResourcesForGroupVersion("apps/v1")
+	if err != nil {
+		t.Fatalf("failed to discover the resources: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, resource := range resources.APIResources {
+		found[resource.Name] = resource.Namespaced
+	}
+	if namespaced, ok := found["replicasets"]; !ok || !namespaced {
+		t.Errorf("expected namespaced replicasets to be discovered, got: %+v", resources.APIResources)
+	}
+	if namespaced, ok := found["nodes"]; !ok || namespaced {
+		t.Errorf("expected cluster scoped nodes to be discovered, got: %+v", resources.APIResources)
+	}
+}
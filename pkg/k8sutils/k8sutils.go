@@ -1,12 +1,22 @@
+// Package k8sutils talks to the k8s api server to create, inspect and validate the objects of torpedo
+// tests. The setters of its package level settings (e.g SetDefaultNamespace, SetAPIServerEndpoints or
+// RegisterWorkerCheck) can be called while validations are running, as TestSettingsConcurrentWithReaders
+// checks under -race. A setting changed while validations are running applies to the requests those
+// validations make afterwards. Validations of different objects can run concurrently.
 package k8sutils
 
 import (
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"net/http"
+	"strings"
 	"time"
 
-	"github.com/portworx/torpedo/pkg/task"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/pkg/api/v1"
 	"k8s.io/client-go/pkg/apis/apps/v1beta1"
@@ -14,14 +24,588 @@ import (
 	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
 	"k8s.io/client-go/rest"
 	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/task"
 )
 
 const (
 	k8sMasterLabelKey        = "node-role.kubernetes.io/master"
 	k8sPVCStorageClassKey    = "volume.beta.kubernetes.io/storage-class"
 	k8sLabelUpdateMaxRetries = 5
+	// pxAnnotationPrefix is the prefix of PVC annotations that carry Portworx volume options
+	pxAnnotationPrefix = "px/"
+	// k8sVolumeBindingWaitForFirstConsumer is the storage class binding mode that delays binding until a pod uses the pvc
+	k8sVolumeBindingWaitForFirstConsumer = "WaitForFirstConsumer"
+	// k8sPodTemplateHashKey is the label the deployment controller sets on the pods of each revision
+	k8sPodTemplateHashKey = "pod-template-hash"
+)
+
+// K8sVersion is the parsed semantic version of the k8s api server
+type K8sVersion struct {
+	Major int
+	Minor int
+	Patch int
+	// GitVersion is the raw version string reported by the server
+	GitVersion string
+}
+
+// ClusterInfo is a fingerprint of the environment of a cluster. Its JSON encoding is stable: the nodes are
+// sorted by name.
+type ClusterInfo struct {
+	// ServerVersion is the version of the k8s api server
+	ServerVersion string `json:"serverVersion"`
+	// CloudProviders are the distinct cloud providers of the nodes, from their provider IDs
+	CloudProviders []string `json:"cloudProviders"`
+	// NodeCount is the number of nodes
+	NodeCount int `json:"nodeCount"`
+	// MasterCount is the number of master nodes
+	MasterCount int `json:"masterCount"`
+	// Nodes are the details of each node
+	Nodes []NodeInfo `json:"nodes"`
+	// Heterogeneous maps the node properties that differ between nodes (e.g kernelVersion) to their
+	// distinct values. Empty if the nodes are homogeneous.
+	Heterogeneous map[string][]string `json:"heterogeneous,omitempty"`
+}
+
+// NodeInfo is the environment of a node
+type NodeInfo struct {
+	Name                    string `json:"name"`
+	ProviderID              string `json:"providerID,omitempty"`
+	Master                  bool   `json:"master"`
+	KernelVersion           string `json:"kernelVersion"`
+	OSImage                 string `json:"osImage"`
+	ContainerRuntime        string `json:"containerRuntime"`
+	ContainerRuntimeVersion string `json:"containerRuntimeVersion"`
+	KubeletVersion          string `json:"kubeletVersion"`
+	KubeProxyVersion        string `json:"kubeProxyVersion"`
+	OperatingSystem         string `json:"operatingSystem"`
+	Architecture            string `json:"architecture"`
+}
+
+// SettleReport describes how a cluster settled
+type SettleReport struct {
+	// Duration is how long the cluster took to settle
+	Duration time.Duration
+	// LastBlocking is the last condition that kept the cluster from settling. Empty if it was settled
+	// from the start.
+	LastBlocking string
+}
+
+// RevisionInfo is a revision of a deployment
+type RevisionInfo struct {
+	// Revision is the revision number of the deployment
+	Revision int64
+	// ReplicaSet is the name of the replica set of the revision
+	ReplicaSet string
+	// PodTemplateHash is the hash labeling the pods of the revision
+	PodTemplateHash string
+	// Replicas is the number of replicas of the revision
+	Replicas int32
+}
+
+// ServiceAccountToken is a token of a service account
+type ServiceAccountToken struct {
+	Token string
+	// ExpiresAt is when a token issued by the TokenRequest API expires. Zero for the tokens of service
+	// account secrets, which don't expire.
+	ExpiresAt time.Time
+}
+
+// ClusterNodesReport is the shape of a cluster whose nodes are ready
+type ClusterNodesReport struct {
+	// Masters is the number of ready master nodes
+	Masters int
+	// Workers is the number of ready worker nodes
+	Workers int
+	// WorkerChecks maps the registered worker checks to the number of workers that passed them
+	WorkerChecks map[string]int
+}
+
+// Evacuation is the result of moving the pods of a deployment off a node. The pods of a deployment are
+// interchangeable and the controller doesn't record which pod a new one replaces, so the replacement pods
+// are reported as a set rather than paired with the deleted pods.
+type Evacuation struct {
+	// Node is the evacuated node
+	Node string
+	// DeletedPods are the names of the pods deleted from the node, sorted
+	DeletedPods []string
+	// ReplacementPods maps the names of the pods created to replace the deleted pods to their node
+	ReplacementPods map[string]string
+}
+
+// TerminationEscalationPolicy escalates the teardown of an app whose pods stop terminating. Progress is
+// the number of remaining pods decreasing. Zero durations disable the escalation step.
+type TerminationEscalationPolicy struct {
+	// ForceDeleteAfter is how long without progress before the remaining pods are deleted with a zero
+	// grace period
+	ForceDeleteAfter time.Duration
+	// GiveUpAfter is how long without progress before the wait fails. The wait never outlasts the app
+	// delete timeout of the validation profile
+	GiveUpAfter time.Duration
+}
+
+// ComponentHealth is the health of a control plane component
+type ComponentHealth struct {
+	// Name is the name of the component (e.g scheduler)
+	Name string
+	// Healthy is true if the component is healthy
+	Healthy bool
+	// Message describes why the component is unhealthy
+	Message string
+}
+
+// PodPlacementEvent is a change of the node or running state of a pod
+type PodPlacementEvent struct {
+	// Time is when the change was observed
+	Time time.Time
+	// Pod is the name of the pod
+	Pod string
+	// Node is the node the pod is scheduled on. Empty if it isn't scheduled.
+	Node string
+	// Running is true if the pod is running on the node
+	Running bool
+}
+
+// NodeHealthEvent is a change of the health of the storage driver on a node
+type NodeHealthEvent struct {
+	// Time is when the change was observed
+	Time time.Time
+	// Node is the name of the node
+	Node string
+	// Healthy is false while the storage driver is down on the node
+	Healthy bool
+}
+
+// PlacementViolation is a period during which a pod ran on a node whose storage driver was down
+type PlacementViolation struct {
+	// Pod is the name of the pod
+	Pod string
+	// Node is the name of the node
+	Node string
+	// Start is when the violation started
+	Start time.Time
+	// End is when the violation ended. Zero if it was still ongoing at the end of the histories.
+	End time.Time
+}
+
+// MountState is the result of a write probe of a volume mount
+type MountState string
+
+const (
+	// MountStateReadWrite is a mount that can be written to
+	MountStateReadWrite MountState = "rw"
+	// MountStateReadOnly is a mount whose file system is read-only
+	MountStateReadOnly MountState = "ro"
+	// MountStateError is a mount that could not be probed or failed the probe for another reason
+	MountStateError MountState = "error"
+)
+
+// PodMountState is the state of the mount of a PVC in a container of a pod
+type PodMountState struct {
+	// Pod is the name of the pod
+	Pod string
+	// Container is the name of the container
+	Container string
+	// PVC is the name of the mounted PVC
+	PVC string
+	// MountPath is the path of the mount in the container
+	MountPath string
+	// State is the result of the write probe
+	State MountState
+	// Cause is the output of the probe if the mount is not read-write
+	Cause string
+}
+
+// MutationRecord is a mutating api request made during a test
+type MutationRecord struct {
+	// Test is the name of the test the request was made in
+	Test string `json:"test"`
+	// Time is when the request was sent
+	Time time.Time `json:"time"`
+	// Method is the http method of the request (e.g POST)
+	Method string `json:"method"`
+	// Resource is the resource of the request, with its subresource (e.g pods/eviction)
+	Resource string `json:"resource"`
+	// Object is the <namespace>/<name> of the object of the request
+	Object string `json:"object"`
+	// StatusCode is the status code of the response. Zero if the request failed without a response.
+	StatusCode int `json:"statusCode"`
+	// Error is why the request failed without a response
+	Error string `json:"error,omitempty"`
+	// Body is the start of the request body, if body capture is enabled
+	Body string `json:"body,omitempty"`
+}
+
+// PVCStats is the usage of the volume of a PVC as reported by the kubelet of the node it is mounted on
+type PVCStats struct {
+	// Time is when the kubelet collected the stats
+	Time time.Time
+	// CapacityBytes is the size of the file system of the volume
+	CapacityBytes uint64
+	// UsedBytes is the number of bytes used on the file system
+	UsedBytes uint64
+	// AvailableBytes is the number of bytes available on the file system
+	AvailableBytes uint64
+	// Inodes is the number of inodes of the file system
+	Inodes uint64
+	// InodesUsed is the number of used inodes
+	InodesUsed uint64
+	// InodesFree is the number of free inodes
+	InodesFree uint64
+}
+
+// PodContainerSnapshot is the identity of a pod and of its containers at a point in time
+type PodContainerSnapshot struct {
+	// Pod is the name of the pod
+	Pod string
+	// UID is the UID of the pod
+	UID types.UID
+	// ContainerIDs maps the names of the containers of the pod to the IDs of their running containers, as
+	// reported in the container statuses (<runtime>://<id>)
+	ContainerIDs map[string]string
+}
+
+// NodeReadyTransition is an observed change of the Ready condition of a node
+type NodeReadyTransition struct {
+	// Time is when the change was observed
+	Time time.Time
+	// Ready is the status of the Ready condition after the change
+	Ready bool
+	// Reason is the reason of the condition
+	Reason string
+}
+
+// VolumeHealthFinding is a sign that the volume of a PVC is degraded, from a warning event or the phase
+// of its PV
+type VolumeHealthFinding struct {
+	// Time is when the problem was last reported
+	Time time.Time
+	// PVC is the <namespace>/<name> of the claim
+	PVC string
+	// Object is the <kind>/<name> of the object the problem was reported on (e.g Pod/<name>)
+	Object string
+	// Reason is the reason of the event or the phase of the PV
+	Reason string
+	// Message describes the problem
+	Message string
+}
+
+// InflightRequestStats are the counts of the api requests limited by SetMaxInflightRequests
+type InflightRequestStats struct {
+	// Current is the number of requests in flight
+	Current int
+	// Peak is the highest number of requests that were in flight at the same time
+	Peak int
+	// Max is the limit of requests in flight. Zero if unlimited.
+	Max int
+}
+
+// FailoverMark is a point of the chaos timeline marked during a failover measurement
+type FailoverMark struct {
+	// Label describes the event (e.g "node stopped")
+	Label string `json:"label"`
+	// Time is when the event was marked
+	Time time.Time `json:"time"`
+}
+
+// Outage is a period during which the probes of a failover measurement failed
+type Outage struct {
+	// Start is the time of the first failed probe
+	Start time.Time `json:"start"`
+	// End is the time of the next successful probe. Zero if the app didn't recover before the end of
+	// the measurement.
+	End time.Time `json:"end"`
+}
+
+// FailoverReport is the downtime of an app observed by a failover measurement
+type FailoverReport struct {
+	// Start and End are the times the measurement started and stopped
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Marks are the marked events, in order
+	Marks []FailoverMark `json:"marks"`
+	// Outages are the periods the probes failed, in order
+	Outages []Outage `json:"outages"`
+	// DetectionLatency is the time from the first mark to the start of the first outage after it. Zero
+	// if there is no mark or no outage after it.
+	DetectionLatency time.Duration `json:"detectionLatency"`
+	// TotalDowntime is the total duration of the outages, up to the end of the measurement for an
+	// outage that didn't end
+	TotalDowntime time.Duration `json:"totalDowntime"`
+	// Probes and Failures are the number of probes and of failed probes
+	Probes   int `json:"probes"`
+	Failures int `json:"failures"`
+	// Errors counts the failed probes by class of failure
+	Errors map[string]int `json:"errors"`
+}
+
+// Profile is the set of timeouts the validations use when the caller doesn't give one
+type Profile struct {
+	// Name identifies the profile in the test logs
+	Name string
+	// AppReadyTimeout is how long a deployment or rollout is waited on to be ready
+	AppReadyTimeout time.Duration
+	// AppDeleteTimeout is how long a deleted app or its PVCs are waited on to be gone
+	AppDeleteTimeout time.Duration
+	// PVCBindTimeout is how long a PVC is waited on to be bound
+	PVCBindTimeout time.Duration
+	// RetryInterval is the wait between two checks of a validation
+	RetryInterval time.Duration
+}
+
+// SystemPodSnapshot is the state of the system pods (e.g of kube-system and portworx) at a point in time
+type SystemPodSnapshot struct {
+	// Namespaces are the namespaces of the snapshot
+	Namespaces []string
+	// Pods are the pods of the namespaces
+	Pods []SystemPodState
+}
+
+// SystemPodState is the identity and restart count of a system pod
+type SystemPodState struct {
+	// Namespace is the namespace of the pod
+	Namespace string
+	// Name is the name of the pod
+	Name string
+	// UID is the UID of the pod
+	UID types.UID
+	// Labels are the labels of the pod
+	Labels map[string]string
+	// Node is the node of the pod
+	Node string
+	// Restarts is the sum of the restart counts of the containers of the pod
+	Restarts int32
+}
+
+// ServiceEndpoint is a ready address of a service for one of its ports
+type ServiceEndpoint struct {
+	// IP is the address of the endpoint, usually a pod IP
+	IP string
+	// Port is the port of the endpoint, i.e the resolved target port of the service port
+	Port int32
+	// PortName is the name of the service port. Empty for a service with a single unnamed port.
+	PortName string
+	// Protocol is the protocol of the port
+	Protocol v1.Protocol
+	// Pod is the name of the pod backing the endpoint. Empty if the endpoint isn't a pod.
+	Pod string
+	// Node is the node of the endpoint, if known
+	Node string
+}
+
+// StressSpec describes the resources a stress pod consumes on its node
+type StressSpec struct {
+	// Namespace is the namespace of the stress pod. Defaults to the default namespace.
+	Namespace string
+	// Memory is the amount of memory to consume (Optional)
+	Memory resource.Quantity
+	// Disk is the amount of ephemeral (emptyDir) storage to fill (Optional)
+	Disk resource.Quantity
+	// Image is the image used for the stress pod. Defaults to busybox.
+	Image string
+}
+
+// DeploymentStatusReport describes a deployment that was validated to be running and healthy
+type DeploymentStatusReport struct {
+	Name              string
+	Namespace         string
+	ReadyReplicas     int32
+	AvailableReplicas int32
+	// PodNodes maps the names of the deployment pods to the nodes they are running on
+	PodNodes map[string]string
+	// PVCs are the names of the PVCs used by the deployment
+	PVCs []string
+	// TimeToReady is how long the deployment took to become ready since validation started
+	TimeToReady time.Duration
+}
+
+// FailoverProbe checks once if an app serves requests. It is given the time it must return within.
+type FailoverProbe func(timeout time.Duration) error
+
+// ProbeFailureReason is the class of failure of a service probe
+type ProbeFailureReason string
+
+const (
+	// ProbeFailureDNS is when the service name could not be resolved
+	ProbeFailureDNS ProbeFailureReason = "DNSResolutionFailed"
+	// ProbeFailureConnectionRefused is when nothing accepted the connection on the service port
+	ProbeFailureConnectionRefused ProbeFailureReason = "ConnectionRefused"
+	// ProbeFailureWrongStatus is when the service responded with an unexpected HTTP status
+	ProbeFailureWrongStatus ProbeFailureReason = "WrongStatusCode"
+	// ProbeFailureOther is for any other failure (e.g timeouts)
+	ProbeFailureOther ProbeFailureReason = "Other"
+)
+
+// ProbeResult is the result of a single attempt to probe a service from within the cluster
+type ProbeResult struct {
+	// Attempt is the 1-based index of the attempt
+	Attempt int
+	// StatusCode is the HTTP status returned by the service. 0 if there was no response.
+	StatusCode int
+	// Latency is how long the request took, including the exec round trip to the prober pod
+	Latency time.Duration
+	// Err is nil if the service responded with the expected status
+	Err error
+}
+
+// TerminationRecord describes an abnormal termination of a container or pod
+type TerminationRecord struct {
+	Pod       string
+	Container string
+	Node      string
+	// Reason is the termination reason (e.g OOMKilled, Error or Evicted)
+	Reason   string
+	Message  string
+	ExitCode int32
+	// Time is when the termination happened
+	Time time.Time
+}
+
+// Inventory is a snapshot of the objects in the cluster
+type Inventory struct {
+	// Objects maps the kind of the objects (e.g pods) to their <namespace>/<name> or, for cluster
+	// scoped objects, their name
+	Objects map[string][]string
+}
+
+// ValidationTransition is a change of readiness of an app observed by continuous validation
+type ValidationTransition struct {
+	// Time is when the change was observed
+	Time time.Time
+	// Ready is the readiness of the app after the change
+	Ready bool
+	// Cause is why the app is not ready. Empty if Ready is true.
+	Cause string
+}
+
+// ValidationHistory is the readiness history recorded by continuous validation
+type ValidationHistory struct {
+	// Transitions are the observed readiness changes, starting with the initial state
+	Transitions []ValidationTransition
+	// TotalDowntime is the total time the app was not ready
+	TotalDowntime time.Duration
+	// LongestOutage is the longest continuous period the app was not ready
+	LongestOutage time.Duration
+	// Causes are the distinct causes observed while the app was not ready
+	Causes []string
+}
+
+// ValidationObserver is notified of the attempts and results of the validations, e.g to collect how
+// long apps take to become ready
+type ValidationObserver interface {
+	// OnAttempt is called after each attempt of the validation with the given name (e.g deployment/<name>,
+	// terminated-deployment/<name> or pvc/<name>). elapsed is the time since the validation started.
+	OnAttempt(name string, attempt int, elapsed time.Duration, err error)
+	// OnComplete is called once the validation with the given name succeeded or timed out
+	OnComplete(name string, total time.Duration, err error)
+}
+
+// ValidationStats is a histogram of the times of the successful validations with a given name
+type ValidationStats struct {
+	// Count is the number of validations
+	Count int
+	// Failures is the number of validations that failed
+	Failures int
+	// Attempts is the total number of attempts of all validations
+	Attempts int
+	// Min, Max and Total are over the successful validations
+	Min   time.Duration
+	Max   time.Duration
+	Total time.Duration
+	// Buckets are the upper bounds of the histogram buckets
+	Buckets []time.Duration
+	// Counts are the number of successful validations in each bucket. The last count is for the
+	// validations that took longer than the last bucket.
+	Counts []int
+}
+
+// ParameterChange is a change of a parameter of a storage class. Old is empty for added parameters and
+// New is empty for removed ones.
+type ParameterChange struct {
+	Key string
+	Old string
+	New string
+}
+
+// VolumeParamsVerifier checks that the volume of the given PV honors the given parameters, e.g by
+// inspecting the volume with the storage driver
+type VolumeParamsVerifier func(params map[string]string, pvName string) error
+
+// ReplaceStorageClassOption is an option for ReplaceStorageClass
+type ReplaceStorageClassOption func(*replaceStorageClassOptions)
+
+// MutationRecordingOption is an option for StartMutationRecording
+type MutationRecordingOption func(*mutationRecorder)
+
+// TestNamespaceOption is an option for CreateTestNamespace
+type TestNamespaceOption func(*testNamespaceOptions)
+
+// PodLogOption is an option for GetPodLogs and StreamPodLogs
+type PodLogOption func(*v1.PodLogOptions)
+
+// VolumeHealthOption is an option for MonitorVolumeHealth
+type VolumeHealthOption func(*volumeHealthScanner)
+
+// CommandResult is the result of a command run on a node
+type CommandResult struct {
+	Stdout string
+	Stderr string
+	// ExitCode is the exit code of the command. -1 if the command could not be run.
+	ExitCode int
+	// Err is set if the command could not be run or exited with a non-zero code
+	Err error
+}
+
+// PVCVolumeMode is the volume mode of a PVC
+type PVCVolumeMode string
+
+const (
+	// PVCVolumeModeFilesystem is the mode of PVCs mounted as a filesystem. Claims without a volume mode
+	// have this mode.
+	PVCVolumeModeFilesystem PVCVolumeMode = "Filesystem"
+	// PVCVolumeModeBlock is the mode of PVCs attached as a raw block device
+	PVCVolumeModeBlock PVCVolumeMode = "Block"
 )
 
+// PVCExpectations are the expected properties of a bound PVC. Zero valued fields are not checked.
+type PVCExpectations struct {
+	// Size is the minimum capacity of the bound volume
+	Size resource.Quantity
+	// AccessModes must all be supported by the bound volume
+	AccessModes []v1.PersistentVolumeAccessMode
+	// StorageClass is the name of the storage class of the claim
+	StorageClass string
+	// VolumeMode is the volume mode of the claim
+	VolumeMode PVCVolumeMode
+}
+
+// ListOption is an option for the list helpers
+type ListOption func(*listOptions)
+
+// DeleteOption is an option for the delete helpers
+type DeleteOption func(*deleteOptions)
+
+// CreateOption is an option for the create helpers
+type CreateOption func(*createOptions)
+
+// ValidateOption is an option for the validate helpers
+type ValidateOption func(*validateOptions)
+
+// PodTemplateMutation is a change of the pod template of a deployment
+type PodTemplateMutation func(*v1.PodTemplateSpec) error
+
+// NodeSelector selects the nodes to operate on for bulk node operations
+type NodeSelector func(v1.Node) bool
+
+// StressPod is a handle to a running stress pod
+type StressPod interface {
+	// Pod returns the stress pod
+	Pod() *v1.Pod
+	// Stop deletes the stress pod without waiting for it to terminate
+	Stop() error
+	// Cleanup deletes the stress pod and waits for it to be gone
+	Cleanup() error
+}
+
 // GetK8sClient instantiates a k8s client
 func GetK8sClient() (*kubernetes.Clientset, error) {
 	k8sClient, err := loadClientFromServiceAccount()
@@ -68,13 +652,18 @@ func GetNodeByName(name string) (*v1.Node, error) {
 	return node, nil
 }
 
-// IsNodeReady checks if node with given name is ready. Returns nil is ready.
+// IsNodeReady checks if node with given name is ready. Returns nil is ready. A node without a Ready
+// condition, e.g one that is still joining the cluster, is not ready.
 func IsNodeReady(name string) error {
 	node, err := GetNodeByName(name)
 	if err != nil {
 		return err
 	}
 
+	if getNodeReadyCondition(node) == nil {
+		return fmt.Errorf("node: %v is not ready as it does not have a %v condition", name, v1.NodeReady)
+	}
+
 	for _, condition := range node.Status.Conditions {
 		switch condition.Type {
 		case v1.NodeConditionType(v1.NodeReady):
@@ -97,167 +686,264 @@ func IsNodeReady(name string) error {
 	return nil
 }
 
-// CreateDeployment creates the given deployment
-func CreateDeployment(deployment *v1beta1.Deployment) (*v1beta1.Deployment, error) {
+// CreateDeployment creates the given deployment and returns the deployment created by the server, with
+// its UID. If the deployment has no namespace, it is set to the namespace from the options or the default
+// namespace.
+func CreateDeployment(deployment *v1beta1.Deployment, opts ...CreateOption) (*v1beta1.Deployment, error) {
+	if err := checkAppsV1beta1("CreateDeployment"); err != nil {
+		return nil, err
+	}
+
 	client, err := GetK8sClient()
 	if err != nil {
 		return nil, err
 	}
 
+	if deployment.Namespace, err = resolveCreateNamespace(client, deployment.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&deployment.ObjectMeta)
+	stampInstanceLabel(&deployment.Spec.Template.ObjectMeta)
+
 	return client.AppsV1beta1().Deployments(deployment.Namespace).Create(deployment)
 }
 
-// DeleteDeployment deletes the given deployment
-func DeleteDeployment(deployment *v1beta1.Deployment) error {
+// GetDeployment returns the current state of the deployment with the given name and namespace
+func GetDeployment(name, namespace string) (*v1beta1.Deployment, error) {
+	if err := checkAppsV1beta1("GetDeployment"); err != nil {
+		return nil, err
+	}
+
 	client, err := GetK8sClient()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	policy := meta_v1.DeletePropagationForeground
-	return client.AppsV1beta1().Deployments(deployment.Namespace).Delete(deployment.Name, &meta_v1.DeleteOptions{
-		PropagationPolicy: &policy,
-	})
+	return client.AppsV1beta1().Deployments(namespaceOrDefault(namespace)).Get(name, meta_v1.GetOptions{})
 }
 
-// ValidateDeployement validates the given deployment if it's running and healthy
-func ValidateDeployement(deployment *v1beta1.Deployment) error {
-	t := func() error {
-		client, err := GetK8sClient()
-		if err != nil {
-			return err
-		}
+// DeleteDeployment deletes the given deployment. An ErrNotOwned is returned if another torpedo instance
+// created it, unless WithForceDelete is given.
+func DeleteDeployment(deployment *v1beta1.Deployment, opts ...DeleteOption) error {
+	if err := checkAppsV1beta1("DeleteDeployment"); err != nil {
+		return err
+	}
 
-		dep, err := client.AppsV1beta1().Deployments(deployment.Namespace).Get(deployment.Name, meta_v1.GetOptions{})
-		if err != nil {
-			return err
-		}
+	if err := CheckDestructiveOp("DeleteDeployment"); err != nil {
+		return err
+	}
 
-		if *dep.Spec.Replicas != dep.Status.AvailableReplicas {
-			return &ErrAppNotReady{
-				ID:    dep.Name,
-				Cause: fmt.Sprintf("Expected replicas: %v Available replicas: %v", *dep.Spec.Replicas, dep.Status.AvailableReplicas),
-			}
-		}
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
 
-		if *dep.Spec.Replicas != dep.Status.ReadyReplicas {
-			return &ErrAppNotReady{
-				ID:    dep.Name,
-				Cause: fmt.Sprintf("Expected replicas: %v Ready replicas: %v", *dep.Spec.Replicas, dep.Status.ReadyReplicas),
-			}
+	if current, err := client.AppsV1beta1().Deployments(namespaceOrDefault(deployment.Namespace)).Get(deployment.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("deployment", current.ObjectMeta, opts); err != nil {
+			return err
 		}
+	}
 
-		pods, err := GetDeploymentPods(deployment)
-		if err != nil || pods == nil {
-			return &ErrAppNotReady{
-				ID:    dep.Name,
-				Cause: fmt.Sprintf("Failed to get pods for deployment. Err: %v", err),
-			}
-		}
+	policy := meta_v1.DeletePropagationForeground
+	return client.AppsV1beta1().Deployments(namespaceOrDefault(deployment.Namespace)).Delete(deployment.Name, &meta_v1.DeleteOptions{
+		PropagationPolicy: &policy,
+	})
+}
 
-		for _, pod := range pods {
-			if !IsPodRunning(pod) {
-				return &ErrAppNotReady{
-					ID:    dep.Name,
-					Cause: fmt.Sprintf("pod: %v is not yet ready", pod.Name),
-				}
-			}
-		}
+// ValidateDeployement validates the given deployment if it's running and healthy. It waits for the app
+// ready timeout of the validation profile (see SetValidationProfile). On timeout, the pod terminations and
+// the warning events of the deployment seen during the validation are attached to the error, as is the
+// directory of the pod logs collected if SetFailureLogsDir is set.
+func ValidateDeployement(deployment *v1beta1.Deployment, opts ...ValidateOption) error {
+	_, err := ValidateDeploymentWithResult(deployment, opts...)
+	return err
+}
 
-		return nil
+// ValidateDeploymentWithResult validates the given deployment if it's running and healthy and returns
+// a report of the validated deployment
+func ValidateDeploymentWithResult(deployment *v1beta1.Deployment, opts ...ValidateOption) (*DeploymentStatusReport, error) {
+	if err := checkAppsV1beta1("ValidateDeployement"); err != nil {
+		return nil, err
 	}
 
-	if err := task.DoRetryWithTimeout(t, 10*time.Minute, 10*time.Second); err != nil {
-		return err
+	options := newValidateOptions(opts)
+	if err := checkContainerNames(deployment.Name, deployment.Spec.Template.Spec.Containers, options.containers); err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	start := time.Now()
+	report := &DeploymentStatusReport{
+		Name:      deployment.Name,
+		Namespace: deployment.Namespace,
+		PodNodes:  make(map[string]string),
+		PVCs:      getDeploymentPVCNames(deployment),
+	}
 
-// ValidateTerminatedDeployment validates if given deployment is terminated
-func ValidateTerminatedDeployment(deployment *v1beta1.Deployment) error {
-	t := func() error {
-		client, err := GetK8sClient()
-		if err != nil {
-			return err
-		}
+	t, complete := observeValidation("deployment/"+deployment.Name, func() error {
+		return checkDeploymentReady(deployment, report, options)
+	})
 
-		dep, err := client.AppsV1beta1().Deployments(deployment.Namespace).Get(deployment.Name, meta_v1.GetOptions{})
-		if err != nil {
-			if matched, _ := regexp.MatchString(".+ not found", err.Error()); matched {
-				return nil
-			}
-			return err
+	profile := validationProfile()
+	err := doRetryWithTimeout(t, profile.AppReadyTimeout, profile.RetryInterval)
+	complete(err)
+	if err != nil {
+		var diagnostics []string
+		if records, detectErr := DetectPodTerminations(deployment, start); detectErr == nil && len(records) > 0 {
+			diagnostics = append(diagnostics, fmt.Sprintf("Pod terminations: %v", formatTerminationRecords(records)))
 		}
-
-		pods, err := GetDeploymentPods(deployment)
-		if err != nil {
-			return &ErrAppNotTerminated{
-				ID:    dep.Name,
-				Cause: fmt.Sprintf("Failed to get pods for deployment. Err: %v", err),
+		if events, eventsErr := getDeploymentWarningEvents(deployment, start); eventsErr == nil && len(events) > 0 {
+			diagnostics = append(diagnostics, fmt.Sprintf("Warning events: %v", formatEvents(events)))
+		}
+		if pods, podsErr := GetDeploymentPods(deployment); podsErr == nil {
+			if dir := collectFailureLogs("deployment/"+deployment.Name, pods); len(dir) > 0 {
+				diagnostics = append(diagnostics, fmt.Sprintf("Logs: %v", dir))
 			}
 		}
-
-		if pods != nil && len(pods) > 0 {
-			return &ErrAppNotTerminated{
-				ID:    dep.Name,
-				Cause: fmt.Sprintf("pods: %#v is still present", pods),
+		if len(diagnostics) > 0 {
+			return nil, &ErrAppNotReady{
+				ID:    deployment.Name,
+				Cause: fmt.Sprintf("%v. %v", err, strings.Join(diagnostics, ". ")),
 			}
 		}
-
-		return nil
+		return nil, err
 	}
 
-	if err := task.DoRetryWithTimeout(t, 10*time.Minute, 10*time.Second); err != nil {
+	report.TimeToReady = time.Since(start)
+	return report, nil
+}
+
+// ValidateTerminatedDeployment validates if given deployment is terminated within the app delete timeout
+// of the validation profile
+func ValidateTerminatedDeployment(deployment *v1beta1.Deployment) error {
+	return ValidateTerminatedDeploymentWithTimeout(deployment, 0)
+}
+
+// ValidateTerminatedDeploymentWithTimeout validates if given deployment is terminated within the given
+// timeout, or the app delete timeout of the validation profile if zero
+func ValidateTerminatedDeploymentWithTimeout(deployment *v1beta1.Deployment, timeout time.Duration) error {
+	if err := checkAppsV1beta1("ValidateTerminatedDeployment"); err != nil {
 		return err
 	}
 
-	return nil
+	t := func() error {
+		return checkDeploymentTerminated(deployment)
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppDeleteTimeout
+	}
+
+	t, complete := observeValidation("terminated-deployment/"+deployment.Name, t)
+	err := doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	complete(err)
+	return err
 }
 
-// GetDeploymentPods returns pods for the given deployment
+// GetDeploymentPods returns pods for the given deployment. Pods are looked up using the deployment's
+// selector and their owning ReplicaSet. If the deployment has a UID, only the pods of the ReplicaSets owned
+// by that UID are returned so that a recreated deployment with the same name is told apart. Otherwise the
+// ReplicaSets are matched by name. Pods of all revisions of the deployment are returned.
 func GetDeploymentPods(deployment *v1beta1.Deployment) ([]v1.Pod, error) {
 	client, err := GetK8sClient()
 	if err != nil {
 		return nil, err
 	}
 
-	rSets, err := client.ReplicaSets(deployment.Namespace).List(meta_v1.ListOptions{})
+	if len(deployment.UID) > 0 {
+		replicaSets, err := listAllReplicaSets(client, namespaceOrDefault(deployment.Namespace), meta_v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		current, _, err := getDeploymentInstancePods(client, deployment, replicaSets)
+		return current, err
+	}
+
+	pods, err := getDeploymentSelectorPods(client, deployment)
 	if err != nil {
 		return nil, err
 	}
 
-	for _, rSet := range rSets.Items {
-		for _, owner := range rSet.OwnerReferences {
-			if owner.Name == deployment.Name {
-				return GetReplicaSetPods(rSet)
-			}
+	var result []v1.Pod
+	for _, pod := range pods {
+		if isOwnedByDeployment(pod, deployment.Name) {
+			result = append(result, pod)
 		}
 	}
 
-	return nil, nil
+	return result, nil
 }
 
-// DeletePods deletes the given pods
-func DeletePods(pods []v1.Pod) error {
-	client, err := GetK8sClient()
+// getDeploymentSelectorPods returns the pods matching the selector of the given deployment
+func getDeploymentSelectorPods(client *kubernetes.Clientset, deployment *v1beta1.Deployment) ([]v1.Pod, error) {
+	selector := labels.SelectorFromSet(deployment.Spec.Template.Labels)
+	if deployment.Spec.Selector != nil {
+		var err error
+		if selector, err = meta_v1.LabelSelectorAsSelector(deployment.Spec.Selector); err != nil {
+			return nil, err
+		}
+	}
+
+	return listCachedPods(client, namespaceOrDefault(deployment.Namespace), selector)
+}
+
+// getDeploymentInstancePods returns the pods of the given deployment, which must have a UID, and the pods
+// of previous instances of a deployment with the same name. Pods are matched through the UID chain from
+// the pod to its ReplicaSet to the deployment. The pods whose ReplicaSet is already deleted are taken as
+// previous pods if the ReplicaSet name matches the deployment name, as the ReplicaSets of the current
+// instance are not deleted while their pods exist, except by the revision history limit. The given replica
+// sets are those of the namespace of the deployment.
+func getDeploymentInstancePods(
+	client *kubernetes.Clientset,
+	deployment *v1beta1.Deployment,
+	replicaSets []ext_v1beta1.ReplicaSet,
+) ([]v1.Pod, []v1.Pod, error) {
+	pods, err := getDeploymentSelectorPods(client, deployment)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	var gracePeriod int64
-	gracePeriod = 0
+	replicaSetOwners := make(map[types.UID]*meta_v1.OwnerReference)
+	for _, rs := range replicaSets {
+		replicaSetOwners[rs.UID] = nil
+		for i, owner := range rs.OwnerReferences {
+			if owner.Kind == "Deployment" {
+				replicaSetOwners[rs.UID] = &rs.OwnerReferences[i]
+				break
+			}
+		}
+	}
 
+	var current, previous []v1.Pod
 	for _, pod := range pods {
-		logrus.Infof("[debug] Deleting pod : %v", pod.Name)
-		if err = client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &meta_v1.DeleteOptions{
-			GracePeriodSeconds: &gracePeriod,
-		}); err != nil {
-			return err
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind != "ReplicaSet" {
+				continue
+			}
+
+			rsOwner, found := replicaSetOwners[owner.UID]
+			if !found {
+				if isOwnedByDeployment(pod, deployment.Name) {
+					previous = append(previous, pod)
+				}
+			} else if rsOwner != nil && rsOwner.UID == deployment.UID {
+				current = append(current, pod)
+			} else if rsOwner != nil && rsOwner.Name == deployment.Name {
+				previous = append(previous, pod)
+			}
+			break
 		}
 	}
 
-	return nil
+	return current, previous, nil
+}
+
+// DeletePods deletes the given pods. All pods are attempted and the failures are returned together.
+func DeletePods(pods []v1.Pod) error {
+	_, err := deletePods(pods)
+	return err
 }
 
 // GetReplicaSetPods returns pods for the given replica set
@@ -267,13 +953,13 @@ func GetReplicaSetPods(rSet ext_v1beta1.ReplicaSet) ([]v1.Pod, error) {
 		return nil, err
 	}
 
-	pods, err := client.Pods(rSet.Namespace).List(meta_v1.ListOptions{})
+	pods, err := listCachedPods(client, namespaceOrDefault(rSet.Namespace), labels.Everything())
 	if err != nil {
 		return nil, err
 	}
 
 	var result []v1.Pod
-	for _, pod := range pods.Items {
+	for _, pod := range pods {
 		for _, owner := range pod.OwnerReferences {
 			if owner.Name == rSet.Name {
 				result = append(result, pod)
@@ -291,27 +977,77 @@ func CreateStorageClass(sc *storage_v1beta1.StorageClass) (*storage_v1beta1.Stor
 		return nil, err
 	}
 
+	stampInstanceLabel(&sc.ObjectMeta)
+
 	return client.StorageV1beta1().StorageClasses().Create(sc)
 }
 
-// DeleteStorageClass deletes the given storage class
-func DeleteStorageClass(sc *storage_v1beta1.StorageClass) error {
+// DeleteStorageClass deletes the given storage class. An ErrNotOwned is returned if another torpedo
+// instance created it, unless WithForceDelete is given.
+func DeleteStorageClass(sc *storage_v1beta1.StorageClass, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteStorageClass"); err != nil {
+		return err
+	}
+
 	client, err := GetK8sClient()
 	if err != nil {
 		return err
 	}
 
+	if current, err := client.StorageV1beta1().StorageClasses().Get(sc.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("storage class", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
 	return client.StorageV1beta1().StorageClasses().Delete(sc.Name, &meta_v1.DeleteOptions{})
 }
 
-// ValidateStorageClass validates the given storage class
-func ValidateStorageClass(sc *storage_v1beta1.StorageClass) error {
-	client, err := GetK8sClient()
-	if err != nil {
+// DeleteStorageClassSafe deletes the given storage class if no PVC references it. If force is set, the
+// storage class is deleted regardless, also if another torpedo instance created it.
+func DeleteStorageClassSafe(sc *storage_v1beta1.StorageClass, force bool) error {
+	if err := CheckDestructiveOp("DeleteStorageClassSafe"); err != nil {
 		return err
 	}
 
-	_, err = client.StorageV1beta1().StorageClasses().Get(sc.Name, meta_v1.GetOptions{})
+	if !force {
+		client, err := GetK8sClient()
+		if err != nil {
+			return err
+		}
+
+		pvcs, err := getStorageClassPVCs(client, sc.Name)
+		if err != nil {
+			return err
+		}
+
+		var users []string
+		for _, pvc := range pvcs {
+			users = append(users, fmt.Sprintf("%v/%v", pvc.Namespace, pvc.Name))
+		}
+
+		if len(users) > 0 {
+			return &ErrStorageClassInUse{
+				Name: sc.Name,
+				PVCs: users,
+			}
+		}
+	}
+
+	if force {
+		return DeleteStorageClass(sc, WithForceDelete())
+	}
+	return DeleteStorageClass(sc)
+}
+
+// ValidateStorageClass validates the given storage class
+func ValidateStorageClass(sc *storage_v1beta1.StorageClass) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.StorageV1beta1().StorageClasses().Get(sc.Name, meta_v1.GetOptions{})
 	if err != nil {
 		return err
 	}
@@ -319,72 +1055,297 @@ func ValidateStorageClass(sc *storage_v1beta1.StorageClass) error {
 	return nil
 }
 
-// CreatePersistentVolumeClaim creates the given persistent volume claim
-func CreatePersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, error) {
+// CreatePersistentVolumeClaim creates the given persistent volume claim and returns the claim created by
+// the server, with its UID. If the claim has no namespace, it is set to the namespace from the options or
+// the default namespace.
+func CreatePersistentVolumeClaim(
+	pvc *v1.PersistentVolumeClaim,
+	opts ...CreateOption,
+) (*v1.PersistentVolumeClaim, error) {
 	client, err := GetK8sClient()
 	if err != nil {
 		return nil, err
 	}
 
+	if pvc.Namespace, err = resolveCreateNamespace(client, pvc.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&pvc.ObjectMeta)
+
 	return client.PersistentVolumeClaims(pvc.Namespace).Create(pvc)
 }
 
-// DeletePersistentVolumeClaim deletes the given persistent volume claim
-func DeletePersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) error {
+// GetPVC returns the current state of the persistent volume claim with the given name and namespace
+func GetPVC(name, namespace string) (*v1.PersistentVolumeClaim, error) {
 	client, err := GetK8sClient()
 	if err != nil {
+		return nil, err
+	}
+
+	return client.PersistentVolumeClaims(namespaceOrDefault(namespace)).Get(name, meta_v1.GetOptions{})
+}
+
+// DeletePersistentVolumeClaim deletes the given persistent volume claim. An ErrNotOwned is returned if
+// another torpedo instance created it, unless WithForceDelete is given.
+func DeletePersistentVolumeClaim(pvc *v1.PersistentVolumeClaim, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeletePersistentVolumeClaim"); err != nil {
 		return err
 	}
 
-	return client.PersistentVolumeClaims(pvc.Namespace).Delete(pvc.Name, &meta_v1.DeleteOptions{})
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	if current, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("PVC", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	return client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Delete(pvc.Name, &meta_v1.DeleteOptions{})
 }
 
-// ValidatePersistentVolumeClaim validates the given pvc
-func ValidatePersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) error {
+// DeletePersistentVolumeClaimSafe deletes the given persistent volume claim once no running pod uses it.
+// The pods are waited on up to the given timeout. With a zero timeout, the claim is only deleted if no pod
+// uses it already. The options are passed to DeletePersistentVolumeClaim, e.g WithForceDelete to delete a
+// claim of another torpedo instance.
+func DeletePersistentVolumeClaimSafe(
+	pvc *v1.PersistentVolumeClaim,
+	timeout time.Duration,
+	opts ...DeleteOption,
+) error {
+	if err := CheckDestructiveOp("DeletePersistentVolumeClaimSafe"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	claim := *pvc
+	claim.Namespace = namespaceOrDefault(pvc.Namespace)
+
 	t := func() error {
-		client, err := GetK8sClient()
+		pods, err := getPodsUsingPVC(client, &claim)
+		if err != nil {
+			return err
+		}
+
+		var users []string
+		for _, pod := range pods {
+			if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+				users = append(users, pod.Name)
+			}
+		}
+
+		if len(users) > 0 {
+			return &ErrPVCInUse{
+				Name: claim.Name,
+				Pods: users,
+			}
+		}
+
+		return nil
+	}
+
+	if timeout > 0 {
+		err = doRetryWithTimeout(t, timeout, 5*time.Second)
+	} else {
+		err = t()
+	}
+
+	if err != nil {
+		if inUseErr, ok := task.LastError(err).(*ErrPVCInUse); ok {
+			return inUseErr
+		}
+		return err
+	}
+
+	return DeletePersistentVolumeClaim(&claim, opts...)
+}
+
+// GetPodsUsingPVC returns the pods in the namespace of the given claim that have it as a volume. Claims
+// can only be used through persistentVolumeClaim volumes as projected volumes don't support claims.
+func GetPodsUsingPVC(pvc *v1.PersistentVolumeClaim) ([]v1.Pod, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	claim := *pvc
+	claim.Namespace = namespaceOrDefault(pvc.Namespace)
+	return getPodsUsingPVC(client, &claim)
+}
+
+// WaitForPVCUnused waits for no pod to have the given claim as a volume
+func WaitForPVCUnused(pvc *v1.PersistentVolumeClaim, timeout time.Duration) error {
+	t := func() error {
+		pods, err := GetPodsUsingPVC(pvc)
 		if err != nil {
 			return err
 		}
 
-		result, err := client.PersistentVolumeClaims(pvc.Namespace).Get(pvc.Name, meta_v1.GetOptions{})
+		if len(pods) > 0 {
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			return &ErrPVCInUse{
+				Name: pvc.Name,
+				Pods: names,
+			}
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidatePersistentVolumeClaim validates the given pvc. If the storage class of the pvc delays binding
+// until a pod uses it (WaitForFirstConsumer) and no pod uses it yet, the pending pvc is considered valid.
+// Once the pvc is bound, the verifier registered with RegisterVolumeParamsVerifier, if any, checks its
+// volume.
+func ValidatePersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) error {
+	t := func() error {
+		client, err := GetK8sClient()
 		if err != nil {
 			return err
 		}
 
+		namespace := namespaceOrDefault(pvc.Namespace)
+		result, ok := getCachedPVC(namespace, pvc.Name)
+		if !ok {
+			if result, err = client.PersistentVolumeClaims(namespace).Get(pvc.Name, meta_v1.GetOptions{}); err != nil {
+				return err
+			}
+		}
+
 		if result.Status.Phase == v1.ClaimBound {
 			return nil
 		}
 
+		if result.Status.Phase == v1.ClaimPending {
+			waiting, err := isPVCWaitingForFirstConsumer(client, result)
+			if err != nil {
+				return err
+			}
+			if waiting {
+				logrus.Infof("PVC: %v is waiting for its first consumer to be bound", result.Name)
+				return nil
+			}
+		}
+
 		return &ErrPVCNotReady{
 			ID:    result.Name,
 			Cause: fmt.Sprintf("PVC expected status: %v PVC actual status: %v", v1.ClaimBound, result.Status.Phase),
 		}
 	}
 
-	if err := task.DoRetryWithTimeout(t, 5*time.Minute, 10*time.Second); err != nil {
+	profile := validationProfile()
+	t, complete := observeValidation("pvc/"+pvc.Name, t)
+	err := doRetryWithTimeout(t, profile.PVCBindTimeout, profile.RetryInterval)
+	complete(err)
+	if err != nil {
+		return err
+	}
+
+	return runRegisteredParamsVerifier(pvc)
+}
+
+// ValidatePersistentVolumeClaimAfterConsumer validates that the given pvc gets bound once the given pod
+// using it is scheduled, as is the case for storage classes with WaitForFirstConsumer binding
+func ValidatePersistentVolumeClaimAfterConsumer(pvc *v1.PersistentVolumeClaim, pod *v1.Pod) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	t := func() error {
+		consumer, err := client.CoreV1().Pods(pod.Namespace).Get(pod.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if len(consumer.Spec.NodeName) == 0 {
+			return &ErrPVCNotReady{
+				ID:    pvc.Name,
+				Cause: fmt.Sprintf("consumer pod: %v is not scheduled yet", pod.Name),
+			}
+		}
+
+		result, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if result.Status.Phase != v1.ClaimBound {
+			return &ErrPVCNotReady{
+				ID: result.Name,
+				Cause: fmt.Sprintf("PVC expected status: %v PVC actual status: %v. Consumer pod: %v is on node: %v",
+					v1.ClaimBound, result.Status.Phase, pod.Name, consumer.Spec.NodeName),
+			}
+		}
+
+		return nil
+	}
+
+	profile := validationProfile()
+	if err := doRetryWithTimeout(t, profile.PVCBindTimeout, profile.RetryInterval); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// GetVolumeForPersistentVolumeClaim returns the back volume for the given PVC
+// GetVolumeForPersistentVolumeClaim returns the back volume for the given PVC. ErrPVCNotBound is returned if
+// the PVC is not bound yet.
 func GetVolumeForPersistentVolumeClaim(pvc *v1.PersistentVolumeClaim) (string, error) {
 	client, err := GetK8sClient()
 	if err != nil {
 		return "", err
 	}
 
-	result, err := client.PersistentVolumeClaims(pvc.Namespace).Get(pvc.Name, meta_v1.GetOptions{})
+	result, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
 	if err != nil {
 		return "", err
 	}
 
+	if len(result.Spec.VolumeName) == 0 {
+		return "", &ErrPVCNotBound{
+			ID:    result.Name,
+			Cause: fmt.Sprintf("PVC is in phase: %v", result.Status.Phase),
+		}
+	}
+
 	return result.Spec.VolumeName, nil
 }
 
-// GetPersistentVolumeClaimParams fetches custom parameters for the given PVC
+// GetVolumeForPVCWithTimeout waits for the given PVC to be bound and returns its backing volume
+func GetVolumeForPVCWithTimeout(pvc *v1.PersistentVolumeClaim, timeout time.Duration) (string, error) {
+	var volumeName string
+	t := func() error {
+		var err error
+		volumeName, err = GetVolumeForPersistentVolumeClaim(pvc)
+		return err
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return "", err
+	}
+
+	return volumeName, nil
+}
+
+// GetPersistentVolumeClaimParams fetches custom parameters for the given PVC: its requested size and the
+// parameters of its storage class merged with its annotations (see mergeVolumeParams)
 func GetPersistentVolumeClaimParams(pvc *v1.PersistentVolumeClaim) (map[string]string, error) {
 	client, err := GetK8sClient()
 	if err != nil {
@@ -393,7 +1354,7 @@ func GetPersistentVolumeClaimParams(pvc *v1.PersistentVolumeClaim) (map[string]s
 
 	params := make(map[string]string)
 
-	result, err := client.PersistentVolumeClaims(pvc.Namespace).Get(pvc.Name, meta_v1.GetOptions{})
+	result, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
 	if err != nil {
 		return nil, err
 	}
@@ -407,23 +1368,172 @@ func GetPersistentVolumeClaimParams(pvc *v1.PersistentVolumeClaim) (map[string]s
 	requestSizeInBytes := uint64(requestGB * 1024 * 1024 * 1024)
 	params["size"] = fmt.Sprintf("%d", requestSizeInBytes)
 
-	scName, ok := result.Annotations[k8sPVCStorageClassKey]
+	scName, ok := getPVCStorageClassName(result)
 	if !ok {
 		return nil, fmt.Errorf("failed to get storage class for pvc: %v", result.Name)
 	}
 
-	sc, err := client.StorageV1beta1().StorageClasses().Get(scName, meta_v1.GetOptions{})
+	scParams, err := getStorageClassParams(client, scName)
 	if err != nil {
 		return nil, err
 	}
 
-	for key, value := range sc.Parameters {
+	for key, value := range mergeVolumeParams(scParams, getPVCAnnotationParams(result)) {
 		params[key] = value
 	}
 
+	if err := resolveSecretParams(result, params); err != nil {
+		return nil, err
+	}
+
 	return params, nil
 }
 
+// SetPVCAnnotations sets the given annotations on the PVC, overwriting existing values for the same keys
+func SetPVCAnnotations(pvc *v1.PersistentVolumeClaim, annotations map[string]string) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var current *v1.PersistentVolumeClaim
+		current, err = client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if current.Annotations == nil {
+			current.Annotations = make(map[string]string)
+		}
+		for key, value := range annotations {
+			current.Annotations[key] = value
+		}
+
+		if _, err = client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Update(current); err == nil ||
+			!k8s_errors.IsConflict(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// GetPVCAnnotations returns the annotations of the given PVC
+func GetPVCAnnotations(pvc *v1.PersistentVolumeClaim) (map[string]string, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Annotations, nil
+}
+
+// GetPVCsForStatefulSet returns the per-replica PVCs of the given statefulset for its current replica count.
+// The claim names are derived from the volume claim templates as <template>-<statefulset>-<ordinal>
+func GetPVCsForStatefulSet(ss *v1beta1.StatefulSet) ([]v1.PersistentVolumeClaim, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	liveSS, err := client.AppsV1beta1().StatefulSets(ss.Namespace).Get(ss.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1.PersistentVolumeClaim
+	for _, pvcName := range statefulSetPVCNames(liveSS, 0, *liveSS.Spec.Replicas) {
+		pvc, err := client.PersistentVolumeClaims(liveSS.Namespace).Get(pvcName, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *pvc)
+	}
+
+	return result, nil
+}
+
+// ValidateStatefulSetPVCs waits for all per-replica PVCs of the given statefulset to be bound. The expected
+// claims are recomputed from the live statefulset on every retry so a scale up during validation is handled.
+func ValidateStatefulSetPVCs(ss *v1beta1.StatefulSet) error {
+	t := func() error {
+		pvcs, err := GetPVCsForStatefulSet(ss)
+		if err != nil {
+			return err
+		}
+
+		for _, pvc := range pvcs {
+			if pvc.Status.Phase != v1.ClaimBound {
+				return &ErrPVCNotReady{
+					ID:    pvc.Name,
+					Cause: fmt.Sprintf("PVC expected status: %v PVC actual status: %v", v1.ClaimBound, pvc.Status.Phase),
+				}
+			}
+		}
+
+		return nil
+	}
+
+	profile := validationProfile()
+	if err := doRetryWithTimeout(t, profile.PVCBindTimeout, profile.RetryInterval); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ValidateOrphanPVCsAfterScaleDown validates the PVCs for the ordinals removed when the given statefulset
+// was scaled down from previousReplicas. k8s retains such claims, so by default they are expected to still
+// exist. If expectDeleted is true (the test deleted them explicitly) it waits for them to be gone instead.
+func ValidateOrphanPVCsAfterScaleDown(ss *v1beta1.StatefulSet, previousReplicas int32, expectDeleted bool) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	liveSS, err := client.AppsV1beta1().StatefulSets(ss.Namespace).Get(ss.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	orphanNames := statefulSetPVCNames(liveSS, *liveSS.Spec.Replicas, previousReplicas)
+	if !expectDeleted {
+		for _, pvcName := range orphanNames {
+			if _, err := client.PersistentVolumeClaims(liveSS.Namespace).Get(pvcName, meta_v1.GetOptions{}); err != nil {
+				return fmt.Errorf("PVC: %v of scaled down statefulset: %v is expected to be retained. Err: %v",
+					pvcName, liveSS.Name, err)
+			}
+		}
+		return nil
+	}
+
+	t := func() error {
+		for _, pvcName := range orphanNames {
+			_, err := client.PersistentVolumeClaims(liveSS.Namespace).Get(pvcName, meta_v1.GetOptions{})
+			if err == nil {
+				return fmt.Errorf("PVC: %v of scaled down statefulset: %v is still present", pvcName, liveSS.Name)
+			}
+			if !k8s_errors.IsNotFound(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	profile := validationProfile()
+	if err := doRetryWithTimeout(t, profile.scaledDeleteTimeout(5*time.Minute), profile.RetryInterval); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // IsNodeMaster returns true if given node is a kubernetes master node
 func IsNodeMaster(node v1.Node) bool {
 	_, ok := node.Labels[k8sMasterLabelKey]
@@ -432,6 +1542,10 @@ func IsNodeMaster(node v1.Node) bool {
 
 // AddLabelOnNode adds a label key=value on the given node
 func AddLabelOnNode(name, key, value string) error {
+	if err := CheckDestructiveOp("AddLabelOnNode"); err != nil {
+		return err
+	}
+
 	var err error
 	client, err := GetK8sClient()
 	if err != nil {
@@ -462,6 +1576,10 @@ func AddLabelOnNode(name, key, value string) error {
 
 // RemoveLabelOnNode removes the label with key on given node
 func RemoveLabelOnNode(name, key string) error {
+	if err := CheckDestructiveOp("RemoveLabelOnNode"); err != nil {
+		return err
+	}
+
 	var err error
 	client, err := GetK8sClient()
 	if err != nil {
@@ -490,7 +1608,7 @@ func RemoveLabelOnNode(name, key string) error {
 
 // loadClientFromServiceAccount loads a k8s client from a ServiceAccount specified in the pod running px
 func loadClientFromServiceAccount() (*kubernetes.Clientset, error) {
-	config, err := rest.InClusterConfig()
+	config, err := GetRestConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -502,6 +1620,437 @@ func loadClientFromServiceAccount() (*kubernetes.Clientset, error) {
 	return k8sClient, nil
 }
 
+// checkDeploymentReady checks once if the given deployment is running and healthy and fills in the
+// replica counts and pod nodes of the report. If the options list the containers that must be ready, the
+// ready replica counts of the deployment are not checked since they account for all containers.
+func checkDeploymentReady(
+	deployment *v1beta1.Deployment,
+	report *DeploymentStatusReport,
+	options *validateOptions,
+) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(deployment.Namespace)
+	dep, ok := getCachedDeployment(namespace, deployment.Name)
+	if !ok {
+		if dep, err = client.AppsV1beta1().Deployments(namespace).Get(deployment.Name, meta_v1.GetOptions{}); err != nil {
+			return err
+		}
+	}
+
+	if len(options.containers) == 0 && *dep.Spec.Replicas != dep.Status.AvailableReplicas {
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("Expected replicas: %v Available replicas: %v", *dep.Spec.Replicas, dep.Status.AvailableReplicas),
+		}
+	}
+
+	if len(options.containers) == 0 && *dep.Spec.Replicas != dep.Status.ReadyReplicas {
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("Expected replicas: %v Ready replicas: %v", *dep.Spec.Replicas, dep.Status.ReadyReplicas),
+		}
+	}
+
+	replicaSets, err := listAllReplicaSets(client, namespace, meta_v1.ListOptions{})
+	if err != nil {
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("Failed to list replica sets for deployment. Err: %v", err),
+		}
+	}
+
+	// The fetched deployment has the UID of the current instance, so that pods of a deleted deployment
+	// with the same name are not counted
+	allPods, previous, err := getDeploymentInstancePods(client, dep, replicaSets)
+	if err != nil || allPods == nil {
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("Failed to get pods for deployment. Err: %v", err),
+		}
+	}
+
+	var pods []v1.Pod
+	for _, pod := range allPods {
+		if pod.DeletionTimestamp == nil {
+			pods = append(pods, pod)
+		}
+	}
+
+	if len(previous) > 0 {
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("waiting for previous instance cleanup: %d pods", len(previous)),
+		}
+	}
+
+	// The ready replicas of the deployment count the pods of all revisions, so a rollout is only complete
+	// once the pods of the older revisions are gone
+	currentRS, err := getCurrentReplicaSet(dep, indexReplicaSetsByRevision(filterOwnedReplicaSets(replicaSets, dep)))
+	if err != nil {
+		return err
+	}
+
+	hash, err := getTemplateHash(dep, currentRS)
+	if err != nil {
+		return err
+	}
+
+	pods, oldPods := splitPodsByTemplateHash(pods, hash)
+	if len(oldPods) > 0 {
+		var names []string
+		for _, pod := range oldPods {
+			names = append(names, pod.Name)
+		}
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("waiting for rollout to replica set: %v. Pods of older revisions: %v", currentRS.Name, names),
+		}
+	}
+
+	if len(options.containers) > 0 && int32(len(pods)) != *dep.Spec.Replicas {
+		return &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: fmt.Sprintf("Expected replicas: %v Pods: %v", *dep.Spec.Replicas, len(pods)),
+		}
+	}
+
+	for _, pod := range pods {
+		ready := IsPodRunning(pod)
+		if len(options.containers) > 0 {
+			if ready, err = IsPodContainersReady(pod, options.containers); err != nil {
+				return err
+			}
+		}
+
+		if !ready {
+			return &ErrAppNotReady{
+				ID:    dep.Name,
+				Cause: fmt.Sprintf("pod: %v is not yet ready", pod.Name),
+			}
+		}
+	}
+
+	report.ReadyReplicas = dep.Status.ReadyReplicas
+	report.AvailableReplicas = dep.Status.AvailableReplicas
+	for _, pod := range pods {
+		report.PodNodes[pod.Name] = pod.Spec.NodeName
+	}
+	return nil
+}
+
+// GetRestConfig returns the config used to talk to the k8s api server from the ServiceAccount of the pod,
+// e.g to build clients for extension APIs. Each call returns a new copy of the cached in-cluster config
+// with the failover, mutation recording, in-flight limit and slow request logging transports applied.
+func GetRestConfig() (*rest.Config, error) {
+	restConfigLock.Lock()
+	defer restConfigLock.Unlock()
+
+	if baseRestConfig == nil {
+		config, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, err
+		}
+		baseRestConfig = config
+	}
+
+	config := *baseRestConfig
+	addTransportWrapper(&config, newFailoverRoundTripper)
+	addTransportWrapper(&config, newMutationRecordingRoundTripper)
+	addTransportWrapper(&config, newInflightRoundTripper)
+	configureSlowRequestLogging(&config)
+	return &config, nil
+}
+
+// SetRestConfig sets the config used to talk to the k8s api server instead of the in-cluster config of the
+// pod's ServiceAccount, e.g to run against a kubeconfig or a test server. A nil config goes back to the
+// in-cluster config. The k8s version detected from the previous api server is forgotten.
+func SetRestConfig(config *rest.Config) {
+	restConfigLock.Lock()
+	if config != nil {
+		copied := *config
+		config = &copied
+	}
+	baseRestConfig = config
+	restConfigLock.Unlock()
+
+	k8sVersionLock.Lock()
+	k8sVersionCached = nil
+	replicaSetGroupVersionCached = nil
+	k8sVersionLock.Unlock()
+}
+
+// addTransportWrapper wraps the transport of the given config, on top of the wrappers already added
+func addTransportWrapper(config *rest.Config, wrap func(rt http.RoundTripper) http.RoundTripper) {
+	previous := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if previous != nil {
+			rt = previous(rt)
+		}
+		return wrap(rt)
+	}
+}
+
+// getDeploymentPVCNames returns the names of the PVCs referenced by the pod template of the given deployment
+func getDeploymentPVCNames(deployment *v1beta1.Deployment) []string {
+	var names []string
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			names = append(names, volume.PersistentVolumeClaim.ClaimName)
+		}
+	}
+	return names
+}
+
+// checkDeploymentTerminated checks once if the given deployment and its pods and replica sets are gone
+func checkDeploymentTerminated(deployment *v1beta1.Deployment) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(deployment.Namespace)
+	dep, err := client.AppsV1beta1().Deployments(namespace).Get(deployment.Name, meta_v1.GetOptions{})
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return err
+	}
+
+	if err == nil {
+		// If a deployment with the same name was recreated, only the pods of the given one are checked
+		// as GetDeploymentPods matches by UID
+		pods, err := GetDeploymentPods(deployment)
+		if err != nil {
+			return &ErrAppNotTerminated{
+				ID:    dep.Name,
+				Cause: fmt.Sprintf("Failed to get pods for deployment. Err: %v", err),
+			}
+		}
+
+		if pods != nil && len(pods) > 0 {
+			return &ErrAppNotTerminated{
+				ID:    dep.Name,
+				Cause: fmt.Sprintf("pods: %#v is still present", pods),
+			}
+		}
+
+		if len(deployment.UID) == 0 || dep.UID == deployment.UID {
+			return nil
+		}
+	}
+
+	// With foreground deletion, the replica sets of the deployment can outlive it
+	replicaSets, err := getOwnedReplicaSets(client, namespace, deployment)
+	if err != nil {
+		return &ErrAppNotTerminated{
+			ID:    deployment.Name,
+			Cause: fmt.Sprintf("Failed to list replica sets of deployment. Err: %v", err),
+		}
+	}
+
+	if len(replicaSets) > 0 {
+		var names []string
+		for _, rs := range replicaSets {
+			names = append(names, rs.Name)
+		}
+		return &ErrAppNotTerminated{
+			ID:    deployment.Name,
+			Cause: fmt.Sprintf("replica sets: %v are still present", names),
+		}
+	}
+
+	return nil
+}
+
+// getOwnedReplicaSets returns the replica sets owned by the given deployment. The owner is matched by UID
+// if the deployment has one and by name otherwise.
+func getOwnedReplicaSets(
+	client *kubernetes.Clientset,
+	namespace string,
+	deployment *v1beta1.Deployment,
+) ([]ext_v1beta1.ReplicaSet, error) {
+	replicaSets, err := listAllReplicaSets(client, namespace, meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return filterOwnedReplicaSets(replicaSets, deployment), nil
+}
+
+// filterOwnedReplicaSets returns the given replica sets owned by the deployment, matched like
+// getOwnedReplicaSets does
+func filterOwnedReplicaSets(
+	replicaSets []ext_v1beta1.ReplicaSet,
+	deployment *v1beta1.Deployment,
+) []ext_v1beta1.ReplicaSet {
+	var owned []ext_v1beta1.ReplicaSet
+	for _, rs := range replicaSets {
+		for _, owner := range rs.OwnerReferences {
+			if owner.Kind != "Deployment" {
+				continue
+			}
+			if (len(deployment.UID) > 0 && owner.UID == deployment.UID) ||
+				(len(deployment.UID) == 0 && owner.Name == deployment.Name) {
+				owned = append(owned, rs)
+				break
+			}
+		}
+	}
+	return owned
+}
+
+// isOwnedByReplicaSets checks if the pod is owned by one of the replica sets with the given UIDs
+func isOwnedByReplicaSets(pod v1.Pod, replicaSetUIDs map[types.UID]bool) bool {
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "ReplicaSet" && replicaSetUIDs[owner.UID] {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnedByDeployment checks if the pod belongs to a ReplicaSet created by the given deployment. The
+// deployment controller names its ReplicaSets <deployment>-<pod-template-hash>. Without the hash label, the
+// suffix of the ReplicaSet name must look like a hash, i.e have no dash, so that the pods of deployment
+// foo-bar are not taken as pods of foo.
+func isOwnedByDeployment(pod v1.Pod, deploymentName string) bool {
+	hash, ok := pod.Labels[k8sPodTemplateHashKey]
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind != "ReplicaSet" {
+			continue
+		}
+
+		if ok && owner.Name == fmt.Sprintf("%v-%v", deploymentName, hash) {
+			return true
+		}
+
+		if !ok && strings.HasPrefix(owner.Name, deploymentName+"-") {
+			suffix := strings.TrimPrefix(owner.Name, deploymentName+"-")
+			if len(suffix) > 0 && !strings.Contains(suffix, "-") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getPVCAnnotationParams returns the Portworx options set as annotations on the PVC with the
+// "px/" prefix stripped so that they are comparable with storage class parameters
+func getPVCAnnotationParams(pvc *v1.PersistentVolumeClaim) map[string]string {
+	params := make(map[string]string)
+	for key, value := range pvc.Annotations {
+		if strings.HasPrefix(key, pxAnnotationPrefix) {
+			params[strings.TrimPrefix(key, pxAnnotationPrefix)] = value
+		}
+	}
+	return params
+}
+
+// getPVCStorageClassName returns the name of the storage class of the pvc from the beta annotation
+// or the spec
+func getPVCStorageClassName(pvc *v1.PersistentVolumeClaim) (string, bool) {
+	if scName, ok := pvc.Annotations[k8sPVCStorageClassKey]; ok {
+		return scName, true
+	}
+	if pvc.Spec.StorageClassName != nil {
+		return *pvc.Spec.StorageClassName, true
+	}
+	return "", false
+}
+
+// isPVCWaitingForFirstConsumer checks if the pvc has a WaitForFirstConsumer storage class and no pod
+// uses it yet
+func isPVCWaitingForFirstConsumer(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim) (bool, error) {
+	scName, ok := getPVCStorageClassName(pvc)
+	if !ok {
+		return false, nil
+	}
+
+	mode, err := getStorageClassBindingMode(client, scName)
+	if err != nil || mode != k8sVolumeBindingWaitForFirstConsumer {
+		return false, err
+	}
+
+	pods, err := getPodsUsingPVC(client, pvc)
+	if err != nil {
+		return false, err
+	}
+
+	return len(pods) == 0, nil
+}
+
+// getPodsUsingPVC returns the pods in the namespace of the pvc that have it as a volume
+func getPodsUsingPVC(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim) ([]v1.Pod, error) {
+	pods, err := listAllPods(client, pvc.Namespace, meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1.Pod
+	for _, pod := range pods {
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				result = append(result, pod)
+				break
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// getStorageClassBindingMode returns the volumeBindingMode of the storage class. The field is read from
+// the raw object as it is newer than the storage API types this package is built with.
+func getStorageClassBindingMode(client *kubernetes.Clientset, name string) (string, error) {
+	restClient := client.StorageV1beta1().RESTClient()
+	if SupportsStorageClassV1() {
+		restClient = client.StorageV1().RESTClient()
+	}
+
+	raw, err := restClient.Get().Resource("storageclasses").Name(name).DoRaw()
+	if err != nil {
+		return "", err
+	}
+
+	var sc struct {
+		VolumeBindingMode string `json:"volumeBindingMode"`
+	}
+	if err := json.Unmarshal(raw, &sc); err != nil {
+		return "", err
+	}
+
+	return sc.VolumeBindingMode, nil
+}
+
+// getStorageClassParams returns the parameters of the given storage class using the GA storage API when served
+func getStorageClassParams(client *kubernetes.Clientset, name string) (map[string]string, error) {
+	if SupportsStorageClassV1() {
+		sc, err := client.StorageV1().StorageClasses().Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return sc.Parameters, nil
+	}
+
+	sc, err := client.StorageV1beta1().StorageClasses().Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return sc.Parameters, nil
+}
+
+// statefulSetPVCNames returns the names of the PVCs of the given statefulset for ordinals in [from, to)
+func statefulSetPVCNames(ss *v1beta1.StatefulSet, from, to int32) []string {
+	var names []string
+	for ordinal := from; ordinal < to; ordinal++ {
+		for _, template := range ss.Spec.VolumeClaimTemplates {
+			names = append(names, fmt.Sprintf("%v-%v-%d", template.Name, ss.Name, ordinal))
+		}
+	}
+	return names
+}
+
 func roundUpSize(volumeSizeBytes int64, allocationUnitBytes int64) int64 {
 	return (volumeSizeBytes + allocationUnitBytes - 1) / allocationUnitBytes
 }
@@ -523,3 +2072,38 @@ func IsPodRunning(pod v1.Pod) bool {
 
 	return true
 }
+
+// waitForPodRunning waits for the given pod to be in the running phase and returns its latest state
+func waitForPodRunning(pod *v1.Pod, timeout time.Duration) (*v1.Pod, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	current := pod
+	t := func() error {
+		p, ok := getCachedPod(namespaceOrDefault(pod.Namespace), pod.Name)
+		if !ok {
+			var err error
+			if p, err = client.CoreV1().Pods(pod.Namespace).Get(pod.Name, meta_v1.GetOptions{}); err != nil {
+				return err
+			}
+		}
+
+		if p.Status.Phase != v1.PodRunning {
+			return &ErrAppNotReady{
+				ID:    p.Name,
+				Cause: fmt.Sprintf("pod is in phase: %v", p.Status.Phase),
+			}
+		}
+
+		current = p
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return nil, err
+	}
+
+	return current, nil
+}
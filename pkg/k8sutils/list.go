@@ -0,0 +1,190 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// defaultListChunkSize is the number of objects requested per page by the list helpers
+	defaultListChunkSize = 500
+	// listMaxRelists is how many times a list is restarted from the first page after its continue token
+	// expired
+	listMaxRelists = 3
+)
+
+var (
+	listChunkLock sync.RWMutex
+	listChunkSize int64 = defaultListChunkSize
+)
+
+// listContinue is the part of a list response with the token of the next page
+type listContinue struct {
+	Metadata struct {
+		Continue string `json:"continue"`
+	} `json:"metadata"`
+}
+
+// SetListChunkSize sets the number of objects requested per page when listing pods and replica sets.
+// Zero disables pagination.
+func SetListChunkSize(size int64) {
+	listChunkLock.Lock()
+	defer listChunkLock.Unlock()
+	listChunkSize = size
+}
+
+func getListChunkSize() int64 {
+	listChunkLock.RLock()
+	defer listChunkLock.RUnlock()
+	return listChunkSize
+}
+
+// listAllPods lists the pods matching the options in the namespace (all namespaces if empty) a page at a
+// time and returns them sorted by namespace and name
+func listAllPods(client *kubernetes.Clientset, namespace string, opts meta_v1.ListOptions) ([]v1.Pod, error) {
+	var pods []v1.Pod
+	err := listAllPages(client.CoreV1().RESTClient(), "pods", namespace, opts, func(data []byte) error {
+		var page v1.PodList
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		pods = append(pods, page.Items...)
+		return nil
+	}, func() {
+		pods = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pods, func(i, j int) bool {
+		return objectLess(pods[i].ObjectMeta, pods[j].ObjectMeta)
+	})
+	return pods, nil
+}
+
+// listAllReplicaSets lists the replica sets matching the options in the namespace (all namespaces if
+// empty) a page at a time and returns them sorted by namespace and name. The replica sets are listed from
+// the group version found by getReplicaSetGroupVersion, as newer clusters don't serve extensions/v1beta1.
+// The fields read by the helpers are the same in all the group versions.
+func listAllReplicaSets(
+	client *kubernetes.Clientset,
+	namespace string,
+	opts meta_v1.ListOptions,
+) ([]ext_v1beta1.ReplicaSet, error) {
+	gv, err := getReplicaSetGroupVersion(client)
+	if err != nil {
+		return nil, err
+	}
+
+	rsClient, err := GetDynamicClient(gv)
+	if err != nil {
+		return nil, err
+	}
+
+	var replicaSets []ext_v1beta1.ReplicaSet
+	err = listAllPages(rsClient, "replicasets", namespace, opts, func(data []byte) error {
+		var page ext_v1beta1.ReplicaSetList
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		replicaSets = append(replicaSets, page.Items...)
+		return nil
+	}, func() {
+		replicaSets = nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(replicaSets, func(i, j int) bool {
+		return objectLess(replicaSets[i].ObjectMeta, replicaSets[j].ObjectMeta)
+	})
+	return replicaSets, nil
+}
+
+// listAllPages requests the pages of the given resource until the continue token is exhausted and passes
+// each of them to add. Servers that don't support pagination return all the objects in the first page. If
+// the continue token expires, reset is called and the list restarts from the first page, as the pages of
+// different snapshots can't be assembled.
+func listAllPages(
+	client rest.Interface,
+	resource string,
+	namespace string,
+	opts meta_v1.ListOptions,
+	add func(data []byte) error,
+	reset func(),
+) error {
+	chunkSize := getListChunkSize()
+
+	var token string
+	relists := 0
+	for {
+		req := client.Get().Namespace(namespace).Resource(resource)
+		if len(opts.LabelSelector) > 0 {
+			req = req.Param("labelSelector", opts.LabelSelector)
+		}
+		if len(opts.FieldSelector) > 0 {
+			req = req.Param("fieldSelector", opts.FieldSelector)
+		}
+		if chunkSize > 0 {
+			req = req.Param("limit", strconv.FormatInt(chunkSize, 10))
+		}
+		if len(token) > 0 {
+			req = req.Param("continue", token)
+		}
+
+		data, err := req.DoRaw()
+		if err != nil && len(token) > 0 && isResourceExpired(err) {
+			if relists >= listMaxRelists {
+				return fmt.Errorf("failed to list %v after %d relists of expired continue tokens. Err: %v",
+					resource, relists, err)
+			}
+			relists++
+			reset()
+			token = ""
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := add(data); err != nil {
+			return err
+		}
+
+		var next listContinue
+		if err := json.Unmarshal(data, &next); err != nil {
+			return err
+		}
+
+		if len(next.Metadata.Continue) == 0 {
+			return nil
+		}
+		token = next.Metadata.Continue
+	}
+}
+
+// isResourceExpired returns true if the error is a 410 Gone returned for an expired continue token
+func isResourceExpired(err error) bool {
+	status, ok := err.(k8s_errors.APIStatus)
+	return ok && (status.Status().Code == http.StatusGone || status.Status().Reason == meta_v1.StatusReasonExpired)
+}
+
+func objectLess(a, b meta_v1.ObjectMeta) bool {
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
@@ -0,0 +1,132 @@
+package k8sutils
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// addTestPods adds the given number of pods of a replica set to the server and returns their sorted names
+func addTestPods(server *k8stest.Server, count int) []string {
+	dep := newTestDeployment("web", "dep-uid", int32(count))
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	server.Add(dep, rs)
+
+	var names []string
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("web-abc-%03d", i)
+		server.Add(newTestPod(rs, name, "node1"))
+		names = append(names, name)
+	}
+	return names
+}
+
+// countPodLists returns the number of pod list requests the server received
+func countPodLists(server *k8stest.Server) int {
+	count := 0
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodGet && req.Resource == "pods" && len(req.Name) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func TestListAllPodsAssemblesPages(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defer SetListChunkSize(getListChunkSize())
+	SetListChunkSize(10)
+
+	expected := addTestPods(server, 25)
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+
+	pods, err := listAllPods(client, testNamespace, meta_v1.ListOptions{LabelSelector: "app=web"})
+	if err != nil {
+		t.Fatalf("failed to list the pods: %v", err)
+	}
+
+	if names := podNames(pods); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected pods: %v, got: %v", expected, names)
+	}
+	if lists := countPodLists(server); lists != 3 {
+		t.Errorf("expected 3 pages of 10 pods, got %d list requests", lists)
+	}
+	for _, req := range server.Requests() {
+		if req.Resource == "pods" && req.Query.Get("limit") != "10" {
+			t.Errorf("expected a limit of 10 in each list request, got: %v", req.Query)
+		}
+	}
+}
+
+func TestListAllPodsRelistsOnExpiredContinueToken(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defer SetListChunkSize(getListChunkSize())
+	SetListChunkSize(10)
+
+	expected := addTestPods(server, 25)
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+
+	// The token of the first continued page expires, the retried list succeeds
+	expire := 1
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "pods" || len(req.Query.Get("continue")) == 0 || expire == 0 {
+			return false, 0, nil
+		}
+		expire--
+		return true, http.StatusGone, k8stest.Status(http.StatusGone, "Expired", "continue token expired")
+	})
+
+	pods, err := listAllPods(client, testNamespace, meta_v1.ListOptions{})
+	if err != nil {
+		t.Fatalf("failed to list the pods: %v", err)
+	}
+
+	if names := podNames(pods); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected each pod once after the relist: %v, got: %v", expected, names)
+	}
+	if lists := countPodLists(server); lists != 5 {
+		t.Errorf("expected the expired page and a full relist of 3 pages, got %d list requests", lists)
+	}
+}
+
+func TestListAllPodsGivesUpOnExpiringContinueTokens(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defer SetListChunkSize(getListChunkSize())
+	SetListChunkSize(10)
+
+	addTestPods(server, 25)
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "pods" || len(req.Query.Get("continue")) == 0 {
+			return false, 0, nil
+		}
+		return true, http.StatusGone, k8stest.Status(http.StatusGone, "Expired", "continue token expired")
+	})
+
+	if _, err := listAllPods(client, testNamespace, meta_v1.ListOptions{}); err == nil {
+		t.Fatalf("expected the list to fail when the continue tokens keep expiring")
+	}
+	if lists := countPodLists(server); lists != 2*(listMaxRelists+1) {
+		t.Errorf("expected %d list requests, got %d", 2*(listMaxRelists+1), lists)
+	}
+}
@@ -0,0 +1,210 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	k8sHostnameLabelKey = "kubernetes.io/hostname"
+	// localVolumeNodeLabelKey is the label of local persistent volumes with the name of their node
+	localVolumeNodeLabelKey = "torpedo/local-volume-node"
+)
+
+// CreateLocalPersistentVolume creates a persistent volume backed by the given path on the given node. The
+// volume has node affinity to the node and the Retain reclaim policy, so it has to be deleted with
+// DeleteLocalPersistentVolume once its claim is deleted.
+func CreateLocalPersistentVolume(
+	nodeName string,
+	path string,
+	capacity resource.Quantity,
+	scName string,
+) (*v1.PersistentVolume, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := client.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, ok := node.Labels[k8sHostnameLabelKey]
+	if !ok {
+		hostname = node.Name
+	}
+
+	affinity, err := json.Marshal(&v1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{
+							Key:      k8sHostnameLabelKey,
+							Operator: v1.NodeSelectorOpIn,
+							Values:   []string{hostname},
+						},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: "torpedo-local-",
+			Labels: map[string]string{
+				localVolumeNodeLabelKey: nodeName,
+			},
+			Annotations: map[string]string{
+				v1.AlphaStorageNodeAffinityAnnotation: string(affinity),
+			},
+		},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{
+				v1.ResourceStorage: capacity,
+			},
+			AccessModes:                   []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+			StorageClassName:              scName,
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				Local: &v1.LocalVolumeSource{
+					Path: path,
+				},
+			},
+		},
+	}
+	stampInstanceLabel(&pv.ObjectMeta)
+
+	return client.CoreV1().PersistentVolumes().Create(pv)
+}
+
+// CreatePreBoundPVC creates a claim with the given name that is bound to the given persistent volume
+func CreatePreBoundPVC(
+	pv *v1.PersistentVolume,
+	namespace string,
+	name string,
+	opts ...CreateOption,
+) (*v1.PersistentVolumeClaim, error) {
+	scName := pv.Spec.StorageClassName
+	return CreatePersistentVolumeClaim(&v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: pv.Spec.AccessModes,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: pv.Spec.Capacity[v1.ResourceStorage],
+				},
+			},
+			StorageClassName: &scName,
+			VolumeName:       pv.Name,
+		},
+	}, opts...)
+}
+
+// ValidateLocalVolumeBinding validates that the given claim is bound to the given local persistent volume
+// and that the given pod using the claim is running on the node of the volume
+func ValidateLocalVolumeBinding(
+	pvc *v1.PersistentVolumeClaim,
+	pv *v1.PersistentVolume,
+	pod *v1.Pod,
+	timeout time.Duration,
+) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	t := func() error {
+		claim, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if claim.Status.Phase != v1.ClaimBound || claim.Spec.VolumeName != pv.Name {
+			return &ErrPVCNotReady{
+				ID: claim.Name,
+				Cause: fmt.Sprintf("PVC is in phase: %v with volume: %v. Expected volume: %v",
+					claim.Status.Phase, claim.Spec.VolumeName, pv.Name),
+			}
+		}
+
+		p, err := client.CoreV1().Pods(namespaceOrDefault(pod.Namespace)).Get(pod.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if p.Status.Phase != v1.PodRunning {
+			return &ErrAppNotReady{
+				ID:    p.Name,
+				Cause: fmt.Sprintf("pod is in phase: %v", p.Status.Phase),
+			}
+		}
+
+		if node := pv.Labels[localVolumeNodeLabelKey]; p.Spec.NodeName != node {
+			return fmt.Errorf("pod: %v is running on node: %v instead of the node of local volume: %v: %v",
+				p.Name, p.Spec.NodeName, pv.Name, node)
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// DeleteLocalPersistentVolume deletes the given claim, waits for it to be gone and then deletes the local
+// persistent volume it was bound to, which is retained by kubernetes
+func DeleteLocalPersistentVolume(pvc *v1.PersistentVolumeClaim, pv *v1.PersistentVolume) error {
+	if err := CheckDestructiveOp("DeleteLocalPersistentVolume"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(pvc.Namespace)
+	if err := client.PersistentVolumeClaims(namespace).Delete(pvc.Name, &meta_v1.DeleteOptions{}); err != nil &&
+		!k8s_errors.IsNotFound(err) {
+		return err
+	}
+
+	t := func() error {
+		if _, err := client.PersistentVolumeClaims(namespace).Get(pvc.Name, meta_v1.GetOptions{}); err != nil {
+			if k8s_errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		return fmt.Errorf("PVC: %v is not deleted yet", pvc.Name)
+	}
+
+	if err := doRetryWithTimeout(t, validationProfile().scaledDeleteTimeout(2*time.Minute), 5*time.Second); err != nil {
+		return err
+	}
+
+	if err := client.CoreV1().PersistentVolumes().Delete(pv.Name, &meta_v1.DeleteOptions{}); err != nil &&
+		!k8s_errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package k8sutils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// envFailureLogsDir sets the directory of the logs collected on validation failures
+	envFailureLogsDir = "TORPEDO_FAILURE_LOGS_DIR"
+	// portworxPodSelector selects the Portworx pods in the kube-system namespace
+	portworxPodSelector = "name=portworx"
+)
+
+var (
+	failureLogsLock sync.Mutex
+	failureLogsDir  string
+)
+
+// SetFailureLogsDir sets the directory where a failed deployment or statefulset validation writes the tail
+// of the logs of its pods and of the Portworx pods, in a subdirectory named after the validation and the
+// time of the failure. It defaults to the TORPEDO_FAILURE_LOGS_DIR environment variable. Empty disables
+// the collection.
+func SetFailureLogsDir(dir string) {
+	failureLogsLock.Lock()
+	defer failureLogsLock.Unlock()
+	failureLogsDir = dir
+}
+
+func getFailureLogsDir() string {
+	failureLogsLock.Lock()
+	defer failureLogsLock.Unlock()
+	if len(failureLogsDir) > 0 {
+		return failureLogsDir
+	}
+	return os.Getenv(envFailureLogsDir)
+}
+
+// WithLogContainer selects the container of the pod to get the logs of. Defaults to the first container.
+func WithLogContainer(container string) PodLogOption {
+	return func(o *v1.PodLogOptions) {
+		o.Container = container
+	}
+}
+
+// WithLogsSince only returns the logs written in the given duration before now
+func WithLogsSince(since time.Duration) PodLogOption {
+	return func(o *v1.PodLogOptions) {
+		seconds := int64(since.Seconds())
+		if seconds < 1 {
+			seconds = 1
+		}
+		o.SinceSeconds = &seconds
+	}
+}
+
+// WithTailLines only returns the given number of most recent log lines
+func WithTailLines(lines int64) PodLogOption {
+	return func(o *v1.PodLogOptions) {
+		o.TailLines = &lines
+	}
+}
+
+// WithFollow keeps streaming the logs as they are written until the container exits or the stream is
+// closed. With GetPodLogs, the call only returns once the container exits.
+func WithFollow() PodLogOption {
+	return func(o *v1.PodLogOptions) {
+		o.Follow = true
+	}
+}
+
+// WithPreviousContainer returns the logs of the previous instance of the container, e.g the one that
+// crashed before a restart
+func WithPreviousContainer() PodLogOption {
+	return func(o *v1.PodLogOptions) {
+		o.Previous = true
+	}
+}
+
+// GetPodLogs returns the logs of a container of the given pod
+func GetPodLogs(pod *v1.Pod, opts ...PodLogOption) (string, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return "", err
+	}
+
+	logOptions, err := buildPodLogOptions(pod, opts)
+	if err != nil {
+		return "", err
+	}
+
+	logs, err := client.CoreV1().Pods(namespaceOrDefault(pod.Namespace)).GetLogs(pod.Name, logOptions).Do().Raw()
+	if err != nil {
+		return "", err
+	}
+
+	return string(logs), nil
+}
+
+// StreamPodLogs returns a stream of the logs of a container of the given pod. The caller has to close the
+// stream.
+func StreamPodLogs(pod *v1.Pod, opts ...PodLogOption) (io.ReadCloser, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	logOptions, err := buildPodLogOptions(pod, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().Pods(namespaceOrDefault(pod.Namespace)).GetLogs(pod.Name, logOptions).Stream()
+}
+
+// collectFailureLogs writes the logs of the given app pods and of the Portworx pods for the failed
+// validation and returns the directory they were written to, or an empty string if the collection is
+// disabled. Errors are only logged so that they don't hide the validation error.
+func collectFailureLogs(validation string, pods []v1.Pod) string {
+	dir := getFailureLogsDir()
+	if len(dir) == 0 {
+		return ""
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		logrus.Warnf("Failed to collect the logs of validation: %v. Err: %v", validation, err)
+		return ""
+	}
+
+	outDir := filepath.Join(dir, fmt.Sprintf("%v-%v", strings.Replace(validation, "/", "-", -1),
+		time.Now().Format("20060102-150405")))
+	bundle := &supportBundle{outDir: outDir}
+	for _, pod := range pods {
+		bundle.collectPodLogs(client, pod)
+	}
+
+	pxPods, err := client.CoreV1().Pods(kubeSystemNamespace).List(meta_v1.ListOptions{
+		LabelSelector: portworxPodSelector,
+	})
+	if err != nil {
+		bundle.recordError(fmt.Errorf("failed to list Portworx pods. Err: %v", err))
+	} else {
+		for _, pod := range pxPods.Items {
+			bundle.collectPodLogs(client, pod)
+		}
+	}
+
+	if err := bundle.writeErrors(); err != nil {
+		logrus.Warnf("Failed to collect all the logs of validation: %v. Err: %v", validation, err)
+	}
+	return outDir
+}
+
+func buildPodLogOptions(pod *v1.Pod, opts []PodLogOption) (*v1.PodLogOptions, error) {
+	logOptions := &v1.PodLogOptions{}
+	for _, opt := range opts {
+		opt(logOptions)
+	}
+
+	if len(logOptions.Container) == 0 {
+		if len(pod.Spec.Containers) == 0 {
+			return nil, fmt.Errorf("pod: %v/%v has no containers", pod.Namespace, pod.Name)
+		}
+		logOptions.Container = pod.Spec.Containers[0].Name
+	}
+
+	return logOptions, nil
+}
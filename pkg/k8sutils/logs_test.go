@@ -0,0 +1,133 @@
+package k8sutils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestGetPodLogs(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	pod := newTestPod(newTestReplicaSet(dep, "abc", "rs-uid", 1), "web-abc-1", "node1")
+	server.Add(pod)
+	server.SetPodLogs(testNamespace, pod.Name, "app", "started\n")
+
+	logs, err := GetPodLogs(pod, WithTailLines(10), WithLogsSince(time.Minute), WithPreviousContainer())
+	if err != nil {
+		t.Fatalf("failed to get the pod logs: %v", err)
+	}
+	if logs != "started\n" {
+		t.Errorf("expected the logs of the first container, got: %q", logs)
+	}
+
+	requests := server.Requests()
+	query := requests[len(requests)-1].Query
+	for key, expected := range map[string]string{
+		"container":    "app",
+		"tailLines":    "10",
+		"sinceSeconds": "60",
+		"previous":     "true",
+	} {
+		if got := query.Get(key); got != expected {
+			t.Errorf("expected %v: %v, got: %v", key, expected, got)
+		}
+	}
+
+	if _, err = GetPodLogs(&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "empty"}}); err == nil {
+		t.Errorf("expected an error for a pod without containers")
+	}
+}
+
+func TestStreamPodLogsFollowDoesNotHoldInflightSlots(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+	defer setTestMaxInflightRequests(1)()
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	pod := newTestPod(newTestReplicaSet(dep, "abc", "rs-uid", 1), "web-abc-1", "node1")
+	server.Add(pod)
+	server.SetPodLogs(testNamespace, pod.Name, "app", "started\n")
+
+	for i := 0; i < 3; i++ {
+		stream, err := StreamPodLogs(pod, WithFollow())
+		if err != nil {
+			t.Fatalf("failed to stream the pod logs: %v", err)
+		}
+		defer stream.Close()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := GetPodLogs(pod)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("failed to get the pod logs: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("followed log streams hold the in-flight slots")
+	}
+}
+
+func TestValidateDeploymentCollectsFailureLogs(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "failure-logs")
+	if err != nil {
+		t.Fatalf("failed to create the logs dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	SetFailureLogsDir(dir)
+	defer SetFailureLogsDir("")
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	dep.Status.AvailableReplicas = 0
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	pod := newTestPod(rs, "web-abc-1", "node1")
+	pxPod := &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "portworx-1",
+			Namespace: kubeSystemNamespace,
+			Labels:    map[string]string{"name": "portworx"},
+		},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "portworx"}}},
+	}
+	server.Add(dep, rs, pod, pxPod)
+	server.SetPodLogs(testNamespace, pod.Name, "app", "app failed\n")
+	server.SetPodLogs(kubeSystemNamespace, pxPod.Name, "portworx", "px failed\n")
+
+	err = ValidateDeployement(dep)
+	if !IsAppNotReady(err) || !strings.Contains(err.Error(), "Logs: "+dir) {
+		t.Fatalf("expected the logs dir in the validation error, got: %v", err)
+	}
+
+	collected, err := filepath.Glob(filepath.Join(dir, "deployment-web-*"))
+	if err != nil || len(collected) != 1 {
+		t.Fatalf("expected one collection for the validation, got: %v (err: %v)", collected, err)
+	}
+
+	for file, expected := range map[string]string{
+		filepath.Join(testNamespace, "web-abc-1-app.log"):             "app failed\n",
+		filepath.Join(kubeSystemNamespace, "portworx-1-portworx.log"): "px failed\n",
+	} {
+		data, err := ioutil.ReadFile(filepath.Join(collected[0], "logs", file))
+		if err != nil {
+			t.Errorf("failed to read the collected logs: %v", err)
+		} else if string(data) != expected {
+			t.Errorf("%v: expected %q, got %q", file, expected, string(data))
+		}
+	}
+}
@@ -0,0 +1,298 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	migrateStageScaleDown = "scale down"
+	migrateStageCopy      = "copy data"
+	migrateStageSwap      = "swap volume"
+	migrateStageScaleUp   = "scale up"
+	migrateStageValidate  = "validate"
+
+	migrateCopyPodImage = "busybox"
+	migrateSrcPath      = "/src"
+	migrateDstPath      = "/dst"
+
+	// migrateReplicasAnnotation records the replica count of a deployment while it is scaled down for a migration
+	migrateReplicasAnnotation = "torpedo/migrate-replicas"
+)
+
+// MigrateDeploymentPVC moves the given deployment from oldPVC to newPVC: the deployment is scaled down, the
+// data is copied by a pod mounting both claims, the deployment's volume is switched to newPVC and the
+// deployment is scaled back up and validated. If the deployment already uses newPVC, the copy is skipped
+// so a failed migration can be retried. If the copy fails, the deployment is scaled back up with oldPVC.
+func MigrateDeploymentPVC(
+	deployment *v1beta1.Deployment,
+	oldPVC *v1.PersistentVolumeClaim,
+	newPVC *v1.PersistentVolumeClaim,
+	copyTimeout time.Duration,
+) error {
+	if err := checkAppsV1beta1("MigrateDeploymentPVC"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	dep, err := client.AppsV1beta1().Deployments(namespaceOrDefault(deployment.Namespace)).Get(deployment.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	// Retry of a migration that failed after scale down
+	if replicas == 0 {
+		if original, ok := dep.Annotations[migrateReplicasAnnotation]; ok {
+			if _, err := fmt.Sscanf(original, "%d", &replicas); err != nil {
+				return fmt.Errorf("invalid %v annotation: %v of deployment: %v. Err: %v",
+					migrateReplicasAnnotation, original, dep.Name, err)
+			}
+		}
+	}
+
+	if !deploymentUsesPVC(dep, newPVC.Name) {
+		if !deploymentUsesPVC(dep, oldPVC.Name) {
+			return &ErrFailedToMigratePVC{
+				Deployment: dep.Name,
+				Stage:      migrateStageSwap,
+				Cause:      fmt.Sprintf("deployment does not use PVC: %v", oldPVC.Name),
+			}
+		}
+
+		if err := scaleDeploymentAndWait(client, dep, 0, replicas); err != nil {
+			return &ErrFailedToMigratePVC{
+				Deployment: dep.Name,
+				Stage:      migrateStageScaleDown,
+				Cause:      err.Error(),
+			}
+		}
+
+		if err := copyPVCData(client, oldPVC, newPVC, copyTimeout); err != nil {
+			migrateErr := &ErrFailedToMigratePVC{
+				Deployment: dep.Name,
+				Stage:      migrateStageCopy,
+				Cause:      err.Error(),
+			}
+			if scaleErr := scaleDeploymentAndWait(client, dep, replicas, replicas); scaleErr != nil {
+				migrateErr.Cause = fmt.Sprintf("%v. Failed to scale deployment back up: %v", err, scaleErr)
+			}
+			return migrateErr
+		}
+
+		if err := updateDeploymentWithRetries(client, dep, func(d *v1beta1.Deployment) {
+			for i, volume := range d.Spec.Template.Spec.Volumes {
+				if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == oldPVC.Name {
+					d.Spec.Template.Spec.Volumes[i].PersistentVolumeClaim.ClaimName = newPVC.Name
+				}
+			}
+		}); err != nil {
+			return &ErrFailedToMigratePVC{
+				Deployment: dep.Name,
+				Stage:      migrateStageSwap,
+				Cause:      err.Error(),
+			}
+		}
+	}
+
+	if err := scaleDeploymentAndWait(client, dep, replicas, replicas); err != nil {
+		return &ErrFailedToMigratePVC{
+			Deployment: dep.Name,
+			Stage:      migrateStageScaleUp,
+			Cause:      err.Error(),
+		}
+	}
+
+	if err := ValidateDeployement(dep); err != nil {
+		return &ErrFailedToMigratePVC{
+			Deployment: dep.Name,
+			Stage:      migrateStageValidate,
+			Cause:      err.Error(),
+		}
+	}
+
+	return nil
+}
+
+// scaleDeploymentAndWait scales the deployment to the given replicas. When scaling to zero, the original
+// replica count is recorded on the deployment and all its pods are waited out.
+func scaleDeploymentAndWait(
+	client *kubernetes.Clientset,
+	dep *v1beta1.Deployment,
+	replicas int32,
+	original int32,
+) error {
+	if err := updateDeploymentWithRetries(client, dep, func(d *v1beta1.Deployment) {
+		d.Spec.Replicas = &replicas
+		if d.Annotations == nil {
+			d.Annotations = make(map[string]string)
+		}
+		if replicas == 0 {
+			d.Annotations[migrateReplicasAnnotation] = fmt.Sprintf("%d", original)
+		} else {
+			delete(d.Annotations, migrateReplicasAnnotation)
+		}
+	}); err != nil {
+		return err
+	}
+
+	if replicas > 0 {
+		return nil
+	}
+
+	t := func() error {
+		pods, err := GetDeploymentPods(dep)
+		if err != nil {
+			return err
+		}
+		if len(pods) > 0 {
+			return &ErrAppNotTerminated{
+				ID:    dep.Name,
+				Cause: fmt.Sprintf("%d pods are still present", len(pods)),
+			}
+		}
+		return nil
+	}
+
+	return doRetryWithTimeout(t, validationProfile().scaledDeleteTimeout(5*time.Minute), 5*time.Second)
+}
+
+func updateDeploymentWithRetries(
+	client *kubernetes.Clientset,
+	dep *v1beta1.Deployment,
+	mutate func(*v1beta1.Deployment),
+) error {
+	var err error
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var current *v1beta1.Deployment
+		current, err = client.AppsV1beta1().Deployments(dep.Namespace).Get(dep.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		mutate(current)
+		if _, err = client.AppsV1beta1().Deployments(dep.Namespace).Update(current); err == nil ||
+			!k8s_errors.IsConflict(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// copyPVCData runs a pod that copies the content of src to dst and waits for it to complete
+func copyPVCData(
+	client *kubernetes.Clientset,
+	src *v1.PersistentVolumeClaim,
+	dst *v1.PersistentVolumeClaim,
+	timeout time.Duration,
+) error {
+	pod, err := client.CoreV1().Pods(src.Namespace).Create(buildCopyPod(src, dst))
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		var gracePeriod int64
+		if err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &meta_v1.DeleteOptions{
+			GracePeriodSeconds: &gracePeriod,
+		}); err != nil && !k8s_errors.IsNotFound(err) {
+			logrus.Warnf("Failed to delete copy pod: %v/%v. Err: %v", pod.Namespace, pod.Name, err)
+		}
+	}()
+
+	// phase is only set by the attempt that succeeds
+	var phase v1.PodPhase
+	t := func() error {
+		current, err := client.CoreV1().Pods(pod.Namespace).Get(pod.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if current.Status.Phase != v1.PodSucceeded && current.Status.Phase != v1.PodFailed {
+			return fmt.Errorf("copy pod: %v is in phase: %v", pod.Name, current.Status.Phase)
+		}
+		phase = current.Status.Phase
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return err
+	}
+
+	if phase == v1.PodFailed {
+		return fmt.Errorf("copy pod: %v failed", pod.Name)
+	}
+
+	return nil
+}
+
+func buildCopyPod(src, dst *v1.PersistentVolumeClaim) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: fmt.Sprintf("torpedo-copy-%v-", src.Name),
+			Namespace:    src.Namespace,
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy: v1.RestartPolicyNever,
+			Containers: []v1.Container{
+				{
+					Name:    "copy",
+					Image:   migrateCopyPodImage,
+					Command: []string{"cp", "-a", migrateSrcPath + "/.", migrateDstPath + "/"},
+					VolumeMounts: []v1.VolumeMount{
+						{
+							Name:      "src",
+							MountPath: migrateSrcPath,
+						},
+						{
+							Name:      "dst",
+							MountPath: migrateDstPath,
+						},
+					},
+				},
+			},
+			Volumes: []v1.Volume{
+				{
+					Name: "src",
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: src.Name,
+						},
+					},
+				},
+				{
+					Name: "dst",
+					VolumeSource: v1.VolumeSource{
+						PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+							ClaimName: dst.Name,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func deploymentUsesPVC(dep *v1beta1.Deployment, claimName string) bool {
+	for _, name := range getDeploymentPVCNames(dep) {
+		if name == claimName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package k8sutils
+
+import (
+	"fmt"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// AddEnvVar returns a mutation that sets the given environment variable in the given container
+func AddEnvVar(container, name, value string) PodTemplateMutation {
+	return func(template *v1.PodTemplateSpec) error {
+		c, err := findContainer(template, container)
+		if err != nil {
+			return err
+		}
+
+		for i := range c.Env {
+			if c.Env[i].Name == name {
+				c.Env[i] = v1.EnvVar{Name: name, Value: value}
+				return nil
+			}
+		}
+		c.Env = append(c.Env, v1.EnvVar{Name: name, Value: value})
+		return nil
+	}
+}
+
+// AddVolumeMount returns a mutation that adds the given volume to the pod and mounts it in the given
+// container at the given path
+func AddVolumeMount(container string, volume v1.Volume, mountPath string) PodTemplateMutation {
+	return func(template *v1.PodTemplateSpec) error {
+		c, err := findContainer(template, container)
+		if err != nil {
+			return err
+		}
+
+		for _, existing := range template.Spec.Volumes {
+			if existing.Name == volume.Name {
+				return fmt.Errorf("pod template already has a volume: %v", volume.Name)
+			}
+		}
+
+		template.Spec.Volumes = append(template.Spec.Volumes, volume)
+		c.VolumeMounts = append(c.VolumeMounts, v1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: mountPath,
+		})
+		return nil
+	}
+}
+
+// SetResources returns a mutation that sets the resource requests and limits of the given container
+func SetResources(container string, resources v1.ResourceRequirements) PodTemplateMutation {
+	return func(template *v1.PodTemplateSpec) error {
+		c, err := findContainer(template, container)
+		if err != nil {
+			return err
+		}
+
+		c.Resources = resources
+		return nil
+	}
+}
+
+// MutateDeploymentPodTemplate applies the given mutations to the pod template of the deployment, waits for
+// the rollout and validates the deployment. The mutations are first applied to a copy of the template so
+// that an invalid mutation fails before the deployment is updated. The returned undo function restores
+// the previous template and waits for that rollout.
+func MutateDeploymentPodTemplate(
+	deployment *v1beta1.Deployment,
+	mutations ...PodTemplateMutation,
+) (func() error, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := client.AppsV1beta1().Deployments(namespaceOrDefault(deployment.Namespace)).Get(deployment.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	original := v1.PodTemplateSpec{}
+	if err := v1.DeepCopy_v1_PodTemplateSpec(&current.Spec.Template, &original, conversion.NewCloner()); err != nil {
+		return nil, err
+	}
+
+	mutated := v1.PodTemplateSpec{}
+	if err := v1.DeepCopy_v1_PodTemplateSpec(&current.Spec.Template, &mutated, conversion.NewCloner()); err != nil {
+		return nil, err
+	}
+	for _, mutate := range mutations {
+		if err := mutate(&mutated); err != nil {
+			return nil, fmt.Errorf("invalid mutation of deployment: %v. Err: %v", current.Name, err)
+		}
+	}
+
+	if err := setPodTemplateAndWait(client, current, mutated); err != nil {
+		return nil, err
+	}
+
+	undo := func() error {
+		return setPodTemplateAndWait(client, current, original)
+	}
+
+	return undo, nil
+}
+
+// setPodTemplateAndWait sets the pod template of the deployment, waits for the rollout and validates the
+// deployment
+func setPodTemplateAndWait(client *kubernetes.Clientset, dep *v1beta1.Deployment, template v1.PodTemplateSpec) error {
+	if err := updateDeploymentWithRetries(client, dep, func(d *v1beta1.Deployment) {
+		d.Spec.Template = template
+	}); err != nil {
+		return err
+	}
+
+	updated, err := client.AppsV1beta1().Deployments(dep.Namespace).Get(dep.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if err := waitForDeploymentRollout(client, updated); err != nil {
+		return err
+	}
+
+	return ValidateDeployement(updated)
+}
+
+// waitForDeploymentRollout waits for the controller to have replaced all the pods of the deployment with
+// pods of its current generation
+func waitForDeploymentRollout(client *kubernetes.Clientset, dep *v1beta1.Deployment) error {
+	t := func() error {
+		current, err := client.AppsV1beta1().Deployments(dep.Namespace).Get(dep.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		replicas := int32(1)
+		if current.Spec.Replicas != nil {
+			replicas = *current.Spec.Replicas
+		}
+
+		status := current.Status
+		if status.ObservedGeneration < dep.Generation ||
+			status.UpdatedReplicas != replicas ||
+			status.Replicas != replicas ||
+			status.AvailableReplicas != replicas {
+			return &ErrAppNotReady{
+				ID: dep.Name,
+				Cause: fmt.Sprintf("rollout in progress. Observed generation: %v of %v Updated: %v Total: %v Available: %v Desired: %v",
+					status.ObservedGeneration, dep.Generation, status.UpdatedReplicas, status.Replicas,
+					status.AvailableReplicas, replicas),
+			}
+		}
+		return nil
+	}
+
+	profile := validationProfile()
+	return doRetryWithTimeout(t, profile.AppReadyTimeout, profile.RetryInterval)
+}
+
+func findContainer(template *v1.PodTemplateSpec, name string) (*v1.Container, error) {
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].Name == name {
+			return &template.Spec.Containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("pod template has no container: %v", name)
+}
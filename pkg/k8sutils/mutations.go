@@ -0,0 +1,152 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultMutationRecordLimit is the number of records kept by the mutation recorder. Older records are
+// dropped.
+const defaultMutationRecordLimit = 10000
+
+var (
+	mutationLock   sync.RWMutex
+	activeRecorder *mutationRecorder
+)
+
+type mutationRecorder struct {
+	sync.Mutex
+	test string
+	// maxBodyBytes is how much of the request bodies is captured. Zero disables the capture.
+	maxBodyBytes int
+	// records is a ring buffer of at most limit records, starting at start
+	records []MutationRecord
+	start   int
+	limit   int
+}
+
+type mutationRecordingRoundTripper struct {
+	rt http.RoundTripper
+}
+
+// WithRequestBodies captures up to maxBytes of the body of each recorded request
+func WithRequestBodies(maxBytes int) MutationRecordingOption {
+	return func(r *mutationRecorder) {
+		r.maxBodyBytes = maxBytes
+	}
+}
+
+// WithMutationRecordLimit sets how many records are kept. The oldest records are dropped once the limit is
+// reached.
+func WithMutationRecordLimit(limit int) MutationRecordingOption {
+	return func(r *mutationRecorder) {
+		if limit > 0 {
+			r.limit = limit
+		}
+	}
+}
+
+// StartMutationRecording records the POST, PUT, PATCH and DELETE requests sent to the api server until
+// StopMutationRecording is called. A recording already in progress is replaced.
+func StartMutationRecording(testName string, opts ...MutationRecordingOption) {
+	r := &mutationRecorder{
+		test:  testName,
+		limit: defaultMutationRecordLimit,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	mutationLock.Lock()
+	defer mutationLock.Unlock()
+	activeRecorder = r
+}
+
+// StopMutationRecording stops the recording and returns the recorded requests in the order they were sent
+func StopMutationRecording() []MutationRecord {
+	mutationLock.Lock()
+	r := activeRecorder
+	activeRecorder = nil
+	mutationLock.Unlock()
+
+	if r == nil {
+		return nil
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	return append(append([]MutationRecord{}, r.records[r.start:]...), r.records[:r.start]...)
+}
+
+// WriteMutationRecords writes the given records to w as a JSON array
+func WriteMutationRecords(w io.Writer, records []MutationRecord) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(records)
+}
+
+func newMutationRecordingRoundTripper(rt http.RoundTripper) http.RoundTripper {
+	return &mutationRecordingRoundTripper{rt: rt}
+}
+
+func (m *mutationRecordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	mutationLock.RLock()
+	r := activeRecorder
+	mutationLock.RUnlock()
+
+	if r == nil || !isMutatingMethod(req.Method) {
+		return m.rt.RoundTrip(req)
+	}
+
+	resource, object := describeRequestPath(req.URL.Path)
+	record := MutationRecord{
+		Test:     r.test,
+		Time:     time.Now(),
+		Method:   req.Method,
+		Resource: resource,
+		Object:   object,
+	}
+
+	if r.maxBodyBytes > 0 && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(io.LimitReader(body, int64(r.maxBodyBytes)))
+			body.Close()
+			record.Body = string(data)
+		}
+	}
+
+	resp, err := m.rt.RoundTrip(req)
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.StatusCode = resp.StatusCode
+	}
+
+	r.add(record)
+	return resp, err
+}
+
+func (r *mutationRecorder) add(record MutationRecord) {
+	r.Lock()
+	defer r.Unlock()
+
+	if len(r.records) < r.limit {
+		r.records = append(r.records, record)
+		return
+	}
+
+	r.records[r.start] = record
+	r.start = (r.start + 1) % r.limit
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
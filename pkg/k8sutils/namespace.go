@@ -0,0 +1,214 @@
+package k8sutils
+
+import (
+	"sync"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var (
+	namespaceLock sync.Mutex
+	// defaultNamespace is used for objects without a namespace
+	defaultNamespace = v1.NamespaceDefault
+	// createNamespaceOnUse enables creating missing namespaces when objects are created in them
+	createNamespaceOnUse bool
+	// ensuredNamespaces are the namespaces known to exist
+	ensuredNamespaces = make(map[string]bool)
+)
+
+type createOptions struct {
+	namespace string
+}
+
+// WithNamespace sets the namespace of the object to create if it doesn't have one
+func WithNamespace(namespace string) CreateOption {
+	return func(o *createOptions) {
+		o.namespace = namespace
+	}
+}
+
+// SetDefaultNamespace sets the namespace used for objects that don't have one, both when they are created
+// and when they are validated or deleted. The namespace is reset to "default" if empty.
+func SetDefaultNamespace(namespace string) {
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+
+	if len(namespace) == 0 {
+		namespace = v1.NamespaceDefault
+	}
+	defaultNamespace = namespace
+}
+
+// SetCreateNamespaceOnUse enables or disables creating the namespace of an object when it is created and
+// the namespace doesn't exist
+func SetCreateNamespaceOnUse(enabled bool) {
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+
+	createNamespaceOnUse = enabled
+}
+
+// CreateNamespace creates a namespace with the given name and labels, labeled with the torpedo instance ID
+func CreateNamespace(name string, labels map[string]string) (*v1.Namespace, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ns := &v1.Namespace{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:   name,
+			Labels: make(map[string]string),
+		},
+	}
+	for key, value := range labels {
+		ns.Labels[key] = value
+	}
+	stampInstanceLabel(&ns.ObjectMeta)
+
+	created, err := client.CoreV1().Namespaces().Create(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceLock.Lock()
+	ensuredNamespaces[created.Name] = true
+	namespaceLock.Unlock()
+
+	return created, nil
+}
+
+// DeleteNamespace deletes the given namespace and all the objects in it. An ErrNotOwned is returned if
+// another torpedo instance created it, unless WithForceDelete is given. Use ValidateNamespaceDeleted to
+// wait for it to terminate.
+func DeleteNamespace(name string, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteNamespace"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	if current, err := client.CoreV1().Namespaces().Get(name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("namespace", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	if err := client.CoreV1().Namespaces().Delete(name, &meta_v1.DeleteOptions{}); err != nil {
+		return err
+	}
+
+	forgetNamespace(name)
+	return nil
+}
+
+// ValidateNamespaceDeleted waits for the given namespace to be gone, up to the given timeout or the app
+// delete timeout of the validation profile if zero. On timeout, an ErrNamespaceNotTerminated with the
+// objects holding the namespace with finalizers is returned.
+func ValidateNamespaceDeleted(name string, timeout time.Duration) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppDeleteTimeout
+	}
+
+	t := func() error {
+		_, err := client.CoreV1().Namespaces().Get(name, meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return &ErrNamespaceNotTerminated{
+			Namespace: name,
+		}
+	}
+
+	if err := doRetryWithTimeout(t, timeout, profile.RetryInterval); err != nil {
+		if finalizers, finalizersErr := getNamespaceFinalizers(client, name); finalizersErr == nil {
+			return &ErrNamespaceNotTerminated{
+				Namespace:  name,
+				Finalizers: finalizers,
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// namespaceOrDefault returns the given namespace or the default namespace if empty
+func namespaceOrDefault(namespace string) string {
+	if len(namespace) > 0 {
+		return namespace
+	}
+
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+	return defaultNamespace
+}
+
+// resolveCreateNamespace returns the namespace to create an object in and creates it if enabled
+func resolveCreateNamespace(client *kubernetes.Clientset, namespace string, opts []CreateOption) (string, error) {
+	if len(namespace) == 0 {
+		o := &createOptions{}
+		for _, opt := range opts {
+			opt(o)
+		}
+		namespace = namespaceOrDefault(o.namespace)
+	}
+
+	if err := ensureNamespace(client, namespace); err != nil {
+		return "", err
+	}
+
+	return namespace, nil
+}
+
+func ensureNamespace(client *kubernetes.Clientset, namespace string) error {
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+
+	if !createNamespaceOnUse || ensuredNamespaces[namespace] {
+		return nil
+	}
+
+	_, err := client.CoreV1().Namespaces().Get(namespace, meta_v1.GetOptions{})
+	if k8s_errors.IsNotFound(err) {
+		_, err = client.CoreV1().Namespaces().Create(&v1.Namespace{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: namespace,
+			},
+		})
+		if k8s_errors.IsAlreadyExists(err) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	ensuredNamespaces[namespace] = true
+	return nil
+}
+
+// forgetNamespace drops the given deleted namespace from the namespaces known to exist so that it is
+// created again when used
+func forgetNamespace(name string) {
+	namespaceLock.Lock()
+	defer namespaceLock.Unlock()
+	delete(ensuredNamespaces, name)
+}
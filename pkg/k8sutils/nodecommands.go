@@ -0,0 +1,183 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/pkg/api/v1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	nodeCommandContainer = "node-command"
+	nodeCommandLabelKey  = "torpedo/node-command"
+	// nodeCommandHostPath is where the root filesystem of the node is mounted in the node command pods
+	nodeCommandHostPath = "/host"
+)
+
+// RunOnAllNodes runs the given command on every worker node from a pod of a privileged, host PID
+// daemonset that is deleted once done. The root filesystem of the node is mounted at /host in the pods.
+// The result of each worker node is returned. Nodes where the pod didn't become ready within the timeout
+// have a result with an error.
+func RunOnAllNodes(cmd []string, image string, timeout time.Duration) (map[string]CommandResult, error) {
+	if err := CheckDestructiveOp("RunOnAllNodes"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	workers, err := GetWorkerNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace := namespaceOrDefault("")
+	ds, err := client.ExtensionsV1beta1().DaemonSets(namespace).Create(buildNodeCommandDaemonSet(namespace, image))
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		policy := meta_v1.DeletePropagationForeground
+		if err := client.ExtensionsV1beta1().DaemonSets(namespace).Delete(ds.Name, &meta_v1.DeleteOptions{
+			PropagationPolicy: &policy,
+		}); err != nil {
+			logrus.Warnf("Failed to delete node command daemonset: %v/%v. Err: %v", namespace, ds.Name, err)
+		}
+	}()
+
+	var lock sync.Mutex
+	podsByNode := make(map[string]v1.Pod)
+	t := func() error {
+		pods, err := client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{
+			LabelSelector: fmt.Sprintf("%v=%v", nodeCommandLabelKey, ds.Name),
+		})
+		if err != nil {
+			return err
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == v1.PodRunning && isPodReady(pod) {
+				podsByNode[pod.Spec.NodeName] = pod
+			}
+		}
+
+		if len(podsByNode) < len(workers) {
+			return fmt.Errorf("%d of %d node command pods are ready", len(podsByNode), len(workers))
+		}
+		return nil
+	}
+
+	// Nodes without a ready pod on timeout are reported in the results
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		logrus.Warnf("Running command on the ready nodes only. Err: %v", err)
+	}
+
+	// Held until done so that an attempt still running after the timeout doesn't change the pods
+	lock.Lock()
+	defer lock.Unlock()
+
+	var resultsLock sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]CommandResult)
+	for _, node := range workers {
+		pod, ok := podsByNode[node.Name]
+		if !ok {
+			results[node.Name] = CommandResult{
+				ExitCode: -1,
+				Err:      fmt.Errorf("node command pod did not become ready on node: %v", node.Name),
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(nodeName string, pod v1.Pod) {
+			defer wg.Done()
+
+			stdout, stderr, err := execInPod(pod, nodeCommandContainer, cmd, timeout)
+			result := CommandResult{
+				Stdout: stdout,
+				Stderr: stderr,
+				Err:    err,
+			}
+
+			if execErr, ok := err.(*ErrFailedToExecInPod); ok {
+				result.ExitCode = execErr.ExitCode
+			} else if err != nil {
+				result.ExitCode = -1
+			}
+
+			resultsLock.Lock()
+			results[nodeName] = result
+			resultsLock.Unlock()
+		}(node.Name, pod)
+	}
+
+	wg.Wait()
+	return results, nil
+}
+
+func buildNodeCommandDaemonSet(namespace, image string) *ext_v1beta1.DaemonSet {
+	name := fmt.Sprintf("torpedo-node-command-%v", rand.String(5))
+	labels := map[string]string{
+		nodeCommandLabelKey: name,
+	}
+	privileged := true
+	var gracePeriod int64
+
+	return &ext_v1beta1.DaemonSet{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: ext_v1beta1.DaemonSetSpec{
+			Selector: &meta_v1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: v1.PodSpec{
+					HostPID:                       true,
+					TerminationGracePeriodSeconds: &gracePeriod,
+					Containers: []v1.Container{
+						{
+							Name:    nodeCommandContainer,
+							Image:   image,
+							Command: []string{"sleep", "3600"},
+							SecurityContext: &v1.SecurityContext{
+								Privileged: &privileged,
+							},
+							VolumeMounts: []v1.VolumeMount{
+								{
+									Name:      "host",
+									MountPath: nodeCommandHostPath,
+								},
+							},
+						},
+					},
+					Volumes: []v1.Volume{
+						{
+							Name: "host",
+							VolumeSource: v1.VolumeSource{
+								HostPath: &v1.HostPathVolumeSource{
+									Path: "/",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package k8sutils
+
+import (
+	"sort"
+	"sync"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const k8sZoneLabelKey = "failure-domain.beta.kubernetes.io/zone"
+
+// WorkerNodes selects all nodes that are not k8s masters
+func WorkerNodes(node v1.Node) bool {
+	return !IsNodeMaster(node)
+}
+
+// NodesInZone selects the nodes in the given failure domain zone
+func NodesInZone(zone string) NodeSelector {
+	return func(node v1.Node) bool {
+		return node.Labels[k8sZoneLabelKey] == zone
+	}
+}
+
+// FirstN selects the first n nodes (ordered by name) that match the given selector.
+// The returned selector keeps count of its matches and should be used for a single bulk operation.
+func FirstN(n int, selector NodeSelector) NodeSelector {
+	matched := 0
+	return func(node v1.Node) bool {
+		if matched >= n || !selector(node) {
+			return false
+		}
+		matched++
+		return true
+	}
+}
+
+// LabelNodesMatching applies the given labels to all nodes matching the selector. Nodes are updated
+// concurrently and conflicting updates are retried. It returns the nodes that failed to get labeled.
+func LabelNodesMatching(selector NodeSelector, labels map[string]string) (map[string]error, error) {
+	if err := CheckDestructiveOp("LabelNodesMatching"); err != nil {
+		return nil, err
+	}
+
+	return updateNodesMatching(selector, func(node *v1.Node) bool {
+		changed := false
+		if node.Labels == nil {
+			node.Labels = make(map[string]string)
+		}
+		for key, value := range labels {
+			if current, present := node.Labels[key]; !present || current != value {
+				node.Labels[key] = value
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// UnlabelNodesMatching removes the labels with given keys from all nodes matching the selector.
+// It returns the nodes that failed to get unlabeled.
+func UnlabelNodesMatching(selector NodeSelector, keys []string) (map[string]error, error) {
+	if err := CheckDestructiveOp("UnlabelNodesMatching"); err != nil {
+		return nil, err
+	}
+
+	return updateNodesMatching(selector, func(node *v1.Node) bool {
+		changed := false
+		for _, key := range keys {
+			if _, present := node.Labels[key]; present {
+				delete(node.Labels, key)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
+
+// updateNodesMatching lists nodes once, selects them and concurrently applies the mutate func to each
+// selected node. mutate returns false if the node doesn't need an update.
+func updateNodesMatching(selector NodeSelector, mutate func(*v1.Node) bool) (map[string]error, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(nodes.Items, func(i, j int) bool {
+		return nodes.Items[i].Name < nodes.Items[j].Name
+	})
+
+	var selected []string
+	for _, node := range nodes.Items {
+		if selector(node) {
+			selected = append(selected, node.Name)
+		}
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	failures := make(map[string]error)
+	for _, name := range selected {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if err := updateNodeWithRetries(client, name, mutate); err != nil {
+				lock.Lock()
+				failures[name] = err
+				lock.Unlock()
+			}
+		}(name)
+	}
+	wg.Wait()
+
+	return failures, nil
+}
+
+func updateNodeWithRetries(client *kubernetes.Clientset, name string, mutate func(*v1.Node) bool) error {
+	var err error
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var node *v1.Node
+		node, err = client.CoreV1().Nodes().Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if !mutate(node) {
+			return nil
+		}
+
+		if _, err = client.CoreV1().Nodes().Update(node); err == nil || !k8s_errors.IsConflict(err) {
+			return err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// clusterNodesRetryInterval is the wait between checks of the cluster nodes
+const clusterNodesRetryInterval = 5 * time.Second
+
+var (
+	workerChecksLock sync.RWMutex
+	// workerChecks are the additional checks of worker nodes by name
+	workerChecks = make(map[string]func(nodeName string) error)
+)
+
+// RegisterWorkerCheck registers an additional check that ValidateClusterNodesReady runs on each worker node,
+// e.g that the pod of a storage driver is ready on it
+func RegisterWorkerCheck(name string, check func(nodeName string) error) {
+	workerChecksLock.Lock()
+	defer workerChecksLock.Unlock()
+	workerChecks[name] = check
+}
+
+// ValidateClusterNodesReady validates, until the timeout, that all the master and worker nodes are ready
+// and that the workers pass the registered worker checks. The nodes are checked concurrently. On failure,
+// the ErrNodesNotReady lists each failing node with its cause.
+func ValidateClusterNodesReady(timeout time.Duration) (*ClusterNodesReport, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		report, err := checkClusterNodesReady()
+		if err == nil {
+			return report, nil
+		}
+
+		if _, ok := err.(*ErrNodesNotReady); !ok || time.Now().After(deadline) {
+			return nil, err
+		}
+
+		time.Sleep(clusterNodesRetryInterval)
+	}
+}
+
+func checkClusterNodesReady() (*ClusterNodesReport, error) {
+	nodes, err := GetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	workerChecksLock.RLock()
+	checks := make(map[string]func(string) error)
+	for name, check := range workerChecks {
+		checks[name] = check
+	}
+	workerChecksLock.RUnlock()
+
+	report := &ClusterNodesReport{
+		WorkerChecks: make(map[string]int),
+	}
+	notReady := &ErrNodesNotReady{
+		Masters: make(map[string]string),
+		Workers: make(map[string]string),
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	for _, n := range nodes.Items {
+		wg.Add(1)
+		go func(n v1.Node) {
+			defer wg.Done()
+
+			master := IsNodeMaster(n)
+			readyErr := IsNodeReady(n.Name)
+
+			passed := make(map[string]bool)
+			var failed []string
+			if !master && readyErr == nil {
+				for name, check := range checks {
+					if err := check(n.Name); err != nil {
+						failed = append(failed, fmt.Sprintf("%v: %v", name, err))
+					} else {
+						passed[name] = true
+					}
+				}
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			for name := range passed {
+				report.WorkerChecks[name]++
+			}
+
+			switch {
+			case master && readyErr != nil:
+				notReady.Masters[n.Name] = readyErr.Error()
+			case master:
+				report.Masters++
+			case readyErr != nil:
+				notReady.Workers[n.Name] = readyErr.Error()
+			case len(failed) > 0:
+				notReady.Workers[n.Name] = fmt.Sprintf("%v", failed)
+			default:
+				report.Workers++
+			}
+		}(n)
+	}
+	wg.Wait()
+
+	if len(notReady.Masters) > 0 || len(notReady.Workers) > 0 {
+		return nil, notReady
+	}
+
+	return report, nil
+}
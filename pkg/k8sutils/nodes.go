@@ -0,0 +1,188 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// WaitForNodeCount waits for the cluster to have the expected number of worker nodes
+func WaitForNodeCount(expected int, timeout time.Duration) error {
+	t := func() error {
+		workers, err := GetWorkerNodes()
+		if err != nil {
+			return err
+		}
+
+		if len(workers) != expected {
+			return &ErrNodeCountMismatch{
+				Expected: expected,
+				Actual:   len(workers),
+			}
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+		return nodeCountTimeoutError(err)
+	}
+
+	return nil
+}
+
+// WaitForNewNode waits for a worker node that is not one of the given existing nodes to join the cluster,
+// be ready and be schedulable. The new node is returned.
+func WaitForNewNode(existingNames []string, timeout time.Duration) (*v1.Node, error) {
+	existing := make(map[string]bool)
+	for _, name := range existingNames {
+		existing[name] = true
+	}
+
+	var newNode *v1.Node
+	t := func() error {
+		workers, err := GetWorkerNodes()
+		if err != nil {
+			return err
+		}
+
+		mismatch := &ErrNodeCountMismatch{
+			Expected: len(existingNames) + 1,
+			Actual:   len(workers),
+		}
+		for i := range workers {
+			node := workers[i]
+			if existing[node.Name] {
+				continue
+			}
+
+			if err := IsNodeReady(node.Name); err != nil {
+				mismatch.Cause = err.Error()
+				return mismatch
+			}
+
+			if node.Spec.Unschedulable {
+				mismatch.Cause = fmt.Sprintf("new node: %v is not schedulable", node.Name)
+				return mismatch
+			}
+
+			newNode = &node
+			return nil
+		}
+
+		mismatch.Cause = "no new node has joined the cluster"
+		return mismatch
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+		return nil, nodeCountTimeoutError(err)
+	}
+
+	return newNode, nil
+}
+
+// nodeCountTimeoutError returns the ErrNodeCountMismatch of the last attempt of a timed out wait for nodes,
+// with the timeout as cause. The timeout error is returned as is if the last attempt failed to list the
+// nodes.
+func nodeCountTimeoutError(err error) error {
+	if mismatch, ok := task.LastError(err).(*ErrNodeCountMismatch); ok {
+		return &ErrNodeCountMismatch{
+			Expected: mismatch.Expected,
+			Actual:   mismatch.Actual,
+			Cause:    err.Error(),
+		}
+	}
+	return err
+}
+
+// IsNodeInitialized checks if the kubelet of the node has reported its status: a Ready condition, at
+// least one address and the node system info. Nodes that are still joining the cluster are not.
+func IsNodeInitialized(node v1.Node) bool {
+	condition := getNodeReadyCondition(&node)
+	if condition == nil || len(condition.Status) == 0 {
+		return false
+	}
+
+	if len(node.Status.Addresses) == 0 {
+		return false
+	}
+
+	info := node.Status.NodeInfo
+	return len(info.KubeletVersion) > 0 || len(info.MachineID) > 0 || len(info.SystemUUID) > 0
+}
+
+// GetWorkerNodes returns the nodes that are not masters. Nodes that are not initialized are skipped
+// unless WithUninitializedNodes is given.
+func GetWorkerNodes(opts ...ListOption) ([]v1.Node, error) {
+	return getNodesMatching(func(node v1.Node) bool {
+		return !IsNodeMaster(node)
+	}, opts)
+}
+
+// GetMasterNodes returns the master nodes. Nodes that are not initialized are skipped unless
+// WithUninitializedNodes is given.
+func GetMasterNodes(opts ...ListOption) ([]v1.Node, error) {
+	return getNodesMatching(IsNodeMaster, opts)
+}
+
+// WithUninitializedNodes makes the node list helpers include the nodes that are not initialized
+func WithUninitializedNodes() ListOption {
+	return func(o *listOptions) {
+		o.uninitialized = true
+	}
+}
+
+func getNodesMatching(selector NodeSelector, opts []ListOption) ([]v1.Node, error) {
+	o := &listOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	nodes, err := GetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1.Node
+	for _, node := range nodes.Items {
+		if !selector(node) {
+			continue
+		}
+		if !o.uninitialized && !IsNodeInitialized(node) {
+			continue
+		}
+		result = append(result, node)
+	}
+	return result, nil
+}
+
+// CordonNode marks the given node unschedulable
+func CordonNode(name string) error {
+	if err := CheckDestructiveOp("CordonNode"); err != nil {
+		return err
+	}
+
+	return setNodeUnschedulable(name, true)
+}
+
+// UncordonNode marks the given node schedulable
+func UncordonNode(name string) error {
+	return setNodeUnschedulable(name, false)
+}
+
+func setNodeUnschedulable(name string, unschedulable bool) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	return updateNodeWithRetries(client, name, func(node *v1.Node) bool {
+		if node.Spec.Unschedulable == unschedulable {
+			return false
+		}
+		node.Spec.Unschedulable = unschedulable
+		return true
+	})
+}
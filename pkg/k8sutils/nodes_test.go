@@ -0,0 +1,118 @@
+package k8sutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// newTestNode returns a node whose kubelet reported a Ready condition of the given status, an address
+// and its system info
+func newTestNode(name string, ready v1.ConditionStatus) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: meta_v1.ObjectMeta{Name: name},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: ready}},
+			Addresses:  []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.1"}},
+			NodeInfo:   v1.NodeSystemInfo{KubeletVersion: "v1.7.0"},
+		},
+	}
+}
+
+func TestIsNodeInitialized(t *testing.T) {
+	noAddress := newTestNode("no-address", v1.ConditionTrue)
+	noAddress.Status.Addresses = nil
+	noInfo := newTestNode("no-info", v1.ConditionTrue)
+	noInfo.Status.NodeInfo = v1.NodeSystemInfo{}
+	emptyCondition := newTestNode("empty-condition", "")
+	noCondition := newTestNode("no-condition", v1.ConditionTrue)
+	noCondition.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeDiskPressure, Status: v1.ConditionFalse}}
+
+	tests := []struct {
+		node     *v1.Node
+		expected bool
+	}{
+		{node: &v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "empty-status"}}},
+		{node: noAddress},
+		{node: noInfo},
+		{node: emptyCondition},
+		{node: noCondition},
+		{node: newTestNode("not-ready", v1.ConditionFalse), expected: true},
+		{node: newTestNode("ready", v1.ConditionTrue), expected: true},
+	}
+
+	for _, test := range tests {
+		if initialized := IsNodeInitialized(*test.node); initialized != test.expected {
+			t.Errorf("%v: expected initialized: %v, got: %v", test.node.Name, test.expected, initialized)
+		}
+	}
+}
+
+func TestIsNodeReadyWithEmptyStatus(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.Add(
+		&v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "joining"}},
+		newTestNode("not-ready", v1.ConditionFalse),
+		newTestNode("ready", v1.ConditionTrue),
+	)
+
+	if err := IsNodeReady("joining"); err == nil || !strings.Contains(err.Error(), "does not have a Ready condition") {
+		t.Errorf("expected a node with an empty status not to be ready, got: %v", err)
+	}
+	if err := IsNodeReady("not-ready"); err == nil {
+		t.Errorf("expected a node with a false Ready condition not to be ready")
+	}
+	if err := IsNodeReady("ready"); err != nil {
+		t.Errorf("expected the node to be ready, got: %v", err)
+	}
+}
+
+func TestNodeListsSkipUninitializedNodes(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	master := newTestNode("master", v1.ConditionTrue)
+	master.Labels = map[string]string{k8sMasterLabelKey: ""}
+	joiningMaster := &v1.Node{ObjectMeta: meta_v1.ObjectMeta{
+		Name:   "joining-master",
+		Labels: map[string]string{k8sMasterLabelKey: ""},
+	}}
+	server.Add(
+		master,
+		joiningMaster,
+		newTestNode("worker", v1.ConditionTrue),
+		&v1.Node{ObjectMeta: meta_v1.ObjectMeta{Name: "joining-worker"}},
+	)
+
+	nodeNames := func(nodes []v1.Node, err error) []string {
+		if err != nil {
+			t.Fatalf("failed to list the nodes: %v", err)
+		}
+		var names []string
+		for _, n := range nodes {
+			names = append(names, n.Name)
+		}
+		return names
+	}
+
+	tests := []struct {
+		name     string
+		names    []string
+		expected []string
+	}{
+		{"workers", nodeNames(GetWorkerNodes()), []string{"worker"}},
+		{"all workers", nodeNames(GetWorkerNodes(WithUninitializedNodes())), []string{"joining-worker", "worker"}},
+		{"masters", nodeNames(GetMasterNodes()), []string{"master"}},
+		{"all masters", nodeNames(GetMasterNodes(WithUninitializedNodes())), []string{"joining-master", "master"}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.names, test.expected) {
+			t.Errorf("%v: expected: %v, got: %v", test.name, test.expected, test.names)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package k8sutils
+
+import (
+	"sync"
+	"time"
+)
+
+// validationStatsBuckets are the upper bounds of the buckets of the validation time histograms
+var validationStatsBuckets = []time.Duration{
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+	1 * time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+}
+
+var (
+	observerLock sync.RWMutex
+	observer     ValidationObserver
+)
+
+// SetValidationObserver sets the observer notified of the attempts and results of the validations.
+// Setting a nil observer disables the notifications.
+func SetValidationObserver(o ValidationObserver) {
+	observerLock.Lock()
+	defer observerLock.Unlock()
+	observer = o
+}
+
+// NewValidationStatsObserver returns an observer that aggregates the validation times per name into
+// histograms which are returned by GetValidationStats while the observer is set
+func NewValidationStatsObserver() ValidationObserver {
+	return &validationStatsObserver{
+		stats: make(map[string]*ValidationStats),
+	}
+}
+
+// GetValidationStats returns the validation time histograms per name recorded by the current observer if
+// it was created with NewValidationStatsObserver
+func GetValidationStats() map[string]ValidationStats {
+	o, ok := getValidationObserver().(*validationStatsObserver)
+	if !ok {
+		return nil
+	}
+	return o.snapshot()
+}
+
+func getValidationObserver() ValidationObserver {
+	observerLock.RLock()
+	defer observerLock.RUnlock()
+	return observer
+}
+
+// observeValidation wraps the given validation task so that each attempt is reported to the observer and
+// returns a function to report the result of the validation. If no observer is set, the task is returned
+// as is. An attempt still running when the validation timed out is not reported once the result was, so
+// the observer never gets an attempt after the completion.
+func observeValidation(name string, t func() error) (func() error, func(error)) {
+	o := getValidationObserver()
+	if o == nil {
+		return t, func(error) {}
+	}
+
+	var lock sync.Mutex
+	start := time.Now()
+	attempt := 0
+	completed := false
+	observed := func() error {
+		err := t()
+
+		lock.Lock()
+		defer lock.Unlock()
+		if !completed {
+			attempt++
+			o.OnAttempt(name, attempt, time.Since(start), err)
+		}
+		return err
+	}
+
+	return observed, func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		completed = true
+		o.OnComplete(name, time.Since(start), err)
+	}
+}
+
+type validationStatsObserver struct {
+	sync.Mutex
+	stats map[string]*ValidationStats
+}
+
+func (v *validationStatsObserver) OnAttempt(name string, attempt int, elapsed time.Duration, err error) {
+	v.Lock()
+	defer v.Unlock()
+	v.get(name).Attempts++
+}
+
+func (v *validationStatsObserver) OnComplete(name string, total time.Duration, err error) {
+	v.Lock()
+	defer v.Unlock()
+
+	s := v.get(name)
+	s.Count++
+	if err != nil {
+		s.Failures++
+		return
+	}
+
+	if s.Min == 0 || total < s.Min {
+		s.Min = total
+	}
+	if total > s.Max {
+		s.Max = total
+	}
+	s.Total += total
+
+	bucket := len(s.Buckets)
+	for i, bound := range s.Buckets {
+		if total <= bound {
+			bucket = i
+			break
+		}
+	}
+	s.Counts[bucket]++
+}
+
+func (v *validationStatsObserver) get(name string) *ValidationStats {
+	s, ok := v.stats[name]
+	if !ok {
+		s = &ValidationStats{
+			Buckets: validationStatsBuckets,
+			Counts:  make([]int, len(validationStatsBuckets)+1),
+		}
+		v.stats[name] = s
+	}
+	return s
+}
+
+func (v *validationStatsObserver) snapshot() map[string]ValidationStats {
+	v.Lock()
+	defer v.Unlock()
+
+	result := make(map[string]ValidationStats)
+	for name, s := range v.stats {
+		copied := *s
+		copied.Counts = append([]int(nil), s.Counts...)
+		result[name] = copied
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+)
+
+// recordingObserver records the notifications of the validations in order
+type recordingObserver struct {
+	sync.Mutex
+	events []string
+}
+
+func (r *recordingObserver) OnAttempt(name string, attempt int, elapsed time.Duration, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, fmt.Sprintf("attempt %v", attempt))
+}
+
+func (r *recordingObserver) OnComplete(name string, total time.Duration, err error) {
+	r.Lock()
+	defer r.Unlock()
+	r.events = append(r.events, "complete")
+}
+
+func (r *recordingObserver) get() []string {
+	r.Lock()
+	defer r.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func TestObserveValidationSkipsAttemptsAfterTimeout(t *testing.T) {
+	o := &recordingObserver{}
+	SetValidationObserver(o)
+	defer SetValidationObserver(nil)
+
+	release := make(chan struct{})
+	done := make(chan struct{})
+	attempts := 0
+	observed, complete := observeValidation("timeout", func() error {
+		attempts++
+		if attempts > 1 {
+			// The second attempt is still running when the validation times out
+			<-release
+		}
+		return fmt.Errorf("not ready")
+	})
+
+	err := doRetryWithTimeout(func() error {
+		err := observed()
+		if attempts > 1 {
+			close(done)
+		}
+		return err
+	}, 50*time.Millisecond, 10*time.Millisecond)
+	if !task.IsTimedOut(err) {
+		t.Fatalf("expected the validation to time out, got: %v", err)
+	}
+	complete(err)
+
+	close(release)
+	<-done
+
+	events := o.get()
+	if len(events) != 2 || events[0] != "attempt 1" || events[1] != "complete" {
+		t.Errorf("expected only the attempt before the timeout to be reported before the completion, got: %v",
+			events)
+	}
+}
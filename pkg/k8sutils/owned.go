@@ -0,0 +1,104 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// getOwnedPods returns the pods in the namespace matching the selector, all pods if nil, that the controller
+// of the given kind owns. If the controller has a UID, the owner is matched by UID so that the pods of a
+// recreated controller with the same name are told apart.
+func getOwnedPods(
+	client *kubernetes.Clientset,
+	namespace, kind, name string,
+	uid types.UID,
+	selector *meta_v1.LabelSelector,
+) ([]v1.Pod, error) {
+	podSelector := labels.Everything()
+	if selector != nil {
+		var err error
+		if podSelector, err = meta_v1.LabelSelectorAsSelector(selector); err != nil {
+			return nil, err
+		}
+	}
+
+	pods, err := listCachedPods(client, namespaceOrDefault(namespace), podSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1.Pod
+	for _, pod := range pods {
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind != kind {
+				continue
+			}
+			if (len(uid) > 0 && owner.UID == uid) || (len(uid) == 0 && owner.Name == name) {
+				result = append(result, pod)
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// validateTerminatedController waits for the controller of the given kind and its pods to be deleted, up
+// to the given timeout or the app delete timeout of the validation profile if zero. get returns the live
+// controller and a description of its state for the error, or a NotFound error once it is deleted. A
+// controller with another UID is a recreated one and doesn't count.
+func validateTerminatedController(
+	client *kubernetes.Clientset,
+	namespace, kind, name string,
+	uid types.UID,
+	timeout time.Duration,
+	get func() (meta_v1.ObjectMeta, string, error),
+) error {
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppDeleteTimeout
+	}
+
+	kindName := strings.ToLower(kind)
+	t := func() error {
+		current, state, err := get()
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		}
+		if err == nil && (len(uid) == 0 || current.UID == uid) {
+			return &ErrAppNotTerminated{
+				ID:    current.Name,
+				Cause: fmt.Sprintf("%v still exists with %v", kindName, state),
+			}
+		}
+
+		pods, err := getOwnedPods(client, namespace, kind, name, uid, nil)
+		if err != nil {
+			return err
+		}
+		if len(pods) > 0 {
+			var names []string
+			for _, pod := range pods {
+				names = append(names, pod.Name)
+			}
+			return &ErrAppNotTerminated{
+				ID:    name,
+				Cause: fmt.Sprintf("pods: %v are still present", names),
+			}
+		}
+
+		return nil
+	}
+
+	t, complete := observeValidation(fmt.Sprintf("terminated-%v/%v", kindName, name), t)
+	err := doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	complete(err)
+	return err
+}
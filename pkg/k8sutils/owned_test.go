@@ -0,0 +1,110 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// newTestOwnedPod returns a pod labeled app=<app> owned by the controller of the given kind
+func newTestOwnedPod(name, app, kind, owner string, uid types.UID) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            name,
+			Namespace:       testNamespace,
+			Labels:          map[string]string{"app": app},
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: kind, Name: owner, UID: uid}},
+		},
+	}
+}
+
+func TestGetOwnedPods(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.Add(
+		newTestOwnedPod("db-0", "db", "StatefulSet", "db", "db-uid"),
+		newTestOwnedPod("db-1", "db", "StatefulSet", "db", "db-uid"),
+		// A pod of the previous statefulset with the same name
+		newTestOwnedPod("db-old", "db", "StatefulSet", "db", "old-uid"),
+		// A pod of another kind of controller with the same name
+		newTestOwnedPod("db-agent", "db", "DaemonSet", "db", "ds-uid"),
+		newTestOwnedPod("cache-0", "cache", "StatefulSet", "cache", "cache-uid"),
+	)
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+
+	selector := &meta_v1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	tests := []struct {
+		name     string
+		kind     string
+		uid      types.UID
+		selector *meta_v1.LabelSelector
+		expected []string
+	}{
+		{"by uid", "StatefulSet", "db-uid", selector, []string{"db-0", "db-1"}},
+		{"by name", "StatefulSet", "", selector, []string{"db-0", "db-1", "db-old"}},
+		{"without selector", "StatefulSet", "db-uid", nil, []string{"db-0", "db-1"}},
+		{"other kind", "DaemonSet", "", nil, []string{"db-agent"}},
+		{"no pods", "StatefulSet", "new-uid", selector, nil},
+	}
+
+	for _, test := range tests {
+		pods, err := getOwnedPods(client, testNamespace, test.kind, "db", test.uid, test.selector)
+		if err != nil {
+			t.Fatalf("%v: failed to get the pods: %v", test.name, err)
+		}
+		if names := podNames(pods); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%v: expected the pods: %v, got: %v", test.name, test.expected, names)
+		}
+	}
+}
+
+func TestValidateTerminatedControllers(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	ss := &v1beta1.StatefulSet{ObjectMeta: meta_v1.ObjectMeta{Name: "db", Namespace: testNamespace, UID: "db-uid"}}
+	ds := &ext_v1beta1.DaemonSet{ObjectMeta: meta_v1.ObjectMeta{Name: "agent", Namespace: testNamespace, UID: "ds-uid"}}
+	server.Add(ss, ds, newTestOwnedPod("db-0", "db", "StatefulSet", "db", "db-uid"))
+
+	validations := map[string]func() error{
+		"statefulset": func() error { return ValidateTerminatedStatefulSet(ss, 50*time.Millisecond) },
+		"daemonset":   func() error { return ValidateTerminatedDaemonSet(ds, 50*time.Millisecond) },
+	}
+	for kind, validate := range validations {
+		if err := validate(); !IsAppNotTerminated(task.LastError(err)) {
+			t.Errorf("%v: expected an ErrAppNotTerminated while it exists, got: %v", kind, err)
+		}
+	}
+
+	// Deleted, but the pod of the statefulset remains
+	server.Remove("statefulsets", testNamespace, "db")
+	server.Remove("daemonsets", testNamespace, "agent")
+	err := validations["statefulset"]()
+	if notTerminated, ok := task.LastError(err).(*ErrAppNotTerminated); !ok ||
+		notTerminated.Cause != "pods: [db-0] are still present" {
+		t.Errorf("expected the remaining pod to be reported, got: %v", err)
+	}
+	if err := validations["daemonset"](); err != nil {
+		t.Errorf("expected the daemonset to be terminated, got: %v", err)
+	}
+
+	// Recreated with the same name
+	server.Remove("pods", testNamespace, "db-0")
+	recreated := *ss
+	recreated.UID = "new-uid"
+	server.Add(&recreated, newTestOwnedPod("db-0", "db", "StatefulSet", "db", "new-uid"))
+	if err := validations["statefulset"](); err != nil {
+		t.Errorf("expected the recreated statefulset not to count, got: %v", err)
+	}
+}
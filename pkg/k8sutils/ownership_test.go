@@ -0,0 +1,275 @@
+package k8sutils
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestGetDeploymentPodsMatchesReplicaSetUIDs(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("web", "dep-uid", 2)
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+
+	// A previous instance of the deployment whose replica set had the same name
+	oldDep := newTestDeployment("web", "old-dep-uid", 2)
+	oldRS := newTestReplicaSet(oldDep, "abc", "old-rs-uid", 1)
+	oldRS.Name = "web-abc-old"
+	stalePod := newTestPod(oldRS, "web-abc-stale", "node1")
+	stalePod.OwnerReferences[0].Name = rs.Name
+
+	// The replica set of another deployment whose name has the deployment name as prefix
+	other := newTestDeployment("web-api", "other-dep-uid", 1)
+	other.Spec.Template.Labels = dep.Spec.Template.Labels
+	otherRS := newTestReplicaSet(other, "def", "other-rs-uid", 1)
+
+	server.Add(dep, rs, oldRS, otherRS,
+		newTestPod(rs, "web-abc-1", "node1"),
+		newTestPod(rs, "web-abc-2", "node2"),
+		stalePod,
+		newTestPod(otherRS, "web-api-def-1", "node1"),
+	)
+
+	pods, err := GetDeploymentPods(dep)
+	if err != nil {
+		t.Fatalf("failed to get the deployment pods: %v", err)
+	}
+
+	expected := []string{"web-abc-1", "web-abc-2"}
+	if names := podNames(pods); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected pods: %v, got: %v", expected, names)
+	}
+}
+
+func TestGetOwnedReplicaSets(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("db", "dep-uid", 1)
+	oldDep := newTestDeployment("db", "old-dep-uid", 1)
+	server.Add(
+		newTestReplicaSet(dep, "v1", "rs1-uid", 1),
+		newTestReplicaSet(dep, "v2", "rs2-uid", 2),
+		newTestReplicaSet(oldDep, "v3", "rs3-uid", 1),
+	)
+
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		uid      string
+		expected []string
+	}{
+		{name: "by uid", uid: "dep-uid", expected: []string{"db-v1", "db-v2"}},
+		{name: "other instance", uid: "old-dep-uid", expected: []string{"db-v3"}},
+		{name: "by name without uid", expected: []string{"db-v1", "db-v2", "db-v3"}},
+	}
+
+	for _, test := range tests {
+		target := *dep
+		target.UID = ""
+		if len(test.uid) > 0 {
+			target.UID = types.UID(test.uid)
+		}
+
+		replicaSets, err := getOwnedReplicaSets(client, testNamespace, &target)
+		if err != nil {
+			t.Fatalf("%v: failed to get the replica sets: %v", test.name, err)
+		}
+
+		var names []string
+		for _, rs := range replicaSets {
+			names = append(names, rs.Name)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%v: expected replica sets: %v, got: %v", test.name, test.expected, names)
+		}
+	}
+}
+
+func TestIsOwnedByDeployment(t *testing.T) {
+	tests := []struct {
+		name       string
+		owner      string
+		hash       string
+		deployment string
+		expected   bool
+	}{
+		{name: "hash label", owner: "foo-123", hash: "123", deployment: "foo", expected: true},
+		{name: "hash label of other deployment", owner: "foo-bar-123", hash: "123", deployment: "foo"},
+		{name: "no hash label", owner: "foo-123", deployment: "foo", expected: true},
+		{name: "no hash label, prefixed deployment", owner: "foo-bar-123", deployment: "foo"},
+		{name: "no hash label, prefixed deployment itself", owner: "foo-bar-123", deployment: "foo-bar", expected: true},
+		{name: "no suffix", owner: "foo-", deployment: "foo"},
+		{name: "other name", owner: "bar-123", deployment: "foo"},
+	}
+
+	for _, test := range tests {
+		pod := v1.Pod{
+			ObjectMeta: meta_v1.ObjectMeta{
+				OwnerReferences: []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: test.owner}},
+			},
+		}
+		if len(test.hash) > 0 {
+			pod.Labels = map[string]string{k8sPodTemplateHashKey: test.hash}
+		}
+
+		if got := isOwnedByDeployment(pod, test.deployment); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+func TestCheckDeploymentReadyWaitsForPreviousInstance(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	// The deployment was recreated with the same template, so the replica sets of both instances have the
+	// same name. The replica set of the previous instance is already deleted and its pod is orphaned.
+	dep := newTestDeployment("web", "dep-uid", 1)
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	oldDep := newTestDeployment("web", "old-dep-uid", 1)
+	oldRS := newTestReplicaSet(oldDep, "abc", "old-rs-uid", 1)
+	server.Add(dep, rs, newTestPod(rs, "web-abc-1", "node1"), newTestPod(oldRS, "web-abc-old", "node2"))
+
+	report := &DeploymentStatusReport{PodNodes: make(map[string]string)}
+	err := checkDeploymentReady(dep, report, newValidateOptions(nil))
+	if !IsAppNotReady(err) || !strings.Contains(err.Error(), "waiting for previous instance cleanup: 1 pods") {
+		t.Fatalf("expected to wait for the previous instance, got: %v", err)
+	}
+
+	// The replica set of a previous revision of the previous instance is still present, e.g with foreground
+	// deletion, so its pods are matched through it
+	oldRevisionRS := newTestReplicaSet(oldDep, "def", "old-rs2-uid", 1)
+	server.Remove("pods", testNamespace, "web-abc-old")
+	server.Add(oldRevisionRS, newTestPod(oldRevisionRS, "web-def-old", "node2"))
+	err = checkDeploymentReady(dep, report, newValidateOptions(nil))
+	if !IsAppNotReady(err) || !strings.Contains(err.Error(), "waiting for previous instance cleanup: 1 pods") {
+		t.Fatalf("expected to wait for the previous instance, got: %v", err)
+	}
+
+	server.Remove("pods", testNamespace, "web-def-old")
+	if err = checkDeploymentReady(dep, report, newValidateOptions(nil)); err != nil {
+		t.Fatalf("expected the deployment to be ready, got: %v", err)
+	}
+
+	expected := map[string]string{"web-abc-1": "node1"}
+	if !reflect.DeepEqual(report.PodNodes, expected) {
+		t.Errorf("expected pod nodes: %v, got: %v", expected, report.PodNodes)
+	}
+}
+
+func TestCheckDeploymentReadyReturnsReplicaSetListErrors(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	server.Add(dep, rs, newTestPod(rs, "web-abc-1", "node1"))
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "replicasets" {
+			return false, 0, nil
+		}
+		return true, http.StatusForbidden, k8stest.Status(http.StatusForbidden, "Forbidden", "replicasets are forbidden")
+	})
+
+	report := &DeploymentStatusReport{PodNodes: make(map[string]string)}
+	err := checkDeploymentReady(dep, report, newValidateOptions(nil))
+	if !IsAppNotReady(err) || !strings.Contains(err.Error(), "replicasets") {
+		t.Errorf("expected the replica set list error, got: %v", err)
+	}
+
+	if _, err = GetDeploymentPods(dep); err == nil {
+		t.Errorf("expected GetDeploymentPods to return the replica set list error")
+	}
+}
+
+func TestValidateTerminatedDeploymentWaitsForReplicaSets(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	// The deployment is gone but foreground deletion left its replica set behind, next to the replica
+	// set of a recreated instance of the deployment
+	dep := newTestDeployment("web", "dep-uid", 1)
+	newDep := newTestDeployment("web", "new-dep-uid", 1)
+	orphan := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	server.Add(orphan, newTestReplicaSet(newDep, "def", "new-rs-uid", 1))
+
+	err := ValidateTerminatedDeployment(dep)
+	if !IsAppNotTerminated(err) || !strings.Contains(err.Error(), orphan.Name) {
+		t.Fatalf("expected an ErrAppNotTerminated naming replica set: %v, got: %v", orphan.Name, err)
+	}
+	if strings.Contains(err.Error(), "web-def") {
+		t.Errorf("expected the replica set of the recreated deployment not to be reported, got: %v", err)
+	}
+
+	server.Remove("replicasets", testNamespace, orphan.Name)
+	if err := ValidateTerminatedDeployment(dep); err != nil {
+		t.Errorf("expected the deployment to be terminated once its replica set is gone, got: %v", err)
+	}
+}
+
+func TestValidateTerminatedDeploymentReturnsReplicaSetListErrors(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "replicasets" {
+			return false, 0, nil
+		}
+		return true, http.StatusForbidden, k8stest.Status(http.StatusForbidden, "Forbidden", "replicasets are forbidden")
+	})
+
+	err := checkDeploymentTerminated(newTestDeployment("web", "dep-uid", 1))
+	if !IsAppNotTerminated(err) || !strings.Contains(err.Error(), "replicasets") {
+		t.Errorf("expected an ErrAppNotTerminated with the replica set list error, got: %v", err)
+	}
+}
+
+func TestDeploymentValidationWithoutExtensionsGroup(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	// The cluster no longer serves the extensions group
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if !strings.HasPrefix(req.APIVersion, "extensions/") {
+			return false, 0, nil
+		}
+		return true, http.StatusNotFound, k8stest.Status(http.StatusNotFound, "NotFound",
+			"the server could not find the requested resource")
+	})
+
+	dep := newTestDeployment("web", "dep-uid", 2)
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	server.Add(dep, rs, newTestPod(rs, "web-abc-1", "node1"), newTestPod(rs, "web-abc-2", "node2"))
+
+	if err := ValidateDeployement(dep); err != nil {
+		t.Fatalf("expected the deployment to be validated, got: %v", err)
+	}
+
+	pods, err := GetDeploymentPods(dep)
+	if err != nil {
+		t.Fatalf("failed to get the deployment pods: %v", err)
+	}
+	expected := []string{"web-abc-1", "web-abc-2"}
+	if names := podNames(pods); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected pods: %v, got: %v", expected, names)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Resource == "replicasets" && req.APIVersion != "apps/v1" {
+			t.Errorf("expected the replica sets to be listed from apps/v1, got: %v", req.APIVersion)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package k8sutils
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+type podPlacementRecorder struct {
+	sync.Mutex
+	namespace string
+	selector  string
+	events    []PodPlacementEvent
+	last      map[string]PodPlacementEvent
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// StartPodPlacementRecorder watches the pods matching the selector in the given namespace and records
+// every change of their node or running state. The returned function stops the recording, waits for the
+// watch to exit and returns the recorded events.
+func StartPodPlacementRecorder(namespace, selector string) (func() []PodPlacementEvent, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &podPlacementRecorder{
+		namespace: namespaceOrDefault(namespace),
+		selector:  selector,
+		last:      make(map[string]PodPlacementEvent),
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go r.run(client)
+
+	var once sync.Once
+	var events []PodPlacementEvent
+	return func() []PodPlacementEvent {
+		once.Do(func() {
+			close(r.quit)
+			<-r.done
+			r.Lock()
+			events = r.events
+			r.Unlock()
+		})
+		return events
+	}, nil
+}
+
+// FindPlacementViolations correlates the node health history with the pod placement history and returns
+// the periods during which a pod was running on a node that was not healthy, ordered by start time
+func FindPlacementViolations(nodeHistory []NodeHealthEvent, podHistory []PodPlacementEvent) []PlacementViolation {
+	nodeDown := make(map[string][]timeInterval)
+	for node, events := range groupNodeEvents(nodeHistory) {
+		var start time.Time
+		down := false
+		for _, e := range events {
+			if !e.Healthy && !down {
+				down = true
+				start = e.Time
+			} else if e.Healthy && down {
+				down = false
+				nodeDown[node] = append(nodeDown[node], timeInterval{start: start, end: e.Time})
+			}
+		}
+		if down {
+			nodeDown[node] = append(nodeDown[node], timeInterval{start: start})
+		}
+	}
+
+	var violations []PlacementViolation
+	for pod, events := range groupPodEvents(podHistory) {
+		for i, e := range events {
+			if !e.Running || len(e.Node) == 0 {
+				continue
+			}
+
+			running := timeInterval{start: e.Time}
+			if i+1 < len(events) {
+				running.end = events[i+1].Time
+			}
+
+			for _, down := range nodeDown[e.Node] {
+				if overlap, ok := running.intersect(down); ok {
+					violations = append(violations, PlacementViolation{
+						Pod:   pod,
+						Node:  e.Node,
+						Start: overlap.start,
+						End:   overlap.end,
+					})
+				}
+			}
+		}
+	}
+
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].Start.Before(violations[j].Start)
+	})
+	return violations
+}
+
+func (r *podPlacementRecorder) run(client *kubernetes.Clientset) {
+	defer close(r.done)
+
+	for {
+		if err := r.watch(client); err != nil {
+			logrus.Warnf("Pod placement watch in namespace: %v disconnected. Err: %v", r.namespace, err)
+		}
+
+		select {
+		case <-r.quit:
+			return
+		case <-time.After(cacheRelistDelay):
+		}
+	}
+}
+
+// watch lists the pods, records their current state and then records the watch events until the watch
+// fails or the recorder is stopped
+func (r *podPlacementRecorder) watch(client *kubernetes.Clientset) error {
+	list, err := client.CoreV1().Pods(r.namespace).List(meta_v1.ListOptions{LabelSelector: r.selector})
+	if err != nil {
+		return err
+	}
+
+	for _, pod := range list.Items {
+		r.record(pod, false)
+	}
+
+	w, err := client.CoreV1().Pods(r.namespace).Watch(meta_v1.ListOptions{
+		LabelSelector:   r.selector,
+		ResourceVersion: list.ResourceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	defer w.Stop()
+
+	for {
+		select {
+		case <-r.quit:
+			return nil
+		case event, ok := <-w.ResultChan():
+			if !ok {
+				return nil
+			}
+
+			pod, ok := event.Object.(*v1.Pod)
+			if !ok {
+				continue
+			}
+
+			switch event.Type {
+			case watch.Added, watch.Modified:
+				r.record(*pod, false)
+			case watch.Deleted:
+				r.record(*pod, true)
+			}
+		}
+	}
+}
+
+func (r *podPlacementRecorder) record(pod v1.Pod, deleted bool) {
+	event := PodPlacementEvent{
+		Time:    time.Now(),
+		Pod:     pod.Name,
+		Node:    pod.Spec.NodeName,
+		Running: !deleted && pod.Status.Phase == v1.PodRunning,
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if last, ok := r.last[pod.Name]; ok && last.Node == event.Node && last.Running == event.Running {
+		return
+	}
+	r.last[pod.Name] = event
+	r.events = append(r.events, event)
+}
+
+// timeInterval is a period of time. A zero end means the period has not ended.
+type timeInterval struct {
+	start time.Time
+	end   time.Time
+}
+
+func (a timeInterval) intersect(b timeInterval) (timeInterval, bool) {
+	result := timeInterval{start: a.start}
+	if b.start.After(result.start) {
+		result.start = b.start
+	}
+
+	switch {
+	case a.end.IsZero():
+		result.end = b.end
+	case b.end.IsZero() || a.end.Before(b.end):
+		result.end = a.end
+	default:
+		result.end = b.end
+	}
+
+	if !result.end.IsZero() && !result.end.After(result.start) {
+		return timeInterval{}, false
+	}
+	return result, true
+}
+
+func groupNodeEvents(history []NodeHealthEvent) map[string][]NodeHealthEvent {
+	groups := make(map[string][]NodeHealthEvent)
+	for _, e := range history {
+		groups[e.Node] = append(groups[e.Node], e)
+	}
+	for _, events := range groups {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Time.Before(events[j].Time)
+		})
+	}
+	return groups
+}
+
+func groupPodEvents(history []PodPlacementEvent) map[string][]PodPlacementEvent {
+	groups := make(map[string][]PodPlacementEvent)
+	for _, e := range history {
+		groups[e.Pod] = append(groups[e.Pod], e)
+	}
+	for _, events := range groups {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Time.Before(events[j].Time)
+		})
+	}
+	return groups
+}
@@ -0,0 +1,89 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFindPlacementViolations(t *testing.T) {
+	start := time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		nodes    []NodeHealthEvent
+		pods     []PodPlacementEvent
+		expected []PlacementViolation
+	}{
+		{
+			name:  "healthy nodes",
+			nodes: []NodeHealthEvent{{Time: at(0), Node: "node1", Healthy: true}},
+			pods:  []PodPlacementEvent{{Time: at(0), Pod: "web-1", Node: "node1", Running: true}},
+		},
+		{
+			name: "pod running while the node is down",
+			nodes: []NodeHealthEvent{
+				{Time: at(0), Node: "node1", Healthy: true},
+				{Time: at(5), Node: "node1"},
+				{Time: at(10), Node: "node1", Healthy: true},
+			},
+			pods: []PodPlacementEvent{
+				{Time: at(0), Pod: "web-1", Node: "node1", Running: true},
+				{Time: at(7), Pod: "web-1", Node: "node2", Running: true},
+			},
+			expected: []PlacementViolation{{Pod: "web-1", Node: "node1", Start: at(5), End: at(7)}},
+		},
+		{
+			name:     "node still down at the end",
+			nodes:    []NodeHealthEvent{{Time: at(3), Node: "node1"}},
+			pods:     []PodPlacementEvent{{Time: at(1), Pod: "web-1", Node: "node1", Running: true}},
+			expected: []PlacementViolation{{Pod: "web-1", Node: "node1", Start: at(3)}},
+		},
+		{
+			name: "pod stopped before the node went down",
+			nodes: []NodeHealthEvent{
+				{Time: at(5), Node: "node1"},
+			},
+			pods: []PodPlacementEvent{
+				{Time: at(0), Pod: "web-1", Node: "node1", Running: true},
+				{Time: at(5), Pod: "web-1", Node: "node1"},
+			},
+		},
+		{
+			name: "pending and unscheduled pods",
+			nodes: []NodeHealthEvent{
+				{Time: at(0), Node: "node1"},
+			},
+			pods: []PodPlacementEvent{
+				{Time: at(0), Pod: "web-1", Node: "node1"},
+				{Time: at(0), Pod: "web-2", Running: true},
+			},
+		},
+		{
+			name: "unordered histories and several pods ordered by start",
+			nodes: []NodeHealthEvent{
+				{Time: at(20), Node: "node2", Healthy: true},
+				{Time: at(2), Node: "node1"},
+				{Time: at(15), Node: "node2"},
+				{Time: at(4), Node: "node1", Healthy: true},
+			},
+			pods: []PodPlacementEvent{
+				{Time: at(0), Pod: "web-2", Node: "node2", Running: true},
+				{Time: at(0), Pod: "web-1", Node: "node1", Running: true},
+			},
+			expected: []PlacementViolation{
+				{Pod: "web-1", Node: "node1", Start: at(2), End: at(4)},
+				{Pod: "web-2", Node: "node2", Start: at(15), End: at(20)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if violations := FindPlacementViolations(test.nodes, test.pods); !reflect.DeepEqual(violations, test.expected) {
+			t.Errorf("%v: expected the violations: %+v, got: %+v", test.name, test.expected, violations)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	podVolumeMountPathPrefix = "/mnt/vol"
+	podVolumeNamePrefix      = "vol"
+	podWithPVCsContainer     = "app"
+)
+
+// CreatePodWithPVCs creates a pod that mounts each of the given PVCs. The i-th claim is mounted at
+// /mnt/vol<i> using a volume named vol<i>.
+func CreatePodWithPVCs(
+	namespace string,
+	name string,
+	pvcs []*v1.PersistentVolumeClaim,
+	image string,
+	cmd []string,
+) (*v1.Pod, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pod := &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{
+				{
+					Name:    podWithPVCsContainer,
+					Image:   image,
+					Command: cmd,
+				},
+			},
+		},
+	}
+
+	stampInstanceLabel(&pod.ObjectMeta)
+	for i, pvc := range pvcs {
+		volumeName := fmt.Sprintf("%v%d", podVolumeNamePrefix, i)
+		pod.Spec.Volumes = append(pod.Spec.Volumes, v1.Volume{
+			Name: volumeName,
+			VolumeSource: v1.VolumeSource{
+				PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+					ClaimName: pvc.Name,
+				},
+			},
+		})
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, v1.VolumeMount{
+			Name:      volumeName,
+			MountPath: fmt.Sprintf("%v%d", podVolumeMountPathPrefix, i),
+		})
+	}
+
+	return client.CoreV1().Pods(namespace).Create(pod)
+}
+
+// ValidatePodWithPVCs waits for a pod created with CreatePodWithPVCs to be running and then verifies
+// that each of its volumes is mounted in the container. If the pod does not start, the pod's events
+// are used to report which volumes failed to attach or mount.
+func ValidatePodWithPVCs(pod *v1.Pod, timeout time.Duration) error {
+	current, err := waitForPodRunning(pod, timeout)
+	if err != nil {
+		if failed := getFailedPodVolumes(pod); len(failed) > 0 {
+			return &ErrPodVolumesNotReady{
+				Pod:     pod.Name,
+				Volumes: failed,
+				Cause:   err.Error(),
+			}
+		}
+		return err
+	}
+
+	var mountPaths []string
+	for _, mount := range current.Spec.Containers[0].VolumeMounts {
+		if strings.HasPrefix(mount.MountPath, podVolumeMountPathPrefix) {
+			mountPaths = append(mountPaths, mount.MountPath)
+		}
+	}
+
+	script := fmt.Sprintf("for p in %v; do grep -q \" $p \" /proc/mounts || echo $p; done",
+		strings.Join(mountPaths, " "))
+	stdout, _, err := execInPod(*current, current.Spec.Containers[0].Name, []string{"sh", "-c", script}, time.Minute)
+	if err != nil {
+		return err
+	}
+
+	if missing := strings.Fields(stdout); len(missing) > 0 {
+		failed := make(map[string]string)
+		for _, path := range missing {
+			failed[path] = "mount path is not mounted in the container"
+		}
+		return &ErrPodVolumesNotReady{
+			Pod:     pod.Name,
+			Volumes: failed,
+			Cause:   "not all volumes are mounted",
+		}
+	}
+
+	return nil
+}
+
+// WaitForPodsScheduledOnNode waits for at least minCount pods matching the given label selector, in any
+// namespace, to be running and ready on the given node. On timeout, the error includes the node's taints
+// and whether it is unschedulable.
+func WaitForPodsScheduledOnNode(
+	nodeName string,
+	selector string,
+	minCount int,
+	timeout time.Duration,
+) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	t := func() error {
+		readyCount, err := countReadyPodsOnNode(client, nodeName, selector)
+		if err != nil {
+			return err
+		}
+
+		if readyCount < minCount {
+			return fmt.Errorf("only %d of %d pods are ready", readyCount, minCount)
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		schedErr := &ErrPodsNotScheduledOnNode{
+			Node:     nodeName,
+			Selector: selector,
+			Cause:    err.Error(),
+		}
+		if node, nodeErr := GetNodeByName(nodeName); nodeErr == nil {
+			schedErr.Taints = node.Spec.Taints
+			schedErr.Unschedulable = node.Spec.Unschedulable
+		}
+		return schedErr
+	}
+
+	return nil
+}
+
+// CountReadyPodsOnNode returns the number of running and ready pods matching the selector on the given node
+func CountReadyPodsOnNode(nodeName, selector string) (int, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return 0, err
+	}
+
+	return countReadyPodsOnNode(client, nodeName, selector)
+}
+
+func countReadyPodsOnNode(client *kubernetes.Clientset, nodeName, selector string) (int, error) {
+	pods, err := listAllPods(client, "", meta_v1.ListOptions{
+		LabelSelector: selector,
+		FieldSelector: fmt.Sprintf("spec.nodeName=%v", nodeName),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var readyCount int
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && pod.Status.Phase == v1.PodRunning && isPodReady(pod) {
+			readyCount++
+		}
+	}
+	return readyCount, nil
+}
+
+// DeletePodsOnNode deletes all pods of the given namespace (all namespaces if empty) running on the given
+// node and returns the pods that were deleted. Pods of other torpedo instances are skipped unless
+// WithAllInstances is given.
+func DeletePodsOnNode(nodeName, namespace string, opts ...ListOption) ([]v1.Pod, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := listAllPods(client, namespace, meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("spec.nodeName=%v", nodeName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []v1.Pod
+	for _, pod := range pods {
+		if !isOtherInstance(pod.ObjectMeta, opts) {
+			targets = append(targets, pod)
+		}
+	}
+
+	return deletePods(targets)
+}
+
+// DeleteOnePodPerDomain deletes one pod of the given deployment in each topology domain, where the domain
+// of a pod is the value of the topologyKey label (e.g failure-domain.beta.kubernetes.io/zone or
+// kubernetes.io/hostname) of its node. The pods that were deleted are returned.
+func DeleteOnePodPerDomain(deployment *v1beta1.Deployment, topologyKey string) ([]v1.Pod, error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := GetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	nodeDomains := make(map[string]string)
+	for _, node := range nodes.Items {
+		if domain, ok := node.Labels[topologyKey]; ok {
+			nodeDomains[node.Name] = domain
+		}
+	}
+
+	seenDomains := make(map[string]bool)
+	var targets []v1.Pod
+	for _, pod := range pods {
+		domain, ok := nodeDomains[pod.Spec.NodeName]
+		if !ok || seenDomains[domain] || pod.DeletionTimestamp != nil {
+			continue
+		}
+
+		seenDomains[domain] = true
+		targets = append(targets, pod)
+	}
+
+	return deletePods(targets)
+}
+
+// deletePods deletes the given pods and returns the ones that were deleted. Failures don't stop the
+// deletion of the remaining pods and are returned together.
+func deletePods(pods []v1.Pod) ([]v1.Pod, error) {
+	if err := CheckDestructiveOp("DeletePods"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var gracePeriod int64
+	var deleted []v1.Pod
+	var failed []string
+	for _, pod := range pods {
+		logrus.Infof("[debug] Deleting pod : %v", pod.Name)
+		if err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &meta_v1.DeleteOptions{
+			GracePeriodSeconds: &gracePeriod,
+		}); err != nil {
+			failed = append(failed, fmt.Sprintf("%v: %v", pod.Name, err))
+			continue
+		}
+		deleted = append(deleted, pod)
+	}
+
+	if len(failed) > 0 {
+		return deleted, fmt.Errorf("failed to delete %d of %d pods: %v", len(failed), len(pods), failed)
+	}
+
+	return deleted, nil
+}
+
+// getFailedPodVolumes returns the volumes of the pod mentioned by its warning events along with the
+// event message. Volumes are matched by volume name, claim name or the name of the bound PV.
+func getFailedPodVolumes(pod *v1.Pod) map[string]string {
+	events, err := getPodEvents(pod)
+	if err != nil {
+		return nil
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil
+	}
+
+	failed := make(map[string]string)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		names := []string{fmt.Sprintf("%q", volume.Name), volume.Name + "]", volume.PersistentVolumeClaim.ClaimName}
+		if pvc, err := client.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(
+			volume.PersistentVolumeClaim.ClaimName, meta_v1.GetOptions{}); err == nil && len(pvc.Spec.VolumeName) > 0 {
+			names = append(names, pvc.Spec.VolumeName)
+		}
+
+		for _, event := range events {
+			if event.Type != v1.EventTypeWarning {
+				continue
+			}
+
+			for _, name := range names {
+				if strings.Contains(event.Message, name) {
+					failed[volume.Name] = event.Message
+				}
+			}
+		}
+	}
+
+	return failed
+}
+
+// getPodEvents returns the events recorded for the given pod
+func getPodEvents(pod *v1.Pod) ([]v1.Event, error) {
+	return GetEventsForObject("Pod", pod.Namespace, pod.Name)
+}
+
+func isPodReady(pod v1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
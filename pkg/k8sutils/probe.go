@@ -0,0 +1,165 @@
+package k8sutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	proberPodImage     = "busybox"
+	proberPodContainer = "prober"
+	proberPodLifetime  = 10 * time.Minute
+	probeTimeout       = 10 * time.Second
+)
+
+// probeStatusRegex matches the status line of a response printed by busybox wget -S
+var probeStatusRegex = regexp.MustCompile(`HTTP/1\.[01] (\d{3})`)
+
+// ProbeServiceFromCluster sends the given number of HTTP GET requests for path to the cluster DNS name
+// of the service from a short-lived prober pod in the service's namespace. The first port of the service
+// is used. A result is returned for each attempt. A non-nil error is only returned if the prober pod
+// could not be set up or cleaned up.
+func ProbeServiceFromCluster(
+	svc *v1.Service,
+	path string,
+	expectedCode int,
+	attempts int,
+) (results []ProbeResult, err error) {
+	if len(svc.Spec.Ports) == 0 {
+		return nil, fmt.Errorf("service: %v/%v does not expose any ports", svc.Namespace, svc.Name)
+	}
+
+	pod, cleanup, err := startProberPod(svc.Namespace, svc.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if cleanupErr := cleanup(); cleanupErr != nil && err == nil {
+			err = cleanupErr
+		}
+	}()
+
+	host := fmt.Sprintf("%v.%v.svc.cluster.local", svc.Name, svc.Namespace)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	url := fmt.Sprintf("http://%v:%d%v", host, svc.Spec.Ports[0].Port, path)
+	cmd := []string{"wget", "-S", "-O", "/dev/null", "-T", strconv.Itoa(int(probeTimeout.Seconds())), url}
+
+	for i := 1; i <= attempts; i++ {
+		start := time.Now()
+		stdout, stderr, execErr := execInPod(*pod, proberPodContainer, cmd, probeTimeout+30*time.Second)
+		result := ProbeResult{
+			Attempt: i,
+			Latency: time.Since(start),
+		}
+
+		result.StatusCode, result.Err = classifyProbeOutput(host, stdout+stderr, execErr, expectedCode)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// classifyProbeOutput parses the status code out of the wget output and returns an error if the probe
+// did not get the expected status
+func classifyProbeOutput(host, output string, execErr error, expectedCode int) (int, error) {
+	statusCode := 0
+	if matches := probeStatusRegex.FindAllStringSubmatch(output, -1); len(matches) > 0 {
+		// Use the last status line in case of redirects
+		statusCode, _ = strconv.Atoi(matches[len(matches)-1][1])
+	}
+
+	probeErr := &ErrServiceProbeFailed{
+		Service:    host,
+		StatusCode: statusCode,
+		Cause:      strings.TrimSpace(output),
+	}
+
+	switch {
+	case strings.Contains(output, "bad address"):
+		probeErr.Reason = ProbeFailureDNS
+	case strings.Contains(output, "Connection refused"):
+		probeErr.Reason = ProbeFailureConnectionRefused
+	case statusCode != 0 && statusCode != expectedCode:
+		probeErr.Reason = ProbeFailureWrongStatus
+	case statusCode == expectedCode:
+		return statusCode, nil
+	default:
+		probeErr.Reason = ProbeFailureOther
+		if execErr != nil {
+			probeErr.Cause = fmt.Sprintf("%v. Output: %v", execErr, probeErr.Cause)
+		}
+	}
+
+	return statusCode, probeErr
+}
+
+// startProberPod creates a prober pod in the given namespace and waits for it to be running. The returned
+// function deletes the prober pod.
+func startProberPod(namespace, name string) (*v1.Pod, func() error, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pod, err := client.CoreV1().Pods(namespace).Create(buildProberPod(namespace, name))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cleanup := func() error {
+		var gracePeriod int64
+		err := client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &meta_v1.DeleteOptions{
+			GracePeriodSeconds: &gracePeriod,
+		})
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete prober pod: %v. Err: %v", pod.Name, err)
+		}
+		return nil
+	}
+
+	current, err := waitForPodRunning(pod, 2*time.Minute)
+	if err != nil {
+		if cleanupErr := cleanup(); cleanupErr != nil {
+			return nil, nil, fmt.Errorf("prober pod: %v failed to start: %v. Cleanup also failed: %v",
+				pod.Name, err, cleanupErr)
+		}
+		return nil, nil, err
+	}
+
+	return current, cleanup, nil
+}
+
+func buildProberPod(namespace, name string) *v1.Pod {
+	deadline := int64(proberPodLifetime.Seconds())
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: fmt.Sprintf("torpedo-prober-%v-", name),
+			Namespace:    namespace,
+			Labels: map[string]string{
+				"app": "torpedo-prober",
+			},
+		},
+		Spec: v1.PodSpec{
+			RestartPolicy:         v1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Containers: []v1.Container{
+				{
+					Name:            proberPodContainer,
+					Image:           proberPodImage,
+					ImagePullPolicy: v1.PullIfNotPresent,
+					Command:         []string{"sleep", strconv.Itoa(int(proberPodLifetime.Seconds()))},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,170 @@
+package k8sutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// envValidationProfile selects a preset profile by name (fast, default or scale)
+	envValidationProfile = "TORPEDO_VALIDATION_PROFILE"
+	// envAppReadyTimeout, envAppDeleteTimeout, envPVCBindTimeout and envRetryInterval override a single
+	// budget of the profile. They are parsed with time.ParseDuration (e.g 90s or 15m).
+	envAppReadyTimeout  = "TORPEDO_APP_READY_TIMEOUT"
+	envAppDeleteTimeout = "TORPEDO_APP_DELETE_TIMEOUT"
+	envPVCBindTimeout   = "TORPEDO_PVC_BIND_TIMEOUT"
+	envRetryInterval    = "TORPEDO_RETRY_INTERVAL"
+)
+
+var (
+	// FastProfile suits small clusters (e.g kind) where an app that isn't up in a couple of minutes won't be
+	FastProfile = Profile{
+		Name:             "fast",
+		AppReadyTimeout:  2 * time.Minute,
+		AppDeleteTimeout: 2 * time.Minute,
+		PVCBindTimeout:   time.Minute,
+		RetryInterval:    2 * time.Second,
+	}
+	// DefaultProfile is the profile used unless another one is set
+	DefaultProfile = Profile{
+		Name:             "default",
+		AppReadyTimeout:  10 * time.Minute,
+		AppDeleteTimeout: 10 * time.Minute,
+		PVCBindTimeout:   5 * time.Minute,
+		RetryInterval:    10 * time.Second,
+	}
+	// ScaleProfile suits clusters of hundreds of nodes where scheduling and provisioning are slow
+	ScaleProfile = Profile{
+		Name:             "scale",
+		AppReadyTimeout:  30 * time.Minute,
+		AppDeleteTimeout: 30 * time.Minute,
+		PVCBindTimeout:   15 * time.Minute,
+		RetryInterval:    30 * time.Second,
+	}
+)
+
+var (
+	profileLock   sync.Mutex
+	activeProfile = DefaultProfile
+	// profileLogged is set once the active profile was logged. It is reset when the profile changes.
+	profileLogged bool
+)
+
+func init() {
+	activeProfile = profileFromEnv()
+}
+
+// SetValidationProfile sets the timeouts the validations use when the caller doesn't give one. Zero
+// fields are taken from the DefaultProfile. The profile is logged the next time a validation uses it.
+func SetValidationProfile(profile Profile) {
+	profileLock.Lock()
+	defer profileLock.Unlock()
+	activeProfile = withProfileDefaults(profile)
+	profileLogged = false
+}
+
+// GetValidationProfile returns the timeouts the validations use when the caller doesn't give one
+func GetValidationProfile() Profile {
+	profileLock.Lock()
+	defer profileLock.Unlock()
+	return activeProfile
+}
+
+// LookupValidationProfile returns the preset profile with the given name
+func LookupValidationProfile(name string) (Profile, bool) {
+	for _, profile := range []Profile{FastProfile, DefaultProfile, ScaleProfile} {
+		if strings.EqualFold(profile.Name, name) {
+			return profile, true
+		}
+	}
+	return Profile{}, false
+}
+
+// validationProfile returns the active profile, logging it the first time it is used
+func validationProfile() Profile {
+	profileLock.Lock()
+	defer profileLock.Unlock()
+
+	if !profileLogged {
+		logrus.Infof("Validation profile: %v. App ready timeout: %v App delete timeout: %v "+
+			"PVC bind timeout: %v Retry interval: %v", activeProfile.Name, activeProfile.AppReadyTimeout,
+			activeProfile.AppDeleteTimeout, activeProfile.PVCBindTimeout, activeProfile.RetryInterval)
+		profileLogged = true
+	}
+
+	return activeProfile
+}
+
+// scaledDeleteTimeout returns the given timeout of a wait for a deletion, meant for the DefaultProfile,
+// scaled by the app delete timeout of the profile compared to that of the DefaultProfile. The deletions
+// that are quicker than an app's keep their default timeout and still follow the profile.
+func (p Profile) scaledDeleteTimeout(timeout time.Duration) time.Duration {
+	return time.Duration(float64(timeout) * float64(p.AppDeleteTimeout) / float64(DefaultProfile.AppDeleteTimeout))
+}
+
+// profileFromEnv returns the profile selected by the environment of the process, DefaultProfile if none
+// is. Invalid values are logged and ignored.
+func profileFromEnv() Profile {
+	profile := DefaultProfile
+	if name := os.Getenv(envValidationProfile); len(name) > 0 {
+		if preset, ok := LookupValidationProfile(name); ok {
+			profile = preset
+		} else {
+			logrus.Warnf("Ignoring unknown validation profile: %v in %v", name, envValidationProfile)
+		}
+	}
+
+	overrides := []struct {
+		env   string
+		field *time.Duration
+	}{
+		{envAppReadyTimeout, &profile.AppReadyTimeout},
+		{envAppDeleteTimeout, &profile.AppDeleteTimeout},
+		{envPVCBindTimeout, &profile.PVCBindTimeout},
+		{envRetryInterval, &profile.RetryInterval},
+	}
+	var overridden []string
+	for _, o := range overrides {
+		value := os.Getenv(o.env)
+		if len(value) == 0 {
+			continue
+		}
+
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			logrus.Warnf("Ignoring invalid duration: %v in %v", value, o.env)
+			continue
+		}
+		*o.field = d
+		overridden = append(overridden, o.env)
+	}
+	if len(overridden) > 0 {
+		profile.Name = fmt.Sprintf("%v (overridden by %v)", profile.Name, strings.Join(overridden, ", "))
+	}
+
+	return profile
+}
+
+func withProfileDefaults(profile Profile) Profile {
+	if len(profile.Name) == 0 {
+		profile.Name = "custom"
+	}
+	if profile.AppReadyTimeout == 0 {
+		profile.AppReadyTimeout = DefaultProfile.AppReadyTimeout
+	}
+	if profile.AppDeleteTimeout == 0 {
+		profile.AppDeleteTimeout = DefaultProfile.AppDeleteTimeout
+	}
+	if profile.PVCBindTimeout == 0 {
+		profile.PVCBindTimeout = DefaultProfile.PVCBindTimeout
+	}
+	if profile.RetryInterval == 0 {
+		profile.RetryInterval = DefaultProfile.RetryInterval
+	}
+	return profile
+}
@@ -0,0 +1,24 @@
+package k8sutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScaledDeleteTimeout(t *testing.T) {
+	tests := []struct {
+		profile  Profile
+		expected time.Duration
+	}{
+		{DefaultProfile, 5 * time.Minute},
+		{FastProfile, time.Minute},
+		{ScaleProfile, 15 * time.Minute},
+		{withProfileDefaults(Profile{AppReadyTimeout: time.Minute}), 5 * time.Minute},
+	}
+
+	for _, test := range tests {
+		if timeout := test.profile.scaledDeleteTimeout(5 * time.Minute); timeout != test.expected {
+			t.Errorf("%v: expected the timeout: %v, got: %v", test.profile.Name, test.expected, timeout)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/task"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// pvcBatchLabelKey is the label set on all PVCs created by one CreatePVCs call
+	pvcBatchLabelKey = "torpedo/pvc-batch"
+	// pvcCreateParallelism is the maximum number of PVCs created concurrently
+	pvcCreateParallelism = 10
+	// maxUnboundPVCsInError is the maximum number of unbound PVC names listed in the bind-wait error
+	maxUnboundPVCsInError = 10
+)
+
+// CreatePVCs creates count PVCs from the given template named <namePrefix>-<index>. All PVCs are labeled
+// with the name prefix so that ValidatePVCsBound can list them at once.
+func CreatePVCs(template *v1.PersistentVolumeClaim, count int, namePrefix string) ([]*v1.PersistentVolumeClaim, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	namespace, err := resolveCreateNamespace(client, template.Namespace, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*v1.PersistentVolumeClaim, count)
+	errs := make([]error, count)
+	sem := make(chan struct{}, pvcCreateParallelism)
+	var wg sync.WaitGroup
+
+	cloner := conversion.NewCloner()
+	for i := 0; i < count; i++ {
+		pvc := &v1.PersistentVolumeClaim{}
+		if err := v1.DeepCopy_v1_PersistentVolumeClaim(template, pvc, cloner); err != nil {
+			return nil, err
+		}
+		pvc.ResourceVersion = ""
+		pvc.Namespace = namespace
+		pvc.Name = fmt.Sprintf("%v-%d", namePrefix, i)
+		if pvc.Labels == nil {
+			pvc.Labels = make(map[string]string)
+		}
+		pvc.Labels[pvcBatchLabelKey] = namePrefix
+		stampInstanceLabel(&pvc.ObjectMeta)
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, pvc *v1.PersistentVolumeClaim) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result[i], errs[i] = client.PersistentVolumeClaims(pvc.Namespace).Create(pvc)
+		}(i, pvc)
+	}
+
+	wg.Wait()
+
+	var failed []string
+	var created []*v1.PersistentVolumeClaim
+	for i, err := range errs {
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%v-%d: %v", namePrefix, i, err))
+			continue
+		}
+		created = append(created, result[i])
+	}
+
+	if len(failed) > 0 {
+		return created, fmt.Errorf("failed to create %d of %d PVCs: %v", len(failed), count, failed)
+	}
+
+	return created, nil
+}
+
+// ValidatePVCsBound waits for all the given PVCs to be bound. PVCs created by CreatePVCs are fetched with
+// a single list call per attempt.
+func ValidatePVCsBound(pvcs []*v1.PersistentVolumeClaim, timeout time.Duration) error {
+	if len(pvcs) == 0 {
+		return nil
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(pvcs[0].Namespace)
+	listOptions := meta_v1.ListOptions{}
+	if batch, ok := pvcs[0].Labels[pvcBatchLabelKey]; ok {
+		listOptions.LabelSelector = fmt.Sprintf("%v=%v", pvcBatchLabelKey, batch)
+	}
+
+	t := func() error {
+		list, err := client.PersistentVolumeClaims(namespace).List(listOptions)
+		if err != nil {
+			return err
+		}
+
+		phases := make(map[string]v1.PersistentVolumeClaimPhase)
+		for _, pvc := range list.Items {
+			phases[pvc.Name] = pvc.Status.Phase
+		}
+
+		var unbound []string
+		for _, pvc := range pvcs {
+			if phases[pvc.Name] != v1.ClaimBound {
+				unbound = append(unbound, pvc.Name)
+			}
+		}
+
+		if len(unbound) > 0 {
+			return newErrPVCsNotBound(unbound, len(pvcs))
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 10*time.Second); err != nil {
+		if unboundErr, ok := task.LastError(err).(*ErrPVCsNotBound); ok {
+			return unboundErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+func newErrPVCsNotBound(unbound []string, total int) *ErrPVCsNotBound {
+	e := &ErrPVCsNotBound{
+		Unbound: unbound,
+		Count:   len(unbound),
+		Total:   total,
+	}
+	if len(e.Unbound) > maxUnboundPVCsInError {
+		e.Unbound = e.Unbound[:maxUnboundPVCsInError]
+	}
+	return e
+}
+
+// ValidateDeletedPersistentVolumeClaim waits for the given deleted PVC to be gone, up to the given timeout
+// or the app delete timeout of the validation profile if zero. A PVC still used by pods is kept
+// terminating by its pvc-protection finalizer until the pods are gone. In that state, the
+// ErrPVCNotDeleted lists the consuming pods instead of timing out without a cause. With
+// WithCascadeDeleteConsumers, the consuming pods are deleted so the PVC can go away.
+func ValidateDeletedPersistentVolumeClaim(pvc *v1.PersistentVolumeClaim, timeout time.Duration, opts ...DeleteOption) error {
+	o := &deleteOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppDeleteTimeout
+	}
+
+	namespace := namespaceOrDefault(pvc.Namespace)
+	deletedConsumers := make(map[types.UID]bool)
+	t := func() error {
+		current, err := client.PersistentVolumeClaims(namespace).Get(pvc.Name, meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.DeletionTimestamp == nil {
+			return &ErrPVCNotDeleted{
+				Name:  current.Name,
+				Cause: "PVC is not being deleted",
+			}
+		}
+
+		if !isProtectedOnly(current.ObjectMeta) {
+			return &ErrPVCNotDeleted{
+				Name:  current.Name,
+				Cause: fmt.Sprintf("PVC is terminating. Finalizers: %v", current.Finalizers),
+			}
+		}
+
+		consumers, err := getPVCConsumers(client, current)
+		if err != nil {
+			return err
+		}
+		if len(consumers) == 0 {
+			return &ErrPVCNotDeleted{
+				Name:  current.Name,
+				Cause: "PVC is terminating and no pod uses it anymore",
+			}
+		}
+
+		var names []string
+		var toDelete []v1.Pod
+		for _, pod := range consumers {
+			names = append(names, pod.Name)
+			if o.cascadeConsumers && !deletedConsumers[pod.UID] {
+				toDelete = append(toDelete, pod)
+			}
+		}
+
+		if len(toDelete) > 0 {
+			logrus.Infof("Deleting %d pods using deleted PVC: %v", len(toDelete), current.Name)
+			deleted, err := deletePods(toDelete)
+			for _, pod := range deleted {
+				deletedConsumers[pod.UID] = true
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		return &ErrPVCNotDeleted{
+			Name:  current.Name,
+			Cause: fmt.Sprintf("waiting for consuming pods: %v", names),
+		}
+	}
+
+	return doRetryWithTimeout(t, timeout, profile.RetryInterval)
+}
+
+// isProtectedOnly checks if the only finalizer of the object is the pvc-protection finalizer
+func isProtectedOnly(meta meta_v1.ObjectMeta) bool {
+	return len(meta.Finalizers) == 1 && meta.Finalizers[0] == pvcProtectionFinalizer
+}
+
+// getPVCConsumers returns the pods that keep the PVC protected from deletion, i.e the pods using it that
+// are not terminated
+func getPVCConsumers(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim) ([]v1.Pod, error) {
+	pods, err := getPodsUsingPVC(client, pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	var consumers []v1.Pod
+	for _, pod := range pods {
+		if pod.Status.Phase != v1.PodSucceeded && pod.Status.Phase != v1.PodFailed {
+			consumers = append(consumers, pod)
+		}
+	}
+	return consumers, nil
+}
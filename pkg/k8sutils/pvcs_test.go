@@ -0,0 +1,295 @@
+package k8sutils
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	"github.com/portworx/torpedo/pkg/task"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// newTestProtectedPVC returns a PVC that is terminating but kept by the given finalizers, as the PVC
+// protection admission controller does for a deleted PVC that pods still use
+func newTestProtectedPVC(name string, finalizers ...string) *v1.PersistentVolumeClaim {
+	now := meta_v1.Now()
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:              name,
+			Namespace:         testNamespace,
+			DeletionTimestamp: &now,
+			Finalizers:        finalizers,
+		},
+	}
+}
+
+// newTestPVCConsumer returns a running pod of the deployment mounting the given PVC
+func newTestPVCConsumer(name, claim string) *v1.Pod {
+	dep := newTestDeployment("web", "dep-uid", 1)
+	pod := newTestPod(newTestReplicaSet(dep, "abc", "rs-uid", 1), name, "node1")
+	pod.Spec.Volumes = []v1.Volume{{
+		Name: "data",
+		VolumeSource: v1.VolumeSource{
+			PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+		},
+	}}
+	return pod
+}
+
+func TestValidateDeletedPersistentVolumeClaimReportsConsumers(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := newTestProtectedPVC("data", pvcProtectionFinalizer)
+	done := newTestPVCConsumer("web-abc-1", "data")
+	done.Status.Phase = v1.PodSucceeded
+	server.Add(pvc, newTestPVCConsumer("web-abc-2", "data"), newTestPVCConsumer("other", "logs"), done)
+
+	err := ValidateDeletedPersistentVolumeClaim(pvc, 100*time.Millisecond)
+	if !task.IsTimedOut(err) {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+	notDeleted, ok := task.LastError(err).(*ErrPVCNotDeleted)
+	if !ok || notDeleted.Cause != "waiting for consuming pods: [web-abc-2]" {
+		t.Fatalf("expected the consuming pods to be reported, got: %v", err)
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodDelete {
+			t.Errorf("expected no pods to be deleted without cascade, got: %+v", req)
+		}
+	}
+}
+
+func TestValidateDeletedPersistentVolumeClaimCascadesToConsumers(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := newTestProtectedPVC("data", pvcProtectionFinalizer)
+	server.Add(pvc, newTestPVCConsumer("web-abc-1", "data"), newTestPVCConsumer("web-abc-2", "data"))
+
+	// The protection controller releases the PVC once the last consumer is gone
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method == http.MethodDelete && req.Resource == "pods" && server.Count("pods") == 1 {
+			server.Remove("persistentvolumeclaims", testNamespace, pvc.Name)
+		}
+		return false, 0, nil
+	})
+
+	if err := ValidateDeletedPersistentVolumeClaim(pvc, time.Second, WithCascadeDeleteConsumers()); err != nil {
+		t.Fatalf("expected the PVC to be gone once its consumers are deleted, got: %v", err)
+	}
+
+	var deleted []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodDelete {
+			deleted = append(deleted, req.Name)
+		}
+	}
+	if len(deleted) != 2 || deleted[0] != "web-abc-1" || deleted[1] != "web-abc-2" {
+		t.Errorf("expected each consumer to be deleted once, got: %v", deleted)
+	}
+}
+
+func TestValidateDeletedPersistentVolumeClaimOtherFinalizers(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := newTestProtectedPVC("data", pvcProtectionFinalizer, "example.com/backup")
+	server.Add(pvc, newTestPVCConsumer("web-abc-1", "data"))
+
+	err := ValidateDeletedPersistentVolumeClaim(pvc, 100*time.Millisecond, WithCascadeDeleteConsumers())
+	notDeleted, ok := task.LastError(err).(*ErrPVCNotDeleted)
+	if !ok || notDeleted.Cause != "PVC is terminating. Finalizers: [kubernetes.io/pvc-protection example.com/backup]" {
+		t.Fatalf("expected the finalizers to be reported, got: %v", err)
+	}
+	if server.Count("pods") != 1 {
+		t.Errorf("expected the consumer to be kept if other finalizers hold the PVC")
+	}
+}
+
+func TestDeletePersistentVolumeClaimSafeForceDeletesOtherInstances(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defer SetInstanceID(getInstanceID())
+	SetInstanceID("mine")
+
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: meta_v1.ObjectMeta{
+		Name:      "data",
+		Namespace: testNamespace,
+		Labels:    map[string]string{instanceLabelKey: "other"},
+	}}
+	server.Add(pvc)
+
+	if _, ok := DeletePersistentVolumeClaimSafe(pvc, 0).(*ErrNotOwned); !ok {
+		t.Fatalf("expected an ErrNotOwned without force")
+	}
+
+	if err := DeletePersistentVolumeClaimSafe(pvc, 0, WithForceDelete()); err != nil {
+		t.Fatalf("failed to force the deletion: %v", err)
+	}
+	if server.Get("persistentvolumeclaims", testNamespace, "data", &v1.PersistentVolumeClaim{}) {
+		t.Errorf("expected the claim to be deleted")
+	}
+}
+
+// addTestBindingStorageClass creates a storage class with the given volume binding mode. The class is
+// created raw as the storage API types of the package don't have the field.
+func addTestBindingStorageClass(t *testing.T, name, mode string) {
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"metadata": {"name": %q}, "provisioner": "px", "volumeBindingMode": %q}`, name, mode)
+	if err := client.StorageV1beta1().RESTClient().Post().Resource("storageclasses").
+		Body([]byte(body)).Do().Error(); err != nil {
+		t.Fatalf("failed to create storage class: %v: %v", name, err)
+	}
+}
+
+// newTestPendingPVC returns a pending PVC of the given storage class
+func newTestPendingPVC(name, storageClass string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{Name: name, Namespace: testNamespace},
+		Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &storageClass},
+		Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	}
+}
+
+func TestValidatePersistentVolumeClaimWaitingForFirstConsumer(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	addTestBindingStorageClass(t, "px-wffc", k8sVolumeBindingWaitForFirstConsumer)
+	pvc := newTestPendingPVC("data", "px-wffc")
+	server.Add(pvc)
+
+	if err := ValidatePersistentVolumeClaim(pvc); err != nil {
+		t.Errorf("expected the pending claim without consumer to be valid, got: %v", err)
+	}
+}
+
+func TestValidatePersistentVolumeClaimAfterConsumerPending(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	addTestBindingStorageClass(t, "px-wffc", k8sVolumeBindingWaitForFirstConsumer)
+	pvc := newTestPendingPVC("data", "px-wffc")
+	consumer := newTestPVCConsumer("web-abc-1", "data")
+	server.Add(pvc, consumer)
+
+	err := ValidatePersistentVolumeClaimAfterConsumer(pvc, consumer)
+	if !task.IsTimedOut(err) {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+	notReady, ok := task.LastError(err).(*ErrPVCNotReady)
+	if !ok || !strings.Contains(notReady.Cause, "Consumer pod: web-abc-1 is on node: node1") {
+		t.Fatalf("expected the scheduled consumer to be reported, got: %v", err)
+	}
+
+	// The claim is not waiting for a consumer anymore
+	if err := ValidatePersistentVolumeClaim(pvc); err == nil {
+		t.Errorf("expected the pending claim with a consumer to be invalid")
+	}
+
+	pvc.Status.Phase = v1.ClaimBound
+	server.Add(pvc)
+	if err := ValidatePersistentVolumeClaimAfterConsumer(pvc, consumer); err != nil {
+		t.Errorf("expected the bound claim to be valid, got: %v", err)
+	}
+}
+
+func TestValidatePersistentVolumeClaimImmediatePending(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	addTestBindingStorageClass(t, "px", "Immediate")
+	pvc := newTestPendingPVC("data", "px")
+	server.Add(pvc)
+
+	err := ValidatePersistentVolumeClaim(pvc)
+	if !task.IsTimedOut(err) {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+	if _, ok := task.LastError(err).(*ErrPVCNotReady); !ok {
+		t.Errorf("expected an ErrPVCNotReady, got: %v", err)
+	}
+}
+
+func TestGetPodsUsingPVC(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := newTestPendingPVC("data", "px")
+	server.Add(pvc, newTestPVCConsumer("web-abc-1", "data"), newTestPVCConsumer("other", "logs"))
+
+	pods, err := GetPodsUsingPVC(pvc)
+	if err != nil {
+		t.Fatalf("failed to get the pods using the claim: %v", err)
+	}
+	expected := []string{"web-abc-1"}
+	if names := podNames(pods); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected pods: %v, got: %v", expected, names)
+	}
+}
+
+func TestWaitForPVCUnusedAfterConsumerDeleted(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := newTestPendingPVC("data", "px")
+	server.Add(pvc, newTestPVCConsumer("web-abc-1", "data"))
+
+	// Delete the consumer after the first attempt saw it
+	var lock sync.Mutex
+	lists := 0
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "pods" || req.Method != http.MethodGet {
+			return false, 0, nil
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if lists++; lists == 2 {
+			server.Remove("pods", testNamespace, "web-abc-1")
+		}
+		return false, 0, nil
+	})
+
+	if err := WaitForPVCUnused(pvc, 30*time.Second); err != nil {
+		t.Errorf("expected the claim to be unused once its consumer is deleted, got: %v", err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if lists != 2 {
+		t.Errorf("expected to wait for the consumer to be deleted, got %d attempts", lists)
+	}
+}
+
+func TestWaitForPVCUnusedTimesOut(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := newTestPendingPVC("data", "px")
+	server.Add(pvc, newTestPVCConsumer("web-abc-1", "data"), newTestPVCConsumer("web-abc-2", "data"))
+
+	err := WaitForPVCUnused(pvc, 100*time.Millisecond)
+	if !task.IsTimedOut(err) {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+	inUse, ok := task.LastError(err).(*ErrPVCInUse)
+	if !ok || !reflect.DeepEqual(inUse.Pods, []string{"web-abc-1", "web-abc-2"}) {
+		t.Fatalf("expected the users of the claim to be reported, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "[web-abc-1 web-abc-2]") {
+		t.Errorf("expected the users of the claim in the error, got: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// ValidatePVCAccessModes validates that the volume bound to the given pvc supports all the expected
+// access modes
+func ValidatePVCAccessModes(pvc *v1.PersistentVolumeClaim, expected []v1.PersistentVolumeAccessMode) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	current, err := getBoundPVC(client, pvc)
+	if err != nil {
+		return err
+	}
+
+	return checkPVCAccessModes(current, expected)
+}
+
+// ValidatePVCVolumeMode validates that the given pvc is bound and has the expected volume mode
+func ValidatePVCVolumeMode(pvc *v1.PersistentVolumeClaim, expected PVCVolumeMode) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	current, err := getBoundPVC(client, pvc)
+	if err != nil {
+		return err
+	}
+
+	return checkPVCVolumeMode(client, current, expected)
+}
+
+// ValidatePVCSpec validates that the given pvc is bound and has the expected size, access modes, storage
+// class and volume mode
+func ValidatePVCSpec(pvc *v1.PersistentVolumeClaim, expected PVCExpectations) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	current, err := getBoundPVC(client, pvc)
+	if err != nil {
+		return err
+	}
+
+	if !expected.Size.IsZero() {
+		capacity := current.Status.Capacity[v1.ResourceStorage]
+		if capacity.Cmp(expected.Size) < 0 {
+			return &ErrPVCSpecMismatch{
+				ID:       current.Name,
+				Field:    "size",
+				Expected: expected.Size.String(),
+				Actual:   capacity.String(),
+			}
+		}
+	}
+
+	if len(expected.StorageClass) > 0 {
+		scName, _ := getPVCStorageClassName(current)
+		if scName != expected.StorageClass {
+			return &ErrPVCSpecMismatch{
+				ID:       current.Name,
+				Field:    "storage class",
+				Expected: expected.StorageClass,
+				Actual:   scName,
+			}
+		}
+	}
+
+	if err := checkPVCAccessModes(current, expected.AccessModes); err != nil {
+		return err
+	}
+
+	if len(expected.VolumeMode) > 0 {
+		return checkPVCVolumeMode(client, current, expected.VolumeMode)
+	}
+
+	return nil
+}
+
+// getBoundPVC returns the current state of the given pvc if it is bound
+func getBoundPVC(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, error) {
+	current, err := client.CoreV1().PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if current.Status.Phase != v1.ClaimBound {
+		return nil, &ErrPVCNotBound{
+			ID:    current.Name,
+			Cause: fmt.Sprintf("PVC is in phase: %v", current.Status.Phase),
+		}
+	}
+
+	return current, nil
+}
+
+func checkPVCAccessModes(pvc *v1.PersistentVolumeClaim, expected []v1.PersistentVolumeAccessMode) error {
+	for _, mode := range expected {
+		found := false
+		for _, actual := range pvc.Status.AccessModes {
+			if actual == mode {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return &ErrPVCSpecMismatch{
+				ID:       pvc.Name,
+				Field:    "access modes",
+				Expected: fmt.Sprintf("%v", expected),
+				Actual:   fmt.Sprintf("%v", pvc.Status.AccessModes),
+			}
+		}
+	}
+
+	return nil
+}
+
+func checkPVCVolumeMode(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim, expected PVCVolumeMode) error {
+	mode, err := getPVCVolumeMode(client, pvc)
+	if err != nil {
+		return err
+	}
+
+	if mode != expected {
+		return &ErrPVCSpecMismatch{
+			ID:       pvc.Name,
+			Field:    "volume mode",
+			Expected: string(expected),
+			Actual:   string(mode),
+		}
+	}
+
+	return nil
+}
+
+// getPVCVolumeMode returns the volumeMode of the pvc. The field is read from the raw object as it is newer
+// than the core API types this package is built with.
+func getPVCVolumeMode(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim) (PVCVolumeMode, error) {
+	raw, err := client.CoreV1().RESTClient().Get().
+		Namespace(pvc.Namespace).
+		Resource("persistentvolumeclaims").
+		Name(pvc.Name).
+		DoRaw()
+	if err != nil {
+		return "", err
+	}
+
+	var claim struct {
+		Spec struct {
+			VolumeMode PVCVolumeMode `json:"volumeMode"`
+		} `json:"spec"`
+	}
+	if err := json.Unmarshal(raw, &claim); err != nil {
+		return "", err
+	}
+
+	if len(claim.Spec.VolumeMode) == 0 {
+		return PVCVolumeModeFilesystem, nil
+	}
+
+	return claim.Spec.VolumeMode, nil
+}
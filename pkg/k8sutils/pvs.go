@@ -0,0 +1,112 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// GetPersistentVolume returns the current state of the persistent volume with the given name
+func GetPersistentVolume(name string) (*v1.PersistentVolume, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().PersistentVolumes().Get(name, meta_v1.GetOptions{})
+}
+
+// ListPersistentVolumes returns the persistent volumes matching the given label selector, all of them if
+// empty. Dynamically provisioned PVs don't have the torpedo instance label, so they are not filtered by
+// instance.
+func ListPersistentVolumes(selector string) ([]v1.PersistentVolume, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pvs, err := client.CoreV1().PersistentVolumes().List(meta_v1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pvs.Items, nil
+}
+
+// ValidatePersistentVolumeDeleted validates that the given PV, e.g bound to a PVC that was just deleted, is
+// reclaimed per its reclaim policy: a PV with the Delete policy is deleted, with the Retain policy it is
+// released and kept and with the Recycle policy it is available again. Give the PV as it was before the PVC
+// was deleted so that its policy is known even once it is gone. It waits up to the given timeout or the app
+// delete timeout of the validation profile if zero.
+func ValidatePersistentVolumeDeleted(pv *v1.PersistentVolume, timeout time.Duration) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppDeleteTimeout
+	}
+
+	policy := pv.Spec.PersistentVolumeReclaimPolicy
+	var failed error
+	t := func() error {
+		current, err := client.CoreV1().PersistentVolumes().Get(pv.Name, meta_v1.GetOptions{})
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			return err
+		}
+
+		// A PV recreated with the same name is another volume
+		if k8s_errors.IsNotFound(err) || (len(pv.UID) > 0 && current.UID != pv.UID) {
+			if policy == v1.PersistentVolumeReclaimRetain {
+				// A deleted retained PV won't come back, so stop retrying
+				failed = &ErrPVNotReclaimed{
+					Name:   pv.Name,
+					Policy: policy,
+					Cause:  "retained PV was deleted",
+				}
+			}
+			return nil
+		}
+
+		if len(policy) == 0 {
+			policy = current.Spec.PersistentVolumeReclaimPolicy
+		}
+
+		expected := v1.VolumeReleased
+		switch policy {
+		case v1.PersistentVolumeReclaimRetain:
+		case v1.PersistentVolumeReclaimRecycle:
+			expected = v1.VolumeAvailable
+		default:
+			return &ErrPVNotReclaimed{
+				Name:   current.Name,
+				Policy: policy,
+				Cause:  fmt.Sprintf("PV still exists in phase: %v %v", current.Status.Phase, current.Status.Message),
+			}
+		}
+
+		if current.Status.Phase != expected {
+			return &ErrPVNotReclaimed{
+				Name:   current.Name,
+				Policy: policy,
+				Cause:  fmt.Sprintf("PV expected phase: %v PV actual phase: %v", expected, current.Status.Phase),
+			}
+		}
+		return nil
+	}
+
+	t, complete := observeValidation("deleted-pv/"+pv.Name, t)
+	err = doRetryWithTimeout(t, timeout, profile.RetryInterval)
+	if err == nil {
+		err = failed
+	}
+	complete(err)
+	return err
+}
@@ -0,0 +1,71 @@
+package k8sutils
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+type validateOptions struct {
+	// containers are the names of the containers that must be ready. All containers if empty.
+	containers []string
+}
+
+// WithReadyContainers limits the readiness checks of a validation to the given containers of the pods so
+// that optional sidecars that are not ready don't fail the validation
+func WithReadyContainers(containers ...string) ValidateOption {
+	return func(o *validateOptions) {
+		o.containers = containers
+	}
+}
+
+func newValidateOptions(opts []ValidateOption) *validateOptions {
+	o := &validateOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// IsPodContainersReady checks if the given containers of the pod are running and ready. An error is
+// returned if any of the containers is not part of the pod.
+func IsPodContainersReady(pod v1.Pod, containers []string) (bool, error) {
+	if err := checkContainerNames(pod.Name, pod.Spec.Containers, containers); err != nil {
+		return false, err
+	}
+
+	statuses := make(map[string]v1.ContainerStatus)
+	for _, c := range pod.Status.ContainerStatuses {
+		statuses[c.Name] = c
+	}
+
+	for _, name := range containers {
+		status, ok := statuses[name]
+		if !ok || status.State.Running == nil || !status.Ready {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// checkContainerNames checks that all the given names are containers of the pod or pod template
+func checkContainerNames(owner string, podContainers []v1.Container, names []string) error {
+	existing := make(map[string]bool)
+	for _, c := range podContainers {
+		existing[c.Name] = true
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !existing[name] {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%v does not have containers: %v", owner, missing)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package k8sutils
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// runningStatus returns the status of a running container
+func runningStatus(name string, ready bool) v1.ContainerStatus {
+	return v1.ContainerStatus{
+		Name:  name,
+		Ready: ready,
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+	}
+}
+
+// crashLoopingStatus returns the status of a container restarting after failures
+func crashLoopingStatus(name string) v1.ContainerStatus {
+	return v1.ContainerStatus{
+		Name:         name,
+		RestartCount: 5,
+		State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}
+}
+
+// newTestSidecarPod returns a pod of the app and sidecar containers with the given statuses
+func newTestSidecarPod(statuses ...v1.ContainerStatus) v1.Pod {
+	return v1.Pod{
+		Spec: v1.PodSpec{
+			Containers: []v1.Container{{Name: "app"}, {Name: "sidecar"}},
+		},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			ContainerStatuses: statuses,
+		},
+	}
+}
+
+func TestIsPodContainersReady(t *testing.T) {
+	tests := []struct {
+		name       string
+		pod        v1.Pod
+		containers []string
+		expected   bool
+	}{
+		{
+			name:       "all ready",
+			pod:        newTestSidecarPod(runningStatus("app", true), runningStatus("sidecar", true)),
+			containers: []string{"app", "sidecar"},
+			expected:   true,
+		},
+		{
+			name:       "sidecar crash looping",
+			pod:        newTestSidecarPod(runningStatus("app", true), crashLoopingStatus("sidecar")),
+			containers: []string{"app"},
+			expected:   true,
+		},
+		{
+			name:       "sidecar not ready",
+			pod:        newTestSidecarPod(runningStatus("app", true), runningStatus("sidecar", false)),
+			containers: []string{"app"},
+			expected:   true,
+		},
+		{
+			name:       "listed container not ready",
+			pod:        newTestSidecarPod(runningStatus("app", false), runningStatus("sidecar", true)),
+			containers: []string{"app"},
+		},
+		{
+			name:       "listed container crash looping",
+			pod:        newTestSidecarPod(crashLoopingStatus("app"), runningStatus("sidecar", true)),
+			containers: []string{"app"},
+		},
+		{
+			name:       "listed container without status",
+			pod:        newTestSidecarPod(runningStatus("sidecar", true)),
+			containers: []string{"app"},
+		},
+	}
+
+	for _, test := range tests {
+		ready, err := IsPodContainersReady(test.pod, test.containers)
+		if err != nil || ready != test.expected {
+			t.Errorf("%v: expected ready: %v, got: %v, %v", test.name, test.expected, ready, err)
+		}
+	}
+
+	pod := newTestSidecarPod(runningStatus("app", true), runningStatus("sidecar", true))
+	pod.Name = "web-1"
+	_, err := IsPodContainersReady(pod, []string{"app", "proxy"})
+	if err == nil || !strings.Contains(err.Error(), "web-1 does not have containers: [proxy]") {
+		t.Errorf("expected an error for a container not in the pod, got: %v", err)
+	}
+}
+
+func TestValidateDeploymentWithReadyContainers(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	// The sidecar of the only pod is crash looping, so the deployment reports no ready replica
+	dep := newTestDeployment("web", "dep-uid", 1)
+	dep.Spec.Template.Spec.Containers = append(dep.Spec.Template.Spec.Containers, v1.Container{Name: "sidecar"})
+	dep.Status.ReadyReplicas = 0
+	dep.Status.AvailableReplicas = 0
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	pod := newTestPod(rs, "web-abc-1", "node1")
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{runningStatus("app", true), crashLoopingStatus("sidecar")}
+	server.Add(dep, rs, pod)
+
+	if err := ValidateDeployement(dep); !task.IsTimedOut(err) {
+		t.Fatalf("expected the validation of all containers to time out, got: %v", err)
+	}
+
+	if err := ValidateDeployement(dep, WithReadyContainers("app")); err != nil {
+		t.Fatalf("expected the app container to be validated, got: %v", err)
+	}
+
+	start := time.Now()
+	err := ValidateDeployement(dep, WithReadyContainers("app", "proxy"))
+	if err == nil || !strings.Contains(err.Error(), "does not have containers: [proxy]") {
+		t.Fatalf("expected an error for a container not in the deployment, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= testProfile.AppReadyTimeout {
+		t.Errorf("expected the unknown container to fail immediately, took %v", elapsed)
+	}
+}
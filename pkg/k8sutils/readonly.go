@@ -0,0 +1,191 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/task"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	// writeProbeFile is the file created and removed in a mount to check that it is writable
+	writeProbeFile              = ".torpedo-write-probe"
+	writeProbeTimeout           = 30 * time.Second
+	readOnlyRecoveryRetryPeriod = 10 * time.Second
+	// readOnlyFileSystemError is the error of a write to a read-only file system
+	readOnlyFileSystemError = "Read-only file system"
+)
+
+// DetectReadOnlyMounts probes, in each running pod of the given deployment, the mounts of its PVCs that
+// the pod spec doesn't declare as read-only by creating and removing a file in them
+func DetectReadOnlyMounts(deployment *v1beta1.Deployment) ([]PodMountState, error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []PodMountState
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		states = append(states, probePodMounts(pod)...)
+	}
+
+	return states, nil
+}
+
+// ValidateRecoveryFromReadOnly checks the mounts of the given deployment and, if any of them are
+// read-only, deletes the affected pods and probes the mounts of the deployment again until they are all
+// read-write or the timeout hits
+func ValidateRecoveryFromReadOnly(deployment *v1beta1.Deployment, timeout time.Duration) error {
+	states, err := DetectReadOnlyMounts(deployment)
+	if err != nil {
+		return err
+	}
+
+	affected := make(map[string]bool)
+	for _, state := range states {
+		if state.State == MountStateReadOnly {
+			affected[state.Pod] = true
+		}
+	}
+
+	if len(affected) == 0 {
+		return nil
+	}
+
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[types.UID]bool)
+	var targets []v1.Pod
+	var names []string
+	for _, pod := range pods {
+		if affected[pod.Name] {
+			targets = append(targets, pod)
+			names = append(names, pod.Name)
+			deleted[pod.UID] = true
+		}
+	}
+
+	logrus.Infof("Deleting pods: %v of app: %v with read-only mounts", names, deployment.Name)
+	if err := DeletePods(targets); err != nil {
+		return err
+	}
+
+	t := func() error {
+		pods, err := GetDeploymentPods(deployment)
+		if err != nil {
+			return err
+		}
+
+		for _, pod := range pods {
+			if deleted[pod.UID] {
+				return fmt.Errorf("pod: %v with read-only mounts is not deleted yet", pod.Name)
+			}
+		}
+
+		report := &DeploymentStatusReport{
+			PodNodes: make(map[string]string),
+		}
+		if err := checkDeploymentReady(deployment, report, &validateOptions{}); err != nil {
+			return err
+		}
+
+		states, err := DetectReadOnlyMounts(deployment)
+		if err != nil {
+			return err
+		}
+
+		var failing []PodMountState
+		for _, state := range states {
+			if state.State != MountStateReadWrite {
+				failing = append(failing, state)
+			}
+		}
+
+		if len(failing) > 0 {
+			return &ErrReadOnlyMounts{
+				App:    deployment.Name,
+				Mounts: failing,
+			}
+		}
+
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, readOnlyRecoveryRetryPeriod); err != nil {
+		if mountsErr, ok := task.LastError(err).(*ErrReadOnlyMounts); ok {
+			return mountsErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// probePodMounts probes the PVC mounts of each container of the pod
+func probePodMounts(pod v1.Pod) []PodMountState {
+	claims := make(map[string]string)
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims[volume.Name] = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+
+	var states []PodMountState
+	for _, container := range pod.Spec.Containers {
+		for _, mount := range container.VolumeMounts {
+			claim, ok := claims[mount.Name]
+			if !ok || mount.ReadOnly {
+				continue
+			}
+
+			state := PodMountState{
+				Pod:       pod.Name,
+				Container: container.Name,
+				PVC:       claim,
+				MountPath: mount.MountPath,
+			}
+			state.State, state.Cause = probeMount(pod, container.Name, mount.MountPath)
+			states = append(states, state)
+		}
+	}
+
+	return states
+}
+
+// probeMount creates and removes a file in the mount path and classifies the mount by the result
+func probeMount(pod v1.Pod, container, mountPath string) (MountState, string) {
+	file := strings.TrimSuffix(mountPath, "/") + "/" + writeProbeFile
+	cmd := writeProbeCommand(file)
+
+	stdout, stderr, err := execInPod(pod, container, cmd, writeProbeTimeout)
+	if err == nil {
+		return MountStateReadWrite, ""
+	}
+
+	output := strings.TrimSpace(stdout + stderr)
+	if strings.Contains(output, readOnlyFileSystemError) {
+		return MountStateReadOnly, output
+	}
+
+	if len(output) == 0 {
+		output = err.Error()
+	}
+	return MountStateError, output
+}
+
+// writeProbeCommand returns the command creating and removing the given file. The file is passed as a
+// positional parameter of the script so that it is never parsed by the shell.
+func writeProbeCommand(file string) []string {
+	return []string{"sh", "-c", `touch "$1" && rm -f "$1"`, "sh", file}
+}
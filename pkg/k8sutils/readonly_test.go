@@ -0,0 +1,41 @@
+package k8sutils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteProbeCommandQuotesPath(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	dir, err := ioutil.TempDir("", "torpedo-write-probe")
+	if err != nil {
+		t.Fatalf("failed to create the mount dir: %v", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Errorf("failed to remove: %v: %v", dir, err)
+		}
+	}()
+
+	for _, name := range []string{"plain", "it's", `a "quoted" $path`, "semi;colon && x"} {
+		mount := filepath.Join(dir, name)
+		if err := os.Mkdir(mount, 0755); err != nil {
+			t.Fatalf("failed to create mount: %v: %v", mount, err)
+		}
+
+		file := filepath.Join(mount, writeProbeFile)
+		cmd := writeProbeCommand(file)
+		if output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput(); err != nil {
+			t.Errorf("%v: expected the probe to succeed, got: %v: %s", name, err, output)
+		}
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("%v: expected the probe file to be removed, got: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+type nodeReadyWatch struct {
+	sync.Mutex
+	nodeName    string
+	transitions []NodeReadyTransition
+	// ready and lastTransitionTime are the last seen status and transition time of the Ready condition
+	ready              bool
+	lastTransitionTime time.Time
+	quit               chan struct{}
+	done               chan struct{}
+}
+
+// GetPodContainerIDs returns the UIDs of the pods of the given deployment and the IDs of their running
+// containers, e.g to check with ValidatePodsUnchanged that the pods survived a node component restart
+func GetPodContainerIDs(deployment *v1beta1.Deployment) ([]PodContainerSnapshot, error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot []PodContainerSnapshot
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		snapshot = append(snapshot, getPodContainerSnapshot(pod))
+	}
+
+	return snapshot, nil
+}
+
+// ValidatePodsUnchanged validates that the pods of the given snapshot still exist with the same UIDs and
+// that none of their containers were restarted, i.e had their container ID change. Only the bare IDs are
+// compared, whatever the runtime of the node. An ErrPodsChanged lists the replaced pods and the restarted
+// containers.
+func ValidatePodsUnchanged(deployment *v1beta1.Deployment, snapshot []PodContainerSnapshot) error {
+	current, err := GetPodContainerIDs(deployment)
+	if err != nil {
+		return err
+	}
+
+	byUID := make(map[types.UID]PodContainerSnapshot)
+	for _, pod := range current {
+		byUID[pod.UID] = pod
+	}
+
+	var replaced []string
+	restarted := make(map[string][]string)
+	for _, before := range snapshot {
+		after, ok := byUID[before.UID]
+		if !ok {
+			replaced = append(replaced, before.Pod)
+			continue
+		}
+
+		for container, id := range before.ContainerIDs {
+			if !sameContainerID(after.ContainerIDs[container], id) {
+				restarted[before.Pod] = append(restarted[before.Pod], container)
+			}
+		}
+		sort.Strings(restarted[before.Pod])
+	}
+
+	if len(replaced) > 0 || len(restarted) > 0 {
+		sort.Strings(replaced)
+		return &ErrPodsChanged{
+			App:                 deployment.Name,
+			ReplacedPods:        replaced,
+			RestartedContainers: restarted,
+		}
+	}
+
+	return nil
+}
+
+// StartNodeReadyWatch watches the Ready condition of the given node and records its changes. A change is
+// also recorded if the transition time of the condition moved while the watch was not connected. The
+// returned function stops the watch and returns the recorded transitions.
+func StartNodeReadyWatch(nodeName string) (func() []NodeReadyTransition, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	node, err := client.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	w := &nodeReadyWatch{
+		nodeName: nodeName,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if condition := getNodeReadyCondition(node); condition != nil {
+		w.ready = condition.Status == v1.ConditionTrue
+		w.lastTransitionTime = condition.LastTransitionTime.Time
+	}
+
+	go w.run(client)
+
+	var once sync.Once
+	var transitions []NodeReadyTransition
+	return func() []NodeReadyTransition {
+		once.Do(func() {
+			close(w.quit)
+			<-w.done
+			w.Lock()
+			transitions = w.transitions
+			w.Unlock()
+		})
+		return transitions
+	}, nil
+}
+
+// ValidateNodeFlapped validates that the recorded transitions show the node going NotReady and then Ready
+// again, e.g to prove that its kubelet was restarted
+func ValidateNodeFlapped(nodeName string, transitions []NodeReadyTransition) error {
+	notReady := false
+	for _, t := range transitions {
+		if !t.Ready {
+			notReady = true
+		} else if notReady {
+			return nil
+		}
+	}
+
+	if notReady {
+		return fmt.Errorf("node: %v went NotReady but did not become Ready again", nodeName)
+	}
+	return fmt.Errorf("node: %v did not go NotReady", nodeName)
+}
+
+func getPodContainerSnapshot(pod v1.Pod) PodContainerSnapshot {
+	snapshot := PodContainerSnapshot{
+		Pod:          pod.Name,
+		UID:          pod.UID,
+		ContainerIDs: make(map[string]string),
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		snapshot.ContainerIDs[status.Name] = status.ContainerID
+	}
+	return snapshot
+}
+
+func getNodeReadyCondition(node *v1.Node) *v1.NodeCondition {
+	for i, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return &node.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func (w *nodeReadyWatch) run(client *kubernetes.Clientset) {
+	defer close(w.done)
+
+	for {
+		if err := w.watch(client); err != nil {
+			logrus.Warnf("Watch of node: %v disconnected. Err: %v", w.nodeName, err)
+		}
+
+		select {
+		case <-w.quit:
+			return
+		case <-time.After(cacheRelistDelay):
+		}
+	}
+}
+
+func (w *nodeReadyWatch) watch(client *kubernetes.Clientset) error {
+	selector := fmt.Sprintf("metadata.name=%v", w.nodeName)
+	list, err := client.CoreV1().Nodes().List(meta_v1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return err
+	}
+
+	for i := range list.Items {
+		w.record(&list.Items[i])
+	}
+
+	watcher, err := client.CoreV1().Nodes().Watch(meta_v1.ListOptions{
+		FieldSelector:   selector,
+		ResourceVersion: list.ResourceVersion,
+	})
+	if err != nil {
+		return err
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-w.quit:
+			return nil
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return nil
+			}
+
+			if node, ok := event.Object.(*v1.Node); ok && event.Type == watch.Modified {
+				w.record(node)
+			}
+		}
+	}
+}
+
+// record records the Ready condition of the node if it changed. If the condition transitioned and back
+// between two observations, as its transition time shows, both transitions are recorded.
+func (w *nodeReadyWatch) record(node *v1.Node) {
+	condition := getNodeReadyCondition(node)
+	if condition == nil {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	ready := condition.Status == v1.ConditionTrue
+	transitioned := condition.LastTransitionTime.Time.After(w.lastTransitionTime)
+	w.lastTransitionTime = condition.LastTransitionTime.Time
+
+	if ready == w.ready && !transitioned {
+		return
+	}
+
+	now := time.Now()
+	if ready == w.ready {
+		w.transitions = append(w.transitions, NodeReadyTransition{
+			Time:   condition.LastTransitionTime.Time,
+			Ready:  !ready,
+			Reason: "missed between observations",
+		})
+	}
+
+	w.transitions = append(w.transitions, NodeReadyTransition{
+		Time:   now,
+		Ready:  ready,
+		Reason: condition.Reason,
+	})
+	w.ready = ready
+}
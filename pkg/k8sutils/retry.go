@@ -0,0 +1,39 @@
+package k8sutils
+
+import (
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultTooManyRequestsDelay is used when the api server throttles a request without a Retry-After value
+const defaultTooManyRequestsDelay = 5 * time.Second
+
+// doRetryWithTimeout performs the given task like task.DoRetryWithTimeout. If the task fails because the
+// api server is throttling requests (429), the next attempt waits for the server's Retry-After delay.
+func doRetryWithTimeout(t func() error, timeout, timeBeforeRetry time.Duration) error {
+	return task.DoRetryWithTimeout(func() error {
+		return toRetryAfterError(t())
+	}, timeout, timeBeforeRetry)
+}
+
+// toRetryAfterError converts a 429 error from the api server to an ErrTooManyRequests
+func toRetryAfterError(err error) error {
+	if err == nil || !k8s_errors.IsTooManyRequests(err) {
+		return err
+	}
+
+	delay := defaultTooManyRequestsDelay
+	if statusErr, ok := err.(k8s_errors.APIStatus); ok {
+		if details := statusErr.Status().Details; details != nil && details.RetryAfterSeconds > 0 {
+			delay = time.Duration(details.RetryAfterSeconds) * time.Second
+		}
+	}
+
+	return &ErrTooManyRequests{
+		Delay: delay,
+		Cause: err.Error(),
+		Err:   err,
+	}
+}
@@ -0,0 +1,109 @@
+package k8sutils
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// throttlePodGets makes the server throttle the first gets of pods with the given response and returns
+// the number of throttled requests
+func throttlePodGets(server *k8stest.Server, times int, resp *k8stest.Response) func() int {
+	var lock sync.Mutex
+	throttled := 0
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "pods" || req.Method != http.MethodGet {
+			return false, 0, nil
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		if throttled >= times {
+			return false, 0, nil
+		}
+		throttled++
+		return true, http.StatusTooManyRequests, resp
+	})
+
+	return func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return throttled
+	}
+}
+
+// newTooManyRequestsStatus returns the status of a throttled request asking to retry after the given
+// seconds, none if zero
+func newTooManyRequestsStatus(retryAfterSeconds int) map[string]interface{} {
+	status := k8stest.Status(http.StatusTooManyRequests, "TooManyRequests", "too many requests")
+	if retryAfterSeconds > 0 {
+		status["details"] = map[string]interface{}{"retryAfterSeconds": retryAfterSeconds}
+	}
+	return status
+}
+
+func getTestPod() error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Pods(testNamespace).Get("web", meta_v1.GetOptions{})
+	return err
+}
+
+func TestDoRetryWithTimeoutHonorsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"header", http.Header{"Retry-After": []string{"1"}}},
+		// Without the header, the client doesn't retry and the delay is read from the status details
+		{"status details", nil},
+	}
+
+	for _, test := range tests {
+		server, cleanup := newTestAPIServer(t)
+		server.Add(&v1.Pod{ObjectMeta: meta_v1.ObjectMeta{Name: "web", Namespace: testNamespace}})
+		throttled := throttlePodGets(server, 2, &k8stest.Response{
+			Header: test.header,
+			Object: newTooManyRequestsStatus(1),
+		})
+
+		start := time.Now()
+		err := doRetryWithTimeout(getTestPod, 10*time.Second, 10*time.Millisecond)
+		elapsed := time.Since(start)
+		cleanup()
+
+		if err != nil {
+			t.Errorf("%v: expected the throttled get to succeed, got: %v", test.name, err)
+		}
+		if throttled() != 2 {
+			t.Errorf("%v: expected 2 throttled requests, got: %v", test.name, throttled())
+		}
+		if elapsed < 2*time.Second {
+			t.Errorf("%v: expected to wait the Retry-After delay of each throttled request, waited: %v",
+				test.name, elapsed)
+		}
+	}
+}
+
+func TestToRetryAfterErrorDefaultsDelay(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	throttlePodGets(server, 1, &k8stest.Response{Object: newTooManyRequestsStatus(0)})
+
+	err := toRetryAfterError(getTestPod())
+	throttledErr, ok := err.(*ErrTooManyRequests)
+	if !ok {
+		t.Fatalf("expected an ErrTooManyRequests, got: %v", err)
+	}
+	if throttledErr.Delay != defaultTooManyRequestsDelay {
+		t.Errorf("expected the default delay: %v, got: %v", defaultTooManyRequestsDelay, throttledErr.Delay)
+	}
+}
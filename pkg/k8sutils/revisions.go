@@ -0,0 +1,327 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const (
+	// k8sRevisionAnnotation is the annotation of deployment replica sets with their revision number
+	k8sRevisionAnnotation = "deployment.kubernetes.io/revision"
+	// k8sRevisionHistoryAnnotation is the annotation of deployment replica sets with the revision numbers
+	// they had before a rollback renumbered them
+	k8sRevisionHistoryAnnotation = "deployment.kubernetes.io/revision-history"
+)
+
+// GetDeploymentRevisions returns the revisions of the given deployment that still have a replica set,
+// sorted by revision
+func GetDeploymentRevisions(deployment *v1beta1.Deployment) ([]RevisionInfo, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	_, replicaSets, err := getDeploymentRevisions(client, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []RevisionInfo
+	for revision, rs := range replicaSets {
+		info := RevisionInfo{
+			Revision:        revision,
+			ReplicaSet:      rs.Name,
+			PodTemplateHash: rs.Labels[k8sPodTemplateHashKey],
+		}
+		if rs.Spec.Replicas != nil {
+			info.Replicas = *rs.Spec.Replicas
+		}
+		revisions = append(revisions, info)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+	return revisions, nil
+}
+
+// RollbackDeployment rolls the given deployment back to the given revision by updating its pod template to
+// the template of the revision. The rollback subresource is not used as newer servers don't serve it. An
+// ErrRevisionNotFound is returned if the replica set of the revision doesn't exist anymore.
+func RollbackDeployment(deployment *v1beta1.Deployment, toRevision int64) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	dep, replicaSets, err := getDeploymentRevisions(client, deployment)
+	if err != nil {
+		return err
+	}
+
+	rs, ok := replicaSets[toRevision]
+	if !ok {
+		return errRevisionNotFound(dep.Name, toRevision, replicaSets)
+	}
+
+	template := v1.PodTemplateSpec{}
+	if err := v1.DeepCopy_v1_PodTemplateSpec(&rs.Spec.Template, &template, conversion.NewCloner()); err != nil {
+		return err
+	}
+	// The hash is added by the deployment controller to the template of its replica sets
+	delete(template.Labels, k8sPodTemplateHashKey)
+
+	return updateDeploymentWithRetries(client, dep, func(d *v1beta1.Deployment) {
+		d.Spec.Template = template
+	})
+}
+
+// ValidateDeploymentAtRevision validates that the given deployment is ready and that all its pods belong to
+// the given revision. A rollback to a revision renumbers its replica set to the next revision, keeping the
+// previous numbers in its revision history annotation, so the revision is looked up there too and the
+// deployment is checked against the pod template hash of the revision. An ErrRevisionNotFound is returned
+// if the replica set of the revision doesn't exist anymore.
+func ValidateDeploymentAtRevision(deployment *v1beta1.Deployment, revision int64) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	dep, replicaSets, err := getDeploymentRevisions(client, deployment)
+	if err != nil {
+		return err
+	}
+
+	rs, ok := findRevisionReplicaSet(replicaSets, revision)
+	if !ok {
+		return errRevisionNotFound(dep.Name, revision, replicaSets)
+	}
+	hash := rs.Labels[k8sPodTemplateHashKey]
+
+	t := func() error {
+		dep, replicaSets, err := getDeploymentRevisions(client, deployment)
+		if err != nil {
+			return err
+		}
+
+		current, err := getCurrentReplicaSet(dep, replicaSets)
+		if err != nil {
+			return err
+		}
+
+		if current.Labels[k8sPodTemplateHashKey] != hash {
+			return &ErrAppNotReady{
+				ID: dep.Name,
+				Cause: fmt.Sprintf("current replica set: %v is not of revision: %v (pod template hash: %v)",
+					current.Name, revision, hash),
+			}
+		}
+
+		report := &DeploymentStatusReport{PodNodes: make(map[string]string)}
+		if err := checkDeploymentReady(dep, report, &validateOptions{}); err != nil {
+			return err
+		}
+
+		pods, err := GetDeploymentPods(dep)
+		if err != nil {
+			return err
+		}
+
+		if _, others := splitPodsByTemplateHash(pods, hash); len(others) > 0 {
+			return &ErrAppNotReady{
+				ID: dep.Name,
+				Cause: fmt.Sprintf("pod: %v does not belong to revision: %v (pod template hash: %v)",
+					others[0].Name, revision, hash),
+			}
+		}
+
+		return nil
+	}
+
+	profile := validationProfile()
+	return doRetryWithTimeout(t, profile.AppReadyTimeout, profile.RetryInterval)
+}
+
+// GetDeploymentTemplateHash returns the pod-template-hash of the current revision of the given deployment,
+// i.e of its replica set whose pod template matches the template of the deployment. An error is returned
+// if no replica set matches yet, as when the deployment controller hasn't observed a template change.
+func GetDeploymentTemplateHash(deployment *v1beta1.Deployment) (string, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return "", err
+	}
+
+	dep, replicaSets, err := getDeploymentRevisions(client, deployment)
+	if err != nil {
+		return "", err
+	}
+
+	rs, err := getCurrentReplicaSet(dep, replicaSets)
+	if err != nil {
+		return "", err
+	}
+
+	return getTemplateHash(dep, rs)
+}
+
+// getTemplateHash returns the pod-template-hash label of the given replica set of the deployment
+func getTemplateHash(dep *v1beta1.Deployment, rs *ext_v1beta1.ReplicaSet) (string, error) {
+	hash, ok := rs.Labels[k8sPodTemplateHashKey]
+	if !ok {
+		return "", fmt.Errorf("replica set: %v of deployment: %v has no %v label", rs.Name, dep.Name,
+			k8sPodTemplateHashKey)
+	}
+	return hash, nil
+}
+
+// GetPodsForDeploymentRevision returns the pods of the given deployment labeled with the given
+// pod-template-hash, e.g that of GetDeploymentTemplateHash for the pods of the current revision
+func GetPodsForDeploymentRevision(deployment *v1beta1.Deployment, hash string) ([]v1.Pod, error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	matching, _ := splitPodsByTemplateHash(pods, hash)
+	return matching, nil
+}
+
+// getCurrentReplicaSet returns the replica set of the given deployment with the same pod template. If more
+// than one matches, the one of the latest revision is returned.
+func getCurrentReplicaSet(
+	dep *v1beta1.Deployment,
+	replicaSets map[int64]ext_v1beta1.ReplicaSet,
+) (*ext_v1beta1.ReplicaSet, error) {
+	var (
+		current  *ext_v1beta1.ReplicaSet
+		revision int64
+	)
+	for r, rs := range replicaSets {
+		if r < revision {
+			continue
+		}
+
+		equal, err := equalIgnoreHash(rs.Spec.Template, dep.Spec.Template)
+		if err != nil {
+			return nil, err
+		}
+		if equal {
+			matched := rs
+			current, revision = &matched, r
+		}
+	}
+
+	if current == nil {
+		return nil, &ErrAppNotReady{
+			ID:    dep.Name,
+			Cause: "no replica set matches the pod template of the deployment yet",
+		}
+	}
+	return current, nil
+}
+
+// equalIgnoreHash checks if the given pod templates are the same once the pod-template-hash label, which
+// the deployment controller adds to the templates of its replica sets, is removed
+func equalIgnoreHash(a, b v1.PodTemplateSpec) (bool, error) {
+	var copyA, copyB v1.PodTemplateSpec
+	if err := v1.DeepCopy_v1_PodTemplateSpec(&a, &copyA, conversion.NewCloner()); err != nil {
+		return false, err
+	}
+	if err := v1.DeepCopy_v1_PodTemplateSpec(&b, &copyB, conversion.NewCloner()); err != nil {
+		return false, err
+	}
+
+	delete(copyA.Labels, k8sPodTemplateHashKey)
+	delete(copyB.Labels, k8sPodTemplateHashKey)
+	return equality.Semantic.DeepEqual(copyA, copyB), nil
+}
+
+// splitPodsByTemplateHash returns the pods labeled with the given pod-template-hash and the others
+func splitPodsByTemplateHash(pods []v1.Pod, hash string) ([]v1.Pod, []v1.Pod) {
+	var matching, others []v1.Pod
+	for _, pod := range pods {
+		if pod.Labels[k8sPodTemplateHashKey] == hash {
+			matching = append(matching, pod)
+		} else {
+			others = append(others, pod)
+		}
+	}
+	return matching, others
+}
+
+// getDeploymentRevisions returns the live deployment and its replica sets by revision
+func getDeploymentRevisions(
+	client *kubernetes.Clientset,
+	deployment *v1beta1.Deployment,
+) (*v1beta1.Deployment, map[int64]ext_v1beta1.ReplicaSet, error) {
+	namespace := namespaceOrDefault(deployment.Namespace)
+	dep, err := client.AppsV1beta1().Deployments(namespace).Get(deployment.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	owned, err := getOwnedReplicaSets(client, namespace, dep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dep, indexReplicaSetsByRevision(owned), nil
+}
+
+// indexReplicaSetsByRevision maps the given replica sets of a deployment by their revision. Replica sets
+// without a revision annotation are skipped.
+func indexReplicaSetsByRevision(owned []ext_v1beta1.ReplicaSet) map[int64]ext_v1beta1.ReplicaSet {
+	replicaSets := make(map[int64]ext_v1beta1.ReplicaSet)
+	for _, rs := range owned {
+		revision, err := strconv.ParseInt(rs.Annotations[k8sRevisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+		replicaSets[revision] = rs
+	}
+	return replicaSets
+}
+
+// findRevisionReplicaSet returns the replica set of the given revision. The revision history annotation is
+// checked for the replica sets renumbered by a rollback.
+func findRevisionReplicaSet(
+	replicaSets map[int64]ext_v1beta1.ReplicaSet,
+	revision int64,
+) (ext_v1beta1.ReplicaSet, bool) {
+	if rs, ok := replicaSets[revision]; ok {
+		return rs, true
+	}
+
+	for _, rs := range replicaSets {
+		for _, previous := range strings.Split(rs.Annotations[k8sRevisionHistoryAnnotation], ",") {
+			if r, err := strconv.ParseInt(previous, 10, 64); err == nil && r == revision {
+				return rs, true
+			}
+		}
+	}
+	return ext_v1beta1.ReplicaSet{}, false
+}
+
+func errRevisionNotFound(name string, revision int64, replicaSets map[int64]ext_v1beta1.ReplicaSet) error {
+	var available []int64
+	for r := range replicaSets {
+		available = append(available, r)
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i] < available[j] })
+
+	return &ErrRevisionNotFound{
+		ID:        name,
+		Revision:  revision,
+		Available: available,
+	}
+}
@@ -0,0 +1,227 @@
+package k8sutils
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+// newTestRevisions returns a deployment rolled back from revision 2 to revision 1, which the deployment
+// controller renumbered to revision 3
+func newTestRevisions() (*v1beta1.Deployment, *ext_v1beta1.ReplicaSet, *ext_v1beta1.ReplicaSet) {
+	dep := newTestDeployment("web", "dep-uid", 1)
+	rolledBack := newTestReplicaSet(dep, "aaa", "rs-aaa-uid", 3)
+	rolledBack.Annotations[k8sRevisionHistoryAnnotation] = "1"
+
+	updated := *dep
+	updated.Spec.Template.Spec.Containers = []v1.Container{{Name: "app", Image: "busybox:2"}}
+	broken := newTestReplicaSet(&updated, "bbb", "rs-bbb-uid", 2)
+	return dep, rolledBack, broken
+}
+
+func TestValidateDeploymentAtRevisionAfterRollback(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep, rolledBack, broken := newTestRevisions()
+	server.Add(dep, rolledBack, broken, newTestPod(rolledBack, "web-aaa-1", "node1"))
+
+	for _, revision := range []int64{1, 3} {
+		if err := ValidateDeploymentAtRevision(dep, revision); err != nil {
+			t.Errorf("revision %v: expected the deployment to be validated, got: %v", revision, err)
+		}
+	}
+
+	if err := ValidateDeploymentAtRevision(dep, 2); !IsAppNotReady(err) {
+		t.Errorf("revision 2: expected an ErrAppNotReady, got: %v", err)
+	}
+
+	err := ValidateDeploymentAtRevision(dep, 5)
+	if notFound, ok := err.(*ErrRevisionNotFound); !ok || notFound.Revision != 5 {
+		t.Errorf("revision 5: expected an ErrRevisionNotFound, got: %v", err)
+	}
+}
+
+func TestValidateDeploymentAtRevisionWithPodsOfOtherRevision(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep, rolledBack, broken := newTestRevisions()
+	server.Add(dep, rolledBack, broken,
+		newTestPod(rolledBack, "web-aaa-1", "node1"),
+		newTestPod(broken, "web-bbb-1", "node2"),
+	)
+
+	err := ValidateDeploymentAtRevision(dep, 1)
+	if !IsAppNotReady(err) {
+		t.Errorf("expected an ErrAppNotReady, got: %v", err)
+	}
+}
+
+// indexTestReplicaSets maps the given replica sets by revision like getDeploymentRevisions does
+func indexTestReplicaSets(replicaSets ...*ext_v1beta1.ReplicaSet) map[int64]ext_v1beta1.ReplicaSet {
+	var owned []ext_v1beta1.ReplicaSet
+	for _, rs := range replicaSets {
+		owned = append(owned, *rs)
+	}
+	return indexReplicaSetsByRevision(owned)
+}
+
+func TestGetCurrentReplicaSet(t *testing.T) {
+	dep, rolledBack, broken := newTestRevisions()
+
+	// An older replica set with the same template as the rolled back one
+	duplicate := newTestReplicaSet(dep, "ccc", "rs-ccc-uid", 1)
+
+	updated := *dep
+	updated.Spec.Template.Spec.Containers = broken.Spec.Template.Spec.Containers
+
+	pending := *dep
+	pending.Spec.Template.Spec.Containers = []v1.Container{{Name: "app", Image: "busybox:3"}}
+
+	tests := []struct {
+		name        string
+		dep         *v1beta1.Deployment
+		replicaSets map[int64]ext_v1beta1.ReplicaSet
+		expected    string
+	}{
+		{name: "rolled back", dep: dep, replicaSets: indexTestReplicaSets(rolledBack, broken), expected: "web-aaa"},
+		{name: "updated", dep: &updated, replicaSets: indexTestReplicaSets(rolledBack, broken), expected: "web-bbb"},
+		{
+			name:        "latest matching revision",
+			dep:         dep,
+			replicaSets: indexTestReplicaSets(duplicate, broken, rolledBack),
+			expected:    "web-aaa",
+		},
+		{name: "template change not observed", dep: &pending, replicaSets: indexTestReplicaSets(rolledBack, broken)},
+		{name: "no replica sets", dep: dep, replicaSets: indexTestReplicaSets()},
+	}
+
+	for _, test := range tests {
+		rs, err := getCurrentReplicaSet(test.dep, test.replicaSets)
+		if len(test.expected) == 0 {
+			if !IsAppNotReady(err) {
+				t.Errorf("%v: expected an ErrAppNotReady, got: %v, %v", test.name, rs, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: failed to get the current replica set: %v", test.name, err)
+		} else if rs.Name != test.expected {
+			t.Errorf("%v: expected replica set: %v, got: %v", test.name, test.expected, rs.Name)
+		}
+	}
+}
+
+func TestEqualIgnoreHash(t *testing.T) {
+	dep, rolledBack, broken := newTestRevisions()
+
+	relabeled := dep.Spec.Template
+	relabeled.Labels = map[string]string{"app": "web", "tier": "frontend"}
+
+	tests := []struct {
+		name     string
+		a, b     v1.PodTemplateSpec
+		expected bool
+	}{
+		{name: "same template with hash", a: rolledBack.Spec.Template, b: dep.Spec.Template, expected: true},
+		{name: "different hashes", a: rolledBack.Spec.Template, b: newTestReplicaSet(dep, "ddd", "", 4).Spec.Template, expected: true},
+		{name: "different image", a: broken.Spec.Template, b: dep.Spec.Template},
+		{name: "different labels", a: rolledBack.Spec.Template, b: relabeled},
+	}
+
+	for _, test := range tests {
+		equal, err := equalIgnoreHash(test.a, test.b)
+		if err != nil {
+			t.Errorf("%v: failed to compare the templates: %v", test.name, err)
+		} else if equal != test.expected {
+			t.Errorf("%v: expected equal: %v, got: %v", test.name, test.expected, equal)
+		}
+	}
+
+	if _, ok := rolledBack.Spec.Template.Labels[k8sPodTemplateHashKey]; !ok {
+		t.Errorf("expected the compared templates not to be modified")
+	}
+}
+
+func TestSplitPodsByTemplateHash(t *testing.T) {
+	_, rolledBack, broken := newTestRevisions()
+	unlabeled := newTestPod(rolledBack, "web-unlabeled", "node1")
+	unlabeled.Labels = map[string]string{"app": "web"}
+
+	pods := []v1.Pod{
+		*newTestPod(rolledBack, "web-aaa-1", "node1"),
+		*newTestPod(broken, "web-bbb-1", "node2"),
+		*newTestPod(rolledBack, "web-aaa-2", "node2"),
+		*unlabeled,
+	}
+
+	matching, others := splitPodsByTemplateHash(pods, "aaa")
+	if names := podNames(matching); !reflect.DeepEqual(names, []string{"web-aaa-1", "web-aaa-2"}) {
+		t.Errorf("expected the pods of revision aaa, got: %v", names)
+	}
+	if names := podNames(others); !reflect.DeepEqual(names, []string{"web-bbb-1", "web-unlabeled"}) {
+		t.Errorf("expected the other pods, got: %v", names)
+	}
+
+	if matching, _ := splitPodsByTemplateHash(pods, "zzz"); len(matching) > 0 {
+		t.Errorf("expected no pods of an unknown hash, got: %v", podNames(matching))
+	}
+}
+
+func TestGetDeploymentTemplateHashAndPods(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep, rolledBack, broken := newTestRevisions()
+	server.Add(dep, rolledBack, broken,
+		newTestPod(rolledBack, "web-aaa-1", "node1"),
+		newTestPod(broken, "web-bbb-1", "node2"),
+	)
+
+	hash, err := GetDeploymentTemplateHash(dep)
+	if err != nil || hash != "aaa" {
+		t.Fatalf("expected the hash of the rolled back revision, got: %v, %v", hash, err)
+	}
+
+	for hash, expected := range map[string][]string{"aaa": {"web-aaa-1"}, "bbb": {"web-bbb-1"}} {
+		pods, err := GetPodsForDeploymentRevision(dep, hash)
+		if err != nil {
+			t.Errorf("%v: failed to get the pods: %v", hash, err)
+		} else if names := podNames(pods); !reflect.DeepEqual(names, expected) {
+			t.Errorf("%v: expected pods: %v, got: %v", hash, expected, names)
+		}
+	}
+}
+
+func TestValidateDeploymentWaitsForRollout(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	// The deployment reports all replicas ready while a pod of the previous revision is still running
+	dep, current, previous := newTestRevisions()
+	dep.Spec.Replicas = new(int32)
+	*dep.Spec.Replicas = 2
+	dep.Status.ReadyReplicas, dep.Status.AvailableReplicas = 2, 2
+	server.Add(dep, current, previous,
+		newTestPod(current, "web-aaa-1", "node1"),
+		newTestPod(previous, "web-bbb-1", "node2"),
+	)
+
+	report := &DeploymentStatusReport{PodNodes: make(map[string]string)}
+	err := checkDeploymentReady(dep, report, newValidateOptions(nil))
+	if !IsAppNotReady(err) || !strings.Contains(err.Error(), "web-bbb-1") {
+		t.Fatalf("expected an ErrAppNotReady naming the pod of the previous revision, got: %v", err)
+	}
+
+	server.Remove("pods", testNamespace, "web-bbb-1")
+	server.Add(newTestPod(current, "web-aaa-2", "node2"))
+	if err := ValidateDeployement(dep); err != nil {
+		t.Errorf("expected the deployment to be validated once the rollout completed, got: %v", err)
+	}
+}
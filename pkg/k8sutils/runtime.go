@@ -0,0 +1,75 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// RuntimeDocker is the runtime of container IDs of the form docker://<id>
+	RuntimeDocker = "docker"
+	// RuntimeContainerd is the runtime of container IDs of the form containerd://<id>
+	RuntimeContainerd = "containerd"
+	// RuntimeCRIO is the runtime of container IDs of the form cri-o://<id>
+	RuntimeCRIO = "cri-o"
+)
+
+// ParseContainerID splits a container ID of a container status, of the form <runtime>://<id>
+// (e.g containerd://4f3c...), into the runtime and the bare ID. Runtimes other than docker, containerd and
+// cri-o are returned as is.
+func ParseContainerID(containerID string) (runtime string, id string, err error) {
+	parts := strings.SplitN(containerID, "://", 2)
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[1]) == 0 {
+		return "", "", fmt.Errorf("invalid container ID: %q. Expected <runtime>://<id>", containerID)
+	}
+	return parts[0], parts[1], nil
+}
+
+// GetNodeInfo returns the environment of the given node, with the name of its container runtime
+func GetNodeInfo(nodeName string) (NodeInfo, error) {
+	node, err := GetNodeByName(nodeName)
+	if err != nil {
+		return NodeInfo{}, err
+	}
+	return getNodeInfo(*node), nil
+}
+
+// sameContainerID checks if the given container IDs are of the same container. Only the bare IDs are
+// compared so that the same container reported by a different runtime prefix (e.g a pod moved between a
+// docker and a containerd node) isn't seen as changed. IDs that don't parse are compared as is.
+func sameContainerID(a, b string) bool {
+	_, idA, errA := ParseContainerID(a)
+	_, idB, errB := ParseContainerID(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return idA == idB
+}
+
+func getNodeInfo(n v1.Node) NodeInfo {
+	system := n.Status.NodeInfo
+	return NodeInfo{
+		Name:                    n.Name,
+		ProviderID:              n.Spec.ProviderID,
+		Master:                  IsNodeMaster(n),
+		KernelVersion:           system.KernelVersion,
+		OSImage:                 system.OSImage,
+		ContainerRuntime:        getContainerRuntime(system.ContainerRuntimeVersion),
+		ContainerRuntimeVersion: system.ContainerRuntimeVersion,
+		KubeletVersion:          system.KubeletVersion,
+		KubeProxyVersion:        system.KubeProxyVersion,
+		OperatingSystem:         system.OperatingSystem,
+		Architecture:            system.Architecture,
+	}
+}
+
+// getContainerRuntime returns the runtime of a container runtime version of the form <runtime>://<version>
+// (e.g docker://18.9.7)
+func getContainerRuntime(runtimeVersion string) string {
+	if i := strings.Index(runtimeVersion, "://"); i > 0 {
+		return runtimeVersion[:i]
+	}
+	return runtimeVersion
+}
@@ -0,0 +1,102 @@
+package k8sutils
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestParseContainerID(t *testing.T) {
+	tests := []struct {
+		containerID string
+		runtime     string
+		id          string
+		invalid     bool
+	}{
+		{containerID: "docker://4f3c2a", runtime: RuntimeDocker, id: "4f3c2a"},
+		{containerID: "containerd://9b1d0e", runtime: RuntimeContainerd, id: "9b1d0e"},
+		{containerID: "cri-o://77aa01", runtime: RuntimeCRIO, id: "77aa01"},
+		{containerID: "rkt://5e6f", runtime: "rkt", id: "5e6f"},
+		{containerID: "4f3c2a", invalid: true},
+		{containerID: "docker://", invalid: true},
+		{containerID: "://4f3c2a", invalid: true},
+		{containerID: "", invalid: true},
+	}
+
+	for _, test := range tests {
+		runtime, id, err := ParseContainerID(test.containerID)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("%q: expected an error, got: %v, %v", test.containerID, runtime, id)
+			}
+			continue
+		}
+		if err != nil || runtime != test.runtime || id != test.id {
+			t.Errorf("%q: expected: %v, %v, got: %v, %v, %v", test.containerID, test.runtime, test.id, runtime, id, err)
+		}
+	}
+}
+
+func TestSameContainerID(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"docker://4f3c2a", "docker://4f3c2a", true},
+		{"docker://4f3c2a", "containerd://4f3c2a", true},
+		{"containerd://4f3c2a", "cri-o://4f3c2a", true},
+		{"docker://4f3c2a", "docker://9b1d0e", false},
+		{"cri-o://4f3c2a", "containerd://9b1d0e", false},
+		{"", "", true},
+		{"", "docker://4f3c2a", false},
+	}
+
+	for _, test := range tests {
+		if same := sameContainerID(test.a, test.b); same != test.expected {
+			t.Errorf("%q, %q: expected same: %v, got: %v", test.a, test.b, test.expected, same)
+		}
+	}
+}
+
+func TestGetNodeInfoReportsContainerRuntimes(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	versions := map[string]string{
+		"node1": "docker://18.9.7",
+		"node2": "containerd://1.2.6",
+		"node3": "cri-o://1.14.1",
+	}
+	var nodes []NodeInfo
+	for _, name := range []string{"node1", "node2", "node3"} {
+		node := newTestNode(name, v1.ConditionTrue)
+		node.Status.NodeInfo.ContainerRuntimeVersion = versions[name]
+		server.Add(node)
+
+		info, err := GetNodeInfo(name)
+		if err != nil {
+			t.Fatalf("failed to get the info of %v: %v", name, err)
+		}
+		if info.ContainerRuntimeVersion != versions[name] {
+			t.Errorf("%v: expected the runtime version: %v, got: %v", name, versions[name], info.ContainerRuntimeVersion)
+		}
+		nodes = append(nodes, info)
+	}
+
+	var runtimes []string
+	for _, n := range nodes {
+		runtimes = append(runtimes, n.ContainerRuntime)
+	}
+	if expected := []string{RuntimeDocker, RuntimeContainerd, RuntimeCRIO}; !reflect.DeepEqual(runtimes, expected) {
+		t.Errorf("expected the runtimes: %v, got: %v", expected, runtimes)
+	}
+
+	mixed := DetectHeterogeneity(nodes)
+	if expected := []string{RuntimeContainerd, RuntimeCRIO, RuntimeDocker}; !reflect.DeepEqual(mixed["containerRuntime"], expected) {
+		t.Errorf("expected the mixed runtimes: %v, got: %v", expected, mixed)
+	}
+	if _, ok := mixed["kubeletVersion"]; ok {
+		t.Errorf("expected the kubelet version not to be reported as mixed, got: %v", mixed)
+	}
+}
@@ -0,0 +1,70 @@
+package k8sutils
+
+import (
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// envDisableDestructiveOps closes the destructive ops gate when set to a true value (e.g 1 or true),
+// whatever SetDestructiveOpsEnabled is called with
+const envDisableDestructiveOps = "TORPEDO_DISABLE_DESTRUCTIVE_OPS"
+
+var (
+	destructiveOpsLock    sync.RWMutex
+	destructiveOpsEnabled = true
+)
+
+// SetDestructiveOpsEnabled opens or closes the gate of the destructive helpers: deleting pods, workloads,
+// PVCs, local PVs, storage classes, services, secrets, config maps and namespaces, cordoning, labeling and
+// evacuating nodes, running commands or stress on nodes, stripping finalizers and disabling the storage
+// driver on nodes. While the gate is closed, they return an ErrDestructiveOpsDisabled without doing
+// anything, e.g to inspect a live cluster without a test firing chaos. Read and create helpers are not
+// gated. The gate can't be opened while the TORPEDO_DISABLE_DESTRUCTIVE_OPS environment variable is set to
+// true.
+func SetDestructiveOpsEnabled(enabled bool) {
+	if enabled && isDestructiveOpsDisabledByEnv() {
+		logrus.Warnf("Destructive ops stay disabled: %v is set", envDisableDestructiveOps)
+		enabled = false
+	}
+
+	destructiveOpsLock.Lock()
+	defer destructiveOpsLock.Unlock()
+
+	if destructiveOpsEnabled != enabled {
+		logrus.Warnf("Destructive ops are now enabled: %v", enabled)
+	}
+	destructiveOpsEnabled = enabled
+}
+
+// DestructiveOpsEnabled checks if the destructive helpers are allowed to run
+func DestructiveOpsEnabled() bool {
+	if isDestructiveOpsDisabledByEnv() {
+		return false
+	}
+
+	destructiveOpsLock.RLock()
+	defer destructiveOpsLock.RUnlock()
+	return destructiveOpsEnabled
+}
+
+// CheckDestructiveOp returns an ErrDestructiveOpsDisabled for the given operation if the destructive ops
+// gate is closed. Destructive helpers outside of the package (e.g of the storage drivers) call it before
+// doing anything.
+func CheckDestructiveOp(op string) error {
+	if DestructiveOpsEnabled() {
+		return nil
+	}
+
+	logrus.Warnf("Refusing destructive op: %v. Destructive ops are disabled", op)
+	return &ErrDestructiveOpsDisabled{
+		Op: op,
+	}
+}
+
+func isDestructiveOpsDisabledByEnv() bool {
+	disabled, err := strconv.ParseBool(os.Getenv(envDisableDestructiveOps))
+	return err == nil && disabled
+}
@@ -0,0 +1,110 @@
+package k8sutils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	batch_v1 "k8s.io/client-go/pkg/apis/batch/v1"
+	batch_v2alpha1 "k8s.io/client-go/pkg/apis/batch/v2alpha1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
+)
+
+// TestDestructiveOpsGate covers every destructive entry point of the package, so new ones must be added to
+// the table. DisableOnNode of the portworx schedops, which imports this package, has its own test there.
+
+func TestDestructiveOpsGate(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	pod := newTestPod(newTestReplicaSet(dep, "abc", "rs-uid", 1), "web-abc-1", "node1")
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name, pvc.Namespace = "data", testNamespace
+	sc := &storage_v1beta1.StorageClass{}
+	sc.Name = "px"
+	// CleanupNamespace reads the namespace to plan its deletion before checking the gate
+	server.Add(&v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: testNamespace}})
+	client, err := GetK8sClient()
+	if err != nil {
+		t.Fatalf("failed to get the client: %v", err)
+	}
+	allNodes := func(v1.Node) bool { return true }
+
+	tests := []struct {
+		op  string
+		run func() error
+	}{
+		{"DeletePods", func() error { return DeletePods([]v1.Pod{*pod}) }},
+		{"DeleteDeployment", func() error { return DeleteDeployment(dep) }},
+		{"DeleteStatefulSet", func() error { return DeleteStatefulSet(&v1beta1.StatefulSet{}) }},
+		{"DeleteDaemonSet", func() error { return DeleteDaemonSet(&ext_v1beta1.DaemonSet{}) }},
+		{"DeleteJob", func() error { return DeleteJob(&batch_v1.Job{}) }},
+		{"DeleteCronJob", func() error { return DeleteCronJob(&batch_v2alpha1.CronJob{}) }},
+		{"TearDownAppKeepVolumes", func() error {
+			_, err := TearDownAppKeepVolumes(dep, &v1.Service{})
+			return err
+		}},
+		{"ValidateTerminationWithinGracePeriod", func() error {
+			_, err := ValidateTerminationWithinGracePeriod(dep)
+			return err
+		}},
+		{"DeletePersistentVolumeClaim", func() error { return DeletePersistentVolumeClaim(pvc) }},
+		{"DeletePersistentVolumeClaimSafe", func() error { return DeletePersistentVolumeClaimSafe(pvc, time.Second) }},
+		{"DeleteStorageClass", func() error { return DeleteStorageClass(sc) }},
+		{"DeleteStorageClassSafe", func() error { return DeleteStorageClassSafe(sc, true) }},
+		{"ReplaceStorageClass", func() error {
+			_, err := ReplaceStorageClass(sc)
+			return err
+		}},
+		{"DeleteService", func() error { return DeleteService(&v1.Service{}) }},
+		{"DeleteSecret", func() error { return DeleteSecret(&v1.Secret{}) }},
+		{"DeleteConfigMap", func() error { return DeleteConfigMap(&v1.ConfigMap{}) }},
+		{"DeleteLocalPersistentVolume", func() error {
+			return DeleteLocalPersistentVolume(pvc, &v1.PersistentVolume{})
+		}},
+		{"AddLabelOnNode", func() error { return AddLabelOnNode("node1", "key", "value") }},
+		{"RemoveLabelOnNode", func() error { return RemoveLabelOnNode("node1", "key") }},
+		{"LabelNodesMatching", func() error {
+			_, err := LabelNodesMatching(allNodes, map[string]string{"key": "value"})
+			return err
+		}},
+		{"UnlabelNodesMatching", func() error {
+			_, err := UnlabelNodesMatching(allNodes, []string{"key"})
+			return err
+		}},
+		{"RunOnAllNodes", func() error {
+			_, err := RunOnAllNodes([]string{"true"}, "busybox", time.Second)
+			return err
+		}},
+		{"RunStressPodOnNode", func() error {
+			_, err := RunStressPodOnNode("node1", StressSpec{}, time.Second)
+			return err
+		}},
+		{"CordonNode", func() error { return CordonNode("node1") }},
+		{"DeleteNamespace", func() error { return DeleteNamespace(testNamespace) }},
+		{"CleanupNamespace", func() error { return CleanupNamespace(testNamespace) }},
+		{"StripPVCFinalizers", func() error { return stripPVCFinalizers(client, testNamespace) }},
+	}
+
+	enabled := DestructiveOpsEnabled()
+	SetDestructiveOpsEnabled(false)
+	defer SetDestructiveOpsEnabled(enabled)
+
+	for _, test := range tests {
+		err := test.run()
+		if disabled, ok := err.(*ErrDestructiveOpsDisabled); !ok || disabled.Op != test.op {
+			t.Errorf("%v: expected an ErrDestructiveOpsDisabled, got: %v", test.op, err)
+		}
+	}
+
+	for _, req := range server.Requests() {
+		if req.Method != http.MethodGet {
+			t.Errorf("expected no api writes while the gate is closed, got: %+v", req)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package k8sutils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// pxSecretNameKey is the storage class parameter with the name of the secret of an encrypted volume
+	pxSecretNameKey = "secret_name"
+	// pxSecretNamespaceKey is the storage class parameter with the namespace of the secret of an encrypted volume
+	pxSecretNamespaceKey = "secret_namespace"
+)
+
+// pvcAnnotationTemplateRegex matches a ${pvc.annotations['<key>']} template in a storage class parameter
+var pvcAnnotationTemplateRegex = regexp.MustCompile(`\$\{pvc\.annotations\['([^']+)'\]\}`)
+
+// CreateSecretFromLiterals creates an opaque secret with the given key value pairs
+func CreateSecretFromLiterals(namespace, name string, data map[string]string) (*v1.Secret, error) {
+	secret := &v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: make(map[string][]byte),
+	}
+
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+
+	return CreateSecret(secret)
+}
+
+// CreateTLSSecret creates a TLS secret with the given PEM encoded certificate and key
+func CreateTLSSecret(namespace, name string, certPEM, keyPEM []byte) (*v1.Secret, error) {
+	return CreateSecret(&v1.Secret{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Type: v1.SecretTypeTLS,
+		Data: map[string][]byte{
+			v1.TLSCertKey:       certPEM,
+			v1.TLSPrivateKeyKey: keyPEM,
+		},
+	})
+}
+
+// CreateSecret creates the given secret
+func CreateSecret(secret *v1.Secret, opts ...CreateOption) (*v1.Secret, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.Namespace, err = resolveCreateNamespace(client, secret.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&secret.ObjectMeta)
+
+	return client.CoreV1().Secrets(secret.Namespace).Create(secret)
+}
+
+// GetSecret returns the current state of the secret with the given name and namespace
+func GetSecret(name, namespace string) (*v1.Secret, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CoreV1().Secrets(namespaceOrDefault(namespace)).Get(name, meta_v1.GetOptions{})
+}
+
+// UpdateSecret updates the type and data of the existing secret to match the given secret. The update is
+// retried on conflicts against the latest version of the secret.
+func UpdateSecret(secret *v1.Secret) (*v1.Secret, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return updateSecret(client, namespaceOrDefault(secret.Namespace), secret)
+}
+
+// ValidateSecretExists validates that the given secret exists
+func ValidateSecretExists(namespace, name string) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.CoreV1().Secrets(namespaceOrDefault(namespace)).Get(name, meta_v1.GetOptions{}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// EnsureSecret creates the given secret or, if it already exists, updates its type and data to match
+func EnsureSecret(secret *v1.Secret, opts ...CreateOption) (*v1.Secret, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.Namespace, err = resolveCreateNamespace(client, secret.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&secret.ObjectMeta)
+
+	result, err := client.CoreV1().Secrets(secret.Namespace).Create(secret)
+	if err == nil || !k8s_errors.IsAlreadyExists(err) {
+		return result, err
+	}
+
+	return updateSecret(client, secret.Namespace, secret)
+}
+
+// DeleteSecret deletes the given secret. An ErrNotOwned is returned if another torpedo instance created
+// it, unless WithForceDelete is given.
+func DeleteSecret(secret *v1.Secret, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteSecret"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(secret.Namespace)
+	if current, err := client.CoreV1().Secrets(namespace).Get(secret.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("secret", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	return client.CoreV1().Secrets(namespace).Delete(secret.Name, &meta_v1.DeleteOptions{})
+}
+
+func updateSecret(client *kubernetes.Clientset, namespace string, secret *v1.Secret) (*v1.Secret, error) {
+	var err error
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var current, result *v1.Secret
+		current, err = client.CoreV1().Secrets(namespace).Get(secret.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		current.Type = secret.Type
+		current.Data = secret.Data
+		current.StringData = secret.StringData
+		if result, err = client.CoreV1().Secrets(namespace).Update(current); err == nil ||
+			!k8s_errors.IsConflict(err) {
+			return result, err
+		}
+	}
+
+	return nil, err
+}
+
+// resolveSecretParams resolves the ${pvc.name}, ${pvc.namespace} and ${pvc.annotations['<key>']}
+// templates in the secret name and namespace params for the given PVC. The secret namespace defaults
+// to the namespace of the PVC.
+func resolveSecretParams(pvc *v1.PersistentVolumeClaim, params map[string]string) error {
+	name, ok := params[pxSecretNameKey]
+	if !ok {
+		return nil
+	}
+
+	namespace, ok := params[pxSecretNamespaceKey]
+	if !ok {
+		namespace = pvc.Namespace
+	}
+
+	var err error
+	if params[pxSecretNameKey], err = resolvePVCTemplate(pvc, name); err != nil {
+		return err
+	}
+
+	if params[pxSecretNamespaceKey], err = resolvePVCTemplate(pvc, namespace); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func resolvePVCTemplate(pvc *v1.PersistentVolumeClaim, value string) (string, error) {
+	value = strings.Replace(value, "${pvc.name}", pvc.Name, -1)
+	value = strings.Replace(value, "${pvc.namespace}", pvc.Namespace, -1)
+
+	var missing []string
+	value = pvcAnnotationTemplateRegex.ReplaceAllStringFunc(value, func(match string) string {
+		key := pvcAnnotationTemplateRegex.FindStringSubmatch(match)[1]
+		annotation, ok := pvc.Annotations[key]
+		if !ok {
+			missing = append(missing, key)
+		}
+		return annotation
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("pvc: %v does not have annotations: %v referenced by its storage class",
+			pvc.Name, missing)
+	}
+
+	return value, nil
+}
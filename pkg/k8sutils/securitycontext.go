@@ -0,0 +1,92 @@
+package k8sutils
+
+import (
+	"fmt"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// admissionProbeNodeName is the node of the pods created to check admission. As no such node exists,
+// the pods are never run.
+const admissionProbeNodeName = "torpedo-admission-probe"
+
+// SetPodFSGroup sets the fsGroup of the pod template of the given deployment. The other fields of the
+// pod security context are kept.
+func SetPodFSGroup(deployment *v1beta1.Deployment, gid int64) {
+	podSecurityContext(deployment).FSGroup = &gid
+}
+
+// SetPodRunAsUser sets the user the containers of the pod template of the given deployment run as. The
+// other fields of the pod security context are kept.
+func SetPodRunAsUser(deployment *v1beta1.Deployment, uid int64) {
+	podSecurityContext(deployment).RunAsUser = &uid
+}
+
+// MarkPrivileged makes the given container of the pod template of the given deployment privileged. The
+// other fields of the container's security context are kept.
+func MarkPrivileged(deployment *v1beta1.Deployment, containerName string) error {
+	containers := deployment.Spec.Template.Spec.Containers
+	for i := range containers {
+		if containers[i].Name != containerName {
+			continue
+		}
+
+		if containers[i].SecurityContext == nil {
+			containers[i].SecurityContext = &v1.SecurityContext{}
+		}
+		privileged := true
+		containers[i].SecurityContext.Privileged = &privileged
+		return nil
+	}
+
+	return fmt.Errorf("deployment: %v does not have container: %v", deployment.Name, containerName)
+}
+
+// ValidateAdmission checks if the pods of the given deployment would be admitted, e.g by the pod security
+// policies and resource quotas of the namespace. As the api server doesn't support dry runs, a pod is
+// created from the pod template on a node that doesn't exist and deleted right away. Rejections are
+// returned as an ErrAdmissionDenied.
+func ValidateAdmission(deployment *v1beta1.Deployment) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(deployment.Namespace)
+	pod := &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: fmt.Sprintf("torpedo-admission-%v-", deployment.Name),
+			Namespace:    namespace,
+			Annotations:  deployment.Spec.Template.Annotations,
+		},
+		Spec: deployment.Spec.Template.Spec,
+	}
+	pod.Spec.NodeName = admissionProbeNodeName
+	stampInstanceLabel(&pod.ObjectMeta)
+
+	created, err := client.CoreV1().Pods(namespace).Create(pod)
+	if err != nil {
+		if k8s_errors.IsForbidden(err) || k8s_errors.IsInvalid(err) {
+			return &ErrAdmissionDenied{
+				ID:    deployment.Name,
+				Cause: err.Error(),
+			}
+		}
+		return err
+	}
+
+	var gracePeriod int64
+	return client.CoreV1().Pods(namespace).Delete(created.Name, &meta_v1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	})
+}
+
+func podSecurityContext(deployment *v1beta1.Deployment) *v1.PodSecurityContext {
+	if deployment.Spec.Template.Spec.SecurityContext == nil {
+		deployment.Spec.Template.Spec.SecurityContext = &v1.PodSecurityContext{}
+	}
+	return deployment.Spec.Template.Spec.SecurityContext
+}
@@ -0,0 +1,169 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// serviceAccountTokenTTL is the lifetime requested for tokens issued by the TokenRequest API
+	serviceAccountTokenTTL = time.Hour
+	// serviceAccountTokenRefresh is how long before its expiry a cached token is replaced by a new one
+	serviceAccountTokenRefresh = 5 * time.Minute
+	// serviceAccountTokenKey is the key of the token in service account token secrets
+	serviceAccountTokenKey = "token"
+	// tokenCheckURL is requested from pods to check a token. Any authenticated user may get it.
+	tokenCheckURL     = "https://kubernetes.default.svc/api"
+	tokenCheckTimeout = time.Minute
+)
+
+var (
+	tokenCacheLock sync.Mutex
+	// tokenCache holds the tokens issued by the TokenRequest API by <namespace>/<name>
+	tokenCache = make(map[string]ServiceAccountToken)
+)
+
+// GetServiceAccountToken returns a token for the given service account. It is read from the token secret
+// of the service account or, if the service account has none as is the case on newer servers, issued by
+// the TokenRequest API. Issued tokens are cached and replaced by a new one shortly before they expire.
+func GetServiceAccountToken(namespace, name string) (string, error) {
+	token, err := GetServiceAccountTokenWithExpiry(namespace, name)
+	if err != nil {
+		return "", err
+	}
+	return token.Token, nil
+}
+
+// GetServiceAccountTokenWithExpiry is GetServiceAccountToken with the expiry of the token
+func GetServiceAccountTokenWithExpiry(namespace, name string) (ServiceAccountToken, error) {
+	namespace = namespaceOrDefault(namespace)
+	key := namespace + "/" + name
+
+	// The lock is not held while the token is fetched so that a slow api server doesn't block the callers
+	// with cached tokens. Concurrent callers without one may each issue a token, all of which are valid.
+	tokenCacheLock.Lock()
+	cached, ok := tokenCache[key]
+	tokenCacheLock.Unlock()
+	if ok && time.Until(cached.ExpiresAt) > serviceAccountTokenRefresh {
+		return cached, nil
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return ServiceAccountToken{}, err
+	}
+
+	sa, err := client.CoreV1().ServiceAccounts(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return ServiceAccountToken{}, err
+	}
+
+	for _, ref := range sa.Secrets {
+		secret, err := client.CoreV1().Secrets(namespace).Get(ref.Name, meta_v1.GetOptions{})
+		if err != nil {
+			continue
+		}
+
+		if token, ok := secret.Data[serviceAccountTokenKey]; secret.Type == v1.SecretTypeServiceAccountToken && ok {
+			return ServiceAccountToken{Token: string(token)}, nil
+		}
+	}
+
+	token, err := requestServiceAccountToken(client, namespace, name, serviceAccountTokenTTL)
+	if err != nil {
+		return ServiceAccountToken{}, err
+	}
+
+	tokenCacheLock.Lock()
+	defer tokenCacheLock.Unlock()
+	if cached, ok := tokenCache[key]; !ok || cached.ExpiresAt.Before(token.ExpiresAt) {
+		tokenCache[key] = token
+	}
+	return token, nil
+}
+
+// ValidateTokenWorksFromPod checks that the api server authenticates the given token when used from the
+// first container of the given pod. The container needs curl. The request may be forbidden since only
+// the authentication of the token is checked.
+func ValidateTokenWorksFromPod(pod v1.Pod, token string) error {
+	if len(pod.Spec.Containers) == 0 {
+		return fmt.Errorf("pod: %v has no containers", pod.Name)
+	}
+
+	cmd := []string{
+		"curl", "-sk", "-o", "/dev/null", "-w", "%{http_code}",
+		"-H", "Authorization: Bearer " + token,
+		tokenCheckURL,
+	}
+
+	stdout, stderr, err := execInPod(pod, pod.Spec.Containers[0].Name, cmd, tokenCheckTimeout)
+	if err != nil {
+		return fmt.Errorf("failed to check token from pod: %v. Err: %v Stderr: %v", pod.Name, err, stderr)
+	}
+
+	switch code := strings.TrimSpace(stdout); code {
+	case "200", "403":
+		return nil
+	case "401":
+		return &ErrTokenRejected{
+			Pod:   pod.Name,
+			Cause: "api server returned 401 Unauthorized",
+		}
+	default:
+		return fmt.Errorf("unexpected status: %v checking token from pod: %v", code, pod.Name)
+	}
+}
+
+// requestServiceAccountToken issues a token for the service account with the TokenRequest API. The request
+// is made raw as the API is newer than the client this package is built with.
+func requestServiceAccountToken(
+	client *kubernetes.Clientset,
+	namespace string,
+	name string,
+	ttl time.Duration,
+) (ServiceAccountToken, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"apiVersion": "authentication.k8s.io/v1",
+		"kind":       "TokenRequest",
+		"spec": map[string]interface{}{
+			"expirationSeconds": int64(ttl.Seconds()),
+		},
+	})
+	if err != nil {
+		return ServiceAccountToken{}, err
+	}
+
+	raw, err := client.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("serviceaccounts").
+		Name(name).
+		SubResource("token").
+		Body(body).
+		DoRaw()
+	if err != nil {
+		return ServiceAccountToken{}, fmt.Errorf("failed to request token for service account: %v/%v. Err: %v",
+			namespace, name, err)
+	}
+
+	var response struct {
+		Status struct {
+			Token               string    `json:"token"`
+			ExpirationTimestamp time.Time `json:"expirationTimestamp"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &response); err != nil {
+		return ServiceAccountToken{}, err
+	}
+
+	return ServiceAccountToken{
+		Token:     response.Status.Token,
+		ExpiresAt: response.Status.ExpirationTimestamp,
+	}, nil
+}
@@ -0,0 +1,87 @@
+package k8sutils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestGetServiceAccountTokenDoesNotBlockCachedTokens(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+	defer func() {
+		tokenCacheLock.Lock()
+		tokenCache = make(map[string]ServiceAccountToken)
+		tokenCacheLock.Unlock()
+	}()
+
+	server.Add(
+		&v1.ServiceAccount{ObjectMeta: meta_v1.ObjectMeta{Name: "fast", Namespace: testNamespace}},
+		&v1.ServiceAccount{ObjectMeta: meta_v1.ObjectMeta{Name: "slow", Namespace: testNamespace}},
+	)
+
+	// The token request of the slow service account is answered once released
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method != http.MethodPost || req.Subresource != "token" {
+			return false, 0, nil
+		}
+		if req.Name == "slow" {
+			close(started)
+			<-release
+		}
+		return true, http.StatusCreated, map[string]interface{}{
+			"status": map[string]interface{}{
+				"token":               "token-" + req.Name,
+				"expirationTimestamp": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			},
+		}
+	})
+	countTokenRequests := func(name string) int {
+		var count int
+		for _, req := range server.Requests() {
+			if req.Subresource == "token" && req.Name == name {
+				count++
+			}
+		}
+		return count
+	}
+
+	if token, err := GetServiceAccountToken(testNamespace, "fast"); err != nil || token != "token-fast" {
+		t.Fatalf("expected the issued token, got: %v, %v", token, err)
+	}
+
+	slow := make(chan error, 1)
+	go func() {
+		_, err := GetServiceAccountToken(testNamespace, "slow")
+		slow <- err
+	}()
+	<-started
+
+	cached := make(chan error, 1)
+	go func() {
+		_, err := GetServiceAccountToken(testNamespace, "fast")
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Errorf("failed to get the cached token: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the cached token not to wait for the token request of another service account")
+	}
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Errorf("failed to get the token of the slow service account: %v", err)
+	}
+	if requests := countTokenRequests("fast"); requests != 1 {
+		t.Errorf("expected the token to be issued once, got: %v requests", requests)
+	}
+}
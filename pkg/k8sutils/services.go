@@ -0,0 +1,274 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// servicePortProbeTimeout is the connect timeout of a service port probe
+const servicePortProbeTimeout = 5 * time.Second
+
+// CreateHeadlessService creates a headless service (without a cluster IP) for the pods with the given
+// labels. StatefulSets use such a service to get a stable DNS record for each replica.
+func CreateHeadlessService(
+	namespace string,
+	name string,
+	selector map[string]string,
+	ports []v1.ServicePort,
+) (*v1.Service, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace, err = resolveCreateNamespace(client, namespace, nil); err != nil {
+		return nil, err
+	}
+
+	service := &v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: v1.ServiceSpec{
+			ClusterIP: v1.ClusterIPNone,
+			Selector:  selector,
+			Ports:     ports,
+		},
+	}
+	stampInstanceLabel(&service.ObjectMeta)
+
+	return client.CoreV1().Services(namespace).Create(service)
+}
+
+// CreateService creates the given service. If the service has no namespace, it is set to the namespace
+// from the options or the default namespace.
+func CreateService(service *v1.Service, opts ...CreateOption) (*v1.Service, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if service.Namespace, err = resolveCreateNamespace(client, service.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&service.ObjectMeta)
+
+	return client.CoreV1().Services(service.Namespace).Create(service)
+}
+
+// DeleteService deletes the given service. An ErrNotOwned is returned if another torpedo instance
+// created it, unless WithForceDelete is given.
+func DeleteService(service *v1.Service, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteService"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(service.Namespace)
+	if current, err := client.CoreV1().Services(namespace).Get(service.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("service", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	return client.CoreV1().Services(namespace).Delete(service.Name, &meta_v1.DeleteOptions{})
+}
+
+// GetServiceEndpoints returns the ready endpoints of the given service, one per address and port
+func GetServiceEndpoints(service *v1.Service) ([]ServiceEndpoint, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return getServiceEndpoints(client, namespaceOrDefault(service.Namespace), service.Name)
+}
+
+// ValidateService validates that the given service has a ready endpoint for each of its ports and that its
+// TCP ports accept connections from a prober pod in the namespace of the service. The cluster IP of the
+// service is probed, or each endpoint for a headless service. ExternalName services have no endpoints and
+// are only checked to exist. It waits up to the given timeout or the app ready timeout of the validation
+// profile if zero.
+func ValidateService(service *v1.Service, timeout time.Duration) (err error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	profile := validationProfile()
+	if timeout == 0 {
+		timeout = profile.AppReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	namespace := namespaceOrDefault(service.Namespace)
+	current, err := client.CoreV1().Services(namespace).Get(service.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if current.Spec.Type == v1.ServiceTypeExternalName {
+		return nil
+	}
+
+	var endpoints []ServiceEndpoint
+	t := func() error {
+		ready, err := getServiceEndpoints(client, namespace, current.Name)
+		if err != nil {
+			return err
+		}
+
+		if err := checkServiceEndpoints(current, ready); err != nil {
+			return err
+		}
+
+		endpoints = ready
+		return nil
+	}
+
+	if err := doRetryWithTimeout(t, timeout, profile.RetryInterval); err != nil {
+		return err
+	}
+
+	targets := getServiceProbeTargets(current, endpoints)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	pod, cleanup, err := startProberPod(namespace, current.Name)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cleanupErr := cleanup(); cleanupErr != nil && err == nil {
+			err = cleanupErr
+		}
+	}()
+
+	t = func() error {
+		for _, target := range targets {
+			if err := probeServicePort(*pod, current, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	remaining := time.Until(deadline)
+	if remaining < profile.RetryInterval {
+		remaining = profile.RetryInterval
+	}
+	return doRetryWithTimeout(t, remaining, profile.RetryInterval)
+}
+
+func getServiceEndpoints(client *kubernetes.Clientset, namespace, name string) ([]ServiceEndpoint, error) {
+	endpoints, err := client.CoreV1().Endpoints(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []ServiceEndpoint
+	for _, subset := range endpoints.Subsets {
+		for _, address := range subset.Addresses {
+			for _, port := range subset.Ports {
+				endpoint := ServiceEndpoint{
+					IP:       address.IP,
+					Port:     port.Port,
+					PortName: port.Name,
+					Protocol: port.Protocol,
+				}
+				if address.TargetRef != nil && address.TargetRef.Kind == "Pod" {
+					endpoint.Pod = address.TargetRef.Name
+				}
+				if address.NodeName != nil {
+					endpoint.Node = *address.NodeName
+				}
+				result = append(result, endpoint)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// checkServiceEndpoints checks that each port of the service has at least one ready endpoint
+func checkServiceEndpoints(service *v1.Service, endpoints []ServiceEndpoint) error {
+	var missing []string
+	for _, port := range service.Spec.Ports {
+		found := false
+		for _, endpoint := range endpoints {
+			if endpoint.PortName == port.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, fmt.Sprintf("%v/%v", port.Port, port.Protocol))
+		}
+	}
+
+	if len(missing) > 0 {
+		return &ErrAppNotReady{
+			ID:    service.Name,
+			Cause: fmt.Sprintf("service ports: %v have no ready endpoints", missing),
+		}
+	}
+
+	return nil
+}
+
+// getServiceProbeTargets returns the host:port addresses to probe for the TCP ports of the service
+func getServiceProbeTargets(service *v1.Service, endpoints []ServiceEndpoint) []string {
+	var targets []string
+	if service.Spec.ClusterIP == v1.ClusterIPNone {
+		for _, endpoint := range endpoints {
+			if endpoint.Protocol == v1.ProtocolTCP {
+				targets = append(targets, fmt.Sprintf("%v:%d", endpoint.IP, endpoint.Port))
+			}
+		}
+		return targets
+	}
+
+	for _, port := range service.Spec.Ports {
+		if port.Protocol == v1.ProtocolTCP {
+			targets = append(targets, fmt.Sprintf("%v:%d", service.Spec.ClusterIP, port.Port))
+		}
+	}
+	return targets
+}
+
+// probeServicePort checks that the given host:port target accepts connections from the prober pod
+func probeServicePort(pod v1.Pod, service *v1.Service, target string) error {
+	separator := strings.LastIndex(target, ":")
+	cmd := []string{"nc", "-z", "-w", strconv.Itoa(int(servicePortProbeTimeout.Seconds())),
+		target[:separator], target[separator+1:]}
+
+	stdout, stderr, err := execInPod(pod, proberPodContainer, cmd, servicePortProbeTimeout+30*time.Second)
+	if err == nil {
+		return nil
+	}
+
+	output := strings.TrimSpace(stdout + stderr)
+	probeErr := &ErrServiceProbeFailed{
+		Service: fmt.Sprintf("%v/%v (%v)", service.Namespace, service.Name, target),
+		Reason:  ProbeFailureOther,
+		Cause:   fmt.Sprintf("%v. Output: %v", err, output),
+	}
+	if strings.Contains(output, "Connection refused") {
+		probeErr.Reason = ProbeFailureConnectionRefused
+	}
+	return probeErr
+}
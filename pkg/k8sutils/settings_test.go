@@ -0,0 +1,140 @@
+package k8sutils
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// TestSettingsConcurrentWithReaders changes the package level settings while other goroutines use them and
+// validate deployments in parallel. Run with -race to check that the package doc's concurrency claim holds.
+// The schedops registry, which imports this package, is tested with the validators there.
+func TestSettingsConcurrentWithReaders(t *testing.T) {
+	server := k8stest.NewServer()
+	defer server.Close()
+	SetRestConfig(server.Config())
+	defer SetRestConfig(nil)
+
+	var deployments []*v1beta1.Deployment
+	for i := 0; i < 3; i++ {
+		dep := newTestDeployment(fmt.Sprintf("web-%d", i), types.UID(fmt.Sprintf("dep-%d-uid", i)), 1)
+		rs := newTestReplicaSet(dep, "abc", types.UID(fmt.Sprintf("rs-%d-uid", i)), 1)
+		server.Add(dep, rs, newTestPod(rs, dep.Name+"-abc-1", "node1"))
+		deployments = append(deployments, dep)
+	}
+
+	profile := GetValidationProfile()
+	chunkSize := getListChunkSize()
+	instance := getInstanceID()
+	destructive := DestructiveOpsEnabled()
+	defer func() {
+		SetValidationProfile(profile)
+		SetListChunkSize(chunkSize)
+		SetInstanceID(instance)
+		SetDestructiveOpsEnabled(destructive)
+		SetDefaultNamespace("")
+		SetCreateNamespaceOnUse(false)
+		SetMaxInflightRequests(defaultMaxInflightRequests)
+		SetValidationObserver(nil)
+		DisableSlowRequestLogging()
+		SetInformerCacheStaleness(defaultCacheStaleness)
+		SetFailureLogsDir("")
+
+		workerChecksLock.Lock()
+		for i := 0; i < 3; i++ {
+			delete(workerChecks, fmt.Sprintf("check-%d", i))
+		}
+		workerChecksLock.Unlock()
+	}()
+	defer setTestEndpoints(t)()
+
+	const iterations = 100
+	var wg sync.WaitGroup
+	run := func(f func(i int)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				f(i)
+			}
+		}()
+	}
+
+	// Setters
+	run(func(i int) {
+		SetValidationProfile(Profile{Name: fmt.Sprintf("profile-%d", i), RetryInterval: time.Millisecond})
+		SetDefaultNamespace(fmt.Sprintf("ns-%d", i))
+		SetCreateNamespaceOnUse(i%2 == 0)
+		SetInstanceID(fmt.Sprintf("instance-%d", i))
+		SetListChunkSize(int64(i + 1))
+		SetDestructiveOpsEnabled(i%2 == 0)
+		SetMaxInflightRequests(i%4 + 1)
+		SetInformerCacheStaleness(time.Duration(i) * time.Second)
+		SetFailureLogsDir(fmt.Sprintf("logs-%d", i))
+		RegisterWorkerCheck(fmt.Sprintf("check-%d", i%3), func(string) error { return nil })
+		if i%2 == 0 {
+			SetValidationObserver(NewValidationStatsObserver())
+			EnableSlowRequestLogging(time.Second)
+		} else {
+			SetValidationObserver(nil)
+			DisableSlowRequestLogging()
+		}
+		if err := SetAPIServerEndpoints([]string{server.URL}); err != nil {
+			t.Errorf("failed to set the endpoints: %v", err)
+		}
+	})
+
+	// Readers
+	run(func(i int) {
+		_ = validationProfile()
+		_ = namespaceOrDefault("")
+		_ = getListChunkSize()
+		_ = DestructiveOpsEnabled()
+		_ = GetInflightRequestStats()
+		_ = GetValidationStats()
+		_ = getFailureLogsDir()
+		_, _ = getCacheStore("pods", "")
+
+		meta := meta_v1.ObjectMeta{}
+		stampInstanceLabel(&meta)
+		_ = isOtherInstance(meta, nil)
+	})
+	run(func(i int) {
+		task, complete := observeValidation("settings", func() error { return nil })
+		complete(task())
+	})
+	// Parallel validators, whose results depend on the profile being changed
+	for _, dep := range deployments {
+		dep := dep
+		run(func(i int) {
+			_, _ = ValidateDeploymentWithResult(dep)
+			_, _ = GetDeploymentPods(dep)
+		})
+	}
+	run(func(i int) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		if err != nil {
+			t.Errorf("failed to build the request: %v", err)
+			return
+		}
+
+		rt := newInflightRoundTripper(newFailoverRoundTripper(http.DefaultTransport))
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			t.Errorf("request failed: %v", err)
+			return
+		}
+		if err := resp.Body.Close(); err != nil {
+			t.Errorf("failed to close the body: %v", err)
+		}
+	})
+
+	wg.Wait()
+}
@@ -0,0 +1,129 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// settlePollInterval is how often the cluster is checked while waiting for it to settle
+	settlePollInterval = 5 * time.Second
+	// podReasonCrashLoopBackOff is the waiting reason of containers that keep crashing
+	podReasonCrashLoopBackOff = "CrashLoopBackOff"
+)
+
+// WaitForClusterSettle waits until all nodes and control plane components are ready, no pod in the given
+// namespaces (all namespaces if empty) is pending, terminating or crash looping and no warning event was
+// emitted in these namespaces for the quiet period. On timeout, an ErrClusterNotSettled naming the
+// unsettled resources is returned along with the report.
+func WaitForClusterSettle(namespaces []string, quietPeriod, timeout time.Duration) (*SettleReport, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(namespaces) == 0 {
+		namespaces = []string{meta_v1.NamespaceAll}
+	}
+
+	report := &SettleReport{}
+	start := time.Now()
+	for {
+		unsettled, err := getUnsettledResources(client, namespaces, quietPeriod)
+		if err != nil {
+			return report, err
+		}
+
+		report.Duration = time.Since(start)
+		if len(unsettled) == 0 {
+			return report, nil
+		}
+		report.LastBlocking = unsettled[0]
+
+		if report.Duration > timeout {
+			return report, &ErrClusterNotSettled{
+				Timeout:   timeout,
+				Unsettled: unsettled,
+			}
+		}
+
+		time.Sleep(settlePollInterval)
+	}
+}
+
+// getUnsettledResources returns the nodes, pods and warning events that keep the cluster from being settled
+func getUnsettledResources(client *kubernetes.Clientset, namespaces []string, quietPeriod time.Duration) ([]string, error) {
+	var unsettled []string
+
+	nodes, err := GetNodes()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes.Items {
+		if err := IsNodeReady(n.Name); err != nil {
+			unsettled = append(unsettled, fmt.Sprintf("node/%v: %v", n.Name, err))
+		}
+	}
+
+	components, err := GetComponentStatuses()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range components {
+		if !c.Healthy {
+			unsettled = append(unsettled, fmt.Sprintf("component/%v: %v", c.Name, c.Message))
+		}
+	}
+
+	for _, namespace := range namespaces {
+		pods, err := listAllPods(client, namespace, meta_v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods {
+			if reason := getPodUnsettledReason(pod); len(reason) > 0 {
+				unsettled = append(unsettled, fmt.Sprintf("pod/%v/%v: %v", pod.Namespace, pod.Name, reason))
+			}
+		}
+
+		events, err := client.CoreV1().Events(namespace).List(meta_v1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, event := range events.Items {
+			if event.Type == v1.EventTypeWarning && time.Since(event.LastTimestamp.Time) < quietPeriod {
+				unsettled = append(unsettled, fmt.Sprintf("event/%v/%v: %v on %v/%v at %v", event.Namespace,
+					event.Name, event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name, event.LastTimestamp))
+			}
+		}
+	}
+
+	return unsettled, nil
+}
+
+// getPodUnsettledReason returns why the pod is not settled or an empty string if it is
+func getPodUnsettledReason(pod v1.Pod) string {
+	if pod.DeletionTimestamp != nil {
+		return "terminating"
+	}
+
+	if pod.Status.Phase == v1.PodPending {
+		return "pending"
+	}
+
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason == podReasonCrashLoopBackOff {
+			return fmt.Sprintf("container: %v is crash looping", status.Name)
+		}
+	}
+
+	return ""
+}
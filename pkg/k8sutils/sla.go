@@ -0,0 +1,46 @@
+package k8sutils
+
+import (
+	"time"
+
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// ValidateDeploymentWithin validates the given deployment like ValidateDeployement and returns the time
+// it took to become ready. If the deployment became ready but took longer than the sla, an ErrSLAExceeded
+// is returned. If it never became ready, the error of ValidateDeployement is returned. The measured
+// duration is accurate to the interval at which readiness is checked.
+func ValidateDeploymentWithin(deployment *v1beta1.Deployment, sla time.Duration) (time.Duration, error) {
+	start := time.Now()
+	report, err := ValidateDeploymentWithResult(deployment)
+	if err != nil {
+		return time.Since(start), err
+	}
+
+	return report.TimeToReady, checkSLA(deployment.Name, report.TimeToReady, sla)
+}
+
+// ValidatePVCBoundWithin waits for the given PVC to be bound and returns the time it took. If the PVC got
+// bound but took longer than the sla, an ErrSLAExceeded is returned. If it never got bound, an
+// ErrPVCsNotBound is returned.
+func ValidatePVCBoundWithin(pvc *v1.PersistentVolumeClaim, sla time.Duration) (time.Duration, error) {
+	start := time.Now()
+	if err := ValidatePVCsBound([]*v1.PersistentVolumeClaim{pvc}, validationProfile().PVCBindTimeout); err != nil {
+		return time.Since(start), err
+	}
+
+	elapsed := time.Since(start)
+	return elapsed, checkSLA(pvc.Name, elapsed, sla)
+}
+
+func checkSLA(id string, actual, budget time.Duration) error {
+	if actual > budget {
+		return &ErrSLAExceeded{
+			ID:     id,
+			Actual: actual,
+			Budget: budget,
+		}
+	}
+	return nil
+}
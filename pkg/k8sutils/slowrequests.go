@@ -0,0 +1,148 @@
+package k8sutils
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// defaultSlowRequestThreshold is the latency above which api requests are logged if no threshold is given
+const defaultSlowRequestThreshold = 5 * time.Second
+
+var (
+	slowRequestLock sync.RWMutex
+	// slowRequestThreshold is the latency above which api requests are logged. Zero if logging is disabled.
+	slowRequestThreshold time.Duration
+)
+
+// EnableSlowRequestLogging logs, at warn level, the api requests of the clients created afterwards that
+// take longer than the given threshold (5s if zero). The time the request waited on the client side rate
+// limiter is logged separately from the time the api server took to answer.
+func EnableSlowRequestLogging(threshold time.Duration) {
+	if threshold <= 0 {
+		threshold = defaultSlowRequestThreshold
+	}
+
+	slowRequestLock.Lock()
+	defer slowRequestLock.Unlock()
+	slowRequestThreshold = threshold
+}
+
+// DisableSlowRequestLogging stops logging the slow api requests of the clients created afterwards
+func DisableSlowRequestLogging() {
+	slowRequestLock.Lock()
+	defer slowRequestLock.Unlock()
+	slowRequestThreshold = 0
+}
+
+// slowRequestRoundTripper logs the requests taking longer than the threshold. It applies the rate limiter
+// of the client itself, in place of the client, so that the time each request waited on it is known.
+type slowRequestRoundTripper struct {
+	rt          http.RoundTripper
+	rateLimiter flowcontrol.RateLimiter
+	threshold   time.Duration
+	// warnf logs the slow requests
+	warnf func(format string, args ...interface{})
+}
+
+func (s *slowRequestRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Watches stay open and are not rate limited by the client
+	if isWatchRequest(req) {
+		return s.rt.RoundTrip(req)
+	}
+
+	throttleStart := time.Now()
+	s.rateLimiter.Accept()
+	throttled := time.Since(throttleStart)
+
+	start := time.Now()
+	resp, err := s.rt.RoundTrip(req)
+	latency := time.Since(start)
+
+	if latency+throttled > s.threshold {
+		resource, object := describeRequestPath(req.URL.Path)
+		s.warnf("Slow api request: %v %v %v took: %v (server: %v, client throttling: %v)",
+			req.Method, resource, object, latency+throttled, latency, throttled)
+	}
+
+	return resp, err
+}
+
+// configureSlowRequestLogging moves the rate limiter of the given config to its transport, which logs the
+// slow requests, if slow request logging is enabled
+func configureSlowRequestLogging(config *rest.Config) {
+	slowRequestLock.RLock()
+	threshold := slowRequestThreshold
+	slowRequestLock.RUnlock()
+
+	if threshold == 0 {
+		return
+	}
+
+	rateLimiter := config.RateLimiter
+	if rateLimiter == nil {
+		qps, burst := config.QPS, config.Burst
+		if qps == 0 {
+			qps = rest.DefaultQPS
+		}
+		if burst == 0 {
+			burst = rest.DefaultBurst
+		}
+		rateLimiter = flowcontrol.NewTokenBucketRateLimiter(qps, burst)
+	}
+	config.RateLimiter = flowcontrol.NewFakeAlwaysRateLimiter()
+
+	addTransportWrapper(config, func(rt http.RoundTripper) http.RoundTripper {
+		return &slowRequestRoundTripper{
+			rt:          rt,
+			rateLimiter: rateLimiter,
+			threshold:   threshold,
+			warnf:       logrus.Warnf,
+		}
+	})
+}
+
+// isWatchRequest checks if the request is a watch, which stays open until the watch ends
+func isWatchRequest(req *http.Request) bool {
+	return req.URL.Query().Get("watch") == "true" || strings.Contains(req.URL.Path, "/watch/")
+}
+
+// describeRequestPath returns the resource and the <namespace>/<name> of the object of an api request path
+// like /api/v1/namespaces/<namespace>/<resource>/<name> or /apis/<group>/<version>/<resource>/<name>
+func describeRequestPath(path string) (string, string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		parts = parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		parts = parts[3:]
+	default:
+		return path, ""
+	}
+
+	var namespace string
+	if len(parts) > 2 && parts[0] == "namespaces" {
+		namespace = parts[1]
+		parts = parts[2:]
+	}
+
+	if len(parts) == 0 {
+		return path, ""
+	}
+
+	resource := parts[0]
+	var name string
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	if len(parts) > 2 {
+		resource = resource + "/" + strings.Join(parts[2:], "/")
+	}
+
+	return resource, namespace + "/" + name
+}
@@ -0,0 +1,149 @@
+package k8sutils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// delayRateLimiter makes the requests wait for the delays in the order they call Accept
+type delayRateLimiter struct {
+	flowcontrol.RateLimiter
+	lock   sync.Mutex
+	delays []time.Duration
+	calls  int
+}
+
+func (l *delayRateLimiter) Accept() {
+	l.lock.Lock()
+	var delay time.Duration
+	if l.calls < len(l.delays) {
+		delay = l.delays[l.calls]
+	}
+	l.calls++
+	l.lock.Unlock()
+
+	time.Sleep(delay)
+}
+
+// recordedLogs collects the lines logged by a slowRequestRoundTripper
+type recordedLogs struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (r *recordedLogs) warnf(format string, args ...interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.lines = append(r.lines, fmt.Sprintf(format, args...))
+}
+
+func (r *recordedLogs) find(substring string) (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, line := range r.lines {
+		if strings.Contains(line, substring) {
+			return line, true
+		}
+	}
+	return "", false
+}
+
+func TestSlowRequestsAttributeThrottlingToTheirRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "slow-server") {
+			time.Sleep(300 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	// The first request to call Accept waits on the rate limiter, the second doesn't
+	limiter := &delayRateLimiter{delays: []time.Duration{300 * time.Millisecond, 0}}
+	logs := &recordedLogs{}
+	client := &http.Client{Transport: &slowRequestRoundTripper{
+		rt:          &http.Transport{},
+		rateLimiter: limiter,
+		threshold:   150 * time.Millisecond,
+		warnf:       logs.warnf,
+	}}
+
+	get := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Errorf("request: %v failed: %v", path, err)
+			return
+		}
+		resp.Body.Close()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		get("/api/v1/namespaces/test/pods/throttled")
+	}()
+	go func() {
+		defer wg.Done()
+		// Let the other request take the delayed slot of the rate limiter
+		time.Sleep(50 * time.Millisecond)
+		get("/api/v1/namespaces/test/pods/slow-server")
+	}()
+	wg.Wait()
+
+	checks := []struct {
+		object    string
+		throttled bool
+	}{
+		{object: "test/throttled", throttled: true},
+		{object: "test/slow-server", throttled: false},
+	}
+	for _, check := range checks {
+		line, ok := logs.find(check.object)
+		if !ok {
+			t.Errorf("%v: expected a slow request log, got: %v", check.object, logs.lines)
+			continue
+		}
+
+		const marker = "client throttling: "
+		value := strings.TrimSuffix(line[strings.Index(line, marker)+len(marker):], ")")
+		throttled, err := time.ParseDuration(value)
+		if err != nil {
+			t.Errorf("%v: failed to parse the log line: %v. Err: %v", check.object, line, err)
+			continue
+		}
+		if (throttled > 150*time.Millisecond) != check.throttled {
+			t.Errorf("%v: expected throttled: %v, got: %v", check.object, check.throttled, line)
+		}
+	}
+}
+
+func TestSlowRequestsSkipWatches(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	limiter := &delayRateLimiter{}
+	logs := &recordedLogs{}
+	client := &http.Client{Transport: &slowRequestRoundTripper{
+		rt:          &http.Transport{},
+		rateLimiter: limiter,
+		threshold:   0,
+		warnf:       logs.warnf,
+	}}
+
+	resp, err := client.Get(server.URL + "/api/v1/namespaces/test/pods?watch=true")
+	if err != nil {
+		t.Fatalf("watch failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if limiter.calls != 0 || len(logs.lines) != 0 {
+		t.Errorf("expected the watch not to be rate limited or logged, got calls: %v logs: %v",
+			limiter.calls, logs.lines)
+	}
+}
@@ -0,0 +1,188 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// CreateStatefulSet creates the given statefulset
+func CreateStatefulSet(ss *v1beta1.StatefulSet, opts ...CreateOption) (*v1beta1.StatefulSet, error) {
+	if err := checkAppsV1beta1("CreateStatefulSet"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if ss.Namespace, err = resolveCreateNamespace(client, ss.Namespace, opts); err != nil {
+		return nil, err
+	}
+
+	stampInstanceLabel(&ss.ObjectMeta)
+	stampInstanceLabel(&ss.Spec.Template.ObjectMeta)
+
+	return client.AppsV1beta1().StatefulSets(ss.Namespace).Create(ss)
+}
+
+// GetStatefulSetPods returns the pods of the given statefulset, i.e the pods matching its selector that it
+// owns. If the statefulset has a UID, the owner is matched by UID so that the pods of a recreated
+// statefulset with the same name are told apart.
+func GetStatefulSetPods(ss *v1beta1.StatefulSet) ([]v1.Pod, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return getStatefulSetPods(client, ss)
+}
+
+// ValidateStatefulSet validates that the given statefulset has observed its latest spec, that all its
+// replicas are ready and that their per-replica PVCs are bound. It waits for the app ready timeout of the
+// validation profile. On timeout, the pod logs are collected if SetFailureLogsDir is set.
+func ValidateStatefulSet(ss *v1beta1.StatefulSet) error {
+	if err := checkAppsV1beta1("ValidateStatefulSet"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(ss.Namespace)
+	t := func() error {
+		current, err := client.AppsV1beta1().StatefulSets(namespace).Get(ss.Name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		return checkStatefulSetReady(client, current)
+	}
+
+	profile := validationProfile()
+	t, complete := observeValidation("statefulset/"+ss.Name, t)
+	err = doRetryWithTimeout(t, profile.AppReadyTimeout, profile.RetryInterval)
+	complete(err)
+	if err != nil {
+		if pods, podsErr := getStatefulSetPods(client, ss); podsErr == nil {
+			if dir := collectFailureLogs("statefulset/"+ss.Name, pods); len(dir) > 0 {
+				return &ErrAppNotReady{
+					ID:    ss.Name,
+					Cause: fmt.Sprintf("%v. Logs: %v", err, dir),
+				}
+			}
+		}
+		return err
+	}
+
+	return ValidateStatefulSetPVCs(&v1beta1.StatefulSet{ObjectMeta: meta_v1.ObjectMeta{
+		Name:      ss.Name,
+		Namespace: namespace,
+	}})
+}
+
+// DeleteStatefulSet deletes the given statefulset and its pods. The per-replica PVCs are kept. An
+// ErrNotOwned is returned if another torpedo instance created it, unless WithForceDelete is given.
+func DeleteStatefulSet(ss *v1beta1.StatefulSet, opts ...DeleteOption) error {
+	if err := CheckDestructiveOp("DeleteStatefulSet"); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(ss.Namespace)
+	if current, err := client.AppsV1beta1().StatefulSets(namespace).Get(ss.Name, meta_v1.GetOptions{}); err == nil {
+		if err := checkOwned("statefulset", current.ObjectMeta, opts); err != nil {
+			return err
+		}
+	}
+
+	policy := meta_v1.DeletePropagationForeground
+	return client.AppsV1beta1().StatefulSets(namespace).Delete(ss.Name, &meta_v1.DeleteOptions{
+		PropagationPolicy: &policy,
+	})
+}
+
+// ValidateTerminatedStatefulSet waits for the given statefulset and its pods to be deleted, up to the given
+// timeout or the app delete timeout of the validation profile if zero. With foreground deletion, the
+// statefulset is only removed once its pods are gone.
+func ValidateTerminatedStatefulSet(ss *v1beta1.StatefulSet, timeout time.Duration) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	namespace := namespaceOrDefault(ss.Namespace)
+	return validateTerminatedController(client, namespace, "StatefulSet", ss.Name, ss.UID, timeout,
+		func() (meta_v1.ObjectMeta, string, error) {
+			current, err := client.AppsV1beta1().StatefulSets(namespace).Get(ss.Name, meta_v1.GetOptions{})
+			if err != nil {
+				return meta_v1.ObjectMeta{}, "", err
+			}
+			return current.ObjectMeta, fmt.Sprintf("%d replicas", current.Status.Replicas), nil
+		})
+}
+
+// checkStatefulSetReady checks once if the given live statefulset and its pods are ready
+func checkStatefulSetReady(client *kubernetes.Clientset, ss *v1beta1.StatefulSet) error {
+	replicas := int32(1)
+	if ss.Spec.Replicas != nil {
+		replicas = *ss.Spec.Replicas
+	}
+
+	if ss.Status.ObservedGeneration == nil || *ss.Status.ObservedGeneration < ss.Generation {
+		return &ErrAppNotReady{
+			ID:    ss.Name,
+			Cause: fmt.Sprintf("statefulset controller has not observed generation: %v yet", ss.Generation),
+		}
+	}
+
+	if ss.Status.ReadyReplicas != replicas {
+		return &ErrAppNotReady{
+			ID:    ss.Name,
+			Cause: fmt.Sprintf("Expected replicas: %v Ready replicas: %v", replicas, ss.Status.ReadyReplicas),
+		}
+	}
+
+	pods, err := getStatefulSetPods(client, ss)
+	if err != nil {
+		return err
+	}
+
+	var running int32
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if !IsPodRunning(pod) {
+			return &ErrAppNotReady{
+				ID:    ss.Name,
+				Cause: fmt.Sprintf("pod: %v is not yet ready", pod.Name),
+			}
+		}
+		running++
+	}
+
+	if running != replicas {
+		return &ErrAppNotReady{
+			ID:    ss.Name,
+			Cause: fmt.Sprintf("Expected replicas: %v Running pods: %v", replicas, running),
+		}
+	}
+
+	return nil
+}
+
+func getStatefulSetPods(client *kubernetes.Clientset, ss *v1beta1.StatefulSet) ([]v1.Pod, error) {
+	return getOwnedPods(client, ss.Namespace, "StatefulSet", ss.Name, ss.UID, ss.Spec.Selector)
+}
@@ -0,0 +1,333 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/task"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
+)
+
+const (
+	k8sDefaultStorageClassBetaKey = "storageclass.beta.kubernetes.io/is-default-class"
+	k8sDefaultStorageClassKey     = "storageclass.kubernetes.io/is-default-class"
+)
+
+type replaceStorageClassOptions struct {
+	pendingTimeout time.Duration
+}
+
+// WithPendingPVCsTimeout makes ReplaceStorageClass wait up to the given timeout for the pending PVCs of
+// the storage class to be bound instead of failing right away
+func WithPendingPVCsTimeout(timeout time.Duration) ReplaceStorageClassOption {
+	return func(o *replaceStorageClassOptions) {
+		o.pendingTimeout = timeout
+	}
+}
+
+// ReplaceStorageClass replaces the storage class with the same name as the given one, as storage class
+// parameters can't be updated. The existing class is only deleted once no PVC of the class is pending, and
+// it is recreated if the replacement can't be created. The changes of the parameters are returned.
+func ReplaceStorageClass(
+	sc *storage_v1beta1.StorageClass,
+	opts ...ReplaceStorageClassOption,
+) ([]ParameterChange, error) {
+	if err := CheckDestructiveOp("ReplaceStorageClass"); err != nil {
+		return nil, err
+	}
+
+	o := &replaceStorageClassOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := client.StorageV1beta1().StorageClasses().Get(sc.Name, meta_v1.GetOptions{})
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var changes []ParameterChange
+	if err == nil {
+		changes = diffParameters(existing.Parameters, sc.Parameters)
+
+		if err := waitForNoPendingPVCs(client, sc.Name, o.pendingTimeout); err != nil {
+			return nil, err
+		}
+
+		if err := client.StorageV1beta1().StorageClasses().Delete(sc.Name, &meta_v1.DeleteOptions{}); err != nil &&
+			!k8s_errors.IsNotFound(err) {
+			return nil, err
+		}
+	} else {
+		existing = nil
+		changes = diffParameters(nil, sc.Parameters)
+	}
+
+	replacement := *sc
+	replacement.ResourceVersion = ""
+	if _, err := client.StorageV1beta1().StorageClasses().Create(&replacement); err != nil {
+		if existing != nil {
+			original := *existing
+			original.ResourceVersion = ""
+			original.UID = ""
+			if _, restoreErr := client.StorageV1beta1().StorageClasses().Create(&original); restoreErr != nil {
+				return nil, fmt.Errorf("failed to create storage class: %v: %v. Failed to restore the original "+
+					"storage class. Err: %v", sc.Name, err, restoreErr)
+			}
+		}
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// waitForNoPendingPVCs waits up to the timeout for the storage class to have no pending PVCs. With a
+// zero timeout, the PVCs are checked once.
+func waitForNoPendingPVCs(client *kubernetes.Clientset, scName string, timeout time.Duration) error {
+	t := func() error {
+		pvcs, err := getStorageClassPVCs(client, scName)
+		if err != nil {
+			return err
+		}
+
+		var pending []string
+		for _, pvc := range pvcs {
+			if pvc.Status.Phase == v1.ClaimPending {
+				pending = append(pending, fmt.Sprintf("%v/%v", pvc.Namespace, pvc.Name))
+			}
+		}
+
+		if len(pending) > 0 {
+			return &ErrStorageClassHasPendingPVCs{
+				Name: scName,
+				PVCs: pending,
+			}
+		}
+
+		return nil
+	}
+
+	if timeout == 0 {
+		return t()
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		if pendingErr, ok := task.LastError(err).(*ErrStorageClassHasPendingPVCs); ok {
+			return pendingErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// getStorageClassPVCs returns the PVCs of all namespaces that use the given storage class
+func getStorageClassPVCs(client *kubernetes.Clientset, scName string) ([]v1.PersistentVolumeClaim, error) {
+	pvcs, err := client.PersistentVolumeClaims(meta_v1.NamespaceAll).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1.PersistentVolumeClaim
+	for _, pvc := range pvcs.Items {
+		if name, ok := getPVCStorageClassName(&pvc); ok && name == scName {
+			result = append(result, pvc)
+		}
+	}
+
+	return result, nil
+}
+
+// diffParameters returns the added, removed and changed parameters sorted by key
+func diffParameters(old, new map[string]string) []ParameterChange {
+	var changes []ParameterChange
+	for key, value := range new {
+		if oldValue, ok := old[key]; !ok || oldValue != value {
+			changes = append(changes, ParameterChange{
+				Key: key,
+				Old: oldValue,
+				New: value,
+			})
+		}
+	}
+
+	for key, value := range old {
+		if _, ok := new[key]; !ok {
+			changes = append(changes, ParameterChange{
+				Key: key,
+				Old: value,
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes
+}
+
+// GetDefaultStorageClass returns the default storage class of the cluster, as marked by the beta or GA
+// is-default-class annotation. nil is returned if there is no default storage class and an
+// ErrDefaultStorageClass if there are several.
+func GetDefaultStorageClass() (*storage_v1beta1.StorageClass, error) {
+	defaults, err := getDefaultStorageClasses()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(defaults) > 1 {
+		return nil, newErrDefaultStorageClass(defaults)
+	}
+
+	if len(defaults) == 0 {
+		return nil, nil
+	}
+
+	return &defaults[0], nil
+}
+
+// SetDefaultStorageClass makes the storage class with the given name the only default storage class and
+// returns the names of the previous defaults, so they can be restored by calling
+// RestoreDefaultStorageClasses with them. An empty name clears the default storage class.
+func SetDefaultStorageClass(name string) ([]string, error) {
+	var names []string
+	if len(name) > 0 {
+		names = append(names, name)
+	}
+	return setDefaultStorageClasses(names)
+}
+
+// RestoreDefaultStorageClasses makes the storage classes with the given names, as returned by
+// SetDefaultStorageClass, the default storage classes again and clears the default of the others
+func RestoreDefaultStorageClasses(names []string) error {
+	_, err := setDefaultStorageClasses(names)
+	return err
+}
+
+// setDefaultStorageClasses makes the storage classes with the given names the only default storage
+// classes and returns the names of the previous defaults
+func setDefaultStorageClasses(names []string) ([]string, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, err := getDefaultStorageClasses()
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []string
+	for _, sc := range defaults {
+		previous = append(previous, sc.Name)
+	}
+
+	// The targets are marked first so that PVCs without a class are never left without a default
+	targets := make(map[string]bool)
+	for _, name := range names {
+		targets[name] = true
+		if err := setStorageClassDefault(client, name, true); err != nil {
+			return previous, err
+		}
+	}
+
+	for _, sc := range defaults {
+		if targets[sc.Name] {
+			continue
+		}
+		if err := setStorageClassDefault(client, sc.Name, false); err != nil {
+			return previous, err
+		}
+	}
+
+	return previous, nil
+}
+
+// ValidateSingleDefaultStorageClass validates that the cluster has exactly one default storage class
+func ValidateSingleDefaultStorageClass() error {
+	defaults, err := getDefaultStorageClasses()
+	if err != nil {
+		return err
+	}
+
+	if len(defaults) != 1 {
+		return newErrDefaultStorageClass(defaults)
+	}
+
+	return nil
+}
+
+func getDefaultStorageClasses() ([]storage_v1beta1.StorageClass, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	scs, err := client.StorageV1beta1().StorageClasses().List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var defaults []storage_v1beta1.StorageClass
+	for _, sc := range scs.Items {
+		if isDefaultStorageClass(&sc) {
+			defaults = append(defaults, sc)
+		}
+	}
+
+	return defaults, nil
+}
+
+func isDefaultStorageClass(sc *storage_v1beta1.StorageClass) bool {
+	return sc.Annotations[k8sDefaultStorageClassKey] == "true" || sc.Annotations[k8sDefaultStorageClassBetaKey] == "true"
+}
+
+// setStorageClassDefault sets or clears both is-default-class annotations on the given storage class
+func setStorageClassDefault(client *kubernetes.Clientset, name string, isDefault bool) error {
+	var err error
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var sc *storage_v1beta1.StorageClass
+		sc, err = client.StorageV1beta1().StorageClasses().Get(name, meta_v1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if isDefault {
+			if sc.Annotations == nil {
+				sc.Annotations = make(map[string]string)
+			}
+			sc.Annotations[k8sDefaultStorageClassKey] = "true"
+			sc.Annotations[k8sDefaultStorageClassBetaKey] = "true"
+		} else {
+			delete(sc.Annotations, k8sDefaultStorageClassKey)
+			delete(sc.Annotations, k8sDefaultStorageClassBetaKey)
+		}
+
+		if _, err = client.StorageV1beta1().StorageClasses().Update(sc); err == nil || !k8s_errors.IsConflict(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func newErrDefaultStorageClass(defaults []storage_v1beta1.StorageClass) *ErrDefaultStorageClass {
+	var names []string
+	for _, sc := range defaults {
+		names = append(names, sc.Name)
+	}
+	return &ErrDefaultStorageClass{
+		Defaults: names,
+	}
+}
@@ -0,0 +1,240 @@
+package k8sutils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
+)
+
+func TestDiffParameters(t *testing.T) {
+	tests := []struct {
+		name     string
+		old      map[string]string
+		new      map[string]string
+		expected []ParameterChange
+	}{
+		{name: "no parameters"},
+		{name: "same parameters", old: map[string]string{"repl": "3"}, new: map[string]string{"repl": "3"}},
+		{
+			name:     "added",
+			new:      map[string]string{"repl": "3"},
+			expected: []ParameterChange{{Key: "repl", New: "3"}},
+		},
+		{
+			name:     "removed",
+			old:      map[string]string{"repl": "3"},
+			expected: []ParameterChange{{Key: "repl", Old: "3"}},
+		},
+		{
+			name: "changed, added and removed sorted by key",
+			old:  map[string]string{"repl": "1", "snap_interval": "60"},
+			new:  map[string]string{"repl": "3", "io_profile": "db"},
+			expected: []ParameterChange{
+				{Key: "io_profile", New: "db"},
+				{Key: "repl", Old: "1", New: "3"},
+				{Key: "snap_interval", Old: "60"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		if got := diffParameters(test.old, test.new); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+// getTestStorageClass returns the parameters of the stored storage class with the given name
+func getTestStorageClass(server *k8stest.Server, name string) (map[string]string, bool) {
+	sc := &storage_v1beta1.StorageClass{}
+	if !server.Get("storageclasses", "", name, sc) {
+		return nil, false
+	}
+	return sc.Parameters, true
+}
+
+func TestReplaceStorageClass(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.Add(&storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "px"},
+		Parameters: map[string]string{"repl": "1"},
+	})
+
+	changes, err := ReplaceStorageClass(&storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "px"},
+		Parameters: map[string]string{"repl": "3"},
+	})
+	if err != nil {
+		t.Fatalf("failed to replace the storage class: %v", err)
+	}
+
+	expected := []ParameterChange{{Key: "repl", Old: "1", New: "3"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("expected changes: %v, got: %v", expected, changes)
+	}
+
+	if params, _ := getTestStorageClass(server, "px"); params["repl"] != "3" {
+		t.Errorf("expected the replaced storage class, got parameters: %v", params)
+	}
+}
+
+func TestReplaceStorageClassWithPendingPVCs(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	scName := "px"
+	server.Add(&storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: scName},
+		Parameters: map[string]string{"repl": "1"},
+	}, &v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "data", Namespace: testNamespace},
+		Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &scName},
+		Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimPending},
+	})
+
+	replacement := &storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: scName},
+		Parameters: map[string]string{"repl": "3"},
+	}
+	for _, opts := range [][]ReplaceStorageClassOption{nil, {WithPendingPVCsTimeout(100 * time.Millisecond)}} {
+		_, err := ReplaceStorageClass(replacement, opts...)
+		pending, ok := err.(*ErrStorageClassHasPendingPVCs)
+		if !ok || !reflect.DeepEqual(pending.PVCs, []string{testNamespace + "/data"}) {
+			t.Errorf("expected an ErrStorageClassHasPendingPVCs, got: %v", err)
+		}
+	}
+
+	if params, _ := getTestStorageClass(server, scName); params["repl"] != "1" {
+		t.Errorf("expected the storage class to be kept, got parameters: %v", params)
+	}
+}
+
+func TestReplaceStorageClassRestoresOnFailedCreate(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	server.Add(&storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "px"},
+		Parameters: map[string]string{"repl": "1"},
+	})
+
+	// Only the create of the replacement fails
+	creates := 0
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Resource != "storageclasses" || req.Method != http.MethodPost {
+			return false, 0, nil
+		}
+		creates++
+		if creates > 1 {
+			return false, 0, nil
+		}
+		return true, http.StatusUnprocessableEntity,
+			k8stest.Status(http.StatusUnprocessableEntity, "Invalid", "invalid parameters")
+	})
+
+	_, err := ReplaceStorageClass(&storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "px"},
+		Parameters: map[string]string{"repl": "invalid"},
+	})
+	if err == nil {
+		t.Fatalf("expected the failed create to be returned")
+	}
+
+	params, ok := getTestStorageClass(server, "px")
+	if !ok || params["repl"] != "1" {
+		t.Errorf("expected the original storage class to be restored, got: %v (found: %v)", params, ok)
+	}
+}
+
+// getTestDefaultStorageClasses returns the names of the given storage classes that are stored as defaults
+func getTestDefaultStorageClasses(t *testing.T, server *k8stest.Server, names ...string) []string {
+	var defaults []string
+	for _, name := range names {
+		sc := &storage_v1beta1.StorageClass{}
+		if !server.Get("storageclasses", "", name, sc) {
+			t.Fatalf("storage class: %v was not found", name)
+		}
+		if isDefaultStorageClass(sc) {
+			defaults = append(defaults, name)
+		}
+	}
+	return defaults
+}
+
+func TestSetDefaultStorageClassRestoresAllDefaults(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defaultAnnotations := map[string]string{k8sDefaultStorageClassKey: "true"}
+	server.Add(
+		&storage_v1beta1.StorageClass{ObjectMeta: meta_v1.ObjectMeta{Name: "gp2", Annotations: defaultAnnotations}},
+		&storage_v1beta1.StorageClass{ObjectMeta: meta_v1.ObjectMeta{Name: "standard", Annotations: defaultAnnotations}},
+		&storage_v1beta1.StorageClass{ObjectMeta: meta_v1.ObjectMeta{Name: "px"}},
+	)
+	all := []string{"gp2", "px", "standard"}
+
+	if _, ok := ValidateSingleDefaultStorageClass().(*ErrDefaultStorageClass); !ok {
+		t.Errorf("expected an ErrDefaultStorageClass for two defaults")
+	}
+
+	previous, err := SetDefaultStorageClass("px")
+	if err != nil {
+		t.Fatalf("failed to set the default storage class: %v", err)
+	}
+	if expected := []string{"gp2", "standard"}; !reflect.DeepEqual(previous, expected) {
+		t.Errorf("expected the previous defaults: %v, got: %v", expected, previous)
+	}
+	if defaults := getTestDefaultStorageClasses(t, server, all...); !reflect.DeepEqual(defaults, []string{"px"}) {
+		t.Errorf("expected px to be the only default, got: %v", defaults)
+	}
+	if err := ValidateSingleDefaultStorageClass(); err != nil {
+		t.Errorf("expected a single default storage class, got: %v", err)
+	}
+
+	if err := RestoreDefaultStorageClasses(previous); err != nil {
+		t.Fatalf("failed to restore the default storage classes: %v", err)
+	}
+	if defaults := getTestDefaultStorageClasses(t, server, all...); !reflect.DeepEqual(defaults, previous) {
+		t.Errorf("expected the defaults: %v to be restored, got: %v", previous, defaults)
+	}
+
+	if _, err := SetDefaultStorageClass(""); err != nil {
+		t.Fatalf("failed to clear the default storage class: %v", err)
+	}
+	if defaults := getTestDefaultStorageClasses(t, server, all...); len(defaults) != 0 {
+		t.Errorf("expected no default storage class, got: %v", defaults)
+	}
+}
+
+func TestDeleteStorageClassSafeForceDeletesOtherInstances(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defer SetInstanceID(getInstanceID())
+	SetInstanceID("mine")
+
+	sc := &storage_v1beta1.StorageClass{ObjectMeta: meta_v1.ObjectMeta{
+		Name:   "px",
+		Labels: map[string]string{instanceLabelKey: "other"},
+	}}
+	server.Add(sc)
+
+	if _, ok := DeleteStorageClassSafe(sc, false).(*ErrNotOwned); !ok {
+		t.Fatalf("expected an ErrNotOwned without force")
+	}
+
+	if err := DeleteStorageClassSafe(sc, true); err != nil {
+		t.Fatalf("failed to force the deletion: %v", err)
+	}
+	if server.Get("storageclasses", "", "px", &storage_v1beta1.StorageClass{}) {
+		t.Errorf("expected the storage class to be deleted")
+	}
+}
@@ -0,0 +1,210 @@
+package k8sutils
+
+import (
+	"fmt"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	stressPodDefaultImage = "busybox"
+	stressPodScratchPath  = "/scratch"
+)
+
+type stressPod struct {
+	pod *v1.Pod
+}
+
+// RunStressPodOnNode creates a pod pinned to the given node that consumes the resources in spec for
+// the given duration and waits for it to start. Master nodes are refused.
+func RunStressPodOnNode(nodeName string, spec StressSpec, duration time.Duration) (StressPod, error) {
+	if err := CheckDestructiveOp("RunStressPodOnNode"); err != nil {
+		return nil, err
+	}
+
+	node, err := GetNodeByName(nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsNodeMaster(*node) {
+		return nil, fmt.Errorf("refusing to run stress pod on master node: %v", nodeName)
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	pod, err := client.CoreV1().Pods(stressPodNamespace(spec)).Create(buildStressPod(nodeName, spec, duration))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &stressPod{pod: pod}
+	current, err := waitForPodRunning(pod, 2*time.Minute)
+	if err != nil {
+		if cleanupErr := s.Cleanup(); cleanupErr != nil {
+			return nil, fmt.Errorf("stress pod: %v failed to start: %v. Cleanup also failed: %v",
+				pod.Name, err, cleanupErr)
+		}
+		return nil, err
+	}
+
+	s.pod = current
+	return s, nil
+}
+
+// WaitForNodeCondition waits for the given condition on the node to reach the given status
+func WaitForNodeCondition(
+	nodeName string,
+	conditionType v1.NodeConditionType,
+	status v1.ConditionStatus,
+	timeout time.Duration,
+) error {
+	t := func() error {
+		node, err := GetNodeByName(nodeName)
+		if err != nil {
+			return err
+		}
+
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == conditionType {
+				if condition.Status == status {
+					return nil
+				}
+				return fmt.Errorf("node: %v condition: %v is %v. Expected: %v",
+					nodeName, conditionType, condition.Status, status)
+			}
+		}
+
+		return fmt.Errorf("node: %v does not have condition: %v", nodeName, conditionType)
+	}
+
+	if err := doRetryWithTimeout(t, timeout, 5*time.Second); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *stressPod) Pod() *v1.Pod {
+	return s.pod
+}
+
+func (s *stressPod) Stop() error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	var gracePeriod int64
+	err = client.CoreV1().Pods(s.pod.Namespace).Delete(s.pod.Name, &meta_v1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+	})
+	// The kubelet may already have evicted and removed the pod
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *stressPod) Cleanup() error {
+	if err := s.Stop(); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	t := func() error {
+		_, err := client.CoreV1().Pods(s.pod.Namespace).Get(s.pod.Name, meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return &ErrAppNotTerminated{
+			ID:    s.pod.Name,
+			Cause: "stress pod is still present",
+		}
+	}
+
+	if err := doRetryWithTimeout(t, validationProfile().scaledDeleteTimeout(2*time.Minute), 5*time.Second); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func stressPodNamespace(spec StressSpec) string {
+	if len(spec.Namespace) > 0 {
+		return spec.Namespace
+	}
+	return v1.NamespaceDefault
+}
+
+// buildStressPod builds a pod that fills an emptyDir with spec.Disk bytes and holds spec.Memory bytes
+// in memory for the given duration
+func buildStressPod(nodeName string, spec StressSpec, duration time.Duration) *v1.Pod {
+	image := spec.Image
+	if len(image) == 0 {
+		image = stressPodDefaultImage
+	}
+
+	cmd := ""
+	if diskBytes := spec.Disk.Value(); diskBytes > 0 {
+		cmd += fmt.Sprintf("dd if=/dev/zero of=%v/fill bs=1048576 count=%d; ",
+			stressPodScratchPath, roundUpSize(diskBytes, 1024*1024))
+	}
+	if memoryBytes := spec.Memory.Value(); memoryBytes > 0 {
+		// tail buffers its whole input in memory as there is no newline in it
+		cmd += fmt.Sprintf("head -c %d /dev/zero | tail & ", memoryBytes)
+	}
+	cmd += fmt.Sprintf("sleep %d", int64(duration.Seconds()))
+
+	deadline := int64(duration.Seconds()) + 60
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			GenerateName: fmt.Sprintf("torpedo-stress-%v-", nodeName),
+			Namespace:    stressPodNamespace(spec),
+			Labels: map[string]string{
+				"app": "torpedo-stress",
+			},
+		},
+		Spec: v1.PodSpec{
+			NodeName:              nodeName,
+			RestartPolicy:         v1.RestartPolicyNever,
+			ActiveDeadlineSeconds: &deadline,
+			Containers: []v1.Container{
+				{
+					Name:            "stress",
+					Image:           image,
+					ImagePullPolicy: v1.PullIfNotPresent,
+					Command:         []string{"sh", "-c", cmd},
+					VolumeMounts: []v1.VolumeMount{
+						{
+							Name:      "scratch",
+							MountPath: stressPodScratchPath,
+						},
+					},
+				},
+			},
+			Volumes: []v1.Volume{
+				{
+					Name: "scratch",
+					VolumeSource: v1.VolumeSource{
+						EmptyDir: &v1.EmptyDirVolumeSource{},
+					},
+				},
+			},
+		},
+	}
+}
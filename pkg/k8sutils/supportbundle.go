@@ -0,0 +1,252 @@
+package k8sutils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// supportBundleLogTailLines is the number of log lines collected per container of a non-ready pod
+	supportBundleLogTailLines = 200
+	// supportBundleEventsPerPod is the number of most recent events collected per pod
+	supportBundleEventsPerPod = 10
+	// supportBundleErrorsFile is the file in the bundle where collection errors are recorded
+	supportBundleErrorsFile = "errors.txt"
+	// kubeSystemNamespace is the namespace of the k8s system components
+	kubeSystemNamespace = "kube-system"
+)
+
+// podWithEvents is how a pod is serialized in the support bundle
+type podWithEvents struct {
+	Pod    v1.Pod     `json:"pod"`
+	Events []v1.Event `json:"events,omitempty"`
+}
+
+// supportBundle collects cluster state into a directory, recording errors instead of stopping on them
+type supportBundle struct {
+	outDir  string
+	lock    sync.Mutex
+	errs    []string
+	stopped bool
+}
+
+// CollectSupportBundle serializes the cluster state relevant to torpedo into outDir as YAML files
+// organized per resource kind. It collects nodes, storage classes, PVs and for the given namespaces
+// (plus kube-system) pods with their last events, deployments, statefulsets, PVCs and the tail of logs
+// of non-ready pods. Collection continues past individual errors which are recorded in errors.txt.
+// If collection does not complete within timeout, whatever was collected so far is left in outDir.
+func CollectSupportBundle(namespaces []string, outDir string, timeout time.Duration) error {
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return &ErrFailedToCollectSupportBundle{
+			Path:  outDir,
+			Cause: err.Error(),
+		}
+	}
+
+	bundle := &supportBundle{outDir: outDir}
+
+	done := make(chan bool, 1)
+	go func() {
+		bundle.collect(namespaces)
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		bundle.stop(fmt.Errorf("timed out collecting support bundle after %v", timeout))
+	}
+
+	return bundle.writeErrors()
+}
+
+func (b *supportBundle) collect(namespaces []string) {
+	client, err := GetK8sClient()
+	if err != nil {
+		b.recordError(err)
+		return
+	}
+
+	nodes, err := client.CoreV1().Nodes().List(meta_v1.ListOptions{})
+	b.writeYAML("nodes", "nodes", nodes, err)
+
+	scs, err := client.StorageV1beta1().StorageClasses().List(meta_v1.ListOptions{})
+	b.writeYAML("storageclasses", "storageclasses", scs, err)
+
+	pvs, err := client.CoreV1().PersistentVolumes().List(meta_v1.ListOptions{})
+	b.writeYAML("persistentvolumes", "persistentvolumes", pvs, err)
+
+	for _, namespace := range bundleNamespaces(namespaces) {
+		b.collectNamespace(client, namespace)
+	}
+}
+
+func (b *supportBundle) collectNamespace(client *kubernetes.Clientset, namespace string) {
+	deployments, err := client.AppsV1beta1().Deployments(namespace).List(meta_v1.ListOptions{})
+	b.writeYAML("deployments", namespace, deployments, err)
+
+	statefulSets, err := client.AppsV1beta1().StatefulSets(namespace).List(meta_v1.ListOptions{})
+	b.writeYAML("statefulsets", namespace, statefulSets, err)
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(namespace).List(meta_v1.ListOptions{})
+	b.writeYAML("persistentvolumeclaims", namespace, pvcs, err)
+
+	pods, err := client.CoreV1().Pods(namespace).List(meta_v1.ListOptions{})
+	if err != nil {
+		b.recordError(fmt.Errorf("failed to list pods in namespace: %v. Err: %v", namespace, err))
+		return
+	}
+
+	// The events are listed oldest first, so are the events of each pod
+	events, err := listEvents(client, namespace, meta_v1.ListOptions{})
+	if err != nil {
+		b.recordError(fmt.Errorf("failed to list events in namespace: %v. Err: %v", namespace, err))
+	}
+
+	eventsByPod := make(map[string][]v1.Event)
+	for _, event := range events {
+		if event.InvolvedObject.Kind == "Pod" {
+			eventsByPod[event.InvolvedObject.Name] = append(eventsByPod[event.InvolvedObject.Name], event)
+		}
+	}
+
+	var result []podWithEvents
+	for _, pod := range pods.Items {
+		podEvents := eventsByPod[pod.Name]
+		if len(podEvents) > supportBundleEventsPerPod {
+			podEvents = podEvents[len(podEvents)-supportBundleEventsPerPod:]
+		}
+
+		result = append(result, podWithEvents{
+			Pod:    pod,
+			Events: podEvents,
+		})
+
+		if !IsPodRunning(pod) {
+			b.collectPodLogs(client, pod)
+		}
+	}
+
+	b.writeYAML("pods", namespace, result, nil)
+}
+
+func (b *supportBundle) collectPodLogs(client *kubernetes.Clientset, pod v1.Pod) {
+	tailLines := int64(supportBundleLogTailLines)
+	for _, container := range pod.Spec.Containers {
+		if b.isStopped() {
+			return
+		}
+
+		logs, err := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container: container.Name,
+			TailLines: &tailLines,
+		}).Do().Raw()
+		if err != nil {
+			b.recordError(fmt.Errorf("failed to get logs for pod: %v/%v container: %v. Err: %v",
+				pod.Namespace, pod.Name, container.Name, err))
+			continue
+		}
+
+		fileName := fmt.Sprintf("%v-%v.log", pod.Name, container.Name)
+		b.writeFile(filepath.Join("logs", pod.Namespace), fileName, logs)
+	}
+}
+
+func (b *supportBundle) writeYAML(kind, name string, obj interface{}, listErr error) {
+	if listErr != nil {
+		b.recordError(fmt.Errorf("failed to list %v (%v). Err: %v", kind, name, listErr))
+		return
+	}
+
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		b.recordError(fmt.Errorf("failed to serialize %v (%v). Err: %v", kind, name, err))
+		return
+	}
+
+	b.writeFile(kind, name+".yaml", data)
+}
+
+func (b *supportBundle) writeFile(dir, fileName string, data []byte) {
+	if b.isStopped() {
+		return
+	}
+
+	dir = filepath.Join(b.outDir, dir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		b.recordError(err)
+		return
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, fileName), data, 0644); err != nil {
+		b.recordError(err)
+	}
+}
+
+func (b *supportBundle) recordError(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !b.stopped {
+		b.errs = append(b.errs, err.Error())
+	}
+}
+
+func (b *supportBundle) stop(err error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.errs = append(b.errs, err.Error())
+	b.stopped = true
+}
+
+func (b *supportBundle) isStopped() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.stopped
+}
+
+// writeErrors writes the recorded errors to the bundle and returns an error if there were any
+func (b *supportBundle) writeErrors() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.stopped = true
+
+	if len(b.errs) == 0 {
+		return nil
+	}
+
+	path := filepath.Join(b.outDir, supportBundleErrorsFile)
+	if err := ioutil.WriteFile(path, []byte(strings.Join(b.errs, "\n")+"\n"), 0644); err != nil {
+		return &ErrFailedToCollectSupportBundle{
+			Path:  b.outDir,
+			Cause: fmt.Sprintf("failed to write %v. Err: %v", path, err),
+		}
+	}
+
+	return &ErrFailedToCollectSupportBundle{
+		Path:  b.outDir,
+		Cause: fmt.Sprintf("%d errors during collection. See: %v", len(b.errs), path),
+	}
+}
+
+// bundleNamespaces returns the given namespaces plus kube-system without duplicates
+func bundleNamespaces(namespaces []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, namespace := range append(namespaces, kubeSystemNamespace) {
+		if !seen[namespace] {
+			seen[namespace] = true
+			result = append(result, namespace)
+		}
+	}
+	return result
+}
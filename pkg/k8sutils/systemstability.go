@@ -0,0 +1,184 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sort"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// SnapshotSystemPodState records the UIDs and restart counts of the pods in the given namespaces, so that
+// ValidateSystemStable can check later that chaos injected for a test didn't destabilize them. Pass the
+// namespace of portworx along with kube-system. Defaults to kube-system if no namespace is given.
+func SnapshotSystemPodState(namespaces []string) (SystemPodSnapshot, error) {
+	if len(namespaces) == 0 {
+		namespaces = []string{meta_v1.NamespaceSystem}
+	}
+
+	snapshot := SystemPodSnapshot{
+		Namespaces: namespaces,
+	}
+
+	pods, err := getSystemPodStates(namespaces)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.Pods = pods
+
+	return snapshot, nil
+}
+
+// ValidateSystemStable validates that none of the pods of the given snapshot were replaced and that their
+// containers did not restart since, except for the expected ones. The keys of allowedRestarts are pod
+// names or label selectors (e.g "name=portworx") that allow the mapped number of restarts and
+// replacements in total for the pods they match, so that the replacement of a pod, which gets a new name,
+// is still matched. Pods created after the snapshot are checked for restarts since their start. An
+// ErrSystemUnstable lists the unexpected restarts and replacements.
+func ValidateSystemStable(before SystemPodSnapshot, allowedRestarts map[string]int) error {
+	selectors := parseAllowedRestarts(allowedRestarts)
+
+	current, err := getSystemPodStates(before.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	budget := make(map[string]int)
+	for key, allowed := range allowedRestarts {
+		budget[key] = allowed
+	}
+
+	// consume takes the given number of changes of a pod from the budget of the keys matching it and
+	// returns the changes that are not allowed
+	consume := func(pod SystemPodState, changes int) int {
+		for _, s := range selectors {
+			if changes <= 0 {
+				break
+			}
+			if s.name != pod.Name && !s.selector.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+
+			allowed := budget[s.key]
+			if allowed > changes {
+				allowed = changes
+			}
+			budget[s.key] -= allowed
+			changes -= allowed
+		}
+		if changes < 0 {
+			return 0
+		}
+		return changes
+	}
+
+	byUID := make(map[types.UID]SystemPodState)
+	for _, pod := range current {
+		byUID[pod.UID] = pod
+	}
+
+	existing := make(map[types.UID]bool)
+	var replaced []string
+	restarted := make(map[string]int32)
+	for _, pod := range before.Pods {
+		existing[pod.UID] = true
+
+		after, ok := byUID[pod.UID]
+		if !ok {
+			if consume(pod, 1) > 0 {
+				replaced = append(replaced, pod.Namespace+"/"+pod.Name)
+			}
+			continue
+		}
+
+		if unexpected := consume(after, int(after.Restarts-pod.Restarts)); unexpected > 0 {
+			restarted[after.Namespace+"/"+after.Name] = int32(unexpected)
+		}
+	}
+
+	for _, pod := range current {
+		if existing[pod.UID] {
+			continue
+		}
+		if unexpected := consume(pod, int(pod.Restarts)); unexpected > 0 {
+			restarted[pod.Namespace+"/"+pod.Name] = int32(unexpected)
+		}
+	}
+
+	if len(replaced) > 0 || len(restarted) > 0 {
+		return &ErrSystemUnstable{
+			Restarted: restarted,
+			Replaced:  replaced,
+		}
+	}
+
+	return nil
+}
+
+// allowedRestartsKey is a key of the allowed restarts of ValidateSystemStable, matching pods by name or by
+// label selector
+type allowedRestartsKey struct {
+	key      string
+	name     string
+	selector labels.Selector
+}
+
+// parseAllowedRestarts parses the keys of the allowed restarts, sorted so that the budgets are consumed
+// in the same order on every run
+func parseAllowedRestarts(allowedRestarts map[string]int) []allowedRestartsKey {
+	var keys []allowedRestartsKey
+	for key := range allowedRestarts {
+		selector, err := labels.Parse(key)
+		if err != nil {
+			// Pod names are not always valid label selectors
+			selector = labels.Nothing()
+		}
+		keys = append(keys, allowedRestartsKey{
+			key:      key,
+			name:     key,
+			selector: selector,
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].key < keys[j].key
+	})
+	return keys
+}
+
+func getSystemPodStates(namespaces []string) ([]SystemPodState, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []SystemPodState
+	for _, namespace := range namespaces {
+		pods, err := listAllPods(client, namespace, meta_v1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in namespace: %v. Err: %v", namespace, err)
+		}
+
+		for _, pod := range pods {
+			states = append(states, getSystemPodState(pod))
+		}
+	}
+
+	return states, nil
+}
+
+func getSystemPodState(pod v1.Pod) SystemPodState {
+	state := SystemPodState{
+		Namespace: pod.Namespace,
+		Name:      pod.Name,
+		UID:       pod.UID,
+		Labels:    pod.Labels,
+		Node:      pod.Spec.NodeName,
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		state.Restarts += status.RestartCount
+	}
+	return state
+}
@@ -0,0 +1,124 @@
+package k8sutils
+
+import (
+	"fmt"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/conversion"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// TearDownAppKeepVolumes deletes the given deployment and service (if not nil) and waits for the pods of
+// the deployment to terminate. The PVCs of the deployment are not deleted. It returns the names of the
+// preserved PVCs mapped to the names of their PVs.
+func TearDownAppKeepVolumes(deployment *v1beta1.Deployment, service *v1.Service) (map[string]string, error) {
+	if err := CheckDestructiveOp("TearDownAppKeepVolumes"); err != nil {
+		return nil, err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	// The PVCs must exist before the teardown to be preserved by it
+	if _, err := getPreservedVolumes(client, deployment); err != nil {
+		return nil, err
+	}
+
+	if service != nil {
+		if err := DeleteService(service); err != nil && !k8s_errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	if err := DeleteDeployment(deployment); err != nil && !k8s_errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	if err := ValidateTerminatedDeployment(deployment); err != nil {
+		return nil, err
+	}
+
+	return getPreservedVolumes(client, deployment)
+}
+
+// RedeployAppWithExistingVolumes creates the given deployment again, after TearDownAppKeepVolumes, and
+// validates it. An ErrPreservedVolumeMissing is returned without creating the deployment if any of its
+// PVCs was deleted in between. It returns the names of the PVCs mapped to the names of their PVs.
+func RedeployAppWithExistingVolumes(deployment *v1beta1.Deployment) (map[string]string, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	preserved, err := getPreservedVolumes(client, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	dep := &v1beta1.Deployment{}
+	if err := v1beta1.DeepCopy_v1beta1_Deployment(deployment, dep, conversion.NewCloner()); err != nil {
+		return nil, err
+	}
+	dep.ObjectMeta = meta_v1.ObjectMeta{
+		Name:        deployment.Name,
+		Namespace:   deployment.Namespace,
+		Labels:      dep.Labels,
+		Annotations: dep.Annotations,
+	}
+	dep.Status = v1beta1.DeploymentStatus{}
+
+	created, err := CreateDeployment(dep)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateDeployement(created); err != nil {
+		return nil, err
+	}
+
+	return preserved, nil
+}
+
+// getPreservedVolumes returns the names of the PVCs of the deployment mapped to the names of their PVs. An
+// ErrPreservedVolumeMissing is returned if a PVC doesn't exist, is being deleted or isn't bound.
+func getPreservedVolumes(client *kubernetes.Clientset, deployment *v1beta1.Deployment) (map[string]string, error) {
+	namespace := namespaceOrDefault(deployment.Namespace)
+	preserved := make(map[string]string)
+	for _, name := range getDeploymentPVCNames(deployment) {
+		id := fmt.Sprintf("%v/%v", namespace, name)
+
+		pvc, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(name, meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) {
+			return nil, &ErrPreservedVolumeMissing{
+				PVC:   id,
+				Cause: "PVC not found",
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if pvc.DeletionTimestamp != nil {
+			return nil, &ErrPreservedVolumeMissing{
+				PVC:   id,
+				Cause: fmt.Sprintf("PVC is being deleted since: %v", pvc.DeletionTimestamp),
+			}
+		}
+
+		if pvc.Status.Phase != v1.ClaimBound {
+			return nil, &ErrPreservedVolumeMissing{
+				PVC:   id,
+				Cause: fmt.Sprintf("PVC is in phase: %v", pvc.Status.Phase),
+			}
+		}
+
+		preserved[name] = pvc.Spec.VolumeName
+	}
+
+	return preserved, nil
+}
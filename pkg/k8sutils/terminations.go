@@ -0,0 +1,208 @@
+package k8sutils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+const (
+	terminationReasonOOMKilled = "OOMKilled"
+	terminationReasonError     = "Error"
+	podReasonEvicted           = "Evicted"
+)
+
+// DetectPodTerminations returns the OOM kills and error exits of containers and the evictions of pods of
+// the given deployment that happened after since
+func DetectPodTerminations(deployment *v1beta1.Deployment, since time.Time) ([]TerminationRecord, error) {
+	pods, err := GetDeploymentPods(deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []TerminationRecord
+	for _, pod := range pods {
+		if pod.Status.Reason == podReasonEvicted {
+			record := TerminationRecord{
+				Pod:     pod.Name,
+				Node:    pod.Spec.NodeName,
+				Reason:  podReasonEvicted,
+				Message: pod.Status.Message,
+			}
+			if pod.Status.StartTime != nil {
+				record.Time = pod.Status.StartTime.Time
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if terminated := status.State.Terminated; terminated != nil && terminated.FinishedAt.Time.After(record.Time) {
+					record.Time = terminated.FinishedAt.Time
+				}
+			}
+			if !record.Time.Before(since) {
+				records = append(records, record)
+			}
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			for _, terminated := range []*v1.ContainerStateTerminated{status.State.Terminated, status.LastTerminationState.Terminated} {
+				if terminated == nil || terminated.FinishedAt.Time.Before(since) {
+					continue
+				}
+
+				if terminated.Reason != terminationReasonOOMKilled && terminated.Reason != terminationReasonError {
+					continue
+				}
+
+				records = append(records, TerminationRecord{
+					Pod:       pod.Name,
+					Container: status.Name,
+					Node:      pod.Spec.NodeName,
+					Reason:    terminated.Reason,
+					Message:   terminated.Message,
+					ExitCode:  terminated.ExitCode,
+					Time:      terminated.FinishedAt.Time,
+				})
+			}
+		}
+	}
+
+	return records, nil
+}
+
+// ValidateNoOOMKills validates that no container of the given deployment was OOM killed after since
+func ValidateNoOOMKills(deployment *v1beta1.Deployment, since time.Time) error {
+	records, err := DetectPodTerminations(deployment, since)
+	if err != nil {
+		return err
+	}
+
+	var oomKills []TerminationRecord
+	for _, record := range records {
+		if record.Reason == terminationReasonOOMKilled {
+			oomKills = append(oomKills, record)
+		}
+	}
+
+	if len(oomKills) > 0 {
+		return &ErrAppNotReady{
+			ID:    deployment.Name,
+			Cause: fmt.Sprintf("containers were OOM killed: %v", formatTerminationRecords(oomKills)),
+		}
+	}
+
+	return nil
+}
+
+func formatTerminationRecords(records []TerminationRecord) string {
+	var parts []string
+	for _, r := range records {
+		who := r.Pod
+		if len(r.Container) > 0 {
+			who = fmt.Sprintf("%v/%v", r.Pod, r.Container)
+		}
+		parts = append(parts, fmt.Sprintf("%v on node %v: %v (exit code: %v) at %v",
+			who, r.Node, r.Reason, r.ExitCode, r.Time.Format(time.RFC3339)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ValidateTerminatedDeploymentWithProgress validates that the given deployment terminates, like
+// ValidateTerminatedDeployment, calling progress at each check with the remaining pods mapped to their
+// state. With a policy, the remaining pods are force deleted and the wait fails once the number of
+// remaining pods hasn't decreased for the durations of the policy. In any case, the wait fails after the
+// app delete timeout of the validation profile, so a teardown that keeps draining slowly is still bounded.
+func ValidateTerminatedDeploymentWithProgress(
+	deployment *v1beta1.Deployment,
+	progress func(remaining map[string]string),
+	policy *TerminationEscalationPolicy,
+) error {
+	if err := checkAppsV1beta1("ValidateTerminatedDeploymentWithProgress"); err != nil {
+		return err
+	}
+
+	if policy == nil {
+		policy = &TerminationEscalationPolicy{}
+	}
+
+	profile := validationProfile()
+	start := time.Now()
+	lastProgress := start
+	lastCount := -1
+	forced := false
+	for {
+		err := checkDeploymentTerminated(deployment)
+		if err == nil {
+			return nil
+		}
+
+		pods, listErr := GetDeploymentPods(deployment)
+		if listErr != nil {
+			return listErr
+		}
+
+		remaining := make(map[string]string)
+		for _, pod := range pods {
+			remaining[pod.Name] = getPodTerminationState(pod)
+		}
+
+		if progress != nil {
+			progress(remaining)
+		}
+
+		if lastCount < 0 || len(remaining) < lastCount {
+			lastProgress = time.Now()
+		}
+		lastCount = len(remaining)
+		stuck := time.Since(lastProgress)
+
+		if policy.ForceDeleteAfter > 0 && stuck >= policy.ForceDeleteAfter && !forced && len(pods) > 0 {
+			logrus.Warnf("No termination progress of deployment: %v for %v. Force deleting %d pods",
+				deployment.Name, stuck, len(pods))
+			if _, err := deletePods(pods); err != nil {
+				logrus.Warnf("Failed to force delete pods of deployment: %v. Err: %v", deployment.Name, err)
+			}
+			forced = true
+		}
+
+		if policy.GiveUpAfter > 0 && stuck >= policy.GiveUpAfter {
+			return &ErrAppNotTerminated{
+				ID: deployment.Name,
+				Cause: fmt.Sprintf("no progress for %v. Force deleted: %v Remaining pods: %v. Err: %v",
+					stuck, forced, remaining, err),
+			}
+		}
+
+		if elapsed := time.Since(start); elapsed >= profile.AppDeleteTimeout {
+			return &ErrAppNotTerminated{
+				ID: deployment.Name,
+				Cause: fmt.Sprintf("not terminated after %v. Force deleted: %v Remaining pods: %v. Err: %v",
+					elapsed, forced, remaining, err),
+			}
+		}
+
+		time.Sleep(profile.RetryInterval)
+	}
+}
+
+// getPodTerminationState returns a short description of the state of a pod that is expected to terminate
+func getPodTerminationState(pod v1.Pod) string {
+	state := string(pod.Status.Phase)
+	if pod.DeletionTimestamp != nil {
+		state = fmt.Sprintf("Terminating since %v", pod.DeletionTimestamp)
+	}
+
+	var waiting []string
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Waiting != nil && len(status.State.Waiting.Reason) > 0 {
+			waiting = append(waiting, fmt.Sprintf("%v: %v", status.Name, status.State.Waiting.Reason))
+		}
+	}
+	if len(waiting) > 0 {
+		state = fmt.Sprintf("%v (%v)", state, strings.Join(waiting, ", "))
+	}
+
+	return state
+}
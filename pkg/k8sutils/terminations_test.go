@@ -0,0 +1,158 @@
+package k8sutils
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	"github.com/portworx/torpedo/pkg/task"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// newTestTeardown adds a deployment with the given number of pods to the server. With finalizers, the pods
+// are only marked as being deleted, like pods whose node stopped responding.
+func newTestTeardown(server *k8stest.Server, pods int, finalizers bool) *v1beta1.Deployment {
+	dep := newTestDeployment("web", "dep-uid", int32(pods))
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	server.Add(dep, rs)
+	for i := 0; i < pods; i++ {
+		pod := newTestPod(rs, fmt.Sprintf("web-abc-%d", i), "node1")
+		if finalizers {
+			pod.Finalizers = []string{"test/stuck"}
+		}
+		server.Add(pod)
+	}
+	return dep
+}
+
+// countPodDeletes returns the number of pod deletes the server received
+func countPodDeletes(server *k8stest.Server) int {
+	count := 0
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodDelete && req.Resource == "pods" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestValidateTerminatedDeploymentWithProgressDraining(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestTeardown(server, 5, false)
+
+	// One pod terminates every 50ms, so the teardown takes longer than ForceDeleteAfter but always
+	// makes progress within it
+	var counts []int
+	lastDrain := time.Now()
+	progress := func(remaining map[string]string) {
+		counts = append(counts, len(remaining))
+		if time.Since(lastDrain) >= 50*time.Millisecond {
+			for name := range remaining {
+				server.Remove("pods", testNamespace, name)
+				break
+			}
+			lastDrain = time.Now()
+		}
+	}
+
+	policy := &TerminationEscalationPolicy{
+		ForceDeleteAfter: 150 * time.Millisecond,
+		GiveUpAfter:      300 * time.Millisecond,
+	}
+	if err := ValidateTerminatedDeploymentWithProgress(dep, progress, policy); err != nil {
+		t.Fatalf("expected the draining teardown to complete, got: %v", err)
+	}
+
+	if deletes := countPodDeletes(server); deletes > 0 {
+		t.Errorf("expected no force deletes of a draining teardown, got %d", deletes)
+	}
+	if len(counts) == 0 || counts[0] != 5 {
+		t.Errorf("expected the first progress call to report 5 remaining pods, got: %v", counts)
+	}
+	for i := 1; i < len(counts); i++ {
+		if counts[i] > counts[i-1] {
+			t.Errorf("expected the remaining pods to never increase, got: %v", counts)
+			break
+		}
+	}
+}
+
+func TestValidateTerminatedDeploymentWithProgressStuck(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestTeardown(server, 3, true)
+
+	var last map[string]string
+	policy := &TerminationEscalationPolicy{
+		ForceDeleteAfter: 50 * time.Millisecond,
+		GiveUpAfter:      200 * time.Millisecond,
+	}
+	start := time.Now()
+	err := ValidateTerminatedDeploymentWithProgress(dep, func(remaining map[string]string) {
+		last = remaining
+	}, policy)
+	elapsed := time.Since(start)
+
+	if !IsAppNotTerminated(err) {
+		t.Fatalf("expected an ErrAppNotTerminated, got: %v", err)
+	}
+	if elapsed >= testProfile.AppDeleteTimeout {
+		t.Errorf("expected to give up after %v without progress, took %v", policy.GiveUpAfter, elapsed)
+	}
+	if deletes := countPodDeletes(server); deletes != 3 {
+		t.Errorf("expected a single force delete of the 3 stuck pods, got %d deletes", deletes)
+	}
+	if len(last) != 3 {
+		t.Errorf("expected the last progress call to report the 3 stuck pods, got: %v", last)
+	}
+}
+
+func TestValidateTerminatedDeploymentWithProgressBoundsTotalWait(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestTeardown(server, 2, true)
+
+	policy := &TerminationEscalationPolicy{GiveUpAfter: time.Hour}
+	done := make(chan error, 1)
+	go func() {
+		done <- ValidateTerminatedDeploymentWithProgress(dep, nil, policy)
+	}()
+
+	select {
+	case err := <-done:
+		if !IsAppNotTerminated(err) {
+			t.Errorf("expected an ErrAppNotTerminated, got: %v", err)
+		}
+	case <-time.After(10 * testProfile.AppDeleteTimeout):
+		t.Fatalf("expected the wait to fail after the app delete timeout of %v", testProfile.AppDeleteTimeout)
+	}
+}
+
+func TestValidateTerminatedDeploymentWithTimeout(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestTeardown(server, 1, true)
+
+	start := time.Now()
+	err := ValidateTerminatedDeploymentWithTimeout(dep, 100*time.Millisecond)
+	if !IsAppNotTerminated(task.LastError(err)) {
+		t.Fatalf("expected an ErrAppNotTerminated, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= testProfile.AppDeleteTimeout {
+		t.Errorf("expected the given timeout to be used instead of the profile's, took %v", elapsed)
+	}
+
+	server.Remove("deployments", testNamespace, dep.Name)
+	server.Remove("replicasets", testNamespace, "web-abc")
+	server.Remove("pods", testNamespace, "web-abc-0")
+	if err := ValidateTerminatedDeploymentWithTimeout(dep, 100*time.Millisecond); err != nil {
+		t.Errorf("expected the deployment to be terminated, got: %v", err)
+	}
+}
@@ -0,0 +1,383 @@
+package k8sutils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/plan"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// testLabelKey is the label of a test namespace with the test it was created for
+	testLabelKey = "torpedo.io/test"
+	// pvcProtectionFinalizer is the finalizer k8s sets on PVCs in use by pods
+	pvcProtectionFinalizer = "kubernetes.io/pvc-protection"
+	// testNamespaceSuffixLength is the length of the random suffix of a test namespace name
+	testNamespaceSuffixLength = 5
+	// maxNamespaceNameLength is the DNS-1123 label limit of namespace names
+	maxNamespaceNameLength = 63
+	// defaultFinalizerEscalationDelay is how long a deleted test namespace is waited on before the objects
+	// holding it are logged and, if enabled, their finalizers stripped
+	defaultFinalizerEscalationDelay = 2 * time.Minute
+)
+
+// invalidNameCharsRegex matches the characters that are not allowed in a DNS-1123 label
+var invalidNameCharsRegex = regexp.MustCompile(`[^a-z0-9-]+`)
+
+type testNamespaceOptions struct {
+	stripPVCFinalizers bool
+	escalationDelay    time.Duration
+}
+
+// WithStripPVCFinalizers makes the cleanup of a test namespace remove the pvc-protection finalizer of the
+// PVCs created by this torpedo instance if the namespace is stuck terminating
+func WithStripPVCFinalizers() TestNamespaceOption {
+	return func(o *testNamespaceOptions) {
+		o.stripPVCFinalizers = true
+	}
+}
+
+// WithFinalizerEscalationDelay sets how long the cleanup of a test namespace waits for the namespace to
+// terminate before logging the objects holding it and stripping their finalizers
+func WithFinalizerEscalationDelay(delay time.Duration) TestNamespaceOption {
+	return func(o *testNamespaceOptions) {
+		o.escalationDelay = delay
+	}
+}
+
+// CreateTestNamespace creates a namespace with a unique name made of the given prefix, usually the name of
+// the test, and a random suffix. The prefix is shortened and sanitized so the name is a valid DNS-1123
+// label. The namespace is labeled with the torpedo instance ID and the test. The returned cleanup function
+// deletes the namespace and waits for it to terminate within the app delete timeout of the validation
+// profile. If the namespace is still terminating after the escalation delay, the objects holding it with
+// finalizers are logged. The cleanup function can be called multiple times, e.g deferred after a partial
+// failure: once it succeeded, later calls return nil.
+func CreateTestNamespace(prefix string, opts ...TestNamespaceOption) (string, func() error, error) {
+	o := &testNamespaceOptions{
+		escalationDelay: defaultFinalizerEscalationDelay,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return "", nil, err
+	}
+
+	base := sanitizeNamespacePrefix(prefix)
+	ns := &v1.Namespace{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name: fmt.Sprintf("%v-%v", base, rand.String(testNamespaceSuffixLength)),
+			Labels: map[string]string{
+				testLabelKey: base,
+			},
+		},
+	}
+	stampInstanceLabel(&ns.ObjectMeta)
+
+	created, err := client.CoreV1().Namespaces().Create(ns)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		cleanupLock sync.Mutex
+		cleaned     bool
+	)
+	cleanup := func() error {
+		cleanupLock.Lock()
+		defer cleanupLock.Unlock()
+
+		if cleaned {
+			return nil
+		}
+		if err := deleteTestNamespace(client, created.Name, o); err != nil {
+			return err
+		}
+		cleaned = true
+		return nil
+	}
+
+	return created.Name, cleanup, nil
+}
+
+// CleanupNamespace deletes the given namespace and waits for it to terminate like the cleanup function of
+// CreateTestNamespace, logging the objects holding it with finalizers after the escalation delay. An
+// ErrNotOwned is returned if another torpedo instance created the namespace. With plan.WithDryRun, the
+// pods and PVCs of the namespace are listed in the plan before the namespace as they are deleted with it.
+func CleanupNamespace(name string, opts ...plan.Option) error {
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	ns, err := client.CoreV1().Namespaces().Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if err := checkOwned("namespace", ns.ObjectMeta, nil); err != nil {
+		return err
+	}
+
+	actions, err := getNamespaceCleanupActions(client, name)
+	if err != nil {
+		return err
+	}
+
+	if execute, err := plan.Check("CleanupNamespace", actions, opts); !execute {
+		return err
+	}
+
+	if err := CheckDestructiveOp("CleanupNamespace"); err != nil {
+		return err
+	}
+
+	return deleteTestNamespace(client, name, &testNamespaceOptions{
+		escalationDelay: defaultFinalizerEscalationDelay,
+	})
+}
+
+// getNamespaceCleanupActions returns the plan actions of deleting the given namespace: the deletion of its
+// pods and PVCs, sorted by name, and then of the namespace
+func getNamespaceCleanupActions(client *kubernetes.Clientset, name string) ([]plan.Action, error) {
+	pods, err := listAllPods(client, name, meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	actions := PodDeleteActions(pods)
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(name).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pvcs.Items, func(i, j int) bool { return pvcs.Items[i].Name < pvcs.Items[j].Name })
+	for _, pvc := range pvcs.Items {
+		actions = append(actions, plan.Action{
+			Verb:      plan.VerbDelete,
+			Kind:      planKindPVC,
+			Namespace: name,
+			Name:      pvc.Name,
+			UID:       string(pvc.UID),
+		})
+	}
+
+	return append(actions, plan.Action{Verb: plan.VerbDelete, Kind: planKindNamespace, Name: name}), nil
+}
+
+// sanitizeNamespacePrefix lower cases the prefix, replaces the characters not allowed in a namespace name
+// and shortens it to leave room for the random suffix
+func sanitizeNamespacePrefix(prefix string) string {
+	prefix = invalidNameCharsRegex.ReplaceAllString(strings.ToLower(prefix), "-")
+
+	maxLength := maxNamespaceNameLength - testNamespaceSuffixLength - 1
+	if len(prefix) > maxLength {
+		prefix = prefix[:maxLength]
+	}
+
+	prefix = strings.Trim(prefix, "-")
+	if len(prefix) == 0 {
+		return "torpedo"
+	}
+	return prefix
+}
+
+func deleteTestNamespace(client *kubernetes.Clientset, name string, o *testNamespaceOptions) error {
+	err := client.CoreV1().Namespaces().Delete(name, &meta_v1.DeleteOptions{})
+	if err != nil && !k8s_errors.IsNotFound(err) {
+		return err
+	}
+
+	forgetNamespace(name)
+
+	profile := validationProfile()
+	start := time.Now()
+	escalated := false
+	for {
+		_, err := client.CoreV1().Namespaces().Get(name, meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !escalated && time.Since(start) >= o.escalationDelay {
+			escalateNamespaceFinalizers(client, name, o)
+			escalated = true
+		}
+
+		if time.Since(start) >= profile.AppDeleteTimeout {
+			finalizers, _ := getNamespaceFinalizers(client, name)
+			return &ErrNamespaceNotTerminated{
+				Namespace:  name,
+				Finalizers: finalizers,
+			}
+		}
+
+		time.Sleep(profile.RetryInterval)
+	}
+}
+
+// escalateNamespaceFinalizers logs the objects holding the given terminating namespace and, if enabled,
+// strips the pvc-protection finalizer of the PVCs of this torpedo instance
+func escalateNamespaceFinalizers(client *kubernetes.Clientset, name string, o *testNamespaceOptions) {
+	finalizers, err := getNamespaceFinalizers(client, name)
+	if err != nil {
+		logrus.Warnf("Failed to get the finalizers holding namespace: %v. Err: %v", name, err)
+		return
+	}
+	logrus.Warnf("Namespace: %v is still terminating. Objects with finalizers: %v", name, finalizers)
+	logProtectedPVCs(client, name)
+
+	if !o.stripPVCFinalizers {
+		return
+	}
+
+	if err := stripPVCFinalizers(client, name); err != nil {
+		if _, disabled := err.(*ErrDestructiveOpsDisabled); !disabled {
+			logrus.Warnf("Failed to strip the PVC finalizers of namespace: %v. Err: %v", name, err)
+		}
+	}
+}
+
+// stripPVCFinalizers removes the pvc-protection finalizer of the PVCs of this torpedo instance in the given
+// namespace. Failures to update a PVC are logged and don't stop the others.
+func stripPVCFinalizers(client *kubernetes.Clientset, name string) error {
+	if err := CheckDestructiveOp("StripPVCFinalizers"); err != nil {
+		return err
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(name).List(meta_v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	for _, pvc := range pvcs.Items {
+		if !hasFinalizer(pvc.ObjectMeta, pvcProtectionFinalizer) || !isOwnInstance(pvc.ObjectMeta) {
+			continue
+		}
+		logrus.Warnf("Removing finalizer: %v of PVC: %v/%v", pvcProtectionFinalizer, name, pvc.Name)
+		if err := removePVCFinalizer(client, name, pvc.Name, pvcProtectionFinalizer); err != nil {
+			logrus.Warnf("Failed to remove finalizer of PVC: %v/%v. Err: %v", name, pvc.Name, err)
+		}
+	}
+	return nil
+}
+
+// logProtectedPVCs logs the PVCs of the namespace that are kept terminating by their pvc-protection
+// finalizer, with the pods still using them
+func logProtectedPVCs(client *kubernetes.Clientset, name string) {
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(name).List(meta_v1.ListOptions{})
+	if err != nil {
+		return
+	}
+
+	for i, pvc := range pvcs.Items {
+		if pvc.DeletionTimestamp == nil || !isProtectedOnly(pvc.ObjectMeta) {
+			continue
+		}
+
+		consumers, err := getPVCConsumers(client, &pvcs.Items[i])
+		if err != nil || len(consumers) == 0 {
+			continue
+		}
+
+		var names []string
+		for _, pod := range consumers {
+			names = append(names, pod.Name)
+		}
+		logrus.Warnf("PVC: %v/%v is waiting for consuming pods: %v", name, pvc.Name, names)
+	}
+}
+
+// getNamespaceFinalizers returns the objects of the namespace that have finalizers, mapped to them. The
+// finalizers of the namespace itself are under namespace/<name>.
+func getNamespaceFinalizers(client *kubernetes.Clientset, name string) (map[string][]string, error) {
+	finalizers := make(map[string][]string)
+
+	ns, err := client.CoreV1().Namespaces().Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range ns.Spec.Finalizers {
+		finalizers["namespace/"+name] = append(finalizers["namespace/"+name], string(f))
+	}
+
+	pvcs, err := client.CoreV1().PersistentVolumeClaims(name).List(meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pvc := range pvcs.Items {
+		if len(pvc.Finalizers) > 0 {
+			finalizers["persistentvolumeclaim/"+pvc.Name] = pvc.Finalizers
+		}
+	}
+
+	pods, err := listAllPods(client, name, meta_v1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if len(pod.Finalizers) > 0 {
+			finalizers["pod/"+pod.Name] = pod.Finalizers
+		}
+	}
+
+	return finalizers, nil
+}
+
+func removePVCFinalizer(client *kubernetes.Clientset, namespace, name, finalizer string) error {
+	var err error
+	for retryCnt := 0; retryCnt < k8sLabelUpdateMaxRetries; retryCnt++ {
+		var pvc *v1.PersistentVolumeClaim
+		pvc, err = client.CoreV1().PersistentVolumeClaims(namespace).Get(name, meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var kept []string
+		for _, f := range pvc.Finalizers {
+			if f != finalizer {
+				kept = append(kept, f)
+			}
+		}
+		pvc.Finalizers = kept
+
+		if _, err = client.CoreV1().PersistentVolumeClaims(namespace).Update(pvc); err == nil ||
+			!k8s_errors.IsConflict(err) {
+			return err
+		}
+	}
+	return err
+}
+
+func hasFinalizer(meta meta_v1.ObjectMeta, finalizer string) bool {
+	for _, f := range meta.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnInstance checks if the object was created by this torpedo instance. Without an instance ID, all
+// objects are considered to be of this instance.
+func isOwnInstance(meta meta_v1.ObjectMeta) bool {
+	id := getInstanceID()
+	return len(id) == 0 || meta.Labels[instanceLabelKey] == id
+}
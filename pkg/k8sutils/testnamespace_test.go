@@ -0,0 +1,76 @@
+package k8sutils
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/portworx/torpedo/pkg/plan"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestCleanupNamespaceExecutesThePlan(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	dep := newTestDeployment("web", "dep-uid", 1)
+	rs := newTestReplicaSet(dep, "abc", "rs-uid", 1)
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: meta_v1.ObjectMeta{Name: "data", Namespace: testNamespace}}
+	server.Add(
+		&v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{Name: testNamespace}},
+		newTestPod(rs, "web-abc-1", "node1"),
+		pvc,
+	)
+
+	var reviewed plan.Plan
+	if err := CleanupNamespace(testNamespace, plan.WithDryRun(&reviewed)); err != nil {
+		t.Fatalf("failed to plan the cleanup: %v", err)
+	}
+
+	expected := []plan.Action{
+		{Verb: plan.VerbDelete, Kind: planKindPod, Namespace: testNamespace, Name: "web-abc-1"},
+		{Verb: plan.VerbDelete, Kind: planKindPVC, Namespace: testNamespace, Name: "data"},
+		{Verb: plan.VerbDelete, Kind: planKindNamespace, Name: testNamespace},
+	}
+	if targets := planTargets(&reviewed); !reflect.DeepEqual(targets, expected) {
+		t.Errorf("expected the plan:\n%v\ngot:\n%v", expected, targets)
+	}
+	if server.Count("namespaces") != 1 {
+		t.Fatalf("expected the dry run not to delete the namespace")
+	}
+
+	if err := CleanupNamespace(testNamespace, plan.WithPlan(&reviewed)); err != nil {
+		t.Fatalf("failed to execute the reviewed plan: %v", err)
+	}
+
+	var deletes []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodDelete {
+			deletes = append(deletes, req.Resource+"/"+req.Name)
+		}
+	}
+	if !reflect.DeepEqual(deletes, []string{"namespaces/" + testNamespace}) {
+		t.Errorf("expected only the namespace to be deleted, got: %v", deletes)
+	}
+}
+
+func TestCleanupNamespaceRefusesOtherInstances(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	defer SetInstanceID(getInstanceID())
+	SetInstanceID("mine")
+
+	server.Add(&v1.Namespace{ObjectMeta: meta_v1.ObjectMeta{
+		Name:   testNamespace,
+		Labels: map[string]string{instanceLabelKey: "other"},
+	}})
+
+	if err := CleanupNamespace(testNamespace); err == nil {
+		t.Fatalf("expected the namespace of another instance not to be deleted")
+	}
+	if server.Count("namespaces") != 1 {
+		t.Errorf("expected the namespace to be kept")
+	}
+}
@@ -0,0 +1,184 @@
+package k8sutils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/portworx/torpedo/pkg/errors"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	k8sVersionLock   sync.Mutex
+	k8sVersionCached *K8sVersion
+	k8sVersionRegex  = regexp.MustCompile(`^v?(\d+)\.(\d+)\.(\d+)`)
+
+	replicaSetGroupVersionCached *schema.GroupVersion
+	// replicaSetGroupVersions are the group versions serving replica sets, in order of preference.
+	// extensions/v1beta1 stopped serving them in 1.16.
+	replicaSetGroupVersions = []schema.GroupVersion{
+		{Group: "apps", Version: "v1"},
+		{Group: "apps", Version: "v1beta2"},
+		{Group: "extensions", Version: "v1beta1"},
+	}
+)
+
+// GetK8sVersion returns the version info of the k8s api server using the discovery client
+func GetK8sVersion() (*version.Info, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Discovery().ServerVersion()
+}
+
+// GetParsedK8sVersion returns the semantic version of the k8s api server. The version is queried
+// once and cached for subsequent calls.
+func GetParsedK8sVersion() (*K8sVersion, error) {
+	k8sVersionLock.Lock()
+	defer k8sVersionLock.Unlock()
+
+	if k8sVersionCached != nil {
+		return k8sVersionCached, nil
+	}
+
+	info, err := GetK8sVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseK8sVersion(info.GitVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("Detected k8s version: %v", parsed.GitVersion)
+	k8sVersionCached = parsed
+	return k8sVersionCached, nil
+}
+
+// getReplicaSetGroupVersion returns the preferred group version serving replica sets, found using the
+// discovery client. The group version is queried once and cached for subsequent calls.
+func getReplicaSetGroupVersion(client *kubernetes.Clientset) (schema.GroupVersion, error) {
+	k8sVersionLock.Lock()
+	defer k8sVersionLock.Unlock()
+
+	if replicaSetGroupVersionCached != nil {
+		return *replicaSetGroupVersionCached, nil
+	}
+
+	for _, gv := range replicaSetGroupVersions {
+		resources, err := client.Discovery().ServerResourcesForGroupVersion(gv.String())
+		if k8s_errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return schema.GroupVersion{}, err
+		}
+
+		for _, resource := range resources.APIResources {
+			if resource.Name == "replicasets" {
+				logrus.Infof("Detected replica set api: %v", gv)
+				replicaSetGroupVersionCached = &gv
+				return gv, nil
+			}
+		}
+	}
+
+	return schema.GroupVersion{}, &errors.ErrNotSupported{
+		Operation: "list replica sets (no replica set api is served by this cluster)",
+	}
+}
+
+// AtLeast returns true if the version is at least major.minor
+func (v *K8sVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// SupportsStorageClassV1 returns true if the cluster serves storage.k8s.io/v1 storage classes.
+// If the version cannot be detected, it returns false so callers keep using the beta API.
+func SupportsStorageClassV1() bool {
+	return versionAtLeast(1, 6)
+}
+
+// SupportsAppsV1 returns true if the cluster serves the GA apps/v1 API group.
+// If the version cannot be detected, it returns false.
+func SupportsAppsV1() bool {
+	return versionAtLeast(1, 9)
+}
+
+// SupportsAppsV1beta1 returns true if the cluster still serves the apps/v1beta1 API group (removed in 1.16).
+// If the version cannot be detected, it returns true so callers keep using the beta API.
+func SupportsAppsV1beta1() bool {
+	return !versionAtLeast(1, 16)
+}
+
+// SupportsTaintBasedEvictions returns true if the cluster evicts pods using NoExecute taints by default.
+// If the version cannot be detected, it returns false.
+func SupportsTaintBasedEvictions() bool {
+	return versionAtLeast(1, 13)
+}
+
+// SupportsBlockVolumes returns true if the cluster supports raw block PVCs by default (beta since 1.13).
+// If the version cannot be detected, it returns false.
+func SupportsBlockVolumes() bool {
+	return versionAtLeast(1, 13)
+}
+
+// checkBlockVolumes returns an error if raw block PVCs are not supported by the cluster
+func checkBlockVolumes(operation string) error {
+	if !SupportsBlockVolumes() {
+		return &errors.ErrNotSupported{
+			Operation: fmt.Sprintf("%v (raw block volumes are not supported by this cluster)", operation),
+		}
+	}
+	return nil
+}
+
+// checkAppsV1beta1 returns an error if the apps/v1beta1 API used by the deployment helpers is not served
+func checkAppsV1beta1(operation string) error {
+	if !SupportsAppsV1beta1() {
+		return &errors.ErrNotSupported{
+			Operation: fmt.Sprintf("%v (apps/v1beta1 is not served by this cluster)", operation),
+		}
+	}
+	return nil
+}
+
+// versionAtLeast compares the cached server version against major.minor and returns false when
+// the version cannot be detected
+func versionAtLeast(major, minor int) bool {
+	k8sVersion, err := GetParsedK8sVersion()
+	if err != nil {
+		logrus.Warnf("Failed to detect k8s version. Err: %v", err)
+		return false
+	}
+
+	return k8sVersion.AtLeast(major, minor)
+}
+
+func parseK8sVersion(gitVersion string) (*K8sVersion, error) {
+	matches := k8sVersionRegex.FindStringSubmatch(gitVersion)
+	if len(matches) != 4 {
+		return nil, fmt.Errorf("failed to parse k8s version: %v", gitVersion)
+	}
+
+	// regex guarantees the submatches are numeric
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	patch, _ := strconv.Atoi(matches[3])
+
+	return &K8sVersion{
+		Major:      major,
+		Minor:      minor,
+		Patch:      patch,
+		GitVersion: gitVersion,
+	}, nil
+}
@@ -0,0 +1,228 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+const (
+	// defaultVolumeHealthDedupWindow is how long a finding with the same reason on the same object is
+	// suppressed unless WithDedupWindow is given
+	defaultVolumeHealthDedupWindow = 10 * time.Minute
+	// volumeHealthBufferSize is the number of findings buffered until the monitor blocks on the reader
+	volumeHealthBufferSize = 100
+)
+
+// volumeWarningReasons are the reasons of the warning events that signal a degraded volume
+var volumeWarningReasons = map[string]bool{
+	"FailedMount":        true,
+	"FailedAttachVolume": true,
+	"VolumeFailedDelete": true,
+	"ProvisioningFailed": true,
+}
+
+// volumeHealthScanner finds the problems of volumes and suppresses the ones already reported recently
+type volumeHealthScanner struct {
+	sync.Mutex
+	// since is the time before which events are ignored
+	since time.Time
+	// dedupWindow is how long a finding with the same reason on the same object is suppressed
+	dedupWindow time.Duration
+	// reported is when each finding was last reported, keyed by PVC, object and reason
+	reported map[string]time.Time
+}
+
+// CheckVolumeHealth returns the problems currently reported for the given PVC: the warning events about
+// mount, attach, provisioning or delete failures on the claim, its PV and the pods using it, and a Failed
+// phase of its PV
+func CheckVolumeHealth(pvc *v1.PersistentVolumeClaim) ([]VolumeHealthFinding, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return newVolumeHealthScanner(time.Time{}).scan(client, pvc)
+}
+
+// WithDedupWindow sets how long MonitorVolumeHealth suppresses a problem with the same reason on the same
+// object after reporting it. Zero reports the problem on every check.
+func WithDedupWindow(window time.Duration) VolumeHealthOption {
+	return func(s *volumeHealthScanner) {
+		s.dedupWindow = window
+	}
+}
+
+// MonitorVolumeHealth checks the given PVCs every interval for new problems, like CheckVolumeHealth, and
+// sends them on the returned channel until stopCh is closed. A problem with the same reason on the same
+// object is only reported again once it has not been reported for the dedup window, 10 minutes unless
+// WithDedupWindow is given. The channel is closed once the monitor exits.
+func MonitorVolumeHealth(
+	pvcs []*v1.PersistentVolumeClaim,
+	interval time.Duration,
+	stopCh <-chan struct{},
+	opts ...VolumeHealthOption,
+) (<-chan VolumeHealthFinding, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make(chan VolumeHealthFinding, volumeHealthBufferSize)
+	scanner := newVolumeHealthScanner(time.Now())
+	for _, opt := range opts {
+		opt(scanner)
+	}
+
+	go func() {
+		defer close(findings)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			for _, pvc := range pvcs {
+				found, err := scanner.scan(client, pvc)
+				if err != nil {
+					logrus.Warnf("Failed to check health of volume of pvc: %v. Err: %v", pvc.Name, err)
+					continue
+				}
+
+				for _, f := range found {
+					select {
+					case findings <- f:
+					case <-stopCh:
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	return findings, nil
+}
+
+func newVolumeHealthScanner(since time.Time) *volumeHealthScanner {
+	return &volumeHealthScanner{
+		since:       since,
+		dedupWindow: defaultVolumeHealthDedupWindow,
+		reported:    make(map[string]time.Time),
+	}
+}
+
+// scan returns the findings of the given PVC that were not reported within the dedup window
+func (s *volumeHealthScanner) scan(client *kubernetes.Clientset, pvc *v1.PersistentVolumeClaim) ([]VolumeHealthFinding, error) {
+	namespace := namespaceOrDefault(pvc.Namespace)
+	claim, err := client.CoreV1().PersistentVolumeClaims(namespace).Get(pvc.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []VolumeHealthFinding
+	add := func(f VolumeHealthFinding) {
+		if s.report(f) {
+			findings = append(findings, f)
+		}
+	}
+
+	key := namespace + "/" + claim.Name
+	events, err := getVolumeEvents(client, namespace, "PersistentVolumeClaim", claim.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := getPodsUsingPVC(client, claim)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		podEvents, err := getVolumeEvents(client, namespace, "Pod", pod.Name)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, podEvents...)
+	}
+
+	if len(claim.Spec.VolumeName) > 0 {
+		pv, err := client.CoreV1().PersistentVolumes().Get(claim.Spec.VolumeName, meta_v1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		if pv.Status.Phase == v1.VolumeFailed {
+			add(VolumeHealthFinding{
+				Time:    time.Now(),
+				PVC:     key,
+				Object:  "PersistentVolume/" + pv.Name,
+				Reason:  string(pv.Status.Phase),
+				Message: pv.Status.Message,
+			})
+		}
+
+		// Events of the cluster scoped PV are not in the namespace of the claim
+		pvEvents, err := getVolumeEvents(client, meta_v1.NamespaceAll, "PersistentVolume", pv.Name)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, pvEvents...)
+	}
+
+	for _, event := range events {
+		if event.LastTimestamp.Time.Before(s.since) {
+			continue
+		}
+
+		add(VolumeHealthFinding{
+			Time:    event.LastTimestamp.Time,
+			PVC:     key,
+			Object:  fmt.Sprintf("%v/%v", event.InvolvedObject.Kind, event.InvolvedObject.Name),
+			Reason:  event.Reason,
+			Message: event.Message,
+		})
+	}
+
+	return findings, nil
+}
+
+// report checks if the finding should be reported and records it as reported
+func (s *volumeHealthScanner) report(f VolumeHealthFinding) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	key := f.PVC + "/" + f.Object + "/" + f.Reason
+	if last, ok := s.reported[key]; ok && time.Since(last) < s.dedupWindow {
+		return false
+	}
+	s.reported[key] = time.Now()
+	return true
+}
+
+// getVolumeEvents returns the warning events about volume failures of the given object
+func getVolumeEvents(client *kubernetes.Clientset, namespace, kind, name string) ([]v1.Event, error) {
+	events, err := client.CoreV1().Events(namespace).List(meta_v1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=%v,involvedObject.name=%v,type=%v",
+			kind, name, v1.EventTypeWarning),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []v1.Event
+	for _, event := range events.Items {
+		if volumeWarningReasons[event.Reason] {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
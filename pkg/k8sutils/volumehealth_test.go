@@ -0,0 +1,49 @@
+package k8sutils
+
+import (
+	"testing"
+	"time"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+func TestMonitorVolumeHealthDedupWindow(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	pvc := &v1.PersistentVolumeClaim{ObjectMeta: meta_v1.ObjectMeta{Name: "data", Namespace: testNamespace}}
+	server.Add(pvc, &v1.Event{
+		ObjectMeta:     meta_v1.ObjectMeta{Name: "data.1", Namespace: testNamespace},
+		InvolvedObject: v1.ObjectReference{Kind: "PersistentVolumeClaim", Name: "data", Namespace: testNamespace},
+		Type:           v1.EventTypeWarning,
+		Reason:         "FailedMount",
+		// After the monitors start so that they don't ignore it
+		LastTimestamp: meta_v1.NewTime(time.Now().Add(time.Hour)),
+	})
+
+	countFindings := func(opts ...VolumeHealthOption) int {
+		stopCh := make(chan struct{})
+		findings, err := MonitorVolumeHealth([]*v1.PersistentVolumeClaim{pvc}, 10*time.Millisecond, stopCh, opts...)
+		if err != nil {
+			t.Fatalf("failed to start the monitor: %v", err)
+		}
+		time.AfterFunc(200*time.Millisecond, func() { close(stopCh) })
+
+		var count int
+		for f := range findings {
+			if f.Object != "PersistentVolumeClaim/data" || f.Reason != "FailedMount" {
+				t.Errorf("unexpected finding: %+v", f)
+			}
+			count++
+		}
+		return count
+	}
+
+	if count := countFindings(); count != 1 {
+		t.Errorf("expected the event to be reported once within the default window, got: %v findings", count)
+	}
+	if count := countFindings(WithDedupWindow(0)); count < 2 {
+		t.Errorf("expected the event to be reported on every check without a window, got: %v findings", count)
+	}
+}
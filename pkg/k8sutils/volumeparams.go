@@ -0,0 +1,113 @@
+package k8sutils
+
+import (
+	"fmt"
+	"sync"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+var (
+	paramsVerifierLock sync.RWMutex
+	// paramsVerifier is run by ValidatePersistentVolumeClaim on the volumes of bound PVCs
+	paramsVerifier VolumeParamsVerifier
+)
+
+// RegisterVolumeParamsVerifier registers the verifier ValidatePersistentVolumeClaim runs on the volume of
+// a PVC once it is bound, e.g that of the storage driver under test. A nil verifier unregisters it.
+func RegisterVolumeParamsVerifier(verifier VolumeParamsVerifier) {
+	paramsVerifierLock.Lock()
+	defer paramsVerifierLock.Unlock()
+	paramsVerifier = verifier
+}
+
+// ValidatePVCWithParams waits for the given PVC to be bound within the PVC bind timeout of the validation
+// profile, then checks with the verifier that its volume honors the parameters returned by
+// GetPersistentVolumeClaimParams. The registered verifier is used if the given one is nil. An
+// ErrVolumeParamsNotEffective is returned if the verifier fails.
+func ValidatePVCWithParams(pvc *v1.PersistentVolumeClaim, verifier VolumeParamsVerifier) error {
+	if verifier == nil {
+		if verifier = getParamsVerifier(); verifier == nil {
+			return fmt.Errorf("no volume params verifier given or registered to validate PVC: %v", pvc.Name)
+		}
+	}
+
+	if err := ValidatePVCsBound([]*v1.PersistentVolumeClaim{pvc}, validationProfile().PVCBindTimeout); err != nil {
+		return err
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	return verifyPVCParams(result, verifier)
+}
+
+// mergeVolumeParams returns the parameters of a volume from those of its storage class and those set in
+// the annotations of its PVC. The PVC annotations take precedence over the storage class parameters.
+func mergeVolumeParams(scParams, pvcParams map[string]string) map[string]string {
+	params := make(map[string]string)
+	for key, value := range scParams {
+		params[key] = value
+	}
+	for key, value := range pvcParams {
+		params[key] = value
+	}
+	return params
+}
+
+func getParamsVerifier() VolumeParamsVerifier {
+	paramsVerifierLock.RLock()
+	defer paramsVerifierLock.RUnlock()
+	return paramsVerifier
+}
+
+// runRegisteredParamsVerifier runs the registered verifier on the volume of the PVC. PVCs that are not
+// bound yet, as when waiting for their first consumer, are skipped.
+func runRegisteredParamsVerifier(pvc *v1.PersistentVolumeClaim) error {
+	verifier := getParamsVerifier()
+	if verifier == nil {
+		return nil
+	}
+
+	client, err := GetK8sClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := client.PersistentVolumeClaims(namespaceOrDefault(pvc.Namespace)).Get(pvc.Name, meta_v1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if result.Status.Phase != v1.ClaimBound {
+		return nil
+	}
+
+	return verifyPVCParams(result, verifier)
+}
+
+// verifyPVCParams runs the verifier on the volume of the given bound PVC
+func verifyPVCParams(pvc *v1.PersistentVolumeClaim, verifier VolumeParamsVerifier) error {
+	params, err := GetPersistentVolumeClaimParams(pvc)
+	if err != nil {
+		return err
+	}
+
+	if err := verifier(params, pvc.Spec.VolumeName); err != nil {
+		return &ErrVolumeParamsNotEffective{
+			PVC:   pvc.Name,
+			PV:    pvc.Spec.VolumeName,
+			Cause: err.Error(),
+		}
+	}
+
+	return nil
+}
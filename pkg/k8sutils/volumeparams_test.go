@@ -0,0 +1,126 @@
+package k8sutils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
+)
+
+func TestMergeVolumeParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		scParams  map[string]string
+		pvcParams map[string]string
+		expected  map[string]string
+	}{
+		{name: "empty", expected: map[string]string{}},
+		{
+			name:     "storage class only",
+			scParams: map[string]string{"repl": "3"},
+			expected: map[string]string{"repl": "3"},
+		},
+		{
+			name:      "pvc only",
+			pvcParams: map[string]string{"repl": "2"},
+			expected:  map[string]string{"repl": "2"},
+		},
+		{
+			name:      "pvc annotations override storage class",
+			scParams:  map[string]string{"repl": "3", "io_profile": "db"},
+			pvcParams: map[string]string{"repl": "1", "shared": "true"},
+			expected:  map[string]string{"repl": "1", "io_profile": "db", "shared": "true"},
+		},
+	}
+
+	for _, test := range tests {
+		if got := mergeVolumeParams(test.scParams, test.pvcParams); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.name, test.expected, got)
+		}
+	}
+}
+
+// newTestBoundPVC returns a PVC of 1Gi of the given storage class bound to the PV pv-<name>
+func newTestBoundPVC(name, scName string, annotations map[string]string) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:        name,
+			Namespace:   testNamespace,
+			Annotations: annotations,
+		},
+		Spec: v1.PersistentVolumeClaimSpec{
+			StorageClassName: &scName,
+			VolumeName:       "pv-" + name,
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+			},
+		},
+		Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+	}
+}
+
+func TestValidatePVCWithParamsMergesAnnotations(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	sc := &storage_v1beta1.StorageClass{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "px"},
+		Parameters: map[string]string{"repl": "3", "io_profile": "db"},
+	}
+	pvc := newTestBoundPVC("data", sc.Name, map[string]string{pxAnnotationPrefix + "repl": "2"})
+	server.Add(sc, pvc)
+
+	var gotParams map[string]string
+	var gotPV string
+	verifier := func(params map[string]string, pvName string) error {
+		gotParams, gotPV = params, pvName
+		return nil
+	}
+
+	if err := ValidatePVCWithParams(pvc, verifier); err != nil {
+		t.Fatalf("failed to validate the PVC: %v", err)
+	}
+
+	expected := map[string]string{"repl": "2", "io_profile": "db", "size": fmt.Sprintf("%d", 1<<30)}
+	if !reflect.DeepEqual(gotParams, expected) {
+		t.Errorf("expected params: %v, got: %v", expected, gotParams)
+	}
+	if gotPV != "pv-data" {
+		t.Errorf("expected PV: pv-data, got: %v", gotPV)
+	}
+
+	err := ValidatePVCWithParams(pvc, func(map[string]string, string) error {
+		return fmt.Errorf("repl is 1")
+	})
+	if notEffective, ok := err.(*ErrVolumeParamsNotEffective); !ok || notEffective.PV != "pv-data" {
+		t.Errorf("expected an ErrVolumeParamsNotEffective, got: %v", err)
+	}
+}
+
+func TestValidatePVCWithParamsUsesRegisteredVerifier(t *testing.T) {
+	server, cleanup := newTestAPIServer(t)
+	defer cleanup()
+
+	sc := &storage_v1beta1.StorageClass{ObjectMeta: meta_v1.ObjectMeta{Name: "px"}}
+	pvc := newTestBoundPVC("data", sc.Name, nil)
+	server.Add(sc, pvc)
+
+	if err := ValidatePVCWithParams(pvc, nil); err == nil {
+		t.Errorf("expected an error without a verifier")
+	}
+
+	called := false
+	RegisterVolumeParamsVerifier(func(map[string]string, string) error {
+		called = true
+		return nil
+	})
+	defer RegisterVolumeParamsVerifier(nil)
+
+	if err := ValidatePVCWithParams(pvc, nil); err != nil || !called {
+		t.Errorf("expected the registered verifier to be called, got called: %v err: %v", called, err)
+	}
+}
@@ -0,0 +1,207 @@
+package k8sutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/pkg/api/v1"
+)
+
+// statsSummaryPath is the path of the summary API of the kubelet, under the node proxy
+const statsSummaryPath = "stats/summary"
+
+// statsSummary is the part of the kubelet stats summary with the volume stats of the pods
+type statsSummary struct {
+	Pods []struct {
+		PodRef struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"podRef"`
+		Volume []struct {
+			Name           string       `json:"name"`
+			Time           meta_v1.Time `json:"time"`
+			CapacityBytes  *uint64      `json:"capacityBytes"`
+			UsedBytes      *uint64      `json:"usedBytes"`
+			AvailableBytes *uint64      `json:"availableBytes"`
+			Inodes         *uint64      `json:"inodes"`
+			InodesUsed     *uint64      `json:"inodesUsed"`
+			InodesFree     *uint64      `json:"inodesFree"`
+			// PVCRef is only reported by kubelets of k8s 1.8 and above
+			PVCRef *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"pvcRef"`
+		} `json:"volume"`
+	} `json:"pods"`
+}
+
+// GetPVCStatsFromNode returns the stats of the volumes of the PVCs mounted on the given node, keyed by
+// <namespace>/<name> of the PVC, from the kubelet summary API through the api server node proxy. An
+// ErrStatsUnavailable is returned if the summary API of the kubelet is disabled.
+func GetPVCStatsFromNode(nodeName string) (map[string]PVCStats, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	return getPVCStatsFromNode(client, nodeName)
+}
+
+// GetPVCStats returns the stats of the volume of the given PVC from the kubelet of the node of a running
+// pod using it
+func GetPVCStats(pvc *v1.PersistentVolumeClaim) (*PVCStats, error) {
+	client, err := GetK8sClient()
+	if err != nil {
+		return nil, err
+	}
+
+	claim := *pvc
+	claim.Namespace = namespaceOrDefault(pvc.Namespace)
+	pods, err := getPodsUsingPVC(client, &claim)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodeName string
+	for _, pod := range pods {
+		if pod.Status.Phase == v1.PodRunning && len(pod.Spec.NodeName) > 0 {
+			nodeName = pod.Spec.NodeName
+			break
+		}
+	}
+
+	if len(nodeName) == 0 {
+		return nil, fmt.Errorf("pvc: %v/%v is not used by a running pod", claim.Namespace, claim.Name)
+	}
+
+	stats, err := getPVCStatsFromNode(client, nodeName)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := stats[claim.Namespace+"/"+claim.Name]
+	if !ok {
+		return nil, &ErrStatsUnavailable{
+			Node:  nodeName,
+			Cause: fmt.Sprintf("kubelet did not report stats for pvc: %v/%v", claim.Namespace, claim.Name),
+		}
+	}
+
+	return &s, nil
+}
+
+// SamplePVCStats collects the stats of the volume of the given PVC count times, interval apart, e.g to
+// compare the usage before and after an I/O run
+func SamplePVCStats(pvc *v1.PersistentVolumeClaim, interval time.Duration, count int) ([]PVCStats, error) {
+	var samples []PVCStats
+	for i := 0; i < count; i++ {
+		if i > 0 {
+			time.Sleep(interval)
+		}
+
+		s, err := GetPVCStats(pvc)
+		if err != nil {
+			return samples, err
+		}
+		samples = append(samples, *s)
+	}
+
+	return samples, nil
+}
+
+func getPVCStatsFromNode(client *kubernetes.Clientset, nodeName string) (map[string]PVCStats, error) {
+	data, err := client.CoreV1().RESTClient().Get().
+		Resource("nodes").
+		Name(nodeName).
+		SubResource("proxy").
+		Suffix(statsSummaryPath).
+		DoRaw()
+	if err != nil {
+		if k8s_errors.IsNotFound(err) || k8s_errors.IsMethodNotSupported(err) || isServiceUnavailable(err) {
+			return nil, &ErrStatsUnavailable{
+				Node:  nodeName,
+				Cause: err.Error(),
+			}
+		}
+		return nil, err
+	}
+
+	var summary statsSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, &ErrStatsUnavailable{
+			Node:  nodeName,
+			Cause: fmt.Sprintf("failed to parse stats summary. Err: %v", err),
+		}
+	}
+
+	stats := make(map[string]PVCStats)
+	for _, pod := range summary.Pods {
+		// Older kubelets don't report the PVC of a volume so it is resolved from the pod spec
+		var claims map[string]string
+		for _, volume := range pod.Volume {
+			var key string
+			if volume.PVCRef != nil {
+				key = volume.PVCRef.Namespace + "/" + volume.PVCRef.Name
+			} else {
+				if claims == nil {
+					if claims, err = getPodVolumeClaims(client, pod.PodRef.Namespace, pod.PodRef.Name); err != nil {
+						return nil, err
+					}
+				}
+				claim, ok := claims[volume.Name]
+				if !ok {
+					continue
+				}
+				key = pod.PodRef.Namespace + "/" + claim
+			}
+
+			stats[key] = PVCStats{
+				Time:           volume.Time.Time,
+				CapacityBytes:  uint64Value(volume.CapacityBytes),
+				UsedBytes:      uint64Value(volume.UsedBytes),
+				AvailableBytes: uint64Value(volume.AvailableBytes),
+				Inodes:         uint64Value(volume.Inodes),
+				InodesUsed:     uint64Value(volume.InodesUsed),
+				InodesFree:     uint64Value(volume.InodesFree),
+			}
+		}
+	}
+
+	return stats, nil
+}
+
+// getPodVolumeClaims returns the names of the PVCs of the volumes of the given pod keyed by volume name
+func getPodVolumeClaims(client *kubernetes.Clientset, namespace, name string) (map[string]string, error) {
+	claims := make(map[string]string)
+	pod, err := client.CoreV1().Pods(namespace).Get(name, meta_v1.GetOptions{})
+	if err != nil {
+		if k8s_errors.IsNotFound(err) {
+			return claims, nil
+		}
+		return nil, err
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim != nil {
+			claims[volume.Name] = volume.PersistentVolumeClaim.ClaimName
+		}
+	}
+	return claims, nil
+}
+
+func isServiceUnavailable(err error) bool {
+	status, ok := err.(k8s_errors.APIStatus)
+	return ok && status.Status().Code == http.StatusServiceUnavailable
+}
+
+func uint64Value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
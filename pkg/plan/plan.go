@@ -0,0 +1,159 @@
+package plan
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// VerbCordon marks a node unschedulable
+	VerbCordon = "cordon"
+	// VerbUncordon marks a node schedulable again
+	VerbUncordon = "uncordon"
+	// VerbDelete deletes an object
+	VerbDelete = "delete"
+)
+
+// Plan is the ordered list of the mutations an orchestration helper makes. It is returned instead of
+// executing the helper with WithDryRun, and can be given back with WithPlan to execute exactly what was
+// reviewed.
+type Plan struct {
+	// Operation is the name of the helper
+	Operation string `json:"operation"`
+	// Actions are the mutations in the order the helper makes them
+	Actions []Action `json:"actions"`
+}
+
+// Action is a mutation of a plan
+type Action struct {
+	// Verb is the mutation (e.g cordon, delete or disable-portworx)
+	Verb string `json:"verb"`
+	// Kind is the kind of the target (e.g node or pod)
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the target. Empty for cluster objects.
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the target
+	Name string `json:"name"`
+	// UID is the UID of the target if it was resolved when the plan was made
+	UID string `json:"uid,omitempty"`
+	// Node is the node the target runs on, for targets that run on a node (e.g pods)
+	Node string `json:"node,omitempty"`
+}
+
+// Option is an option for the orchestration helpers that make many mutations, like EvacuateAppFromNode
+// of k8sutils
+type Option func(*options)
+
+type options struct {
+	dryRun   *Plan
+	reviewed *Plan
+}
+
+// WithDryRun makes an orchestration helper resolve its targets and fill the given plan with the actions it
+// would take, without executing any of them
+func WithDryRun(plan *Plan) Option {
+	return func(o *options) {
+		o.dryRun = plan
+	}
+}
+
+// WithPlan makes an orchestration helper execute only if the actions it resolves are those of the given
+// plan, e.g one made earlier with WithDryRun. An ErrMismatch is returned otherwise.
+func WithPlan(plan *Plan) Option {
+	return func(o *options) {
+		o.reviewed = plan
+	}
+}
+
+// ErrMismatch error type for when the actions an orchestration helper resolved differ from the plan
+// given to execute
+type ErrMismatch struct {
+	// Operation is the name of the helper
+	Operation string
+	// Planned is the plan that was given
+	Planned *Plan
+	// Resolved is the plan resolved from the current state of the cluster
+	Resolved *Plan
+}
+
+func (e *ErrMismatch) Error() string {
+	return fmt.Sprintf("%v no longer matches the reviewed plan. Planned: %v Resolved: %v",
+		e.Operation, e.Planned, e.Resolved)
+}
+
+// Check applies the plan options of an orchestration helper to the actions it resolved. It returns false
+// if the helper must not execute, as with WithDryRun where the plan is filled instead, and an ErrMismatch
+// if the actions are not those of the plan given with WithPlan. Helpers call it once their targets are
+// resolved.
+func Check(operation string, actions []Action, opts []Option) (bool, error) {
+	o := newOptions(opts)
+	resolved := &Plan{
+		Operation: operation,
+		Actions:   actions,
+	}
+
+	if o.dryRun != nil {
+		*o.dryRun = *resolved
+		return false, nil
+	}
+
+	if o.reviewed != nil && (o.reviewed.Operation != operation || !EqualActions(o.reviewed.Actions, actions)) {
+		return false, &ErrMismatch{
+			Operation: operation,
+			Planned:   o.reviewed,
+			Resolved:  resolved,
+		}
+	}
+
+	return true, nil
+}
+
+// Reviewed returns the plan given with WithPlan, or nil. Helpers whose targets are only final when a step
+// runs (e.g the pods on a node after the earlier nodes were drained) compare them to it at that step.
+func Reviewed(opts []Option) *Plan {
+	return newOptions(opts).reviewed
+}
+
+// EqualActions checks if the given actions are the same, in the same order
+func EqualActions(a, b []Action) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the actions of the plan, one per line
+func (p *Plan) String() string {
+	lines := []string{fmt.Sprintf("%v:", p.Operation)}
+	for i, a := range p.Actions {
+		lines = append(lines, fmt.Sprintf("%d. %v", i+1, a))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// String returns the verb and target of the action
+func (a Action) String() string {
+	target := a.Name
+	if len(a.Namespace) > 0 {
+		target = a.Namespace + "/" + a.Name
+	}
+
+	action := fmt.Sprintf("%v %v/%v", a.Verb, a.Kind, target)
+	if len(a.Node) > 0 {
+		action = fmt.Sprintf("%v on node %v", action, a.Node)
+	}
+	return action
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
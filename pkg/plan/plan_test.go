@@ -0,0 +1,65 @@
+package plan
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testActions = []Action{
+	{Verb: VerbCordon, Kind: "node", Name: "node1"},
+	{Verb: VerbDelete, Kind: "pod", Namespace: "test", Name: "web-1", UID: "web-1-uid", Node: "node1"},
+	{Verb: VerbUncordon, Kind: "node", Name: "node1"},
+}
+
+func TestCheck(t *testing.T) {
+	execute, err := Check("Evacuate", testActions, nil)
+	if !execute || err != nil {
+		t.Errorf("expected to execute without options, got: %v, %v", execute, err)
+	}
+
+	var dryRun Plan
+	execute, err = Check("Evacuate", testActions, []Option{WithDryRun(&dryRun)})
+	if execute || err != nil {
+		t.Errorf("expected not to execute a dry run, got: %v, %v", execute, err)
+	}
+	if dryRun.Operation != "Evacuate" || !reflect.DeepEqual(dryRun.Actions, testActions) {
+		t.Errorf("expected the dry run to fill the plan, got: %+v", dryRun)
+	}
+
+	execute, err = Check("Evacuate", testActions, []Option{WithPlan(&dryRun)})
+	if !execute || err != nil {
+		t.Errorf("expected to execute the reviewed plan, got: %v, %v", execute, err)
+	}
+
+	for name, reviewed := range map[string]*Plan{
+		"other operation": {Operation: "Drain", Actions: testActions},
+		"other actions":   {Operation: "Evacuate", Actions: testActions[:2]},
+	} {
+		execute, err = Check("Evacuate", testActions, []Option{WithPlan(reviewed)})
+		if _, ok := err.(*ErrMismatch); execute || !ok {
+			t.Errorf("%v: expected an ErrMismatch, got: %v, %v", name, execute, err)
+		}
+	}
+}
+
+func TestReviewed(t *testing.T) {
+	if reviewed := Reviewed(nil); reviewed != nil {
+		t.Errorf("expected no reviewed plan, got: %v", reviewed)
+	}
+
+	p := &Plan{Operation: "Evacuate"}
+	if reviewed := Reviewed([]Option{WithPlan(p)}); reviewed != p {
+		t.Errorf("expected the plan given with WithPlan, got: %v", reviewed)
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	p := &Plan{Operation: "Evacuate", Actions: testActions}
+	expected := "Evacuate:\n" +
+		"1. cordon node/node1\n" +
+		"2. delete pod/test/web-1 on node node1\n" +
+		"3. uncordon node/node1"
+	if s := p.String(); s != expected {
+		t.Errorf("expected:\n%v\ngot:\n%v", expected, s)
+	}
+}
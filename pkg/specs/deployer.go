@@ -0,0 +1,155 @@
+package specs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AppResult is the outcome of an app submitted to an AppDeployer
+type AppResult struct {
+	// Submission is the index of the app in the order it was submitted, starting at 0
+	Submission int
+	// Objects are the objects created for the app, in the order they were created. On failure, these are
+	// the objects created before the failure.
+	Objects []interface{}
+	// TimeToReady is how long the app took to be created and validated. Zero on failure.
+	TimeToReady time.Duration
+	// Err is set if the app could not be created or validated, or if it was not deployed as the context
+	// of the deployer was canceled first
+	Err error
+}
+
+// AppDeployer deploys and validates apps concurrently with DeployAndValidate. The api requests of all apps
+// are subject to the in-flight request limit of k8sutils.
+type AppDeployer struct {
+	ctx   context.Context
+	slots chan struct{}
+
+	lock      sync.Mutex
+	cond      *sync.Cond
+	submitted int
+	closed    bool
+	// pending are the results not yet delivered on the results channel
+	pending []AppResult
+	done    bool
+
+	wg      sync.WaitGroup
+	results chan AppResult
+}
+
+// NewAppDeployer returns a deployer running at most concurrency deployments at a time. Once the context
+// is canceled, the apps that are not being deployed yet are not deployed and their result has the error of
+// the context. The deployments in progress run to completion.
+func NewAppDeployer(ctx context.Context, concurrency int) *AppDeployer {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	d := &AppDeployer{
+		ctx:     ctx,
+		slots:   make(chan struct{}, concurrency),
+		results: make(chan AppResult),
+	}
+	d.cond = sync.NewCond(&d.lock)
+
+	go d.deliver()
+	return d
+}
+
+// Submit queues the given objects of an app, as returned by App.Objects, to be deployed and returns the
+// index of the submission. It doesn't block. An error is returned once Wait was called.
+func (d *AppDeployer) Submit(objs []interface{}) (int, error) {
+	d.lock.Lock()
+	if d.closed {
+		d.lock.Unlock()
+		return 0, fmt.Errorf("app deployer is closed")
+	}
+	submission := d.submitted
+	d.submitted++
+	d.wg.Add(1)
+	d.lock.Unlock()
+
+	go func() {
+		defer d.wg.Done()
+		d.push(d.deploy(submission, objs))
+	}()
+
+	return submission, nil
+}
+
+// Results returns the channel on which the result of each submitted app is delivered as it completes, in
+// completion order. The channel is closed once Wait was called and all the results were delivered.
+// Results are queued until they are read, so a slow reader doesn't hold up the deployments.
+func (d *AppDeployer) Results() <-chan AppResult {
+	return d.results
+}
+
+// Wait blocks until all the submitted apps are deployed or failed. Submit fails once Wait was called.
+func (d *AppDeployer) Wait() {
+	d.lock.Lock()
+	d.closed = true
+	d.lock.Unlock()
+
+	d.wg.Wait()
+
+	d.lock.Lock()
+	d.done = true
+	d.cond.Broadcast()
+	d.lock.Unlock()
+}
+
+func (d *AppDeployer) deploy(submission int, objs []interface{}) AppResult {
+	result := AppResult{
+		Submission: submission,
+	}
+
+	select {
+	case <-d.ctx.Done():
+		result.Err = d.ctx.Err()
+		return result
+	case d.slots <- struct{}{}:
+	}
+	defer func() { <-d.slots }()
+
+	// The context can be canceled while waiting for a slot
+	if err := d.ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+
+	start := time.Now()
+	result.Objects, result.Err = deployAndValidate(objs)
+	if result.Err == nil {
+		result.TimeToReady = time.Since(start)
+	}
+	return result
+}
+
+func (d *AppDeployer) push(result AppResult) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.pending = append(d.pending, result)
+	d.cond.Broadcast()
+}
+
+// deliver sends the pending results on the results channel and closes it once the deployer is done
+func (d *AppDeployer) deliver() {
+	for {
+		d.lock.Lock()
+		for len(d.pending) == 0 && !d.done {
+			d.cond.Wait()
+		}
+		if len(d.pending) == 0 {
+			d.lock.Unlock()
+			close(d.results)
+			return
+		}
+		result := d.pending[0]
+		d.pending = d.pending[1:]
+		d.lock.Unlock()
+
+		d.results <- result
+	}
+}
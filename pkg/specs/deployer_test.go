@@ -0,0 +1,185 @@
+package specs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// collectResults reads the results of the deployer until its channel is closed, failing the test if that
+// takes longer than the given timeout
+func collectResults(t *testing.T, d *AppDeployer, timeout time.Duration) map[int]AppResult {
+	results := make(map[int]AppResult)
+	deadline := time.After(timeout)
+	for {
+		select {
+		case result, ok := <-d.Results():
+			if !ok {
+				return results
+			}
+			if _, dup := results[result.Submission]; dup {
+				t.Errorf("submission %d was delivered twice", result.Submission)
+			}
+			results[result.Submission] = result
+		case <-deadline:
+			t.Fatalf("results channel not closed after %v. Got %d results", timeout, len(results))
+		}
+	}
+}
+
+func TestAppDeployerDeliversAllResults(t *testing.T) {
+	server, cleanup := newTestCluster(t)
+	defer cleanup()
+
+	// The deployment of app-1 is rejected, the first apps are the slowest to create
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method != http.MethodPost || req.Resource != "deployments" {
+			return false, 0, nil
+		}
+		switch getString(req.Body, "metadata", "name") {
+		case "app-0":
+			time.Sleep(100 * time.Millisecond)
+		case "app-1":
+			return true, http.StatusForbidden, k8stest.Status(http.StatusForbidden, "Forbidden", "quota exceeded")
+		}
+		return false, 0, nil
+	})
+
+	d := NewAppDeployer(context.Background(), 3)
+	for i := 0; i < 6; i++ {
+		submission, err := d.Submit(newTestAppObjects(fmt.Sprintf("app-%d", i)))
+		if err != nil || submission != i {
+			t.Fatalf("expected submission %d, got: %d, %v", i, submission, err)
+		}
+	}
+
+	// Results are read as they complete, while Wait blocks
+	waited := make(chan struct{})
+	go func() {
+		d.Wait()
+		close(waited)
+	}()
+	results := collectResults(t, d, 10*time.Second)
+	<-waited
+
+	if len(results) != 6 {
+		t.Fatalf("expected 6 results, got: %v", results)
+	}
+	for i, result := range results {
+		if i == 1 {
+			if result.Err == nil || result.TimeToReady != 0 {
+				t.Errorf("expected app-1 to fail without a time to ready, got: %+v", result)
+			}
+			continue
+		}
+
+		if result.Err != nil {
+			t.Errorf("app-%d: expected to be deployed, got: %v", i, result.Err)
+			continue
+		}
+		if len(result.Objects) != 2 || result.TimeToReady <= 0 {
+			t.Errorf("app-%d: expected the deployment and service with a time to ready, got: %+v", i, result)
+		}
+		if dep, ok := result.Objects[0].(*v1beta1.Deployment); !ok || dep.Name != fmt.Sprintf("app-%d", i) {
+			t.Errorf("app-%d: expected the created deployment first, got: %#v", i, result.Objects[0])
+		}
+	}
+
+	if _, err := d.Submit(newTestAppObjects("late")); err == nil {
+		t.Errorf("expected Submit to fail after Wait")
+	}
+}
+
+func TestAppDeployerConcurrency(t *testing.T) {
+	server, cleanup := newTestCluster(t)
+	defer cleanup()
+
+	var (
+		lock                  sync.Mutex
+		inflight, maxInflight int
+	)
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method != http.MethodPost || req.Resource != "deployments" {
+			return false, 0, nil
+		}
+		lock.Lock()
+		inflight++
+		if inflight > maxInflight {
+			maxInflight = inflight
+		}
+		lock.Unlock()
+
+		time.Sleep(50 * time.Millisecond)
+
+		lock.Lock()
+		inflight--
+		lock.Unlock()
+		return false, 0, nil
+	})
+
+	d := NewAppDeployer(context.Background(), 2)
+	for i := 0; i < 6; i++ {
+		if _, err := d.Submit(newTestAppObjects(fmt.Sprintf("app-%d", i))); err != nil {
+			t.Fatalf("failed to submit app-%d: %v", i, err)
+		}
+	}
+	go d.Wait()
+
+	for i, result := range collectResults(t, d, 10*time.Second) {
+		if result.Err != nil {
+			t.Errorf("app-%d: expected to be deployed, got: %v", i, result.Err)
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if maxInflight != 2 {
+		t.Errorf("expected 2 deployments at a time, got at most %d", maxInflight)
+	}
+}
+
+func TestAppDeployerCanceled(t *testing.T) {
+	server, cleanup := newTestCluster(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	d := NewAppDeployer(ctx, 2)
+	for i := 0; i < 3; i++ {
+		if _, err := d.Submit(newTestAppObjects(fmt.Sprintf("app-%d", i))); err != nil {
+			t.Fatalf("failed to submit app-%d: %v", i, err)
+		}
+	}
+	go d.Wait()
+
+	results := collectResults(t, d, 10*time.Second)
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got: %v", results)
+	}
+	for i, result := range results {
+		if result.Err != context.Canceled || len(result.Objects) > 0 {
+			t.Errorf("app-%d: expected the cancellation error without objects, got: %+v", i, result)
+		}
+	}
+
+	if requests := server.Requests(); len(requests) > 0 {
+		t.Errorf("expected no api requests once canceled, got: %+v", requests)
+	}
+}
+
+// getString returns the string at the given path of a decoded JSON object
+func getString(obj map[string]interface{}, path ...string) string {
+	for _, key := range path[:len(path)-1] {
+		next, _ := obj[key].(map[string]interface{})
+		obj = next
+	}
+	s, _ := obj[path[len(path)-1]].(string)
+	return s
+}
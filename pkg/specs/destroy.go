@@ -0,0 +1,119 @@
+package specs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/task"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
+)
+
+// destroyRetryInterval is the wait between the checks for PVCs and storage classes to be unused
+const destroyRetryInterval = 5 * time.Second
+
+// DestroyAppObjects deletes the given objects, as returned by App.Objects or the spec factory, in
+// dependency order: deployments and statefulsets first, waiting for them to terminate, then services,
+// config maps and secrets, then PVCs once no pod uses them, waiting for them to be gone, and last storage
+// classes once no PVC refers to them. Objects that are already gone are skipped. The deletion goes on
+// after a failure and the failures are returned together.
+func DestroyAppObjects(objs []interface{}, timeout time.Duration) error {
+	var (
+		deployments  []*v1beta1.Deployment
+		statefulSets []*v1beta1.StatefulSet
+		services     []*v1.Service
+		configMaps   []*v1.ConfigMap
+		secrets      []*v1.Secret
+		pvcs         []*v1.PersistentVolumeClaim
+		scs          []*storage_v1beta1.StorageClass
+	)
+
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *v1beta1.Deployment:
+			deployments = append(deployments, o)
+		case *v1beta1.StatefulSet:
+			statefulSets = append(statefulSets, o)
+		case *v1.Service:
+			services = append(services, o)
+		case *v1.ConfigMap:
+			configMaps = append(configMaps, o)
+		case *v1.Secret:
+			secrets = append(secrets, o)
+		case *v1.PersistentVolumeClaim:
+			pvcs = append(pvcs, o)
+		case *storage_v1beta1.StorageClass:
+			scs = append(scs, o)
+		default:
+			return fmt.Errorf("unsupported object: %#v", obj)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	var failed []string
+	record := func(kind, name string, err error) {
+		if err != nil && !k8s_errors.IsNotFound(err) {
+			failed = append(failed, fmt.Sprintf("%v/%v: %v", kind, name, err))
+		}
+	}
+
+	for _, dep := range deployments {
+		record("deployment", dep.Name, k8sutils.DeleteDeployment(dep))
+	}
+	for _, ss := range statefulSets {
+		record("statefulset", ss.Name, k8sutils.DeleteStatefulSet(ss))
+	}
+
+	for _, dep := range deployments {
+		record("deployment", dep.Name, k8sutils.ValidateTerminatedDeploymentWithTimeout(dep, remaining(deadline)))
+	}
+	for _, ss := range statefulSets {
+		record("statefulset", ss.Name, k8sutils.ValidateTerminatedStatefulSet(ss, remaining(deadline)))
+	}
+
+	for _, service := range services {
+		record("service", service.Name, k8sutils.DeleteService(service))
+	}
+	for _, configMap := range configMaps {
+		record("configmap", configMap.Name, k8sutils.DeleteConfigMap(configMap))
+	}
+	for _, secret := range secrets {
+		record("secret", secret.Name, k8sutils.DeleteSecret(secret))
+	}
+
+	for _, pvc := range pvcs {
+		err := k8sutils.DeletePersistentVolumeClaimSafe(pvc, remaining(deadline))
+		if err == nil {
+			err = k8sutils.ValidateDeletedPersistentVolumeClaim(pvc, remaining(deadline))
+		}
+		record("pvc", pvc.Name, err)
+	}
+
+	for _, sc := range scs {
+		t := func() error {
+			if err := k8sutils.DeleteStorageClassSafe(sc, false); err != nil && !k8s_errors.IsNotFound(err) {
+				return err
+			}
+			return nil
+		}
+		record("storageclass", sc.Name, task.DoRetryWithTimeout(t, remaining(deadline), destroyRetryInterval))
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to destroy %d of %d objects: %v", len(failed), len(objs), failed)
+	}
+
+	return nil
+}
+
+// remaining returns the time left until the deadline, at least one retry interval so that each wait
+// checks at least once
+func remaining(deadline time.Time) time.Duration {
+	if left := time.Until(deadline); left > destroyRetryInterval {
+		return left
+	}
+	return destroyRetryInterval
+}
@@ -0,0 +1,87 @@
+package specs
+
+import (
+	"net/http"
+	"testing"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// newTestStatefulApp returns the objects of a mysql app along with a statefulset reading a config map
+func newTestStatefulApp() []interface{} {
+	app := NewMySQLApp(testNamespace, "px", 1, nil)
+
+	replicas := int32(2)
+	labels := map[string]string{appLabel: "cache"}
+	ss := &v1beta1.StatefulSet{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cache", Namespace: testNamespace},
+		Spec: v1beta1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: "cache",
+			Selector:    &meta_v1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{Labels: labels},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{{
+						Name:  "cache",
+						Image: "redis",
+						EnvFrom: []v1.EnvFromSource{{
+							ConfigMapRef: &v1.ConfigMapEnvSource{
+								LocalObjectReference: v1.LocalObjectReference{Name: "cache"},
+							},
+						}},
+					}},
+				},
+			},
+		},
+	}
+	configMap := &v1.ConfigMap{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cache", Namespace: testNamespace},
+		Data:       map[string]string{"MAXMEMORY": "64mb"},
+	}
+
+	return append(app.Objects(), ss, configMap)
+}
+
+func TestDeployAndDestroyAreSymmetric(t *testing.T) {
+	server, cleanup := newTestCluster(t)
+	defer cleanup()
+	bindTestPVCs(server)
+
+	objs := newTestStatefulApp()
+	if err := DeployAndValidate(objs); err != nil {
+		t.Fatalf("failed to deploy the app: %v", err)
+	}
+
+	expected := []string{"secrets", "configmaps", "persistentvolumeclaims", "deployments", "statefulsets", "services"}
+	var created []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPost {
+			created = append(created, req.Resource)
+		}
+	}
+	if len(created) != len(expected) {
+		t.Fatalf("expected the objects to be created in the order: %v, got: %v", expected, created)
+	}
+	for i := range expected {
+		if created[i] != expected[i] {
+			t.Fatalf("expected the objects to be created in the order: %v, got: %v", expected, created)
+		}
+	}
+
+	if err := DestroyAppObjects(objs, 0); err != nil {
+		t.Fatalf("failed to destroy the app: %v", err)
+	}
+	for _, resource := range append(expected, "replicasets", "pods") {
+		if count := server.Count(resource); count > 0 {
+			t.Errorf("expected all the %v to be deleted, %d remain", resource, count)
+		}
+	}
+
+	// Destroying objects that are already gone is not an error
+	if err := DestroyAppObjects(objs, 0); err != nil {
+		t.Errorf("expected destroying the deleted objects to succeed, got: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+package specs
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"github.com/portworx/torpedo/pkg/k8sutils/k8stest"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	ext_v1beta1 "k8s.io/client-go/pkg/apis/extensions/v1beta1"
+)
+
+const testNamespace = "test"
+
+// newTestCluster starts a k8stest server that the k8sutils helpers talk to until the returned func is
+// called, with short validation timeouts. Created deployments and statefulsets are rolled out by a fake
+// controller the first time they are read: the replica set and ready pods are added and the status reports
+// them. Deleting a deployment or statefulset removes the objects added for it, like the garbage collector.
+func newTestCluster(t *testing.T) (*k8stest.Server, func()) {
+	server := k8stest.NewServer()
+	k8sutils.SetRestConfig(server.Config())
+
+	profile := k8sutils.GetValidationProfile()
+	k8sutils.SetValidationProfile(k8sutils.Profile{
+		Name:             "test",
+		AppReadyTimeout:  time.Second,
+		AppDeleteTimeout: time.Second,
+		PVCBindTimeout:   time.Second,
+		RetryInterval:    10 * time.Millisecond,
+	})
+
+	var lock sync.Mutex
+	dependents := make(map[string][]testObjectKey)
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if len(req.Name) == 0 || (req.Resource != "deployments" && req.Resource != "statefulsets") {
+			return false, 0, nil
+		}
+
+		lock.Lock()
+		defer lock.Unlock()
+		owner := fmt.Sprintf("%v/%v/%v", req.Resource, req.Namespace, req.Name)
+		switch req.Method {
+		case http.MethodGet:
+			if _, ok := dependents[owner]; ok {
+				break
+			}
+			var added []testObjectKey
+			if req.Resource == "deployments" {
+				added = rolloutTestDeployment(server, req.Namespace, req.Name)
+			} else {
+				added = rolloutTestStatefulSet(server, req.Namespace, req.Name)
+			}
+			if added != nil {
+				dependents[owner] = added
+			}
+		case http.MethodDelete:
+			for _, key := range dependents[owner] {
+				server.Remove(key.resource, req.Namespace, key.name)
+			}
+			delete(dependents, owner)
+		}
+		return false, 0, nil
+	})
+
+	return server, func() {
+		k8sutils.SetValidationProfile(profile)
+		k8sutils.SetRestConfig(nil)
+		server.Close()
+	}
+}
+
+// testObjectKey is an object added to the server by a fake controller
+type testObjectKey struct {
+	resource string
+	name     string
+}
+
+// rolloutTestDeployment adds the replica set and ready pods of the given deployment and returns them, or
+// nil if the deployment doesn't exist
+func rolloutTestDeployment(server *k8stest.Server, namespace, name string) []testObjectKey {
+	var dep v1beta1.Deployment
+	if !server.Get("deployments", namespace, name, &dep) {
+		return nil
+	}
+
+	hash := "abc"
+	labels := map[string]string{"pod-template-hash": hash}
+	for key, value := range dep.Spec.Template.Labels {
+		labels[key] = value
+	}
+
+	rs := &ext_v1beta1.ReplicaSet{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            fmt.Sprintf("%v-%v", name, hash),
+			Namespace:       namespace,
+			UID:             dep.UID + "-rs",
+			Labels:          labels,
+			Annotations:     map[string]string{"deployment.kubernetes.io/revision": "1"},
+			OwnerReferences: []meta_v1.OwnerReference{{Kind: "Deployment", Name: name, UID: dep.UID}},
+		},
+		Spec: ext_v1beta1.ReplicaSetSpec{Replicas: dep.Spec.Replicas},
+	}
+	rs.Spec.Template = dep.Spec.Template
+	rs.Spec.Template.Labels = labels
+	server.Add(rs)
+
+	added := []testObjectKey{{"replicasets", rs.Name}}
+	replicas := getReplicas(dep.Spec.Replicas)
+	for i := int32(0); i < replicas; i++ {
+		pod := newTestReadyPod(fmt.Sprintf("%v-%d", rs.Name, i), namespace, labels, dep.Spec.Template.Spec)
+		pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "ReplicaSet", Name: rs.Name, UID: rs.UID}}
+		server.Add(pod)
+		added = append(added, testObjectKey{"pods", pod.Name})
+	}
+
+	dep.Status = v1beta1.DeploymentStatus{
+		ObservedGeneration: 1,
+		Replicas:           replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+	}
+	server.Add(&dep)
+	return added
+}
+
+// rolloutTestStatefulSet adds the ready pods of the given statefulset and returns them, or nil if the
+// statefulset doesn't exist
+func rolloutTestStatefulSet(server *k8stest.Server, namespace, name string) []testObjectKey {
+	var ss v1beta1.StatefulSet
+	if !server.Get("statefulsets", namespace, name, &ss) {
+		return nil
+	}
+
+	added := []testObjectKey{}
+	replicas := getReplicas(ss.Spec.Replicas)
+	for i := int32(0); i < replicas; i++ {
+		pod := newTestReadyPod(fmt.Sprintf("%v-%d", name, i), namespace, ss.Spec.Template.Labels, ss.Spec.Template.Spec)
+		pod.OwnerReferences = []meta_v1.OwnerReference{{Kind: "StatefulSet", Name: name, UID: ss.UID}}
+		server.Add(pod)
+		added = append(added, testObjectKey{"pods", pod.Name})
+	}
+
+	generation := ss.Generation
+	ss.Status = v1beta1.StatefulSetStatus{
+		ObservedGeneration: &generation,
+		Replicas:           replicas,
+		ReadyReplicas:      replicas,
+	}
+	server.Add(&ss)
+	return added
+}
+
+// newTestReadyPod returns a running and ready pod of the given template on node1
+func newTestReadyPod(name, namespace string, labels map[string]string, spec v1.PodSpec) *v1.Pod {
+	spec.NodeName = "node1"
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: spec,
+		Status: v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		},
+	}
+}
+
+func getReplicas(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// bindTestPVCs makes the server bind the PVCs the first time they are read, like a provisioner
+func bindTestPVCs(server *k8stest.Server) {
+	server.AddReactor(func(req *k8stest.Request) (bool, int, interface{}) {
+		if req.Method == http.MethodGet && req.Resource == "persistentvolumeclaims" && len(req.Name) > 0 {
+			var pvc v1.PersistentVolumeClaim
+			if server.Get(req.Resource, req.Namespace, req.Name, &pvc) && pvc.Status.Phase != v1.ClaimBound {
+				pvc.Spec.VolumeName = "pvc-" + string(pvc.UID)
+				pvc.Status.Phase = v1.ClaimBound
+				server.Add(&pvc)
+			}
+		}
+		return false, 0, nil
+	})
+}
+
+// newTestAppObjects returns the objects of an nginx app without volume with the given name. The
+// deployment only mounts a volume if the app has a PVC, which the fake cluster doesn't bind.
+func newTestAppObjects(name string) []interface{} {
+	app := NewNginxApp(testNamespace, "px", 1, &Options{Name: name})
+	app.Deployment.Spec.Template.Spec.Volumes = nil
+	app.Deployment.Spec.Template.Spec.Containers[0].VolumeMounts = nil
+	return []interface{}{app.Deployment, app.Service}
+}
@@ -0,0 +1,432 @@
+// Package specs builds the objects of common test workloads, with the app wired to a volume of a given
+// storage class
+package specs
+
+import (
+	"fmt"
+
+	"github.com/portworx/torpedo/pkg/k8sutils"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+	storage_v1beta1 "k8s.io/client-go/pkg/apis/storage/v1beta1"
+)
+
+const (
+	// storageClassAnnotation is the annotation of a PVC with the name of its storage class
+	storageClassAnnotation = "volume.beta.kubernetes.io/storage-class"
+	// appLabel is the label used to select the pods of an app
+	appLabel = "app"
+	// volumeName is the name of the app volume in the pod spec
+	volumeName = "data"
+
+	passwordLength = 16
+	secretUserKey  = "username"
+	secretPassKey  = "password"
+)
+
+// Options customize the objects built for an app
+type Options struct {
+	// Name of the app objects. Defaults to the name of the workload (e.g nginx).
+	Name string
+	// Replicas of the deployment. Defaults to 1.
+	Replicas int32
+	// Resources are the requests and limits of the app container
+	Resources v1.ResourceRequirements
+	// Labels are added to all the objects of the app and to its pods
+	Labels map[string]string
+}
+
+// App is the set of objects of a workload
+type App struct {
+	// Secret holds the credentials of the app. Nil if the app has none.
+	Secret *v1.Secret
+	// PVC is the volume of the app
+	PVC *v1.PersistentVolumeClaim
+	// Deployment runs the app with the PVC mounted
+	Deployment *v1beta1.Deployment
+	// Service exposes the app. Nil if the app has none.
+	Service *v1.Service
+}
+
+// workload describes the container of an app and how it uses its volume
+type workload struct {
+	key       string
+	container v1.Container
+	mountPath string
+	// port is exposed by a service if not zero
+	port int32
+	// credentials are stored in a secret if set
+	credentials bool
+}
+
+// NewNginxApp returns an nginx app serving the content of its volume, exposed on port 80
+func NewNginxApp(namespace, scName string, volSizeGi int, options *Options) *App {
+	return newApp(namespace, scName, volSizeGi, options, workload{
+		key: "nginx",
+		container: v1.Container{
+			Image: "nginx:1.13",
+		},
+		mountPath: "/usr/share/nginx/html",
+		port:      80,
+	})
+}
+
+// NewMySQLApp returns a mysql app storing its databases on its volume, exposed on port 3306. The root
+// password is in the secret of the app.
+func NewMySQLApp(namespace, scName string, volSizeGi int, options *Options) *App {
+	return newApp(namespace, scName, volSizeGi, options, workload{
+		key: "mysql",
+		container: v1.Container{
+			Image: "mysql:5.7",
+			Args:  []string{"--ignore-db-dir=lost+found"},
+			Env: []v1.EnvVar{
+				secretEnv("MYSQL_ROOT_PASSWORD", secretPassKey),
+			},
+		},
+		mountPath:   "/var/lib/mysql",
+		port:        3306,
+		credentials: true,
+	})
+}
+
+// NewPostgresApp returns a postgres app storing its databases on its volume, exposed on port 5432. The
+// user and password are in the secret of the app.
+func NewPostgresApp(namespace, scName string, volSizeGi int, options *Options) *App {
+	return newApp(namespace, scName, volSizeGi, options, workload{
+		key: "postgres",
+		container: v1.Container{
+			Image: "postgres:9.5",
+			Env: []v1.EnvVar{
+				secretEnv("POSTGRES_USER", secretUserKey),
+				secretEnv("POSTGRES_PASSWORD", secretPassKey),
+				{
+					Name:  "PGDATA",
+					Value: "/var/lib/postgresql/data/pgdata",
+				},
+			},
+		},
+		mountPath:   "/var/lib/postgresql/data",
+		port:        5432,
+		credentials: true,
+	})
+}
+
+// NewFioApp returns an app continuously running a random read write fio job on half of its volume
+func NewFioApp(namespace, scName string, volSizeGi int, options *Options) *App {
+	return newApp(namespace, scName, volSizeGi, options, workload{
+		key: "fio",
+		container: v1.Container{
+			Image: "xridge/fio",
+			Command: []string{
+				"fio",
+				"--name=torpedo",
+				"--directory=/data",
+				"--rw=randrw",
+				"--bs=4k",
+				fmt.Sprintf("--size=%dM", volSizeGi*512),
+				"--time_based",
+				"--runtime=86400",
+			},
+		},
+		mountPath: "/data",
+	})
+}
+
+// Objects returns the objects of the app in the order they are created
+func (a *App) Objects() []interface{} {
+	var objs []interface{}
+	if a.Secret != nil {
+		objs = append(objs, a.Secret)
+	}
+	objs = append(objs, a.PVC, a.Deployment)
+	if a.Service != nil {
+		objs = append(objs, a.Service)
+	}
+	return objs
+}
+
+// DeployAndValidate creates the given objects, storage classes, secrets, config maps and PVCs before the
+// deployments, statefulsets and services using them, then validates the PVCs, deployments and
+// statefulsets. It supports the kinds of objects DestroyAppObjects deletes.
+func DeployAndValidate(objs []interface{}) error {
+	_, err := deployAndValidate(objs)
+	return err
+}
+
+// deployAndValidate is DeployAndValidate returning the objects created before it returned, in the order
+// they were created
+func deployAndValidate(objs []interface{}) ([]interface{}, error) {
+	var (
+		scs          []*storage_v1beta1.StorageClass
+		secrets      []*v1.Secret
+		configMaps   []*v1.ConfigMap
+		pvcs         []*v1.PersistentVolumeClaim
+		deployments  []*v1beta1.Deployment
+		statefulSets []*v1beta1.StatefulSet
+		services     []*v1.Service
+	)
+
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *storage_v1beta1.StorageClass:
+			scs = append(scs, o)
+		case *v1.Secret:
+			secrets = append(secrets, o)
+		case *v1.ConfigMap:
+			configMaps = append(configMaps, o)
+		case *v1.PersistentVolumeClaim:
+			pvcs = append(pvcs, o)
+		case *v1beta1.Deployment:
+			deployments = append(deployments, o)
+		case *v1beta1.StatefulSet:
+			statefulSets = append(statefulSets, o)
+		case *v1.Service:
+			services = append(services, o)
+		default:
+			return nil, fmt.Errorf("unsupported object: %#v", obj)
+		}
+	}
+
+	var created []interface{}
+	for _, sc := range scs {
+		result, err := k8sutils.CreateStorageClass(sc)
+		if err != nil {
+			return created, fmt.Errorf("failed to create storage class: %v. Err: %v", sc.Name, err)
+		}
+		created = append(created, result)
+	}
+
+	for _, secret := range secrets {
+		result, err := k8sutils.EnsureSecret(secret)
+		if err != nil {
+			return created, fmt.Errorf("failed to create secret: %v. Err: %v", secret.Name, err)
+		}
+		created = append(created, result)
+	}
+
+	for _, configMap := range configMaps {
+		result, err := k8sutils.CreateConfigMap(configMap)
+		if err != nil {
+			return created, fmt.Errorf("failed to create config map: %v. Err: %v", configMap.Name, err)
+		}
+		created = append(created, result)
+	}
+
+	var createdPVCs []*v1.PersistentVolumeClaim
+	for _, pvc := range pvcs {
+		result, err := k8sutils.CreatePersistentVolumeClaim(pvc)
+		if err != nil {
+			return created, fmt.Errorf("failed to create PVC: %v. Err: %v", pvc.Name, err)
+		}
+		createdPVCs = append(createdPVCs, result)
+		created = append(created, result)
+	}
+
+	var createdDeployments []*v1beta1.Deployment
+	for _, dep := range deployments {
+		result, err := k8sutils.CreateDeployment(dep)
+		if err != nil {
+			return created, fmt.Errorf("failed to create deployment: %v. Err: %v", dep.Name, err)
+		}
+		createdDeployments = append(createdDeployments, result)
+		created = append(created, result)
+	}
+
+	var createdStatefulSets []*v1beta1.StatefulSet
+	for _, ss := range statefulSets {
+		result, err := k8sutils.CreateStatefulSet(ss)
+		if err != nil {
+			return created, fmt.Errorf("failed to create statefulset: %v. Err: %v", ss.Name, err)
+		}
+		createdStatefulSets = append(createdStatefulSets, result)
+		created = append(created, result)
+	}
+
+	for _, service := range services {
+		result, err := k8sutils.CreateService(service)
+		if err != nil {
+			return created, fmt.Errorf("failed to create service: %v. Err: %v", service.Name, err)
+		}
+		created = append(created, result)
+	}
+
+	for _, pvc := range createdPVCs {
+		if err := k8sutils.ValidatePersistentVolumeClaim(pvc); err != nil {
+			return created, err
+		}
+	}
+
+	for _, dep := range createdDeployments {
+		if err := k8sutils.ValidateDeployement(dep); err != nil {
+			return created, err
+		}
+	}
+
+	for _, ss := range createdStatefulSets {
+		if err := k8sutils.ValidateStatefulSet(ss); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
+
+func newApp(namespace, scName string, volSizeGi int, options *Options, w workload) *App {
+	if options == nil {
+		options = &Options{}
+	}
+
+	name := options.Name
+	if len(name) == 0 {
+		name = w.key
+	}
+
+	replicas := options.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+
+	app := &App{}
+	if w.credentials {
+		app.Secret = &v1.Secret{
+			ObjectMeta: objectMeta(namespace, name, options),
+			Type:       v1.SecretTypeOpaque,
+			StringData: map[string]string{
+				secretUserKey: "torpedo",
+				secretPassKey: rand.String(passwordLength),
+			},
+		}
+	}
+
+	pvcMeta := objectMeta(namespace, name, options)
+	pvcMeta.Annotations = map[string]string{
+		storageClassAnnotation: scName,
+	}
+	app.PVC = &v1.PersistentVolumeClaim{
+		ObjectMeta: pvcMeta,
+		Spec: v1.PersistentVolumeClaimSpec{
+			AccessModes: []v1.PersistentVolumeAccessMode{
+				v1.ReadWriteOnce,
+			},
+			Resources: v1.ResourceRequirements{
+				Requests: v1.ResourceList{
+					v1.ResourceStorage: resource.MustParse(fmt.Sprintf("%dGi", volSizeGi)),
+				},
+			},
+		},
+	}
+
+	container := w.container
+	container.Name = w.key
+	container.ImagePullPolicy = v1.PullIfNotPresent
+	container.Resources = options.Resources
+	container.VolumeMounts = []v1.VolumeMount{
+		{
+			Name:      volumeName,
+			MountPath: w.mountPath,
+		},
+	}
+	for i := range container.Env {
+		if ref := container.Env[i].ValueFrom; ref != nil && ref.SecretKeyRef != nil {
+			ref.SecretKeyRef.Name = name
+		}
+	}
+	if w.port != 0 {
+		container.Ports = []v1.ContainerPort{
+			{
+				Protocol:      v1.ProtocolTCP,
+				ContainerPort: w.port,
+			},
+		}
+	}
+
+	podLabels := podLabels(name, options)
+	app.Deployment = &v1beta1.Deployment{
+		ObjectMeta: objectMeta(namespace, name, options),
+		Spec: v1beta1.DeploymentSpec{
+			// The pods of the app share a ReadWriteOnce volume
+			Strategy: v1beta1.DeploymentStrategy{
+				Type: v1beta1.RecreateDeploymentStrategyType,
+			},
+			Replicas: &replicas,
+			Selector: &meta_v1.LabelSelector{
+				MatchLabels: map[string]string{
+					appLabel: name,
+				},
+			},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Labels: podLabels,
+				},
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{container},
+					Volumes: []v1.Volume{
+						{
+							Name: volumeName,
+							VolumeSource: v1.VolumeSource{
+								PersistentVolumeClaim: &v1.PersistentVolumeClaimVolumeSource{
+									ClaimName: name,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if w.port != 0 {
+		app.Service = &v1.Service{
+			ObjectMeta: objectMeta(namespace, name, options),
+			Spec: v1.ServiceSpec{
+				Selector: map[string]string{
+					appLabel: name,
+				},
+				Ports: []v1.ServicePort{
+					{
+						Protocol:   v1.ProtocolTCP,
+						Port:       w.port,
+						TargetPort: intstr.FromInt(int(w.port)),
+					},
+				},
+			},
+		}
+	}
+
+	return app
+}
+
+func objectMeta(namespace, name string, options *Options) meta_v1.ObjectMeta {
+	return meta_v1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels:    podLabels(name, options),
+	}
+}
+
+func podLabels(name string, options *Options) map[string]string {
+	labels := make(map[string]string)
+	for key, value := range options.Labels {
+		labels[key] = value
+	}
+	// The app label selects the pods of the deployment and service so it can't be overridden
+	labels[appLabel] = name
+	return labels
+}
+
+// secretEnv returns an environment variable set from the given key of the app secret. The secret name is
+// filled in when the app is built.
+func secretEnv(name, key string) v1.EnvVar {
+	return v1.EnvVar{
+		Name: name,
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				Key: key,
+			},
+		},
+	}
+}
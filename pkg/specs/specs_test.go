@@ -0,0 +1,150 @@
+package specs
+
+import (
+	"net/http"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/pkg/api/v1"
+	"k8s.io/client-go/pkg/apis/apps/v1beta1"
+)
+
+// factories are the workloads of the package
+var factories = map[string]func(namespace, scName string, volSizeGi int, options *Options) *App{
+	"nginx":    NewNginxApp,
+	"mysql":    NewMySQLApp,
+	"postgres": NewPostgresApp,
+	"fio":      NewFioApp,
+}
+
+// validateApp checks that the objects of the app are valid and refer to each other. The API servers
+// torpedo supports have no server-side dry-run, so this runs the api server validations that the app
+// objects could fail.
+func validateApp(t *testing.T, key string, app *App) {
+	for _, obj := range app.Objects() {
+		var name string
+		var objLabels map[string]string
+		switch o := obj.(type) {
+		case *v1.Secret:
+			name, objLabels = o.Name, o.Labels
+		case *v1.PersistentVolumeClaim:
+			name, objLabels = o.Name, o.Labels
+		case *v1beta1.Deployment:
+			name, objLabels = o.Name, o.Labels
+		case *v1.Service:
+			name, objLabels = o.Name, o.Labels
+		}
+		for _, msg := range validation.IsDNS1123Label(name) {
+			t.Errorf("%v: invalid name: %v: %v", key, name, msg)
+		}
+		for k, v := range objLabels {
+			for _, msg := range append(validation.IsQualifiedName(k), validation.IsValidLabelValue(v)...) {
+				t.Errorf("%v: invalid label: %v=%v: %v", key, k, v, msg)
+			}
+		}
+	}
+
+	if modes := app.PVC.Spec.AccessModes; len(modes) == 0 {
+		t.Errorf("%v: expected the PVC to have access modes", key)
+	}
+	if _, ok := app.PVC.Spec.Resources.Requests[v1.ResourceStorage]; !ok {
+		t.Errorf("%v: expected the PVC to request storage", key)
+	}
+
+	dep := app.Deployment
+	selector := labels.SelectorFromSet(dep.Spec.Selector.MatchLabels)
+	if !selector.Matches(labels.Set(dep.Spec.Template.Labels)) {
+		t.Errorf("%v: the selector %v doesn't match the pod labels %v", key, selector, dep.Spec.Template.Labels)
+	}
+
+	pod := dep.Spec.Template.Spec
+	volumes := make(map[string]bool)
+	for _, volume := range pod.Volumes {
+		if claim := volume.PersistentVolumeClaim; claim != nil && claim.ClaimName != app.PVC.Name {
+			t.Errorf("%v: volume %v refers to PVC %v instead of %v", key, volume.Name, claim.ClaimName, app.PVC.Name)
+		}
+		volumes[volume.Name] = true
+	}
+
+	container := pod.Containers[0]
+	for _, mount := range container.VolumeMounts {
+		if !volumes[mount.Name] {
+			t.Errorf("%v: the mount %v has no volume", key, mount.Name)
+		}
+	}
+	for _, env := range container.Env {
+		ref := env.ValueFrom
+		if ref == nil || ref.SecretKeyRef == nil {
+			continue
+		}
+		if app.Secret == nil || ref.SecretKeyRef.Name != app.Secret.Name {
+			t.Errorf("%v: %v refers to a missing secret: %v", key, env.Name, ref.SecretKeyRef.Name)
+		} else if _, ok := app.Secret.StringData[ref.SecretKeyRef.Key]; !ok {
+			t.Errorf("%v: %v refers to a missing secret key: %v", key, env.Name, ref.SecretKeyRef.Key)
+		}
+	}
+
+	if app.Service == nil {
+		return
+	}
+	if !labels.SelectorFromSet(app.Service.Spec.Selector).Matches(labels.Set(dep.Spec.Template.Labels)) {
+		t.Errorf("%v: the service doesn't select the pods of the app", key)
+	}
+	for _, port := range app.Service.Spec.Ports {
+		exposed := false
+		for _, containerPort := range container.Ports {
+			exposed = exposed || containerPort.ContainerPort == port.TargetPort.IntVal
+		}
+		if !exposed {
+			t.Errorf("%v: the service targets port %v that the container doesn't expose", key, port.TargetPort)
+		}
+	}
+}
+
+func TestAppFactories(t *testing.T) {
+	for key, factory := range factories {
+		validateApp(t, key, factory(testNamespace, "px", 2, nil))
+
+		app := factory(testNamespace, "px", 2, &Options{
+			Name:     key + "-custom",
+			Replicas: 3,
+			Labels:   map[string]string{"suite": "smoke", appLabel: "overridden"},
+		})
+		validateApp(t, key, app)
+		if *app.Deployment.Spec.Replicas != 3 || app.Deployment.Spec.Template.Labels["suite"] != "smoke" {
+			t.Errorf("%v: expected the replicas and labels of the options, got: %+v", key, app.Deployment)
+		}
+		if app.Deployment.Spec.Template.Labels[appLabel] != key+"-custom" {
+			t.Errorf("%v: expected the app label not to be overridden, got: %v", key, app.Deployment.Spec.Template.Labels)
+		}
+	}
+}
+
+func TestDeployAndValidate(t *testing.T) {
+	server, cleanup := newTestCluster(t)
+	defer cleanup()
+
+	bindTestPVCs(server)
+	app := NewMySQLApp(testNamespace, "px", 1, nil)
+	if err := DeployAndValidate(app.Objects()); err != nil {
+		t.Fatalf("failed to deploy the app: %v", err)
+	}
+
+	var created []string
+	for _, req := range server.Requests() {
+		if req.Method == http.MethodPost {
+			created = append(created, req.Resource)
+		}
+	}
+	expected := []string{"secrets", "persistentvolumeclaims", "deployments", "services"}
+	if len(created) != len(expected) {
+		t.Fatalf("expected the objects to be created in the order: %v, got: %v", expected, created)
+	}
+	for i := range expected {
+		if created[i] != expected[i] {
+			t.Errorf("expected the objects to be created in the order: %v, got: %v", expected, created)
+			break
+		}
+	}
+}
@@ -1,18 +1,38 @@
 package task
 
 import (
-	"time"
 	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
 )
 
-// ErrTimedOut is returned when an operation times out
+// retryJitterFraction is the maximum fraction of timeBeforeRetry randomly added to each retry interval
+// so that concurrent tasks don't all poll on the same boundary
+const retryJitterFraction = 0.2
+
+// ErrTimedOut is returned when an operation times out. Use IsTimedOut to check for it as the returned
+// error also wraps the last error of the task.
 var ErrTimedOut = errors.New("timed out performing task")
 
-// DoRetryWithTimeout performs given task with given timeout and timeBeforeRetry
+// RetryAfterError can be returned by a task to ask for a minimum wait before the next attempt
+type RetryAfterError interface {
+	error
+	// RetryAfter is the minimum time to wait before retrying the task
+	RetryAfter() time.Duration
+}
+
+// DoRetryWithTimeout performs given task with given timeout and timeBeforeRetry. A random jitter is
+// added to timeBeforeRetry and if the task returns a RetryAfterError, its delay is honored if longer.
+// On timeout, IsTimedOut is true for the returned error and LastError returns the last error of the task.
 func DoRetryWithTimeout(t func() error, timeout, timeBeforeRetry time.Duration) error {
 	done := make(chan bool, 1)
 	quit := make(chan bool, 1)
 
+	var lock sync.Mutex
+	var lastErr error
+
 	go func() {
 		for {
 			select {
@@ -28,7 +48,11 @@ func DoRetryWithTimeout(t func() error, timeout, timeBeforeRetry time.Duration)
 					return
 				}
 
-				time.Sleep(timeBeforeRetry)
+				lock.Lock()
+				lastErr = err
+				lock.Unlock()
+
+				time.Sleep(retryInterval(err, timeBeforeRetry))
 			}
 		}
 	}()
@@ -38,6 +62,63 @@ func DoRetryWithTimeout(t func() error, timeout, timeBeforeRetry time.Duration)
 		return nil
 	case <-time.After(timeout):
 		quit <- true
-		return ErrTimedOut
+
+		lock.Lock()
+		defer lock.Unlock()
+		if lastErr == nil {
+			return ErrTimedOut
+		}
+		return &timedOutError{lastErr: lastErr}
 	}
-}
\ No newline at end of file
+}
+
+// timedOutError is returned on timeout when the task failed at least once
+type timedOutError struct {
+	lastErr error
+}
+
+func (e *timedOutError) Error() string {
+	return fmt.Sprintf("%v. Last error: %v", ErrTimedOut, e.lastErr)
+}
+
+// Is makes errors.Is(err, ErrTimedOut) true
+func (e *timedOutError) Is(target error) bool {
+	return target == ErrTimedOut
+}
+
+// Unwrap returns the last error of the task
+func (e *timedOutError) Unwrap() error {
+	return e.lastErr
+}
+
+// IsTimedOut checks if the given error is the timeout of DoRetryWithTimeout
+func IsTimedOut(err error) bool {
+	if err == ErrTimedOut {
+		return true
+	}
+	_, ok := err.(*timedOutError)
+	return ok
+}
+
+// LastError returns the last error of the task if the given error is the timeout of DoRetryWithTimeout
+// and the given error otherwise
+func LastError(err error) error {
+	if timedOut, ok := err.(*timedOutError); ok {
+		return timedOut.lastErr
+	}
+	return err
+}
+
+// retryInterval returns how long to wait before retrying a task that failed with the given error
+func retryInterval(err error, timeBeforeRetry time.Duration) time.Duration {
+	interval := timeBeforeRetry
+	if maxJitter := int64(float64(timeBeforeRetry) * retryJitterFraction); maxJitter > 0 {
+		interval += time.Duration(rand.Int63n(maxJitter))
+	}
+
+	if retryAfterErr, ok := err.(RetryAfterError); ok && retryAfterErr.RetryAfter() > interval {
+		interval = retryAfterErr.RetryAfter()
+	}
+
+	return interval
+}
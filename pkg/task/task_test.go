@@ -0,0 +1,86 @@
+package task
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDoRetryWithTimeoutSucceeds(t *testing.T) {
+	attempts := 0
+	err := DoRetryWithTimeout(func() error {
+		attempts++
+		if attempts < 3 {
+			return fmt.Errorf("attempt %d failed", attempts)
+		}
+		return nil
+	}, time.Second, time.Millisecond)
+
+	if err != nil {
+		t.Fatalf("expected the task to succeed, got: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %v", attempts)
+	}
+}
+
+func TestDoRetryWithTimeoutReturnsLastError(t *testing.T) {
+	taskErr := fmt.Errorf("not ready")
+	err := DoRetryWithTimeout(func() error {
+		return taskErr
+	}, 50*time.Millisecond, 10*time.Millisecond)
+
+	if !IsTimedOut(err) {
+		t.Fatalf("expected a timeout, got: %v", err)
+	}
+	if last := LastError(err); last != taskErr {
+		t.Errorf("expected the last error of the task, got: %v", last)
+	}
+}
+
+func TestIsTimedOutAndLastError(t *testing.T) {
+	other := fmt.Errorf("other")
+	tests := []struct {
+		err      error
+		timedOut bool
+		last     error
+	}{
+		{err: nil, last: nil},
+		{err: other, last: other},
+		{err: ErrTimedOut, timedOut: true, last: ErrTimedOut},
+		{err: &timedOutError{lastErr: other}, timedOut: true, last: other},
+	}
+
+	for _, test := range tests {
+		if got := IsTimedOut(test.err); got != test.timedOut {
+			t.Errorf("%v: IsTimedOut: expected %v, got %v", test.err, test.timedOut, got)
+		}
+		if got := LastError(test.err); got != test.last {
+			t.Errorf("%v: LastError: expected %v, got %v", test.err, test.last, got)
+		}
+	}
+}
+
+func TestRetryIntervalHonorsRetryAfter(t *testing.T) {
+	err := &retryAfterErr{delay: time.Hour}
+	if got := retryInterval(err, time.Second); got != time.Hour {
+		t.Errorf("expected the retry after delay, got %v", got)
+	}
+
+	if got := retryInterval(fmt.Errorf("other"), time.Second); got < time.Second ||
+		got > time.Second+time.Duration(float64(time.Second)*retryJitterFraction) {
+		t.Errorf("expected the interval with at most %v jitter, got %v", retryJitterFraction, got)
+	}
+}
+
+type retryAfterErr struct {
+	delay time.Duration
+}
+
+func (e *retryAfterErr) Error() string {
+	return fmt.Sprintf("retry after %v", e.delay)
+}
+
+func (e *retryAfterErr) RetryAfter() time.Duration {
+	return e.delay
+}